@@ -1,3 +1,4 @@
 @echo off
 
 go build .\cmd\terong-server
+go build .\cmd\terongctl
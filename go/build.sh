@@ -1,3 +1,4 @@
 #!/bin/bash
 
 go build ./cmd/terong-client
+go build ./cmd/terongctl
@@ -4,11 +4,32 @@ package main
 
 import (
 	"context"
+	"flag"
+	"os"
 
+	"kafji.net/terong/crash"
 	"kafji.net/terong/terong/client"
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/shutdown"
 )
 
 func main() {
-	ctx := context.Background()
-	client.Start(ctx)
+	defer crash.Recover()
+
+	var opts client.Options
+	flag.BoolVar(&opts.TUI, "tui", false, "show a status dashboard instead of log lines")
+	profile := flag.String("profile", os.Getenv(config.ProfileEnv), "config profile to apply")
+	selfTest := flag.Bool("self-test", false, "check the config, certificates, port, and input device, then exit")
+	flag.Parse()
+	config.SelectProfile(*profile)
+
+	ctx, stop := shutdown.Context(context.Background())
+	if *selfTest {
+		err := client.SelfTest(ctx, os.Stdout)
+		stop()
+		os.Exit(shutdown.Code(err))
+	}
+	err := client.Start(ctx, opts)
+	stop()
+	os.Exit(shutdown.Code(err))
 }
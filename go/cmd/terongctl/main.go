@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"kafji.net/terong/terong/ctl"
+)
+
+func main() {
+	os.Exit(ctl.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
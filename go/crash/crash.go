@@ -0,0 +1,127 @@
+// Package crash writes a report when the process panics, so users can attach
+// it to bug reports.
+package crash
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"kafji.net/terong/logging"
+)
+
+var slog = logging.NewLogger("crash")
+
+// exitCode is the exit code of a process stopped by a panic, the same the Go
+// runtime uses.
+const exitCode = 2
+
+// Recover writes a crash report and stops the process when the calling
+// goroutine panics. A panic stops the whole process anyway, so every
+// goroutine should defer it first thing.
+func Recover() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	stack := debug.Stack()
+
+	fmt.Fprintf(os.Stderr, "panic: %v\n\n%s\n", v, stack)
+	now := time.Now()
+	path, err := writeFile(Dir(), now.Format("20060102-150405"), func(w io.Writer, program string) {
+		writeReport(w, now, program, v, stack)
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write crash report: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "crash report written to %s\n", path)
+	}
+	os.Exit(exitCode)
+}
+
+// Dir returns the directory crash reports are written to.
+func Dir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "terong", "crashes")
+}
+
+// DumpLogs writes the latest log records down to debug level, whatever the
+// log level, to help diagnose err after the fact. The dump replaces the
+// previous one.
+func DumpLogs(err error) {
+	now := time.Now()
+	path, werr := writeFile(Dir(), "last-error", func(w io.Writer, program string) {
+		fmt.Fprintf(w, "%s failed at %s\n\n", program, now.Format(time.RFC3339))
+		fmt.Fprintf(w, "error: %v\n\n", err)
+		writeBuild(w)
+		writeLogs(w)
+	})
+	if werr != nil {
+		slog.Warn("failed to dump recent logs", "error", werr)
+		return
+	}
+	slog.Info("recent logs dumped", "path", path)
+}
+
+// writeFile writes a file named after the program and suffix in dir.
+func writeFile(dir string, suffix string, write func(w io.Writer, program string)) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+	program := filepath.Base(os.Args[0])
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.txt", program, suffix))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	write(f, program)
+	return path, f.Close()
+}
+
+func writeReport(w io.Writer, now time.Time, program string, v any, stack []byte) {
+	fmt.Fprintf(w, "%s crashed at %s\n\n", program, now.Format(time.RFC3339))
+	fmt.Fprintf(w, "panic: %v\n\n", v)
+	writeBuild(w)
+	fmt.Fprintf(w, "stack:\n%s\n", stack)
+	writeLogs(w)
+}
+
+func writeBuild(w io.Writer) {
+	fmt.Fprintf(w, "version: %s\n", Version())
+	fmt.Fprintf(w, "go: %s %s/%s\n\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+func writeLogs(w io.Writer) {
+	fmt.Fprintf(w, "recent logs:\n")
+	for _, line := range logging.History() {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// Version describes the build from its module version and VCS revision.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v += " " + s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				v += " (modified)"
+			}
+		}
+	}
+	return v
+}
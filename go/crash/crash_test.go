@@ -0,0 +1,24 @@
+package crash
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/logging"
+)
+
+func TestWriteReport(t *testing.T) {
+	logging.NewLogger("crash_test").Debug("before the panic", "key", "value")
+
+	var b strings.Builder
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	writeReport(&b, now, "terong-server", "boom", []byte("goroutine 1 [running]:"))
+
+	report := b.String()
+	assert.Contains(t, report, "terong-server crashed at 2024-05-06T07:08:09Z")
+	assert.Contains(t, report, "panic: boom")
+	assert.Contains(t, report, "goroutine 1 [running]:")
+	assert.Contains(t, report, "DEBUG crash_test: before the panic key=value")
+}
@@ -0,0 +1,60 @@
+// Package errs is the taxonomy of errors shared by the transport, input
+// source, input sink, and config, so callers can act on the kind of an error
+// with [errors.Is] instead of matching its message.
+package errs
+
+import "errors"
+
+var (
+	// ErrConfigInvalid is unusable configurations. Retrying won't help until
+	// they're fixed.
+	ErrConfigInvalid = errors.New("invalid configurations")
+	// ErrAuthRejected is the peer refusing the certificate or join code of
+	// this end.
+	ErrAuthRejected = errors.New("authentication rejected")
+	// ErrDeviceUnavailable is an input device that can't be created or
+	// hooked, e.g. without permission to open /dev/uinput.
+	ErrDeviceUnavailable = errors.New("device unavailable")
+	// ErrPeerBusy is the server serving another client.
+	ErrPeerBusy = errors.New("peer busy")
+	// ErrIncompatible is peers sharing no protocol version.
+	ErrIncompatible = errors.New("incompatible peer")
+)
+
+// kinds are checked by Kind in this order.
+var kinds = []error{ErrConfigInvalid, ErrAuthRejected, ErrDeviceUnavailable, ErrPeerBusy, ErrIncompatible}
+
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Mark returns err with its message unchanged, matching kind with
+// [errors.Is]. It returns err itself if err or kind is nil.
+//
+// Errors are wrapped with %v across the tree, which drops the kinds of the
+// wrapped errors, so the error leaving a package is the one to mark.
+func Mark(err error, kind error) error {
+	if err == nil || kind == nil {
+		return err
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// Kind returns the kind of err, or nil if it has none.
+func Kind(err error) error {
+	for _, kind := range kinds {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
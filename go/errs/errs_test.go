@@ -0,0 +1,24 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMark(t *testing.T) {
+	cause := errors.New("permission denied")
+	err := Mark(fmt.Errorf("failed to open uinput: %v", cause), ErrDeviceUnavailable)
+
+	assert.Equal(t, "failed to open uinput: permission denied", err.Error())
+	assert.ErrorIs(t, err, ErrDeviceUnavailable)
+	assert.NotErrorIs(t, err, ErrConfigInvalid)
+	assert.Equal(t, ErrDeviceUnavailable, Kind(err))
+	assert.Equal(t, ErrDeviceUnavailable, Kind(fmt.Errorf("run: %w", err)))
+
+	assert.Nil(t, Mark(nil, ErrPeerBusy))
+	assert.Equal(t, cause, Mark(cause, nil))
+	assert.Nil(t, Kind(cause))
+}
@@ -0,0 +1,44 @@
+// Package foreground reports which window currently has the user's focus.
+package foreground
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type Window struct {
+	// ProcessName is the executable file name of the process owning the
+	// window, e.g. "KeePassXC.exe".
+	ProcessName string
+	Class       string
+}
+
+// Rule matches windows by process name or window class. Empty fields match
+// anything, comparisons are case-insensitive.
+type Rule struct {
+	ProcessName string
+	Class       string
+}
+
+func (r Rule) Match(w Window) bool {
+	if r.ProcessName == "" && r.Class == "" {
+		return false
+	}
+	if r.ProcessName != "" && !strings.EqualFold(r.ProcessName, filepath.Base(w.ProcessName)) {
+		return false
+	}
+	if r.Class != "" && !strings.EqualFold(r.Class, w.Class) {
+		return false
+	}
+	return true
+}
+
+// MatchAny returns the first rule matching w.
+func MatchAny(rules []Rule, w Window) (Rule, bool) {
+	for _, r := range rules {
+		if r.Match(w) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
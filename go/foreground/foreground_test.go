@@ -0,0 +1,29 @@
+package foreground
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleMatch(t *testing.T) {
+	w := Window{ProcessName: "KeePassXC.exe", Class: "Qt5QWindowIcon"}
+
+	assert.True(t, Rule{ProcessName: "keepassxc.exe"}.Match(w))
+	assert.True(t, Rule{Class: "qt5qwindowicon"}.Match(w))
+	assert.True(t, Rule{ProcessName: "KeePassXC.exe", Class: "Qt5QWindowIcon"}.Match(w))
+	assert.False(t, Rule{ProcessName: "KeePassXC.exe", Class: "Chrome_WidgetWin_1"}.Match(w))
+	assert.False(t, Rule{ProcessName: "1Password.exe"}.Match(w))
+	assert.False(t, Rule{}.Match(w))
+}
+
+func TestMatchAny(t *testing.T) {
+	rules := []Rule{{ProcessName: "1Password.exe"}, {Class: "Credential Dialog Xaml Host"}}
+
+	r, ok := MatchAny(rules, Window{ProcessName: "CredentialUIBroker.exe", Class: "Credential Dialog Xaml Host"})
+	assert.True(t, ok)
+	assert.Equal(t, rules[1], r)
+
+	_, ok = MatchAny(rules, Window{ProcessName: "notepad.exe", Class: "Notepad"})
+	assert.False(t, ok)
+}
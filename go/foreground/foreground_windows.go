@@ -0,0 +1,47 @@
+package foreground
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// Current returns the foreground window. It returns the zero Window when no
+// window has the focus, e.g. while the secure desktop is shown.
+func Current() (Window, error) {
+	hwnd := windows.GetForegroundWindow()
+	if hwnd == 0 {
+		return Window{}, nil
+	}
+
+	var w Window
+
+	class := make([]uint16, 256)
+	n, err := windows.GetClassName(hwnd, &class[0], int32(len(class)))
+	if err != nil {
+		return Window{}, fmt.Errorf("failed to get class name: %v", err)
+	}
+	w.Class = windows.UTF16ToString(class[:n])
+
+	var pid uint32
+	if _, err := windows.GetWindowThreadProcessId(hwnd, &pid); err != nil {
+		return Window{}, fmt.Errorf("failed to get process id: %v", err)
+	}
+
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, pid)
+	if err != nil {
+		// elevated processes can't be queried from a non-elevated server
+		return w, nil
+	}
+	defer windows.CloseHandle(process)
+
+	name := make([]uint16, windows.MAX_PATH)
+	size := uint32(len(name))
+	if err := windows.QueryFullProcessImageName(process, 0, &name[0], &size); err != nil {
+		return Window{}, fmt.Errorf("failed to get process image name: %v", err)
+	}
+	w.ProcessName = filepath.Base(windows.UTF16ToString(name[:size]))
+
+	return w, nil
+}
@@ -0,0 +1,40 @@
+// Package gctune sets the garbage collector up for relaying, so collections
+// don't show up as periodic cursor stutter.
+package gctune
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// DefaultMemoryLimit is the soft memory limit when none is configured. The
+// heap stays far below it, it only bounds a heap that grows unexpectedly.
+const DefaultMemoryLimit = 256 << 20
+
+type Config struct {
+	// Percent is as GOGC. Zero uses the default of 100, negative turns the
+	// collector off until the heap reaches MemoryLimit.
+	Percent int
+	// MemoryLimit is the soft memory limit in bytes, as GOMEMLIMIT. Zero
+	// uses DefaultMemoryLimit.
+	MemoryLimit int64
+}
+
+// Apply applies cfg. It can be called again to apply new configurations. The
+// GOGC and GOMEMLIMIT environment variables take precedence.
+func Apply(cfg Config) {
+	if os.Getenv("GOGC") == "" {
+		percent := cfg.Percent
+		if percent == 0 {
+			percent = 100
+		}
+		debug.SetGCPercent(percent)
+	}
+	if os.Getenv("GOMEMLIMIT") == "" {
+		limit := cfg.MemoryLimit
+		if limit == 0 {
+			limit = DefaultMemoryLimit
+		}
+		debug.SetMemoryLimit(limit)
+	}
+}
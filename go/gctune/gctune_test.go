@@ -0,0 +1,24 @@
+package gctune
+
+import (
+	"math"
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	t.Setenv("GOGC", "")
+	t.Setenv("GOMEMLIMIT", "")
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(math.MaxInt64))
+
+	Apply(Config{Percent: 200, MemoryLimit: 64 << 20})
+	assert.Equal(t, 200, debug.SetGCPercent(-1))
+	assert.Equal(t, int64(64<<20), debug.SetMemoryLimit(-1))
+
+	Apply(Config{})
+	assert.Equal(t, 100, debug.SetGCPercent(-1))
+	assert.Equal(t, int64(DefaultMemoryLimit), debug.SetMemoryLimit(-1))
+}
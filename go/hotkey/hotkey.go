@@ -0,0 +1,115 @@
+// Package hotkey detects key sequences, e.g. double tapping a key.
+package hotkey
+
+import (
+	"slices"
+	"time"
+
+	"kafji.net/terong/inputevent"
+)
+
+// DoubleTap is the sequence of pressing and releasing key twice.
+func DoubleTap(key inputevent.KeyCode) []inputevent.KeyPress {
+	return []inputevent.KeyPress{
+		{Key: key, Action: inputevent.KeyActionDown},
+		{Key: key, Action: inputevent.KeyActionUp},
+		{Key: key, Action: inputevent.KeyActionDown},
+		{Key: key, Action: inputevent.KeyActionUp},
+	}
+}
+
+type entry struct {
+	k inputevent.KeyPress
+	t time.Time
+}
+
+// Matcher matches a sequence of key presses happening within a window of
+// time. Repeats and presses of keys not in the sequence are ignored, so they
+// don't break a sequence in progress.
+type Matcher struct {
+	seq    []inputevent.KeyPress
+	window time.Duration
+	keys   map[inputevent.KeyCode]struct{}
+	// the latest relevant presses, at most len(seq)
+	buf []entry
+}
+
+func NewMatcher(seq []inputevent.KeyPress, window time.Duration) *Matcher {
+	keys := make(map[inputevent.KeyCode]struct{}, len(seq))
+	for _, k := range seq {
+		keys[k.Key] = struct{}{}
+	}
+	return &Matcher{
+		seq:    slices.Clone(seq),
+		window: window,
+		keys:   keys,
+		buf:    make([]entry, 0, len(seq)),
+	}
+}
+
+// Push records k pressed at t and reports whether it completes the sequence.
+// Presses that completed a sequence don't count toward the next one.
+func (m *Matcher) Push(k inputevent.KeyPress, t time.Time) bool {
+	if len(m.seq) == 0 || k.Action == inputevent.KeyActionRepeat {
+		return false
+	}
+	if _, ok := m.keys[k.Key]; !ok {
+		return false
+	}
+
+	// drop presses that are too old to be part of the sequence
+	i := 0
+	for i < len(m.buf) && t.Sub(m.buf[i].t) > m.window {
+		i++
+	}
+	m.buf = m.buf[:copy(m.buf, m.buf[i:])]
+
+	if len(m.buf) == len(m.seq) {
+		m.buf = m.buf[:copy(m.buf, m.buf[1:])]
+	}
+	m.buf = append(m.buf, entry{k: k, t: t})
+
+	if len(m.buf) < len(m.seq) {
+		return false
+	}
+	for i, e := range m.buf {
+		if e.k != m.seq[i] {
+			return false
+		}
+	}
+	m.buf = m.buf[:0]
+	return true
+}
+
+// Reset forgets every recorded press.
+func (m *Matcher) Reset() {
+	m.buf = m.buf[:0]
+}
+
+// Contains reports whether key is in the sequence.
+func (m *Matcher) Contains(key inputevent.KeyCode) bool {
+	_, ok := m.keys[key]
+	return ok
+}
+
+// Pending reports whether the latest recorded presses start the sequence, so
+// pushing the rest of it may complete it. It doesn't account for the window,
+// presses that became too old are only dropped by Push.
+func (m *Matcher) Pending() bool {
+	for i := range m.buf {
+		if len(m.buf)-i >= len(m.seq) {
+			continue
+		}
+		ok := true
+		for j, e := range m.buf[i:] {
+			if e.k != m.seq[j] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
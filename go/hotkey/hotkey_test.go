@@ -0,0 +1,204 @@
+package hotkey
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+const window = 300 * time.Millisecond
+
+func down(k inputevent.KeyCode) inputevent.KeyPress {
+	return inputevent.KeyPress{Key: k, Action: inputevent.KeyActionDown}
+}
+
+func up(k inputevent.KeyCode) inputevent.KeyPress {
+	return inputevent.KeyPress{Key: k, Action: inputevent.KeyActionUp}
+}
+
+func repeat(k inputevent.KeyCode) inputevent.KeyPress {
+	return inputevent.KeyPress{Key: k, Action: inputevent.KeyActionRepeat}
+}
+
+type press struct {
+	k  inputevent.KeyPress
+	at time.Duration
+}
+
+// pushAll pushes the presses and returns the indexes of the presses that
+// completed the sequence.
+func pushAll(m *Matcher, presses []press) []int {
+	t0 := time.Unix(0, 0)
+	matches := []int{}
+	for i, p := range presses {
+		if m.Push(p.k, t0.Add(p.at)) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func TestDoubleTap(t *testing.T) {
+	ctrl := inputevent.RightCtrl
+	tests := []struct {
+		name    string
+		presses []press
+		matches []int
+	}{
+		{
+			name:    "double tap",
+			presses: []press{{down(ctrl), 0}, {up(ctrl), 50}, {down(ctrl), 100}, {up(ctrl), 150}},
+			matches: []int{3},
+		},
+		{
+			name:    "too slow",
+			presses: []press{{down(ctrl), 0}, {up(ctrl), 100}, {down(ctrl), 200}, {up(ctrl), 301}},
+			matches: []int{},
+		},
+		{
+			name:    "single tap",
+			presses: []press{{down(ctrl), 0}, {up(ctrl), 50}},
+			matches: []int{},
+		},
+		{
+			name: "other keys and repeats are ignored",
+			presses: []press{
+				{down(ctrl), 0}, {repeat(ctrl), 10}, {down(inputevent.A), 20}, {up(ctrl), 30},
+				{up(inputevent.A), 40}, {down(ctrl), 50}, {up(ctrl), 60},
+			},
+			matches: []int{6},
+		},
+		{
+			name: "presses are not reused",
+			presses: []press{
+				{down(ctrl), 0}, {up(ctrl), 10}, {down(ctrl), 20}, {up(ctrl), 30},
+				{down(ctrl), 40}, {up(ctrl), 50},
+			},
+			matches: []int{3},
+		},
+		{
+			name: "triple tap matches the last two taps",
+			presses: []press{
+				{down(ctrl), 0}, {up(ctrl), 200}, {down(ctrl), 400}, {up(ctrl), 450},
+				{down(ctrl), 500}, {up(ctrl), 550},
+			},
+			matches: []int{5},
+		},
+		{
+			name: "two double taps",
+			presses: []press{
+				{down(ctrl), 0}, {up(ctrl), 10}, {down(ctrl), 20}, {up(ctrl), 30},
+				{down(ctrl), 1000}, {up(ctrl), 1010}, {down(ctrl), 1020}, {up(ctrl), 1030},
+			},
+			matches: []int{3, 7},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.presses {
+				tt.presses[i].at *= time.Millisecond
+			}
+			m := NewMatcher(DoubleTap(ctrl), window)
+			assert.Equal(t, tt.matches, pushAll(m, tt.presses))
+		})
+	}
+}
+
+func TestChord(t *testing.T) {
+	seq := []inputevent.KeyPress{down(inputevent.LeftCtrl), down(inputevent.LeftAlt), down(inputevent.Right)}
+	m := NewMatcher(seq, window)
+	presses := []press{
+		{down(inputevent.LeftCtrl), 0},
+		{down(inputevent.LeftAlt), 10 * time.Millisecond},
+		{repeat(inputevent.LeftAlt), 20 * time.Millisecond},
+		{down(inputevent.Right), 30 * time.Millisecond},
+	}
+	assert.Equal(t, []int{3}, pushAll(m, presses))
+}
+
+func TestReset(t *testing.T) {
+	ctrl := inputevent.RightCtrl
+	m := NewMatcher(DoubleTap(ctrl), window)
+	t0 := time.Unix(0, 0)
+	m.Push(down(ctrl), t0)
+	m.Push(up(ctrl), t0)
+	m.Reset()
+	m.Push(down(ctrl), t0)
+	assert.False(t, m.Push(up(ctrl), t0))
+}
+
+func TestPending(t *testing.T) {
+	ctrl := inputevent.RightCtrl
+	m := NewMatcher(DoubleTap(ctrl), window)
+	t0 := time.Unix(0, 0)
+	assert.True(t, m.Contains(ctrl))
+	assert.False(t, m.Contains(inputevent.A))
+	assert.False(t, m.Pending())
+
+	m.Push(up(ctrl), t0)
+	assert.False(t, m.Pending())
+	m.Push(down(ctrl), t0)
+	assert.True(t, m.Pending())
+	m.Push(up(ctrl), t0)
+	m.Push(down(ctrl), t0)
+	assert.True(t, m.Pending())
+	m.Push(up(ctrl), t0)
+	assert.False(t, m.Pending(), "completed")
+
+	// a broken sequence is pending again when a new one starts
+	m.Push(down(ctrl), t0)
+	m.Push(down(ctrl), t0)
+	assert.True(t, m.Pending())
+}
+
+func TestEmptySequenceNeverMatches(t *testing.T) {
+	m := NewMatcher(nil, window)
+	assert.False(t, m.Push(down(inputevent.A), time.Now()))
+}
+
+func FuzzMatcher(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 1}, []byte{0, 10, 10, 10})
+	f.Add([]byte{0, 2, 1, 0, 3, 1}, []byte{0, 200, 200, 0, 0, 0})
+	keys := []inputevent.KeyCode{inputevent.RightCtrl, inputevent.A}
+	actions := []inputevent.KeyAction{inputevent.KeyActionDown, inputevent.KeyActionUp, inputevent.KeyActionRepeat}
+	f.Fuzz(func(t *testing.T, codes []byte, gaps []byte) {
+		seq := DoubleTap(inputevent.RightCtrl)
+		m := NewMatcher(seq, window)
+		var relevant []press
+		at := time.Duration(0)
+		for i, c := range codes {
+			k := inputevent.KeyPress{Key: keys[int(c>>2)%len(keys)], Action: actions[int(c&3)%len(actions)]}
+			if i < len(gaps) {
+				at += time.Duration(gaps[i]) * 5 * time.Millisecond
+			}
+			matched := m.Push(k, time.Unix(0, 0).Add(at))
+
+			if len(m.buf) > len(seq) {
+				t.Fatalf("buffer grew to %d", len(m.buf))
+			}
+			if k.Key == inputevent.RightCtrl && k.Action != inputevent.KeyActionRepeat {
+				relevant = append(relevant, press{k, at})
+			}
+			if !matched {
+				continue
+			}
+			// a match means the latest relevant presses are the sequence
+			// and happened within the window
+			if len(relevant) < len(seq) {
+				t.Fatalf("matched after %d relevant presses", len(relevant))
+			}
+			tail := relevant[len(relevant)-len(seq):]
+			for j, p := range tail {
+				if p.k != seq[j] {
+					t.Fatalf("matched %v", tail)
+				}
+			}
+			if tail[len(tail)-1].at-tail[0].at > window {
+				t.Fatalf("matched presses spanning %v", tail[len(tail)-1].at-tail[0].at)
+			}
+			relevant = nil
+		}
+	})
+}
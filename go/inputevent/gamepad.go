@@ -0,0 +1,110 @@
+package inputevent
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// gamepad
+
+// GamepadButtonPress is a button of the gamepad going down or up. Buttons are
+// named by their position, as on an Xbox controller.
+type GamepadButtonPress struct {
+	Button GamepadButton       `json:"button"`
+	Action GamepadButtonAction `json:"action"`
+}
+
+// GamepadAxisMove is the new position of an axis of the gamepad. Sticks range
+// from -32768 to 32767, up and right positive. Triggers range from 0 to 255.
+type GamepadAxisMove struct {
+	Axis  GamepadAxis `json:"axis"`
+	Value int16       `json:"value"`
+}
+
+func (e GamepadButtonPress) String() string {
+	return fmt.Sprintf("GamepadButtonPress{Button: %v, Action: %v}", e.Button, e.Action)
+}
+
+func (e GamepadButtonPress) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "GamepadButtonPress"),
+		slog.Any("button", e.Button),
+		slog.Any("action", e.Action),
+	)
+}
+
+func (e GamepadAxisMove) String() string {
+	return fmt.Sprintf("GamepadAxisMove{Axis: %v, Value: %d}", e.Axis, e.Value)
+}
+
+func (e GamepadAxisMove) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "GamepadAxisMove"),
+		slog.Any("axis", e.Axis),
+		slog.Int("value", int(e.Value)),
+	)
+}
+
+type GamepadButton uint8
+
+const (
+	gamepadButtonMinorant GamepadButton = iota
+	GamepadButtonSouth
+	GamepadButtonEast
+	GamepadButtonWest
+	GamepadButtonNorth
+	GamepadButtonLeftShoulder
+	GamepadButtonRightShoulder
+	GamepadButtonBack
+	GamepadButtonStart
+	GamepadButtonGuide
+	GamepadButtonLeftThumb
+	GamepadButtonRightThumb
+	GamepadButtonDPadUp
+	GamepadButtonDPadDown
+	GamepadButtonDPadLeft
+	GamepadButtonDPadRight
+	gamepadButtonMajorant
+)
+
+var GamepadButtons = sync.OnceValue(func() []GamepadButton {
+	xs := make([]GamepadButton, 0)
+	for i := gamepadButtonMinorant + 1; i < gamepadButtonMajorant; i++ {
+		xs = append(xs, i)
+	}
+	return xs
+})
+
+type GamepadButtonAction uint8
+
+const (
+	GamepadButtonActionDown GamepadButtonAction = iota + 1
+	GamepadButtonActionUp
+)
+
+type GamepadAxis uint8
+
+const (
+	gamepadAxisMinorant GamepadAxis = iota
+	GamepadAxisLeftX
+	GamepadAxisLeftY
+	GamepadAxisRightX
+	GamepadAxisRightY
+	GamepadAxisLeftTrigger
+	GamepadAxisRightTrigger
+	gamepadAxisMajorant
+)
+
+var GamepadAxes = sync.OnceValue(func() []GamepadAxis {
+	xs := make([]GamepadAxis, 0)
+	for i := gamepadAxisMinorant + 1; i < gamepadAxisMajorant; i++ {
+		xs = append(xs, i)
+	}
+	return xs
+})
+
+// Trigger reports whether the axis is a trigger rather than a stick axis.
+func (v GamepadAxis) Trigger() bool {
+	return v == GamepadAxisLeftTrigger || v == GamepadAxisRightTrigger
+}
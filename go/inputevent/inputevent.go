@@ -1,6 +1,11 @@
 package inputevent
 
-import "sync"
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+)
 
 type InputEvent interface {
 	inputEvent()
@@ -11,10 +16,51 @@ func (MouseClick) inputEvent()  {}
 func (MouseScroll) inputEvent() {}
 func (KeyPress) inputEvent()    {}
 
+func (GamepadButtonPress) inputEvent() {}
+func (GamepadAxisMove) inputEvent()    {}
+func (TouchFrame) inputEvent()         {}
+func (PenState) inputEvent()           {}
+
 var _ InputEvent = MouseMove{}
 var _ InputEvent = MouseClick{}
 var _ InputEvent = MouseScroll{}
 var _ InputEvent = KeyPress{}
+var _ InputEvent = GamepadButtonPress{}
+var _ InputEvent = GamepadAxisMove{}
+var _ InputEvent = TouchFrame{}
+var _ InputEvent = PenState{}
+
+var _ slog.LogValuer = MouseMove{}
+var _ slog.LogValuer = MouseClick{}
+var _ slog.LogValuer = MouseScroll{}
+var _ slog.LogValuer = KeyPress{}
+var _ slog.LogValuer = GamepadButtonPress{}
+var _ slog.LogValuer = GamepadAxisMove{}
+var _ slog.LogValuer = TouchFrame{}
+var _ slog.LogValuer = PenState{}
+
+// TypeName returns the name of the event type, e.g. "MouseMove".
+func TypeName(event InputEvent) string {
+	switch event.(type) {
+	case MouseMove:
+		return "MouseMove"
+	case MouseClick:
+		return "MouseClick"
+	case MouseScroll:
+		return "MouseScroll"
+	case KeyPress:
+		return "KeyPress"
+	case GamepadButtonPress:
+		return "GamepadButtonPress"
+	case GamepadAxisMove:
+		return "GamepadAxisMove"
+	case TouchFrame:
+		return "TouchFrame"
+	case PenState:
+		return "PenState"
+	}
+	return "Unknown"
+}
 
 // mouse
 
@@ -33,6 +79,42 @@ type MouseScroll struct {
 	Count     uint8                `json:"count"`
 }
 
+func (e MouseMove) String() string {
+	return fmt.Sprintf("MouseMove{DX: %d, DY: %d}", e.DX, e.DY)
+}
+
+func (e MouseMove) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "MouseMove"),
+		slog.Int("dx", int(e.DX)),
+		slog.Int("dy", int(e.DY)),
+	)
+}
+
+func (e MouseClick) String() string {
+	return fmt.Sprintf("MouseClick{Button: %v, Action: %v}", e.Button, e.Action)
+}
+
+func (e MouseClick) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "MouseClick"),
+		slog.Any("button", e.Button),
+		slog.Any("action", e.Action),
+	)
+}
+
+func (e MouseScroll) String() string {
+	return fmt.Sprintf("MouseScroll{Direction: %v, Count: %d}", e.Direction, e.Count)
+}
+
+func (e MouseScroll) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "MouseScroll"),
+		slog.Any("direction", e.Direction),
+		slog.Int("count", int(e.Count)),
+	)
+}
+
 type MouseButton uint8
 
 const (
@@ -74,6 +156,18 @@ type KeyPress struct {
 	Action KeyAction `json:"action"`
 }
 
+func (e KeyPress) String() string {
+	return fmt.Sprintf("KeyPress{Key: %v, Action: %v}", e.Key, e.Action)
+}
+
+func (e KeyPress) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "KeyPress"),
+		slog.Any("key", e.Key),
+		slog.Any("action", e.Action),
+	)
+}
+
 type KeyAction uint8
 
 const (
@@ -196,37 +290,93 @@ const (
 	Left
 	Down
 	Right
+
+	// New codes are appended here to keep the values of the existing ones on
+	// the wire.
+
+	NumLock
+	// The context menu key.
+	Menu
+	Sleep
+
+	keyCodeMajorant
 )
 
 var KeyCodes = sync.OnceValue(func() []KeyCode {
 	xs := make([]KeyCode, 0)
-	for i := Escape; i <= Right; i++ {
+	for i := Escape; i < keyCodeMajorant; i++ {
 		xs = append(xs, i)
 	}
 	return xs
 })
 
+// Normalizer marks key downs of keys that are already down as repeats.
+// Platforms report auto-repeat as repeated key downs.
 type Normalizer struct {
-	prev InputEvent
+	// bitset of keys that are down
+	down [(keyCodeMajorant + 63) / 64]uint64
 }
 
 func (n *Normalizer) Normalize(event InputEvent) InputEvent {
-	prev, ok := n.prev.(KeyPress)
-	if !ok || prev.Action != KeyActionDown {
-		n.prev = event
+	e, ok := event.(KeyPress)
+	if !ok || e.Key >= keyCodeMajorant {
 		return event
 	}
 
-	this, ok := event.(KeyPress)
-	if !ok || this.Action != KeyActionDown {
-		n.prev = event
-		return event
+	i, bit := e.Key/64, uint64(1)<<(e.Key%64)
+	switch e.Action {
+	case KeyActionDown:
+		if n.down[i]&bit != 0 {
+			return KeyPress{Key: e.Key, Action: KeyActionRepeat}
+		}
+		n.down[i] |= bit
+	case KeyActionUp:
+		n.down[i] &^= bit
 	}
+	return event
+}
 
-	if this.Key != prev.Key {
-		n.prev = event
-		return event
+// Adjuster scales mouse moves and inverts scrolls.
+type Adjuster struct {
+	// PointerScale multiplies mouse moves. Zero leaves them as they are.
+	PointerScale float64
+	// InvertScroll swaps scroll up and down.
+	InvertScroll bool
+
+	// fractions of a pixel carried over to the next move, so slow moves
+	// aren't lost when scaling down
+	restX, restY float64
+}
+
+func (a *Adjuster) Adjust(event InputEvent) InputEvent {
+	switch e := event.(type) {
+	case MouseMove:
+		if a.PointerScale == 0 || a.PointerScale == 1 {
+			return event
+		}
+		var dx, dy int16
+		dx, a.restX = scale(e.DX, a.PointerScale, a.restX)
+		dy, a.restY = scale(e.DY, a.PointerScale, a.restY)
+		return MouseMove{DX: dx, DY: dy}
+	case MouseScroll:
+		if !a.InvertScroll {
+			return event
+		}
+		switch e.Direction {
+		case MouseScrollUp:
+			e.Direction = MouseScrollDown
+		case MouseScrollDown:
+			e.Direction = MouseScrollUp
+		}
+		return e
 	}
+	return event
+}
 
-	return KeyPress{Key: this.Key, Action: KeyActionRepeat}
+// scale returns d times factor plus rest, truncated, and the truncated
+// fraction.
+func scale(d int16, factor float64, rest float64) (int16, float64) {
+	v := float64(d)*factor + rest
+	i := math.Trunc(v)
+	return int16(max(min(i, math.MaxInt16), math.MinInt16)), v - i
 }
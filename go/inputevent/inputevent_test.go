@@ -0,0 +1,78 @@
+package inputevent
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func normalizeAll(events ...InputEvent) []InputEvent {
+	var n Normalizer
+	got := make([]InputEvent, 0, len(events))
+	for _, e := range events {
+		got = append(got, n.Normalize(e))
+	}
+	return got
+}
+
+func down(k KeyCode) KeyPress   { return KeyPress{Key: k, Action: KeyActionDown} }
+func repeat(k KeyCode) KeyPress { return KeyPress{Key: k, Action: KeyActionRepeat} }
+func up(k KeyCode) KeyPress     { return KeyPress{Key: k, Action: KeyActionUp} }
+
+func TestNormalizeRepeat(t *testing.T) {
+	got := normalizeAll(down(A), down(A), down(A), up(A), down(A))
+	assert.Equal(t, []InputEvent{down(A), repeat(A), repeat(A), up(A), down(A)}, got)
+}
+
+func TestNormalizeRollover(t *testing.T) {
+	// holding A while tapping B
+	got := normalizeAll(down(A), down(A), down(B), up(B), down(A), down(B), up(B), up(A))
+	assert.Equal(t, []InputEvent{down(A), repeat(A), down(B), up(B), repeat(A), down(B), up(B), up(A)}, got)
+}
+
+func TestNormalizeInterleavedMouseEvents(t *testing.T) {
+	move := MouseMove{DX: 1, DY: 1}
+	click := MouseClick{Button: MouseButtonLeft, Action: MouseButtonActionDown}
+	got := normalizeAll(down(LeftCtrl), move, click, down(LeftCtrl), up(LeftCtrl))
+	assert.Equal(t, []InputEvent{down(LeftCtrl), move, click, repeat(LeftCtrl), up(LeftCtrl)}, got)
+}
+
+func TestNormalizeKeepsRepeatAndStrayUp(t *testing.T) {
+	got := normalizeAll(up(A), repeat(B), down(B))
+	assert.Equal(t, []InputEvent{up(A), repeat(B), down(B)}, got)
+}
+
+func TestNormalizeUnknownKey(t *testing.T) {
+	k := keyCodeMajorant + 1
+	got := normalizeAll(down(k), down(k))
+	assert.Equal(t, []InputEvent{down(k), down(k)}, got)
+}
+
+func TestAdjustPointerScale(t *testing.T) {
+	a := Adjuster{PointerScale: 1.5}
+	assert.Equal(t, MouseMove{DX: 15, DY: -3}, a.Adjust(MouseMove{DX: 10, DY: -2}))
+	assert.Equal(t, MouseMove{DX: math.MaxInt16, DY: 0}, a.Adjust(MouseMove{DX: math.MaxInt16}))
+}
+
+func TestAdjustPointerScaleCarriesFractions(t *testing.T) {
+	a := Adjuster{PointerScale: 0.5}
+	var sum int
+	for range 5 {
+		sum += int(a.Adjust(MouseMove{DX: 1}).(MouseMove).DX)
+	}
+	assert.Equal(t, 2, sum)
+}
+
+func TestAdjustInvertScroll(t *testing.T) {
+	a := Adjuster{InvertScroll: true}
+	assert.Equal(t, MouseScroll{Direction: MouseScrollDown, Count: 2}, a.Adjust(MouseScroll{Direction: MouseScrollUp, Count: 2}))
+	assert.Equal(t, MouseScroll{Direction: MouseScrollUp, Count: 1}, a.Adjust(MouseScroll{Direction: MouseScrollDown, Count: 1}))
+}
+
+func TestAdjustUnchanged(t *testing.T) {
+	var a Adjuster
+	for _, e := range []InputEvent{MouseMove{DX: 3, DY: 4}, MouseScroll{Direction: MouseScrollUp, Count: 1}, down(A)} {
+		assert.Equal(t, e, a.Adjust(e))
+	}
+}
@@ -0,0 +1,54 @@
+package inputevent
+
+import "time"
+
+// JitterFilter drops mouse moves that step back and forth by a few pixels,
+// the jitter of a worn sensor. A small move is dropped if it reverses the
+// previous small move, and that move was within Window.
+type JitterFilter struct {
+	// Threshold is the largest delta on either axis considered jitter. Zero
+	// disables the filter.
+	Threshold int16
+	Window    time.Duration
+
+	last   MouseMove
+	lastAt time.Time
+}
+
+// Allow reports whether event should be relayed.
+func (f *JitterFilter) Allow(event InputEvent, now time.Time) bool {
+	move, ok := event.(MouseMove)
+	if !ok || f.Threshold == 0 {
+		return true
+	}
+
+	if absInt16(move.DX) > f.Threshold || absInt16(move.DY) > f.Threshold {
+		f.last = MouseMove{}
+		return true
+	}
+
+	reversed := f.last != (MouseMove{}) &&
+		sign(move.DX) == -sign(f.last.DX) &&
+		sign(move.DY) == -sign(f.last.DY) &&
+		now.Sub(f.lastAt) <= f.Window
+	f.last = move
+	f.lastAt = now
+	return !reversed
+}
+
+func absInt16(v int16) int16 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int16) int16 {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,43 @@
+package inputevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitterFilter(t *testing.T) {
+	f := JitterFilter{Threshold: 1, Window: 50 * time.Millisecond}
+	now := time.Unix(0, 0)
+	step := func(d time.Duration) time.Time {
+		now = now.Add(d)
+		return now
+	}
+
+	// oscillation settles after the first step
+	assert.True(t, f.Allow(MouseMove{DX: 1}, step(0)))
+	assert.False(t, f.Allow(MouseMove{DX: -1}, step(5*time.Millisecond)))
+	assert.False(t, f.Allow(MouseMove{DX: 1}, step(5*time.Millisecond)))
+	assert.False(t, f.Allow(MouseMove{DX: -1, DY: 0}, step(5*time.Millisecond)))
+
+	// slow moves in one direction pass
+	assert.True(t, f.Allow(MouseMove{DX: -1}, step(5*time.Millisecond)))
+	assert.True(t, f.Allow(MouseMove{DX: -1}, step(5*time.Millisecond)))
+
+	// a reversal outside the window is a real move
+	assert.True(t, f.Allow(MouseMove{DX: 1}, step(time.Second)))
+
+	// large moves always pass and reset the filter
+	assert.True(t, f.Allow(MouseMove{DX: -10, DY: 3}, step(time.Millisecond)))
+	assert.True(t, f.Allow(MouseMove{DX: -1}, step(time.Millisecond)))
+
+	assert.True(t, f.Allow(MouseClick{Button: MouseButtonLeft}, step(time.Millisecond)))
+}
+
+func TestJitterFilterDisabled(t *testing.T) {
+	var f JitterFilter
+	now := time.Unix(0, 0)
+	assert.True(t, f.Allow(MouseMove{DX: 1}, now))
+	assert.True(t, f.Allow(MouseMove{DX: -1}, now))
+}
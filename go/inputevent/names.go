@@ -0,0 +1,290 @@
+package inputevent
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Enums print as, and are parsed from, their Go names, e.g. "RightCtrl". Mouse
+// buttons, actions, and directions drop their type prefix, e.g. "Left".
+
+var mouseButtonNames = [...]string{
+	MouseButtonLeft:   "Left",
+	MouseButtonRight:  "Right",
+	MouseButtonMiddle: "Middle",
+	MouseButtonMouse4: "Mouse4",
+	MouseButtonMouse5: "Mouse5",
+}
+
+var mouseButtonActionNames = [...]string{
+	MouseButtonActionDown: "Down",
+	MouseButtonActionUp:   "Up",
+}
+
+var mouseScrollDirectionNames = [...]string{
+	MouseScrollUp:   "Up",
+	MouseScrollDown: "Down",
+}
+
+var gamepadButtonNames = [...]string{
+	GamepadButtonSouth:         "South",
+	GamepadButtonEast:          "East",
+	GamepadButtonWest:          "West",
+	GamepadButtonNorth:         "North",
+	GamepadButtonLeftShoulder:  "LeftShoulder",
+	GamepadButtonRightShoulder: "RightShoulder",
+	GamepadButtonBack:          "Back",
+	GamepadButtonStart:         "Start",
+	GamepadButtonGuide:         "Guide",
+	GamepadButtonLeftThumb:     "LeftThumb",
+	GamepadButtonRightThumb:    "RightThumb",
+	GamepadButtonDPadUp:        "DPadUp",
+	GamepadButtonDPadDown:      "DPadDown",
+	GamepadButtonDPadLeft:      "DPadLeft",
+	GamepadButtonDPadRight:     "DPadRight",
+}
+
+var gamepadButtonActionNames = [...]string{
+	GamepadButtonActionDown: "Down",
+	GamepadButtonActionUp:   "Up",
+}
+
+var gamepadAxisNames = [...]string{
+	GamepadAxisLeftX:        "LeftX",
+	GamepadAxisLeftY:        "LeftY",
+	GamepadAxisRightX:       "RightX",
+	GamepadAxisRightY:       "RightY",
+	GamepadAxisLeftTrigger:  "LeftTrigger",
+	GamepadAxisRightTrigger: "RightTrigger",
+}
+
+var keyActionNames = [...]string{
+	KeyActionDown:   "Down",
+	KeyActionRepeat: "Repeat",
+	KeyActionUp:     "Up",
+}
+
+var keyCodeNames = [...]string{
+	Escape:      "Escape",
+	F1:          "F1",
+	F2:          "F2",
+	F3:          "F3",
+	F4:          "F4",
+	F5:          "F5",
+	F6:          "F6",
+	F7:          "F7",
+	F8:          "F8",
+	F9:          "F9",
+	F10:         "F10",
+	F11:         "F11",
+	F12:         "F12",
+	PrintScreen: "PrintScreen",
+	ScrollLock:  "ScrollLock",
+	PauseBreak:  "PauseBreak",
+	Grave:       "Grave",
+	D1:          "D1",
+	D2:          "D2",
+	D3:          "D3",
+	D4:          "D4",
+	D5:          "D5",
+	D6:          "D6",
+	D7:          "D7",
+	D8:          "D8",
+	D9:          "D9",
+	D0:          "D0",
+	Minus:       "Minus",
+	Equal:       "Equal",
+	A:           "A",
+	B:           "B",
+	C:           "C",
+	D:           "D",
+	E:           "E",
+	F:           "F",
+	G:           "G",
+	H:           "H",
+	I:           "I",
+	J:           "J",
+	K:           "K",
+	L:           "L",
+	M:           "M",
+	N:           "N",
+	O:           "O",
+	P:           "P",
+	Q:           "Q",
+	R:           "R",
+	S:           "S",
+	T:           "T",
+	U:           "U",
+	V:           "V",
+	W:           "W",
+	X:           "X",
+	Y:           "Y",
+	Z:           "Z",
+	LeftBrace:   "LeftBrace",
+	RightBrace:  "RightBrace",
+	SemiColon:   "SemiColon",
+	Apostrophe:  "Apostrophe",
+	Comma:       "Comma",
+	Dot:         "Dot",
+	Slash:       "Slash",
+	Backspace:   "Backspace",
+	BackSlash:   "BackSlash",
+	Enter:       "Enter",
+	Space:       "Space",
+	Tab:         "Tab",
+	CapsLock:    "CapsLock",
+	LeftShift:   "LeftShift",
+	RightShift:  "RightShift",
+	LeftCtrl:    "LeftCtrl",
+	RightCtrl:   "RightCtrl",
+	LeftAlt:     "LeftAlt",
+	RightAlt:    "RightAlt",
+	LeftMeta:    "LeftMeta",
+	RightMeta:   "RightMeta",
+	Insert:      "Insert",
+	Delete:      "Delete",
+	Home:        "Home",
+	End:         "End",
+	PageUp:      "PageUp",
+	PageDown:    "PageDown",
+	Up:          "Up",
+	Left:        "Left",
+	Down:        "Down",
+	Right:       "Right",
+	NumLock:     "NumLock",
+	Menu:        "Menu",
+	Sleep:       "Sleep",
+}
+
+func (v MouseButton) String() string {
+	return enumString(mouseButtonNames[:], v, "MouseButton")
+}
+
+func (v MouseButton) MarshalText() ([]byte, error) {
+	return enumMarshalText(mouseButtonNames[:], v, "MouseButton")
+}
+
+func (v *MouseButton) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(mouseButtonNames[:], text, v, "MouseButton")
+}
+
+func (v MouseButtonAction) String() string {
+	return enumString(mouseButtonActionNames[:], v, "MouseButtonAction")
+}
+
+func (v MouseButtonAction) MarshalText() ([]byte, error) {
+	return enumMarshalText(mouseButtonActionNames[:], v, "MouseButtonAction")
+}
+
+func (v *MouseButtonAction) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(mouseButtonActionNames[:], text, v, "MouseButtonAction")
+}
+
+func (v MouseScrollDirection) String() string {
+	return enumString(mouseScrollDirectionNames[:], v, "MouseScrollDirection")
+}
+
+func (v MouseScrollDirection) MarshalText() ([]byte, error) {
+	return enumMarshalText(mouseScrollDirectionNames[:], v, "MouseScrollDirection")
+}
+
+func (v *MouseScrollDirection) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(mouseScrollDirectionNames[:], text, v, "MouseScrollDirection")
+}
+
+func (v KeyAction) String() string {
+	return enumString(keyActionNames[:], v, "KeyAction")
+}
+
+func (v KeyAction) MarshalText() ([]byte, error) {
+	return enumMarshalText(keyActionNames[:], v, "KeyAction")
+}
+
+func (v *KeyAction) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(keyActionNames[:], text, v, "KeyAction")
+}
+
+func (v KeyCode) String() string {
+	return enumString(keyCodeNames[:], v, "KeyCode")
+}
+
+func (v KeyCode) MarshalText() ([]byte, error) {
+	return enumMarshalText(keyCodeNames[:], v, "KeyCode")
+}
+
+func (v *KeyCode) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(keyCodeNames[:], text, v, "KeyCode")
+}
+
+func (v GamepadButton) String() string {
+	return enumString(gamepadButtonNames[:], v, "GamepadButton")
+}
+
+func (v GamepadButton) MarshalText() ([]byte, error) {
+	return enumMarshalText(gamepadButtonNames[:], v, "GamepadButton")
+}
+
+func (v *GamepadButton) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(gamepadButtonNames[:], text, v, "GamepadButton")
+}
+
+func (v GamepadButtonAction) String() string {
+	return enumString(gamepadButtonActionNames[:], v, "GamepadButtonAction")
+}
+
+func (v GamepadButtonAction) MarshalText() ([]byte, error) {
+	return enumMarshalText(gamepadButtonActionNames[:], v, "GamepadButtonAction")
+}
+
+func (v *GamepadButtonAction) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(gamepadButtonActionNames[:], text, v, "GamepadButtonAction")
+}
+
+func (v GamepadAxis) String() string {
+	return enumString(gamepadAxisNames[:], v, "GamepadAxis")
+}
+
+func (v GamepadAxis) MarshalText() ([]byte, error) {
+	return enumMarshalText(gamepadAxisNames[:], v, "GamepadAxis")
+}
+
+func (v *GamepadAxis) UnmarshalText(text []byte) error {
+	return enumUnmarshalText(gamepadAxisNames[:], text, v, "GamepadAxis")
+}
+
+type enum interface {
+	~uint8 | ~uint16
+}
+
+func enumName[T enum](names []string, v T) (string, bool) {
+	if int(v) >= len(names) || names[v] == "" {
+		return "", false
+	}
+	return names[v], true
+}
+
+func enumString[T enum](names []string, v T, typeName string) string {
+	if name, ok := enumName(names, v); ok {
+		return name
+	}
+	return fmt.Sprintf("%s(%d)", typeName, v)
+}
+
+func enumMarshalText[T enum](names []string, v T, typeName string) ([]byte, error) {
+	if name, ok := enumName(names, v); ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("invalid %s %d", typeName, v)
+}
+
+// enumUnmarshalText parses names case-insensitively.
+func enumUnmarshalText[T enum](names []string, text []byte, v *T, typeName string) error {
+	s := string(text)
+	for i, name := range names {
+		if name != "" && strings.EqualFold(name, s) {
+			*v = T(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s %q", typeName, s)
+}
@@ -0,0 +1,69 @@
+package inputevent
+
+import (
+	"encoding"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type textEnum interface {
+	fmt.Stringer
+	encoding.TextMarshaler
+}
+
+func testRoundTrip[T comparable, P interface {
+	*T
+	encoding.TextUnmarshaler
+}](t *testing.T, values []T) {
+	for _, v := range values {
+		text, err := any(v).(textEnum).MarshalText()
+		require.NoError(t, err, "%v", v)
+		assert.Equal(t, any(v).(textEnum).String(), string(text))
+
+		var got T
+		require.NoError(t, P(&got).UnmarshalText(text))
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestKeyCodeRoundTrip(t *testing.T) {
+	testRoundTrip(t, KeyCodes())
+}
+
+func TestMouseButtonRoundTrip(t *testing.T) {
+	testRoundTrip(t, MouseButtons())
+}
+
+func TestGamepadRoundTrip(t *testing.T) {
+	testRoundTrip(t, GamepadButtons())
+	testRoundTrip(t, GamepadAxes())
+	testRoundTrip(t, []GamepadButtonAction{GamepadButtonActionDown, GamepadButtonActionUp})
+}
+
+func TestActionsAndDirectionsRoundTrip(t *testing.T) {
+	testRoundTrip(t, []MouseButtonAction{MouseButtonActionDown, MouseButtonActionUp})
+	testRoundTrip(t, []MouseScrollDirection{MouseScrollUp, MouseScrollDown})
+	testRoundTrip(t, []KeyAction{KeyActionDown, KeyActionRepeat, KeyActionUp})
+}
+
+func TestUnmarshalTextIgnoresCase(t *testing.T) {
+	var k KeyCode
+	require.NoError(t, k.UnmarshalText([]byte("rightctrl")))
+	assert.Equal(t, RightCtrl, k)
+}
+
+func TestInvalidEnums(t *testing.T) {
+	var k KeyCode
+	assert.Error(t, k.UnmarshalText([]byte("Hyper")))
+
+	assert.Equal(t, "KeyCode(0)", KeyCode(0).String())
+	_, err := KeyCode(0).MarshalText()
+	assert.Error(t, err)
+}
+
+func TestKeyPressString(t *testing.T) {
+	assert.Equal(t, "KeyPress{Key: RightCtrl, Action: Down}", KeyPress{Key: RightCtrl, Action: KeyActionDown}.String())
+}
@@ -0,0 +1,47 @@
+package inputevent
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// pen
+
+// MaxPenPressure is the pressure of a pen pressed all the way down.
+const MaxPenPressure = 1024
+
+// PenState is a pen over the tablet at one moment. The position is scaled to
+// the tablet surface, 0 to 65535 from the top left corner. Tilt is in
+// degrees, -90 to 90, positive to the right and toward the user. Once the pen
+// leaves the tablet InRange is false and the other fields are zero.
+type PenState struct {
+	X        uint16 `json:"x"`
+	Y        uint16 `json:"y"`
+	Pressure uint16 `json:"pressure"`
+	TiltX    int8   `json:"tilt_x"`
+	TiltY    int8   `json:"tilt_y"`
+	InRange  bool   `json:"in_range"`
+	// Tip is set while the pen touches the tablet.
+	Tip    bool `json:"tip"`
+	Barrel bool `json:"barrel"`
+	// Eraser is set while the pen is flipped to its eraser end.
+	Eraser bool `json:"eraser"`
+}
+
+func (e PenState) String() string {
+	return fmt.Sprintf(
+		"PenState{X: %d, Y: %d, Pressure: %d, TiltX: %d, TiltY: %d, InRange: %t, Tip: %t, Barrel: %t, Eraser: %t}",
+		e.X, e.Y, e.Pressure, e.TiltX, e.TiltY, e.InRange, e.Tip, e.Barrel, e.Eraser,
+	)
+}
+
+func (e PenState) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "PenState"),
+		slog.Int("x", int(e.X)),
+		slog.Int("y", int(e.Y)),
+		slog.Int("pressure", int(e.Pressure)),
+		slog.Bool("in_range", e.InRange),
+		slog.Bool("tip", e.Tip),
+	)
+}
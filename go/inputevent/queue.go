@@ -0,0 +1,61 @@
+package inputevent
+
+// Queue is a queue of inputs, or of what carries them, whose array is
+// reused, so pushing and popping don't allocate once it has room for the
+// most items queued at once.
+type Queue[T any] struct {
+	items []T
+	// buf is the array items is in, from its start, which items is moved
+	// back to
+	buf []T
+}
+
+// NewQueue returns a queue with room for capacity items before it grows.
+func NewQueue[T any](capacity int) *Queue[T] {
+	buf := make([]T, 0, capacity)
+	return &Queue[T]{items: buf, buf: buf}
+}
+
+// Items returns the queued items, oldest first. They're valid until the
+// next push or pop.
+func (q *Queue[T]) Items() []T {
+	return q.items
+}
+
+func (q *Queue[T]) Len() int {
+	return len(q.items)
+}
+
+// Head returns the oldest item. The queue must not be empty.
+func (q *Queue[T]) Head() T {
+	return q.items[0]
+}
+
+// Last returns the newest item, to be updated in place. The queue must not
+// be empty.
+func (q *Queue[T]) Last() *T {
+	return &q.items[len(q.items)-1]
+}
+
+func (q *Queue[T]) Push(v T) {
+	grow := len(q.items) == cap(q.items)
+	q.items = append(q.items, v)
+	if grow {
+		q.buf = q.items[:0]
+	}
+}
+
+// Pop removes the oldest item. The items left are moved back to the start
+// of buf once fewer than those popped before them, which copies each item
+// once at most, so a queue that never empties doesn't creep to the end of
+// buf and grow.
+func (q *Queue[T]) Pop() {
+	var zero T
+	q.items[0] = zero
+	q.items = q.items[1:]
+	if len(q.items) < cap(q.buf)-cap(q.items) {
+		n := copy(q.buf[:len(q.items)], q.items)
+		clear(q.items)
+		q.items = q.buf[:n]
+	}
+}
@@ -0,0 +1,42 @@
+package inputevent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueue(t *testing.T) {
+	q := NewQueue[int](2)
+	for i := range 3 {
+		q.Push(i)
+	}
+	assert.Equal(t, []int{0, 1, 2}, q.Items())
+	*q.Last() = 5
+	assert.Equal(t, 0, q.Head())
+	q.Pop()
+	q.Pop()
+	assert.Equal(t, []int{5}, q.Items())
+	q.Push(6)
+	assert.Equal(t, []int{5, 6}, q.Items())
+	q.Pop()
+	q.Pop()
+	assert.Zero(t, q.Len())
+}
+
+func TestQueueAllocs(t *testing.T) {
+	q := NewQueue[InputEvent](64)
+	var key InputEvent = KeyPress{Key: A, Action: KeyActionDown}
+	// never emptied, as while the consumer keeps up just barely
+	for range 10 {
+		q.Push(key)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for range 1000 {
+			q.Push(key)
+			q.Pop()
+		}
+	})
+	assert.Zero(t, allocs)
+	assert.Equal(t, 10, q.Len())
+}
@@ -0,0 +1,43 @@
+package inputevent
+
+import "time"
+
+// RampIn scales mouse moves up from zero to full speed over Duration after
+// Start, so the first moves after relay starts don't jump the cursor.
+type RampIn struct {
+	// Duration of the ramp. Zero disables it.
+	Duration time.Duration
+
+	started time.Time
+	// fractions of a pixel carried over to the next move, see [Adjuster]
+	restX, restY float64
+}
+
+// Start begins the ramp at now.
+func (r *RampIn) Start(now time.Time) {
+	r.started = now
+	r.restX, r.restY = 0, 0
+}
+
+// Apply returns event with mouse moves scaled down during the ramp. It
+// reports false for a move scaled down to nothing.
+func (r *RampIn) Apply(event InputEvent, now time.Time) (InputEvent, bool) {
+	move, ok := event.(MouseMove)
+	if !ok || r.Duration <= 0 || r.started.IsZero() {
+		return event, true
+	}
+
+	elapsed := now.Sub(r.started)
+	if elapsed >= r.Duration {
+		return event, true
+	}
+	factor := max(float64(elapsed)/float64(r.Duration), 0)
+
+	var dx, dy int16
+	dx, r.restX = scale(move.DX, factor, r.restX)
+	dy, r.restY = scale(move.DY, factor, r.restY)
+	if dx == 0 && dy == 0 {
+		return nil, false
+	}
+	return MouseMove{DX: dx, DY: dy}, true
+}
@@ -0,0 +1,48 @@
+package inputevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRampIn(t *testing.T) {
+	r := RampIn{Duration: 100 * time.Millisecond}
+	start := time.Unix(0, 0)
+	r.Start(start)
+
+	// nothing moves at the start of the ramp
+	_, ok := r.Apply(MouseMove{DX: 50, DY: -50}, start)
+	assert.False(t, ok)
+
+	// half way through, moves are halved
+	event, ok := r.Apply(MouseMove{DX: 10, DY: -10}, start.Add(50*time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 5, DY: -5}, event)
+
+	// slow moves add up instead of being lost
+	_, ok = r.Apply(MouseMove{DX: 1}, start.Add(50*time.Millisecond))
+	assert.False(t, ok)
+	event, ok = r.Apply(MouseMove{DX: 1}, start.Add(50*time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 1}, event)
+
+	// full speed after the ramp
+	event, ok = r.Apply(MouseMove{DX: 10}, start.Add(100*time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 10}, event)
+
+	// other inputs pass
+	event, ok = r.Apply(KeyPress{Key: A, Action: KeyActionDown}, start)
+	assert.True(t, ok)
+	assert.Equal(t, KeyPress{Key: A, Action: KeyActionDown}, event)
+}
+
+func TestRampInDisabled(t *testing.T) {
+	var r RampIn
+	r.Start(time.Unix(0, 0))
+	event, ok := r.Apply(MouseMove{DX: 10}, time.Unix(0, 0))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 10}, event)
+}
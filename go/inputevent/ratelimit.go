@@ -0,0 +1,86 @@
+package inputevent
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimiter limits inputs to Rate per second with bursts of up to Burst,
+// like a token bucket. Only mouse moves are held back over the limit, and
+// their motion is added to the next move let through, or sent on its own by
+// [RateLimiter.Flush]. Other inputs always pass, as a lost key or click would
+// be stuck down or missed.
+type RateLimiter struct {
+	// Rate is the number of inputs per second. Zero disables the limiter.
+	Rate float64
+	// Burst is how many inputs may pass at once after a pause. Zero allows
+	// one.
+	Burst int
+
+	tokens float64
+	last   time.Time
+	// motion of the held back moves
+	restX, restY int32
+}
+
+// Apply returns the input to inject, or false for a mouse move held back over
+// the limit.
+func (l *RateLimiter) Apply(event InputEvent, now time.Time) (InputEvent, bool) {
+	if l.Rate <= 0 {
+		return event, true
+	}
+	l.refill(now)
+
+	move, ok := event.(MouseMove)
+	if !ok {
+		l.tokens = max(l.tokens-1, 0)
+		return event, true
+	}
+
+	dx, dy := l.restX+int32(move.DX), l.restY+int32(move.DY)
+	if l.tokens < 1 {
+		l.restX, l.restY = clampInt16(dx), clampInt16(dy)
+		return nil, false
+	}
+	l.tokens--
+	l.restX, l.restY = 0, 0
+	return MouseMove{DX: int16(clampInt16(dx)), DY: int16(clampInt16(dy))}, true
+}
+
+// Flush returns the motion of the moves held back since the last move let
+// through as a move of its own, or false if there's none. It's injected ahead
+// of a key or click, which would otherwise land where the pointer was before
+// that motion, and once [RateLimiter.Wait] passed, so the motion isn't left
+// for a next move that may come much later.
+func (l *RateLimiter) Flush(now time.Time) (MouseMove, bool) {
+	if l.restX == 0 && l.restY == 0 {
+		return MouseMove{}, false
+	}
+	l.refill(now)
+	l.tokens = max(l.tokens-1, 0)
+	move := MouseMove{DX: int16(l.restX), DY: int16(l.restY)}
+	l.restX, l.restY = 0, 0
+	return move, true
+}
+
+// Wait returns how long until another move may pass.
+func (l *RateLimiter) Wait() time.Duration {
+	if l.Rate <= 0 || l.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - l.tokens) / l.Rate * float64(time.Second))
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	burst := float64(max(l.Burst, 1))
+	if l.last.IsZero() {
+		l.tokens = burst
+	} else {
+		l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.Rate, burst)
+	}
+	l.last = now
+}
+
+func clampInt16(v int32) int32 {
+	return max(min(v, math.MaxInt16), math.MinInt16)
+}
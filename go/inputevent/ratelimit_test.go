@@ -0,0 +1,76 @@
+package inputevent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := RateLimiter{Rate: 100, Burst: 2}
+	now := time.Unix(0, 0)
+	step := func(d time.Duration) time.Time {
+		now = now.Add(d)
+		return now
+	}
+
+	// a burst passes
+	_, ok := l.Apply(MouseMove{DX: 1}, step(0))
+	assert.True(t, ok)
+	_, ok = l.Apply(MouseMove{DX: 1}, step(0))
+	assert.True(t, ok)
+
+	// then moves are held back, and their motion carried over
+	_, ok = l.Apply(MouseMove{DX: 2, DY: -1}, step(time.Millisecond))
+	assert.False(t, ok)
+	_, ok = l.Apply(MouseMove{DX: 3}, step(time.Millisecond))
+	assert.False(t, ok)
+
+	// keys and clicks always pass
+	_, ok = l.Apply(KeyPress{Key: A, Action: KeyActionDown}, step(time.Millisecond))
+	assert.True(t, ok)
+	_, ok = l.Apply(MouseClick{Button: MouseButtonLeft, Action: MouseButtonActionDown}, step(time.Millisecond))
+	assert.True(t, ok)
+
+	// once refilled the carried motion is sent
+	event, ok := l.Apply(MouseMove{DX: 1}, step(10*time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 6, DY: -1}, event)
+
+	// nothing is left to flush
+	_, ok = l.Flush(step(0))
+	assert.False(t, ok)
+
+	// disabled
+	l = RateLimiter{}
+	for range 10 {
+		_, ok = l.Apply(MouseMove{DX: 1}, step(0))
+		assert.True(t, ok)
+	}
+}
+
+func TestRateLimiterFlush(t *testing.T) {
+	l := RateLimiter{Rate: 100}
+	now := time.Unix(0, 0)
+
+	_, ok := l.Apply(MouseMove{DX: 1}, now)
+	assert.True(t, ok)
+	_, ok = l.Apply(MouseMove{DX: 5, DY: -1}, now.Add(time.Millisecond))
+	assert.False(t, ok)
+	assert.Equal(t, 9*time.Millisecond, l.Wait())
+
+	// a click after held back moves has their motion sent ahead of it
+	move, ok := l.Flush(now.Add(2 * time.Millisecond))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 5, DY: -1}, move)
+	_, ok = l.Apply(MouseClick{Button: MouseButtonLeft, Action: MouseButtonActionDown}, now.Add(2*time.Millisecond))
+	assert.True(t, ok)
+
+	// and isn't sent again with the next move
+	event, ok := l.Apply(MouseMove{DX: 1}, now.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, MouseMove{DX: 1}, event)
+	_, ok = l.Flush(now.Add(time.Second))
+	assert.False(t, ok)
+}
@@ -0,0 +1,38 @@
+package inputevent
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// touch
+
+// MaxTouchContacts is the number of fingers a TouchFrame carries at most.
+const MaxTouchContacts = 5
+
+// TouchFrame is the fingers on the touchpad at one moment, raw so the
+// receiving desktop recognizes gestures as it does for its own touchpad. A
+// frame without contacts means every finger was lifted.
+type TouchFrame struct {
+	Contacts []TouchContact `json:"contacts"`
+}
+
+// TouchContact is a finger on the touchpad. The position is scaled to the
+// touchpad surface, 0 to 65535 from the top left corner. ID stays the same
+// while the finger is down.
+type TouchContact struct {
+	ID uint8  `json:"id"`
+	X  uint16 `json:"x"`
+	Y  uint16 `json:"y"`
+}
+
+func (e TouchFrame) String() string {
+	return fmt.Sprintf("TouchFrame{Contacts: %v}", e.Contacts)
+}
+
+func (e TouchFrame) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("type", "TouchFrame"),
+		slog.Int("contacts", len(e.Contacts)),
+	)
+}
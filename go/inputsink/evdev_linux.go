@@ -0,0 +1,133 @@
+//go:build linux && cgo && !uinput
+
+package inputsink
+
+/*
+#cgo pkg-config: libevdev
+#cgo CFLAGS: -Wall -g -O2
+#include <stdlib.h>
+#include <string.h>
+#include <libevdev/libevdev.h>
+#include <libevdev/libevdev-uinput.h>
+#include <linux/input.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+// https://www.freedesktop.org/software/libevdev/doc/latest/libevdev_8h.html
+// https://www.freedesktop.org/software/libevdev/doc/latest/libevdev-uinput_8h.html
+
+type evdevDevice struct {
+	dev    *C.struct_libevdev
+	uinput *C.struct_libevdev_uinput
+}
+
+func createUinputDevice(spec deviceSpec) (device, error) {
+	dev, err := createEvdevDevice(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create evdev device: %v", err)
+	}
+
+	var uinput *C.struct_libevdev_uinput
+	ret := C.libevdev_uinput_create_from_device(dev, C.LIBEVDEV_UINPUT_OPEN_MANAGED, &uinput)
+	if err := evdevError(ret); err != nil {
+		C.libevdev_free(dev)
+		return nil, fmt.Errorf("failed to create uinput device: %v", err)
+	}
+
+	return &evdevDevice{dev: dev, uinput: uinput}, nil
+}
+
+func createEvdevDevice(spec deviceSpec) (*C.struct_libevdev, error) {
+	dev := C.libevdev_new()
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		C.libevdev_free(dev)
+	}()
+
+	// libevdev_set_name copies the string argument using strdup
+	name := C.CString(spec.name)
+	C.libevdev_set_name(dev, name)
+	// the string is safe to free here
+	C.free(unsafe.Pointer(name))
+
+	C.libevdev_set_id_bustype(dev, C.int(spec.bustype))
+	C.libevdev_set_id_vendor(dev, C.int(spec.vendor))
+	C.libevdev_set_id_product(dev, C.int(spec.product))
+
+	for _, prop := range spec.props {
+		if err := evdevError(C.libevdev_enable_property(dev, C.uint(prop))); err != nil {
+			return nil, fmt.Errorf("failed to enable property: %v", err)
+		}
+	}
+
+	for type_, codes := range spec.codes {
+		for _, code := range codes {
+			var data unsafe.Pointer
+			switch type_ {
+			case evcode.EV_REP:
+				// EV_REP codes must be enabled with their value
+				value := C.int(spec.repeatValue(code))
+				data = unsafe.Pointer(&value)
+			case evcode.EV_ABS:
+				// EV_ABS codes must be enabled with their range
+				info := spec.abs[code]
+				absinfo := C.struct_input_absinfo{
+					minimum:    C.int(info.min),
+					maximum:    C.int(info.max),
+					fuzz:       C.int(info.fuzz),
+					flat:       C.int(info.flat),
+					resolution: C.int(info.resolution),
+				}
+				data = unsafe.Pointer(&absinfo)
+			}
+			ret := C.libevdev_enable_event_code(dev, C.uint(type_), C.uint(code), data)
+			err := evdevError(ret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to enable event code: %v", err)
+			}
+		}
+	}
+
+	ok = true
+	return dev, nil
+}
+
+func (d *evdevDevice) write(events []event) error {
+	for _, event := range events {
+		ret := C.libevdev_uinput_write_event(d.uinput, C.uint(event.type_), C.uint(event.code), C.int(event.value))
+		if syscall.Errno(-ret) == syscall.EAGAIN {
+			return &droppedError{err: syscall.EAGAIN}
+		}
+		if err := evdevError(ret); err != nil {
+			return fmt.Errorf("failed to write event: %v", err)
+		}
+	}
+	return nil
+}
+
+func (d *evdevDevice) close() {
+	C.libevdev_uinput_destroy(d.uinput)
+	C.libevdev_free(d.dev)
+}
+
+func evdevError(returnValue C.int) error {
+	if returnValue > -1 {
+		return nil
+	}
+	errno := -returnValue
+	name := unix.ErrnoName(syscall.Errno(errno))
+	desc := C.GoString(C.strerror(errno))
+	return fmt.Errorf("%s %d %s", name, errno, desc)
+}
@@ -0,0 +1,139 @@
+package inputsink
+
+import (
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+const gamepadDeviceName = "Terong Virtual Gamepad"
+
+// The virtual gamepad claims to be an Xbox 360 controller, which games and
+// SDL map without configuration.
+const (
+	gamepadVendor  = 0x045e
+	gamepadProduct = 0x028e
+)
+
+// gamepadSpec describes the virtual gamepad. It is a separate device so
+// desktops don't take the keyboard and mouse for a joystick.
+func gamepadSpec() deviceSpec {
+	codes := make(map[uint16][]uint16)
+	abs := make(map[uint16]absInfo)
+
+	codes[evcode.EV_SYN] = append(codes[evcode.EV_SYN], evcode.SYN_REPORT)
+
+	for _, b := range inputevent.GamepadButtons() {
+		codes[evcode.EV_KEY] = append(codes[evcode.EV_KEY], gamepadButtonToEvKey(b))
+	}
+
+	for _, a := range inputevent.GamepadAxes() {
+		code := gamepadAxisToEvAbs(a)
+		codes[evcode.EV_ABS] = append(codes[evcode.EV_ABS], code)
+		if a.Trigger() {
+			abs[code] = absInfo{max: 255}
+		} else {
+			abs[code] = absInfo{min: -32768, max: 32767, fuzz: 16, flat: 128}
+		}
+	}
+
+	return deviceSpec{
+		name:    gamepadDeviceName,
+		bustype: evcode.BUS_USB,
+		vendor:  gamepadVendor,
+		product: gamepadProduct,
+		codes:   codes,
+		abs:     abs,
+	}
+}
+
+// gamepadEvents translates a gamepad input into events of the virtual
+// gamepad. It returns nil for other inputs.
+func gamepadEvents(input inputevent.InputEvent) []event {
+	var ev event
+	switch v := input.(type) {
+	case inputevent.GamepadButtonPress:
+		ev = event{type_: evcode.EV_KEY, code: gamepadButtonToEvKey(v.Button)}
+		if v.Action == inputevent.GamepadButtonActionDown {
+			ev.value = 1
+		}
+
+	case inputevent.GamepadAxisMove:
+		ev = event{type_: evcode.EV_ABS, code: gamepadAxisToEvAbs(v.Axis), value: int32(v.Value)}
+		if v.Axis == inputevent.GamepadAxisLeftY || v.Axis == inputevent.GamepadAxisRightY {
+			// evdev sticks are positive down
+			ev.value = min(-ev.value, 32767)
+		}
+
+	default:
+		return nil
+	}
+	return []event{ev, {type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0}}
+}
+
+// gamepadNeutralEvents lifts every button and centers every axis.
+func gamepadNeutralEvents() []event {
+	events := make([]event, 0, len(inputevent.GamepadButtons())+len(inputevent.GamepadAxes())+1)
+	for _, b := range inputevent.GamepadButtons() {
+		events = append(events, event{type_: evcode.EV_KEY, code: gamepadButtonToEvKey(b), value: 0})
+	}
+	for _, a := range inputevent.GamepadAxes() {
+		events = append(events, event{type_: evcode.EV_ABS, code: gamepadAxisToEvAbs(a), value: 0})
+	}
+	return append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+}
+
+func gamepadButtonToEvKey(button inputevent.GamepadButton) uint16 {
+	var evKey uint16
+	switch button {
+	case inputevent.GamepadButtonSouth:
+		evKey = evcode.BTN_SOUTH
+	case inputevent.GamepadButtonEast:
+		evKey = evcode.BTN_EAST
+	case inputevent.GamepadButtonWest:
+		evKey = evcode.BTN_WEST
+	case inputevent.GamepadButtonNorth:
+		evKey = evcode.BTN_NORTH
+	case inputevent.GamepadButtonLeftShoulder:
+		evKey = evcode.BTN_TL
+	case inputevent.GamepadButtonRightShoulder:
+		evKey = evcode.BTN_TR
+	case inputevent.GamepadButtonBack:
+		evKey = evcode.BTN_SELECT
+	case inputevent.GamepadButtonStart:
+		evKey = evcode.BTN_START
+	case inputevent.GamepadButtonGuide:
+		evKey = evcode.BTN_MODE
+	case inputevent.GamepadButtonLeftThumb:
+		evKey = evcode.BTN_THUMBL
+	case inputevent.GamepadButtonRightThumb:
+		evKey = evcode.BTN_THUMBR
+	case inputevent.GamepadButtonDPadUp:
+		evKey = evcode.BTN_DPAD_UP
+	case inputevent.GamepadButtonDPadDown:
+		evKey = evcode.BTN_DPAD_DOWN
+	case inputevent.GamepadButtonDPadLeft:
+		evKey = evcode.BTN_DPAD_LEFT
+	case inputevent.GamepadButtonDPadRight:
+		evKey = evcode.BTN_DPAD_RIGHT
+	}
+	return evKey
+}
+
+func gamepadAxisToEvAbs(axis inputevent.GamepadAxis) uint16 {
+	var evAbs uint16
+	switch axis {
+	case inputevent.GamepadAxisLeftX:
+		evAbs = evcode.ABS_X
+	case inputevent.GamepadAxisLeftY:
+		evAbs = evcode.ABS_Y
+	case inputevent.GamepadAxisRightX:
+		evAbs = evcode.ABS_RX
+	case inputevent.GamepadAxisRightY:
+		evAbs = evcode.ABS_RY
+	case inputevent.GamepadAxisLeftTrigger:
+		evAbs = evcode.ABS_Z
+	case inputevent.GamepadAxisRightTrigger:
+		evAbs = evcode.ABS_RZ
+	}
+	return evAbs
+}
@@ -0,0 +1,870 @@
+package inputsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/inputsink/internal/evcode"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+)
+
+var slog = logging.NewLogger("inputsink")
+
+// rateLimited counts mouse moves held back by the rate limiter, their motion
+// is injected later, see [Config.MaxEventRate].
+var rateLimited = metrics.NewCounterMap("inputsink_rate_limited_inputs")
+
+// unmappedKeys counts key presses not injected because their key maps to no
+// ev code, e.g. keys added after this build, by key.
+var unmappedKeys = metrics.NewCounterMap("inputsink_unmapped_keys")
+
+// duplicateKeys counts key presses not injected because they repeat the state
+// of their key, a down of a key held down or an up of a key not held, by
+// action.
+var duplicateKeys = metrics.NewCounterMap("inputsink_duplicate_keys")
+
+// resyncs counts writes the device dropped events of, by whether the device
+// was resynchronized after.
+var resyncs = metrics.NewCounterMap("inputsink_resyncs")
+
+// unmapped are the keys counted by unmappedKeys.
+var unmapped struct {
+	sync.Mutex
+	keys map[inputevent.KeyCode]struct{}
+}
+
+// UnmappedKeys returns the keys that were pressed but couldn't be injected,
+// in no particular order.
+func UnmappedKeys() []inputevent.KeyCode {
+	unmapped.Lock()
+	defer unmapped.Unlock()
+	keys := make([]inputevent.KeyCode, 0, len(unmapped.keys))
+	for k := range unmapped.keys {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// skipUnmapped reports whether k can't be injected. Each such key is logged
+// once.
+func skipUnmapped(k inputevent.KeyCode) bool {
+	if keyCodeToEvKey(k) != 0 {
+		return false
+	}
+	unmappedKeys.Add(k.String(), 1)
+	unmapped.Lock()
+	defer unmapped.Unlock()
+	if _, ok := unmapped.keys[k]; !ok {
+		if unmapped.keys == nil {
+			unmapped.keys = make(map[inputevent.KeyCode]struct{})
+		}
+		unmapped.keys[k] = struct{}{}
+		slog.Warn("key has no mapping, not injecting it", "key", k)
+	}
+	return true
+}
+
+const deviceName = "Terong Virtual Input Device"
+
+type Backend string
+
+const (
+	// BackendUinput injects inputs through a virtual uinput device. It needs
+	// write access to /dev/uinput.
+	BackendUinput Backend = "uinput"
+	// BackendXTest injects inputs through the XTest extension of the X11
+	// session in DISPLAY. It needs no special permissions, but it moves the X
+	// pointer instead of emitting raw relative motion: the cursor stops at
+	// screen edges and applications reading raw device events (e.g. games
+	// that grab the pointer) may not see the movement. It's only built with
+	// cgo and -tags xtest, which links libXtst.
+	BackendXTest Backend = "xtest"
+)
+
+type Config struct {
+	// Backend defaults to BackendUinput.
+	Backend Backend
+	// RepeatDelay and RepeatPeriod, when both set, make the uinput device
+	// repeat held keys by itself with these parameters. Repeats relayed from
+	// the server are dropped then.
+	RepeatDelay  time.Duration
+	RepeatPeriod time.Duration
+	// DoubleClickAssist, when set, turns two clicks of a button arriving
+	// further apart than DoubleClickInterval, but within DoubleClickAssist,
+	// into a double-click by injecting an extra click right before the
+	// second. Network delay can push a relayed double-click outside the
+	// desktop's interval. DoubleClickInterval defaults to 400ms.
+	DoubleClickAssist   time.Duration
+	DoubleClickInterval time.Duration
+	// MaxEventRate, when set, limits injected inputs to this many per second
+	// with bursts of up to EventBurst, to protect slow consumers of the
+	// device. Mouse moves over the limit are merged into the next one, or
+	// injected on their own ahead of the next key or click, or once the limit
+	// allows. Other inputs always pass.
+	MaxEventRate float64
+	EventBurst   int
+	// HighPriority runs the sink on a thread of its own with the SCHED_FIFO
+	// real-time policy, or at nice -10 if that isn't permitted, so inputs
+	// are injected in time under load. Both need CAP_SYS_NICE or a
+	// matching RLIMIT_RTPRIO or RLIMIT_NICE.
+	HighPriority bool
+}
+
+const defaultDoubleClickInterval = 400 * time.Millisecond
+
+// assistsDoubleClick reports whether a button press gap after the previous
+// press of the same button needs an extra click to be a double-click.
+func (c Config) assistsDoubleClick(gap time.Duration) bool {
+	interval := c.DoubleClickInterval
+	if interval == 0 {
+		interval = defaultDoubleClickInterval
+	}
+	return c.DoubleClickAssist > 0 && gap > interval && gap <= c.DoubleClickAssist
+}
+
+// deviceRepeats reports whether the virtual device repeats held keys.
+func (c Config) deviceRepeats() bool {
+	return c.Backend != BackendXTest && c.RepeatDelay > 0 && c.RepeatPeriod > 0
+}
+
+// repeatValue is the EV_REP value of code in milliseconds.
+func (c Config) repeatValue(code uint16) int32 {
+	switch code {
+	case evcode.REP_DELAY:
+		return int32(c.RepeatDelay / time.Millisecond)
+	case evcode.REP_PERIOD:
+		return int32(c.RepeatPeriod / time.Millisecond)
+	}
+	return 0
+}
+
+// deviceSpec describes a virtual uinput device.
+type deviceSpec struct {
+	name    string
+	bustype uint16
+	vendor  uint16
+	product uint16
+	// codes lists the event codes the device emits by event type
+	codes map[uint16][]uint16
+	// abs holds the range of every EV_ABS code
+	abs map[uint16]absInfo
+	// props are INPUT_PROP_* properties
+	props []uint16
+	// repeatValue returns the value of an EV_REP code
+	repeatValue func(code uint16) int32
+}
+
+type absInfo struct {
+	min, max, fuzz, flat int32
+	// resolution is in units per millimeter
+	resolution int32
+}
+
+// device is a virtual input device backend.
+type device interface {
+	write(events []event) error
+	close()
+}
+
+// droppedError is a write of events the device took only some of, or none,
+// as it's overloaded. The rest are lost, so the device has to be
+// resynchronized, see [resync].
+type droppedError struct {
+	err error
+}
+
+func (e *droppedError) Error() string {
+	return fmt.Sprintf("events dropped: %v", e.err)
+}
+
+type event struct {
+	type_ uint16
+	code  uint16
+	value int32
+}
+
+// supportedCodes lists the event codes the virtual device emits by event
+// type.
+func supportedCodes(cfg Config) map[uint16][]uint16 {
+	codes := make(map[uint16][]uint16)
+
+	if cfg.deviceRepeats() {
+		codes[evcode.EV_REP] = append(codes[evcode.EV_REP], evcode.REP_DELAY, evcode.REP_PERIOD)
+	}
+
+	codes[evcode.EV_SYN] = append(codes[evcode.EV_SYN], evcode.SYN_REPORT)
+
+	codes[evcode.EV_REL] = append(codes[evcode.EV_REL], evcode.REL_X, evcode.REL_Y, evcode.REL_WHEEL)
+
+	codes[evcode.EV_MSC] = append(codes[evcode.EV_MSC], evcode.MSC_SCAN)
+
+	for _, b := range inputevent.MouseButtons() {
+		code := mouseButtonToEvKey(b)
+		codes[evcode.EV_KEY] = append(codes[evcode.EV_KEY], code)
+	}
+
+	for _, c := range inputevent.KeyCodes() {
+		code := keyCodeToEvKey(c)
+		codes[evcode.EV_KEY] = append(codes[evcode.EV_KEY], code)
+	}
+
+	return codes
+}
+
+type Handle struct {
+	done    chan error
+	release chan struct{}
+}
+
+func (h *Handle) Done() <-chan error {
+	return h.done
+}
+
+// ReleaseAll lifts every key and mouse button held down on the virtual device.
+func (h *Handle) ReleaseAll() {
+	select {
+	case h.release <- struct{}{}:
+	default:
+	}
+}
+
+func Start(ctx context.Context, cfg Config, source <-chan inputevent.InputEvent) *Handle {
+	h := &Handle{done: make(chan error, 1), release: make(chan struct{}, 1)}
+	go func() {
+		defer crash.Recover()
+		if cfg.HighPriority {
+			// never unlocked, so the thread ends with the sink instead of
+			// running other goroutines at its priority
+			runtime.LockOSThread()
+			raisePriority()
+		}
+		err := start(ctx, cfg, source, h.release)
+		h.done <- err
+	}()
+	return h
+}
+
+func createDevice(cfg Config) (device, error) {
+	switch cfg.Backend {
+	case "", BackendUinput:
+		spec := deviceSpec{
+			name:        deviceName,
+			bustype:     evcode.BUS_VIRTUAL,
+			codes:       supportedCodes(cfg),
+			repeatValue: cfg.repeatValue,
+		}
+		return createUinputDevice(spec)
+	case BackendXTest:
+		return createXTestDevice()
+	}
+	return nil, errs.Mark(fmt.Errorf("unknown backend %q", cfg.Backend), errs.ErrConfigInvalid)
+}
+
+// fifoPriority is the SCHED_FIFO priority of the sink thread, above most
+// threads of the desktop but below the kernel's own.
+const fifoPriority = 10
+
+// raisePriority raises the scheduling priority of the calling thread.
+// Failing is not fatal, inputs are only injected with more latency under
+// load.
+func raisePriority() {
+	err := unix.SchedSetAttr(0, &unix.SchedAttr{Policy: unix.SCHED_FIFO, Priority: fifoPriority}, 0)
+	if err == nil {
+		slog.Info("sink runs with SCHED_FIFO", "priority", fifoPriority)
+		return
+	}
+	slog.Warn("failed to set SCHED_FIFO, trying nice", "error", err)
+	// nice is per thread on Linux
+	if err := unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), -10); err != nil {
+		slog.Warn("failed to raise sink priority", "error", err)
+		return
+	}
+	slog.Info("sink runs at nice -10")
+}
+
+// openDevice creates the device of cfg. Its errors are
+// [errs.ErrDeviceUnavailable] unless they're of another kind.
+func openDevice(cfg Config) (device, error) {
+	dev, err := createDevice(cfg)
+	if err != nil {
+		kind := errs.Kind(err)
+		if kind == nil {
+			kind = errs.ErrDeviceUnavailable
+		}
+		return nil, errs.Mark(fmt.Errorf("failed to create device: %v", err), kind)
+	}
+	return dev, nil
+}
+
+// Check creates the device of cfg and destroys it right away, to tell
+// whether inputs can be injected without starting a sink.
+func Check(cfg Config) error {
+	dev, err := openDevice(cfg)
+	if err != nil {
+		return err
+	}
+	dev.close()
+	return nil
+}
+
+func start(ctx context.Context, cfg Config, source <-chan inputevent.InputEvent, release <-chan struct{}) error {
+	dev, err := openDevice(cfg)
+	if err != nil {
+		return err
+	}
+	defer dev.close()
+
+	if cfg.deviceRepeats() {
+		events := []event{
+			{type_: evcode.EV_REP, code: evcode.REP_DELAY, value: cfg.repeatValue(evcode.REP_DELAY)},
+			{type_: evcode.EV_REP, code: evcode.REP_PERIOD, value: cfg.repeatValue(evcode.REP_PERIOD)},
+		}
+		if err := dev.write(events); err != nil {
+			return fmt.Errorf("failed to set repeat parameters: %v", err)
+		}
+	}
+
+	// codes of keys and buttons currently held down
+	held := make(map[uint16]struct{})
+	// when buttons were last pressed, see [Config.DoubleClickAssist]
+	pressed := make(map[uint16]time.Time)
+
+	pad := &lazyDevice{name: gamepadDeviceName, spec: gamepadSpec}
+	defer pad.close()
+	touchpad := &lazyDevice{name: touchpadDeviceName, spec: touchpadSpec}
+	defer touchpad.close()
+	touches := newTouchTracker()
+	pen := &lazyDevice{name: penDeviceName, spec: penSpec}
+	defer pen.close()
+	var pens penTracker
+
+	limiter := inputevent.RateLimiter{Rate: cfg.MaxEventRate, Burst: cfg.EventBurst}
+	// fires once the limiter allows the motion it held back
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+
+		case <-flush:
+			flush = nil
+			move, ok := limiter.Flush(time.Now())
+			if !ok {
+				continue
+			}
+			// lost motion leaves no key stuck, no need to resynchronize
+			if err := dev.write(inputEvents(move)); err != nil {
+				if derr := (*droppedError)(nil); !errors.As(err, &derr) {
+					return fmt.Errorf("failed to write events: %v", err)
+				}
+			}
+
+		case <-release:
+			if pad.created() {
+				if err := pad.write(gamepadNeutralEvents()); err != nil {
+					return fmt.Errorf("failed to write gamepad events: %v", err)
+				}
+			}
+			if touchpad.created() {
+				if err := touchpad.write(touches.events(inputevent.TouchFrame{})); err != nil {
+					return fmt.Errorf("failed to write touchpad events: %v", err)
+				}
+			}
+			if pen.created() {
+				if err := pen.write(pens.events(inputevent.PenState{})); err != nil {
+					return fmt.Errorf("failed to write pen events: %v", err)
+				}
+			}
+			if len(held) == 0 {
+				continue
+			}
+			events := make([]event, 0, 2*len(held)+1)
+			for code := range held {
+				events = appendKeyEvent(events, code, 0)
+			}
+			events = append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+			if err := dev.write(events); err != nil {
+				return fmt.Errorf("failed to write events: %v", err)
+			}
+			clear(held)
+
+		case input := <-source:
+			if v, ok := input.(inputevent.KeyPress); ok && v.Action == inputevent.KeyActionRepeat && cfg.deviceRepeats() {
+				continue
+			}
+			if v, ok := input.(inputevent.KeyPress); ok && skipUnmapped(v.Key) {
+				continue
+			}
+			if v, ok := input.(inputevent.KeyPress); ok && duplicate(held, v) {
+				// some applications take a second down for a repeat
+				duplicateKeys.Add(v.Action.String(), 1)
+				continue
+			}
+
+			input, ok := limiter.Apply(input, time.Now())
+			if !ok {
+				rateLimited.Add(inputevent.TypeName(inputevent.MouseMove{}), 1)
+				if flush == nil {
+					flush = time.After(limiter.Wait())
+				}
+				continue
+			}
+
+			if events := gamepadEvents(input); events != nil {
+				if err := pad.write(events); err != nil {
+					return fmt.Errorf("failed to write gamepad events: %v", err)
+				}
+				continue
+			}
+
+			if v, ok := input.(inputevent.TouchFrame); ok {
+				if err := touchpad.write(touches.events(v)); err != nil {
+					return fmt.Errorf("failed to write touchpad events: %v", err)
+				}
+				continue
+			}
+
+			if v, ok := input.(inputevent.PenState); ok {
+				if err := pen.write(pens.events(v)); err != nil {
+					return fmt.Errorf("failed to write pen events: %v", err)
+				}
+				continue
+			}
+
+			events := inputEvents(input)
+			if _, ok := input.(inputevent.MouseMove); !ok {
+				// held back motion goes first, so a click lands where it's
+				// meant to
+				if move, ok := limiter.Flush(time.Now()); ok {
+					events = append(inputEvents(move), events...)
+				}
+			}
+
+			if v, ok := input.(inputevent.MouseClick); ok && v.Action == inputevent.MouseButtonActionDown {
+				code := mouseButtonToEvKey(v.Button)
+				now := time.Now()
+				if last, ok := pressed[code]; ok && cfg.assistsDoubleClick(now.Sub(last)) {
+					click := []event{
+						{type_: evcode.EV_KEY, code: code, value: 1},
+						{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0},
+						{type_: evcode.EV_KEY, code: code, value: 0},
+						{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0},
+					}
+					events = append(click, events...)
+					// a third press continues this double-click
+					delete(pressed, code)
+				} else {
+					pressed[code] = now
+				}
+			}
+
+			for _, event := range events {
+				if event.type_ != evcode.EV_KEY {
+					continue
+				}
+				if event.value == 0 {
+					delete(held, event.code)
+				} else {
+					held[event.code] = struct{}{}
+				}
+			}
+
+			if err := dev.write(events); err != nil {
+				if derr := (*droppedError)(nil); !errors.As(err, &derr) {
+					return fmt.Errorf("failed to write events: %v", err)
+				}
+				slog.Warn("device dropped events, resynchronizing", "error", err)
+				if err := resync(dev, held); err != nil {
+					resyncs.Add("failed", 1)
+					slog.Warn("failed to resynchronize device", "error", err)
+					continue
+				}
+				resyncs.Add("resynced", 1)
+			}
+		}
+	}
+}
+
+// resync brings the device back in line with held after it dropped events.
+// Every key and button is released, then the modifiers in held are pressed
+// again, so a shortcut being typed still works but no other key is left
+// stuck. The other keys are removed from held.
+func resync(dev device, held map[uint16]struct{}) error {
+	events := make([]event, 0, 4*len(held)+2)
+	for code := range held {
+		events = appendKeyEvent(events, code, 0)
+	}
+	events = append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+	for code := range held {
+		if modifier(code) {
+			events = appendKeyEvent(events, code, 1)
+		} else {
+			delete(held, code)
+		}
+	}
+	events = append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+	return dev.write(events)
+}
+
+func modifier(code uint16) bool {
+	switch code {
+	case evcode.KEY_LEFTCTRL, evcode.KEY_RIGHTCTRL,
+		evcode.KEY_LEFTSHIFT, evcode.KEY_RIGHTSHIFT,
+		evcode.KEY_LEFTALT, evcode.KEY_RIGHTALT,
+		evcode.KEY_LEFTMETA, evcode.KEY_RIGHTMETA:
+		return true
+	}
+	return false
+}
+
+// lazyDevice is a virtual uinput device created on first use, so desktops
+// only see it once it has something to do. Its inputs are dropped if it
+// can't be created.
+type lazyDevice struct {
+	name   string
+	spec   func() deviceSpec
+	dev    device
+	failed bool
+}
+
+func (d *lazyDevice) created() bool {
+	return d.dev != nil
+}
+
+func (d *lazyDevice) write(events []event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if d.dev == nil && !d.failed {
+		dev, err := createUinputDevice(d.spec())
+		if err != nil {
+			slog.Warn("failed to create virtual device, dropping its inputs", "device", d.name, "error", err)
+			d.failed = true
+			return nil
+		}
+		d.dev = dev
+	}
+	if d.dev == nil {
+		return nil
+	}
+	return d.dev.write(events)
+}
+
+func (d *lazyDevice) close() {
+	if d.dev != nil {
+		d.dev.close()
+	}
+}
+
+// duplicate reports whether k doesn't change the state of its key in held.
+func duplicate(held map[uint16]struct{}, k inputevent.KeyPress) bool {
+	_, down := held[keyCodeToEvKey(k.Key)]
+	switch k.Action {
+	case inputevent.KeyActionDown:
+		return down
+	case inputevent.KeyActionUp:
+		return !down
+	}
+	return false
+}
+
+// inputEvents translates input into events of the virtual device.
+func inputEvents(input inputevent.InputEvent) []event {
+	events := make([]event, 0, 4)
+
+	switch v := input.(type) {
+	case inputevent.MouseMove:
+		events = append(
+			events,
+			event{
+				type_: evcode.EV_REL,
+				code:  evcode.REL_X,
+				value: int32(v.DX),
+			},
+			event{
+				type_: evcode.EV_REL,
+				code:  evcode.REL_Y,
+				value: int32(-v.DY),
+			},
+		)
+
+	case inputevent.MouseClick:
+		ev := event{type_: evcode.EV_KEY}
+		ev.code = mouseButtonToEvKey(v.Button)
+		switch v.Action {
+		case inputevent.MouseButtonActionDown:
+			ev.value = 1
+		case inputevent.MouseButtonActionUp:
+			ev.value = 0
+		}
+		events = append(events, ev)
+
+	case inputevent.MouseScroll:
+		ev := event{type_: evcode.EV_REL, code: evcode.REL_WHEEL}
+		switch v.Direction {
+		case inputevent.MouseScrollUp:
+			ev.value = int32(v.Count)
+		case inputevent.MouseScrollDown:
+			ev.value = -int32(v.Count)
+		}
+		events = append(events, ev)
+
+	case inputevent.KeyPress:
+		var value int32
+		switch v.Action {
+		case inputevent.KeyActionDown:
+			value = 1
+		case inputevent.KeyActionRepeat:
+			value = 2
+		case inputevent.KeyActionUp:
+			value = 0
+		}
+		events = appendKeyEvent(events, keyCodeToEvKey(v.Key), value)
+	}
+
+	events = append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+
+	return events
+}
+
+// appendKeyEvent appends a key event preceded by its scan code, as keyboards
+// report them. Some applications key off scan codes instead of key codes.
+func appendKeyEvent(events []event, code uint16, value int32) []event {
+	if scanCode, ok := evKeyToScanCode(code); ok {
+		events = append(events, event{type_: evcode.EV_MSC, code: evcode.MSC_SCAN, value: scanCode})
+	}
+	return append(events, event{type_: evcode.EV_KEY, code: code, value: value})
+}
+
+func mouseButtonToEvKey(button inputevent.MouseButton) uint16 {
+	var evKey uint16
+	switch button {
+	case inputevent.MouseButtonLeft:
+		evKey = evcode.BTN_LEFT
+	case inputevent.MouseButtonRight:
+		evKey = evcode.BTN_RIGHT
+	case inputevent.MouseButtonMiddle:
+		evKey = evcode.BTN_MIDDLE
+	case inputevent.MouseButtonMouse4:
+		evKey = evcode.BTN_SIDE
+	case inputevent.MouseButtonMouse5:
+		evKey = evcode.BTN_EXTRA
+	}
+	return evKey
+}
+
+func keyCodeToEvKey(code inputevent.KeyCode) uint16 {
+	var evKey uint16
+	switch code {
+	case inputevent.Escape:
+		evKey = evcode.KEY_ESC
+
+	case inputevent.F1:
+		evKey = evcode.KEY_F1
+	case inputevent.F2:
+		evKey = evcode.KEY_F2
+	case inputevent.F3:
+		evKey = evcode.KEY_F3
+	case inputevent.F4:
+		evKey = evcode.KEY_F4
+	case inputevent.F5:
+		evKey = evcode.KEY_F5
+	case inputevent.F6:
+		evKey = evcode.KEY_F6
+	case inputevent.F7:
+		evKey = evcode.KEY_F7
+	case inputevent.F8:
+		evKey = evcode.KEY_F8
+	case inputevent.F9:
+		evKey = evcode.KEY_F9
+	case inputevent.F10:
+		evKey = evcode.KEY_F10
+	case inputevent.F11:
+		evKey = evcode.KEY_F11
+	case inputevent.F12:
+		evKey = evcode.KEY_F12
+
+	case inputevent.PrintScreen:
+		evKey = evcode.KEY_PRINT
+	case inputevent.ScrollLock:
+		evKey = evcode.KEY_SCROLLLOCK
+	case inputevent.PauseBreak:
+		evKey = evcode.KEY_PAUSE
+
+	case inputevent.Grave:
+		evKey = evcode.KEY_GRAVE
+
+	case inputevent.D1:
+		evKey = evcode.KEY_1
+	case inputevent.D2:
+		evKey = evcode.KEY_2
+	case inputevent.D3:
+		evKey = evcode.KEY_3
+	case inputevent.D4:
+		evKey = evcode.KEY_4
+	case inputevent.D5:
+		evKey = evcode.KEY_5
+	case inputevent.D6:
+		evKey = evcode.KEY_6
+	case inputevent.D7:
+		evKey = evcode.KEY_7
+	case inputevent.D8:
+		evKey = evcode.KEY_8
+	case inputevent.D9:
+		evKey = evcode.KEY_9
+	case inputevent.D0:
+		evKey = evcode.KEY_0
+
+	case inputevent.Minus:
+		evKey = evcode.KEY_MINUS
+	case inputevent.Equal:
+		evKey = evcode.KEY_EQUAL
+
+	case inputevent.A:
+		evKey = evcode.KEY_A
+	case inputevent.B:
+		evKey = evcode.KEY_B
+	case inputevent.C:
+		evKey = evcode.KEY_C
+	case inputevent.D:
+		evKey = evcode.KEY_D
+	case inputevent.E:
+		evKey = evcode.KEY_E
+	case inputevent.F:
+		evKey = evcode.KEY_F
+	case inputevent.G:
+		evKey = evcode.KEY_G
+	case inputevent.H:
+		evKey = evcode.KEY_H
+	case inputevent.I:
+		evKey = evcode.KEY_I
+	case inputevent.J:
+		evKey = evcode.KEY_J
+	case inputevent.K:
+		evKey = evcode.KEY_K
+	case inputevent.L:
+		evKey = evcode.KEY_L
+	case inputevent.M:
+		evKey = evcode.KEY_M
+	case inputevent.N:
+		evKey = evcode.KEY_N
+	case inputevent.O:
+		evKey = evcode.KEY_O
+	case inputevent.P:
+		evKey = evcode.KEY_P
+	case inputevent.Q:
+		evKey = evcode.KEY_Q
+	case inputevent.R:
+		evKey = evcode.KEY_R
+	case inputevent.S:
+		evKey = evcode.KEY_S
+	case inputevent.T:
+		evKey = evcode.KEY_T
+	case inputevent.U:
+		evKey = evcode.KEY_U
+	case inputevent.V:
+		evKey = evcode.KEY_V
+	case inputevent.W:
+		evKey = evcode.KEY_W
+	case inputevent.X:
+		evKey = evcode.KEY_X
+	case inputevent.Y:
+		evKey = evcode.KEY_Y
+	case inputevent.Z:
+		evKey = evcode.KEY_Z
+
+	case inputevent.LeftBrace:
+		evKey = evcode.KEY_LEFTBRACE
+	case inputevent.RightBrace:
+		evKey = evcode.KEY_RIGHTBRACE
+
+	case inputevent.SemiColon:
+		evKey = evcode.KEY_SEMICOLON
+	case inputevent.Apostrophe:
+		evKey = evcode.KEY_APOSTROPHE
+
+	case inputevent.Comma:
+		evKey = evcode.KEY_COMMA
+	case inputevent.Dot:
+		evKey = evcode.KEY_DOT
+	case inputevent.Slash:
+		evKey = evcode.KEY_SLASH
+
+	case inputevent.Backspace:
+		evKey = evcode.KEY_BACKSPACE
+	case inputevent.BackSlash:
+		evKey = evcode.KEY_BACKSLASH
+	case inputevent.Enter:
+		evKey = evcode.KEY_ENTER
+
+	case inputevent.Space:
+		evKey = evcode.KEY_SPACE
+
+	case inputevent.Tab:
+		evKey = evcode.KEY_TAB
+	case inputevent.CapsLock:
+		evKey = evcode.KEY_CAPSLOCK
+
+	case inputevent.LeftShift:
+		evKey = evcode.KEY_LEFTSHIFT
+	case inputevent.RightShift:
+		evKey = evcode.KEY_RIGHTSHIFT
+
+	case inputevent.LeftCtrl:
+		evKey = evcode.KEY_LEFTCTRL
+	case inputevent.RightCtrl:
+		evKey = evcode.KEY_RIGHTCTRL
+
+	case inputevent.LeftAlt:
+		evKey = evcode.KEY_LEFTALT
+	case inputevent.RightAlt:
+		evKey = evcode.KEY_RIGHTALT
+
+	case inputevent.LeftMeta:
+		evKey = evcode.KEY_LEFTMETA
+	case inputevent.RightMeta:
+		evKey = evcode.KEY_RIGHTMETA
+
+	case inputevent.Insert:
+		evKey = evcode.KEY_INSERT
+	case inputevent.Delete:
+		evKey = evcode.KEY_DELETE
+
+	case inputevent.Home:
+		evKey = evcode.KEY_HOME
+	case inputevent.End:
+		evKey = evcode.KEY_END
+
+	case inputevent.PageUp:
+		evKey = evcode.KEY_PAGEUP
+	case inputevent.PageDown:
+		evKey = evcode.KEY_PAGEDOWN
+
+	case inputevent.Up:
+		evKey = evcode.KEY_UP
+	case inputevent.Left:
+		evKey = evcode.KEY_LEFT
+	case inputevent.Down:
+		evKey = evcode.KEY_DOWN
+	case inputevent.Right:
+		evKey = evcode.KEY_RIGHT
+
+	case inputevent.NumLock:
+		evKey = evcode.KEY_NUMLOCK
+	case inputevent.Menu:
+		evKey = evcode.KEY_COMPOSE
+	case inputevent.Sleep:
+		evKey = evcode.KEY_SLEEP
+	}
+	return evKey
+}
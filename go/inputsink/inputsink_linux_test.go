@@ -0,0 +1,106 @@
+package inputsink
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+// recordingDevice keeps the events written to it.
+type recordingDevice struct {
+	writes [][]event
+}
+
+func (d *recordingDevice) write(events []event) error {
+	d.writes = append(d.writes, events)
+	return nil
+}
+
+func (d *recordingDevice) close() {}
+
+// keyValues returns the values of the key events in events by code, and
+// whether a SYN_REPORT ends them.
+func keyValues(events []event) (map[uint16]int32, bool) {
+	values := make(map[uint16]int32)
+	for _, e := range events {
+		if e.type_ == evcode.EV_KEY {
+			values[e.code] = e.value
+		}
+	}
+	last := events[len(events)-1]
+	return values, last.type_ == evcode.EV_SYN && last.code == evcode.SYN_REPORT
+}
+
+func TestResync(t *testing.T) {
+	held := map[uint16]struct{}{
+		evcode.KEY_A:         {},
+		evcode.KEY_LEFTCTRL:  {},
+		evcode.KEY_RIGHTMETA: {},
+		evcode.BTN_LEFT:      {},
+	}
+	var dev recordingDevice
+	require.NoError(t, resync(&dev, held))
+	require.Len(t, dev.writes, 1)
+
+	events := dev.writes[0]
+	var split int
+	for i, e := range events {
+		if e.type_ == evcode.EV_SYN {
+			split = i + 1
+			break
+		}
+	}
+	released, synced := keyValues(events[:split])
+	assert.True(t, synced)
+	assert.Equal(t, map[uint16]int32{evcode.KEY_A: 0, evcode.KEY_LEFTCTRL: 0, evcode.KEY_RIGHTMETA: 0, evcode.BTN_LEFT: 0}, released)
+	pressed, synced := keyValues(events[split:])
+	assert.True(t, synced)
+	assert.Equal(t, map[uint16]int32{evcode.KEY_LEFTCTRL: 1, evcode.KEY_RIGHTMETA: 1}, pressed)
+
+	assert.Equal(t, map[uint16]struct{}{evcode.KEY_LEFTCTRL: {}, evcode.KEY_RIGHTMETA: {}}, held)
+}
+
+func TestDuplicate(t *testing.T) {
+	held := make(map[uint16]struct{})
+	press := func(key inputevent.KeyCode, action inputevent.KeyAction) bool {
+		k := inputevent.KeyPress{Key: key, Action: action}
+		if duplicate(held, k) {
+			return true
+		}
+		switch action {
+		case inputevent.KeyActionDown:
+			held[keyCodeToEvKey(key)] = struct{}{}
+		case inputevent.KeyActionUp:
+			delete(held, keyCodeToEvKey(key))
+		}
+		return false
+	}
+
+	assert.True(t, press(inputevent.A, inputevent.KeyActionUp), "up of a key not held")
+	assert.False(t, press(inputevent.A, inputevent.KeyActionDown))
+	assert.True(t, press(inputevent.A, inputevent.KeyActionDown), "second down")
+	assert.False(t, press(inputevent.A, inputevent.KeyActionRepeat), "repeats aren't duplicates")
+	assert.False(t, press(inputevent.B, inputevent.KeyActionDown), "other keys are apart")
+	assert.False(t, press(inputevent.A, inputevent.KeyActionUp))
+	assert.True(t, press(inputevent.A, inputevent.KeyActionUp), "second up")
+}
+
+func TestSkipUnmapped(t *testing.T) {
+	assert.False(t, skipUnmapped(inputevent.A))
+	assert.NotContains(t, UnmappedKeys(), inputevent.A)
+
+	var key inputevent.KeyCode
+	for k := inputevent.KeyCode(1); k < 1<<8; k++ {
+		if keyCodeToEvKey(k) == 0 {
+			key = k
+			break
+		}
+	}
+	require.NotZero(t, key, "every key is mapped")
+	assert.True(t, skipUnmapped(key))
+	assert.True(t, skipUnmapped(key))
+	assert.Contains(t, UnmappedKeys(), key)
+}
@@ -0,0 +1,102 @@
+package inputsink
+
+import (
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+const penDeviceName = "Terong Virtual Pen"
+
+// The virtual tablet claims to be 300 by 170 millimeters, libinput needs a
+// resolution to handle tablets. The tablet maps to the whole screen either
+// way.
+const (
+	penMax         = 65535
+	penResolutionX = penMax / 300
+	penResolutionY = penMax / 170
+)
+
+// penSpec describes the virtual pen tablet.
+func penSpec() deviceSpec {
+	codes := make(map[uint16][]uint16)
+
+	codes[evcode.EV_SYN] = append(codes[evcode.EV_SYN], evcode.SYN_REPORT)
+
+	codes[evcode.EV_KEY] = append(
+		codes[evcode.EV_KEY],
+		evcode.BTN_TOOL_PEN,
+		evcode.BTN_TOOL_RUBBER,
+		evcode.BTN_TOUCH,
+		evcode.BTN_STYLUS,
+	)
+
+	abs := map[uint16]absInfo{
+		evcode.ABS_X:        {max: penMax, resolution: penResolutionX},
+		evcode.ABS_Y:        {max: penMax, resolution: penResolutionY},
+		evcode.ABS_PRESSURE: {max: inputevent.MaxPenPressure},
+		// tilt resolution is in units per radian
+		evcode.ABS_TILT_X: {min: -90, max: 90, resolution: 57},
+		evcode.ABS_TILT_Y: {min: -90, max: 90, resolution: 57},
+	}
+	for code := range abs {
+		codes[evcode.EV_ABS] = append(codes[evcode.EV_ABS], code)
+	}
+
+	return deviceSpec{
+		name:    penDeviceName,
+		bustype: evcode.BUS_VIRTUAL,
+		codes:   codes,
+		abs:     abs,
+		props:   []uint16{evcode.INPUT_PROP_POINTER},
+	}
+}
+
+// penTracker translates pen states into tablet events, where the tool in
+// proximity is a key held down.
+//
+// https://www.kernel.org/doc/html/latest/input/event-codes.html#tablets
+type penTracker struct {
+	// tool is the BTN_TOOL_* code in proximity, zero when none
+	tool uint16
+}
+
+func (t *penTracker) events(state inputevent.PenState) []event {
+	var tool uint16
+	if state.InRange {
+		tool = evcode.BTN_TOOL_PEN
+		if state.Eraser {
+			tool = evcode.BTN_TOOL_RUBBER
+		}
+	}
+
+	events := make([]event, 0, 16)
+
+	if t.tool != 0 && t.tool != tool {
+		// the previous tool leaves before the next one comes
+		events = append(
+			events,
+			event{type_: evcode.EV_KEY, code: evcode.BTN_TOUCH, value: 0},
+			event{type_: evcode.EV_KEY, code: evcode.BTN_STYLUS, value: 0},
+			event{type_: evcode.EV_ABS, code: evcode.ABS_PRESSURE, value: 0},
+			event{type_: evcode.EV_KEY, code: t.tool, value: 0},
+			event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0},
+		)
+	}
+	t.tool = tool
+	if tool == 0 {
+		return events
+	}
+
+	return append(
+		events,
+		event{type_: evcode.EV_ABS, code: evcode.ABS_X, value: int32(state.X)},
+		event{type_: evcode.EV_ABS, code: evcode.ABS_Y, value: int32(state.Y)},
+		event{type_: evcode.EV_ABS, code: evcode.ABS_PRESSURE, value: int32(min(state.Pressure, inputevent.MaxPenPressure))},
+		event{type_: evcode.EV_ABS, code: evcode.ABS_TILT_X, value: int32(max(min(state.TiltX, 90), -90))},
+		event{type_: evcode.EV_ABS, code: evcode.ABS_TILT_Y, value: int32(max(min(state.TiltY, 90), -90))},
+		event{type_: evcode.EV_KEY, code: tool, value: 1},
+		event{type_: evcode.EV_KEY, code: evcode.BTN_TOUCH, value: boolValue(state.Tip)},
+		event{type_: evcode.EV_KEY, code: evcode.BTN_STYLUS, value: boolValue(state.Barrel)},
+		event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0},
+	)
+}
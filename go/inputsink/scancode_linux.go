@@ -0,0 +1,110 @@
+package inputsink
+
+import "kafji.net/terong/inputsink/internal/evcode"
+
+// Scan codes are reported as USB HID usages, like USB keyboards do.
+//
+// https://usb.org/sites/default/files/hut1_5.pdf, chapter 10.
+
+const hidUsagePageKeyboard = 0x07 << 16
+
+// hidKeyboardUsages maps evdev key codes to HID keyboard usage IDs.
+var hidKeyboardUsages = map[uint16]int32{
+	evcode.KEY_ESC:        0x29,
+	evcode.KEY_1:          0x1e,
+	evcode.KEY_2:          0x1f,
+	evcode.KEY_3:          0x20,
+	evcode.KEY_4:          0x21,
+	evcode.KEY_5:          0x22,
+	evcode.KEY_6:          0x23,
+	evcode.KEY_7:          0x24,
+	evcode.KEY_8:          0x25,
+	evcode.KEY_9:          0x26,
+	evcode.KEY_0:          0x27,
+	evcode.KEY_MINUS:      0x2d,
+	evcode.KEY_EQUAL:      0x2e,
+	evcode.KEY_BACKSPACE:  0x2a,
+	evcode.KEY_TAB:        0x2b,
+	evcode.KEY_A:          0x04,
+	evcode.KEY_B:          0x05,
+	evcode.KEY_C:          0x06,
+	evcode.KEY_D:          0x07,
+	evcode.KEY_E:          0x08,
+	evcode.KEY_F:          0x09,
+	evcode.KEY_G:          0x0a,
+	evcode.KEY_H:          0x0b,
+	evcode.KEY_I:          0x0c,
+	evcode.KEY_J:          0x0d,
+	evcode.KEY_K:          0x0e,
+	evcode.KEY_L:          0x0f,
+	evcode.KEY_M:          0x10,
+	evcode.KEY_N:          0x11,
+	evcode.KEY_O:          0x12,
+	evcode.KEY_P:          0x13,
+	evcode.KEY_Q:          0x14,
+	evcode.KEY_R:          0x15,
+	evcode.KEY_S:          0x16,
+	evcode.KEY_T:          0x17,
+	evcode.KEY_U:          0x18,
+	evcode.KEY_V:          0x19,
+	evcode.KEY_W:          0x1a,
+	evcode.KEY_X:          0x1b,
+	evcode.KEY_Y:          0x1c,
+	evcode.KEY_Z:          0x1d,
+	evcode.KEY_LEFTBRACE:  0x2f,
+	evcode.KEY_RIGHTBRACE: 0x30,
+	evcode.KEY_ENTER:      0x28,
+	evcode.KEY_SEMICOLON:  0x33,
+	evcode.KEY_APOSTROPHE: 0x34,
+	evcode.KEY_GRAVE:      0x35,
+	evcode.KEY_BACKSLASH:  0x31,
+	evcode.KEY_COMMA:      0x36,
+	evcode.KEY_DOT:        0x37,
+	evcode.KEY_SLASH:      0x38,
+	evcode.KEY_SPACE:      0x2c,
+	evcode.KEY_CAPSLOCK:   0x39,
+	evcode.KEY_F1:         0x3a,
+	evcode.KEY_F2:         0x3b,
+	evcode.KEY_F3:         0x3c,
+	evcode.KEY_F4:         0x3d,
+	evcode.KEY_F5:         0x3e,
+	evcode.KEY_F6:         0x3f,
+	evcode.KEY_F7:         0x40,
+	evcode.KEY_F8:         0x41,
+	evcode.KEY_F9:         0x42,
+	evcode.KEY_F10:        0x43,
+	evcode.KEY_F11:        0x44,
+	evcode.KEY_F12:        0x45,
+	evcode.KEY_PRINT:      0x46,
+	evcode.KEY_SCROLLLOCK: 0x47,
+	evcode.KEY_PAUSE:      0x48,
+	evcode.KEY_INSERT:     0x49,
+	evcode.KEY_HOME:       0x4a,
+	evcode.KEY_PAGEUP:     0x4b,
+	evcode.KEY_DELETE:     0x4c,
+	evcode.KEY_END:        0x4d,
+	evcode.KEY_PAGEDOWN:   0x4e,
+	evcode.KEY_RIGHT:      0x4f,
+	evcode.KEY_LEFT:       0x50,
+	evcode.KEY_DOWN:       0x51,
+	evcode.KEY_UP:         0x52,
+	evcode.KEY_NUMLOCK:    0x53,
+	evcode.KEY_COMPOSE:    0x65,
+	evcode.KEY_LEFTCTRL:   0xe0,
+	evcode.KEY_LEFTSHIFT:  0xe1,
+	evcode.KEY_LEFTALT:    0xe2,
+	evcode.KEY_LEFTMETA:   0xe3,
+	evcode.KEY_RIGHTCTRL:  0xe4,
+	evcode.KEY_RIGHTSHIFT: 0xe5,
+	evcode.KEY_RIGHTALT:   0xe6,
+	evcode.KEY_RIGHTMETA:  0xe7,
+}
+
+// evKeyToScanCode returns the MSC_SCAN value reported with key code.
+func evKeyToScanCode(code uint16) (int32, bool) {
+	usage, ok := hidKeyboardUsages[code]
+	if !ok {
+		return 0, false
+	}
+	return hidUsagePageKeyboard | usage, true
+}
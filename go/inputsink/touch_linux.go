@@ -0,0 +1,164 @@
+package inputsink
+
+import (
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+const touchpadDeviceName = "Terong Virtual Touchpad"
+
+// The virtual touchpad claims to be 100 by 65 millimeters, libinput scales
+// gestures by the physical size.
+const (
+	touchpadMax         = 65535
+	touchpadResolutionX = touchpadMax / 100
+	touchpadResolutionY = touchpadMax / 65
+)
+
+// touchpadSpec describes the virtual touchpad. The desktop recognizes
+// gestures from its contacts as for a real touchpad.
+func touchpadSpec() deviceSpec {
+	codes := make(map[uint16][]uint16)
+
+	codes[evcode.EV_SYN] = append(codes[evcode.EV_SYN], evcode.SYN_REPORT)
+
+	codes[evcode.EV_KEY] = append(
+		codes[evcode.EV_KEY],
+		evcode.BTN_LEFT,
+		evcode.BTN_TOUCH,
+		evcode.BTN_TOOL_FINGER,
+		evcode.BTN_TOOL_DOUBLETAP,
+		evcode.BTN_TOOL_TRIPLETAP,
+		evcode.BTN_TOOL_QUADTAP,
+		evcode.BTN_TOOL_QUINTTAP,
+	)
+
+	abs := map[uint16]absInfo{
+		evcode.ABS_X:              {max: touchpadMax, resolution: touchpadResolutionX},
+		evcode.ABS_Y:              {max: touchpadMax, resolution: touchpadResolutionY},
+		evcode.ABS_MT_SLOT:        {max: inputevent.MaxTouchContacts - 1},
+		evcode.ABS_MT_TRACKING_ID: {max: 0xffff},
+		evcode.ABS_MT_POSITION_X:  {max: touchpadMax, resolution: touchpadResolutionX},
+		evcode.ABS_MT_POSITION_Y:  {max: touchpadMax, resolution: touchpadResolutionY},
+	}
+	for code := range abs {
+		codes[evcode.EV_ABS] = append(codes[evcode.EV_ABS], code)
+	}
+
+	return deviceSpec{
+		name:    touchpadDeviceName,
+		bustype: evcode.BUS_VIRTUAL,
+		codes:   codes,
+		abs:     abs,
+		props:   []uint16{evcode.INPUT_PROP_POINTER},
+	}
+}
+
+// touchTracker translates touch frames into the multitouch protocol B, where
+// every finger down is tracked in a slot.
+//
+// https://www.kernel.org/doc/html/latest/input/multi-touch-protocol.html
+type touchTracker struct {
+	// slots holds the contact ID in each slot, -1 when the slot is free
+	slots          [inputevent.MaxTouchContacts]int
+	nextTrackingID int32
+}
+
+func newTouchTracker() *touchTracker {
+	t := &touchTracker{}
+	for i := range t.slots {
+		t.slots[i] = -1
+	}
+	return t
+}
+
+func (t *touchTracker) events(frame inputevent.TouchFrame) []event {
+	contacts := frame.Contacts[:min(len(frame.Contacts), inputevent.MaxTouchContacts)]
+	events := make([]event, 0, 4*inputevent.MaxTouchContacts+8)
+
+	// lift the fingers that are gone
+	for slot, id := range t.slots {
+		if id < 0 || containsContact(contacts, id) {
+			continue
+		}
+		events = append(
+			events,
+			event{type_: evcode.EV_ABS, code: evcode.ABS_MT_SLOT, value: int32(slot)},
+			event{type_: evcode.EV_ABS, code: evcode.ABS_MT_TRACKING_ID, value: -1},
+		)
+		t.slots[slot] = -1
+	}
+
+	down := 0
+	for _, c := range contacts {
+		slot := t.slotOf(int(c.ID))
+		if slot < 0 {
+			slot = t.slotOf(-1)
+			if slot < 0 {
+				continue
+			}
+			t.slots[slot] = int(c.ID)
+			events = append(
+				events,
+				event{type_: evcode.EV_ABS, code: evcode.ABS_MT_SLOT, value: int32(slot)},
+				event{type_: evcode.EV_ABS, code: evcode.ABS_MT_TRACKING_ID, value: t.nextTrackingID},
+			)
+			t.nextTrackingID = (t.nextTrackingID + 1) & 0xffff
+		} else {
+			events = append(events, event{type_: evcode.EV_ABS, code: evcode.ABS_MT_SLOT, value: int32(slot)})
+		}
+		events = append(
+			events,
+			event{type_: evcode.EV_ABS, code: evcode.ABS_MT_POSITION_X, value: int32(c.X)},
+			event{type_: evcode.EV_ABS, code: evcode.ABS_MT_POSITION_Y, value: int32(c.Y)},
+		)
+		if down == 0 {
+			// single touch emulation follows the first finger
+			events = append(
+				events,
+				event{type_: evcode.EV_ABS, code: evcode.ABS_X, value: int32(c.X)},
+				event{type_: evcode.EV_ABS, code: evcode.ABS_Y, value: int32(c.Y)},
+			)
+		}
+		down++
+	}
+
+	tools := []uint16{
+		evcode.BTN_TOOL_FINGER,
+		evcode.BTN_TOOL_DOUBLETAP,
+		evcode.BTN_TOOL_TRIPLETAP,
+		evcode.BTN_TOOL_QUADTAP,
+		evcode.BTN_TOOL_QUINTTAP,
+	}
+	events = append(events, event{type_: evcode.EV_KEY, code: evcode.BTN_TOUCH, value: boolValue(down > 0)})
+	for i, tool := range tools {
+		events = append(events, event{type_: evcode.EV_KEY, code: tool, value: boolValue(down == i+1)})
+	}
+
+	return append(events, event{type_: evcode.EV_SYN, code: evcode.SYN_REPORT, value: 0})
+}
+
+func (t *touchTracker) slotOf(id int) int {
+	for slot, v := range t.slots {
+		if v == id {
+			return slot
+		}
+	}
+	return -1
+}
+
+func containsContact(contacts []inputevent.TouchContact, id int) bool {
+	for _, c := range contacts {
+		if int(c.ID) == id {
+			return true
+		}
+	}
+	return false
+}
+
+func boolValue(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
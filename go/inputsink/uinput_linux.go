@@ -0,0 +1,161 @@
+//go:build linux && (!cgo || uinput)
+
+package inputsink
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+// https://www.kernel.org/doc/html/latest/input/uinput.html
+
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetRelBit  = 0x40045566
+	uiSetAbsBit  = 0x40045567
+	uiSetMscBit  = 0x40045568
+	uiAbsSetup   = 0x401c5504
+	uiSetPropBit = 0x4004556e
+)
+
+const uinputMaxNameSize = 80
+
+// struct uinput_setup
+type uinputSetup struct {
+	bustype      uint16
+	vendor       uint16
+	product      uint16
+	version      uint16
+	name         [uinputMaxNameSize]byte
+	ffEffectsMax uint32
+}
+
+// struct uinput_abs_setup
+type uinputAbsSetup struct {
+	code uint16
+	_    uint16
+	// struct input_absinfo
+	value      int32
+	minimum    int32
+	maximum    int32
+	fuzz       int32
+	flat       int32
+	resolution int32
+}
+
+// struct input_event
+type inputEvent struct {
+	time  unix.Timeval
+	type_ uint16
+	code  uint16
+	value int32
+}
+
+type uinputDevice struct {
+	fd int
+}
+
+func createUinputDevice(spec deviceSpec) (device, error) {
+	fd, err := unix.Open("/dev/uinput", unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open uinput: %v", err)
+	}
+	ok := false
+	defer func() {
+		if ok {
+			return
+		}
+		unix.Close(fd)
+	}()
+
+	for type_, codes := range spec.codes {
+		if err := unix.IoctlSetInt(fd, uiSetEvBit, int(type_)); err != nil {
+			return nil, fmt.Errorf("failed to enable event type: %v", err)
+		}
+
+		var req uint
+		switch type_ {
+		case evcode.EV_KEY:
+			req = uiSetKeyBit
+		case evcode.EV_REL:
+			req = uiSetRelBit
+		case evcode.EV_ABS:
+			req = uiSetAbsBit
+		case evcode.EV_MSC:
+			req = uiSetMscBit
+		default:
+			continue
+		}
+		for _, code := range codes {
+			if err := unix.IoctlSetInt(fd, req, int(code)); err != nil {
+				return nil, fmt.Errorf("failed to enable event code: %v", err)
+			}
+		}
+	}
+
+	for _, prop := range spec.props {
+		if err := unix.IoctlSetInt(fd, uiSetPropBit, int(prop)); err != nil {
+			return nil, fmt.Errorf("failed to enable property: %v", err)
+		}
+	}
+
+	for code, info := range spec.abs {
+		absSetup := uinputAbsSetup{code: code, minimum: info.min, maximum: info.max, fuzz: info.fuzz, flat: info.flat, resolution: info.resolution}
+		if err := ioctl(fd, uiAbsSetup, unsafe.Pointer(&absSetup)); err != nil {
+			return nil, fmt.Errorf("failed to set up axis: %v", err)
+		}
+	}
+
+	setup := uinputSetup{bustype: spec.bustype, vendor: spec.vendor, product: spec.product}
+	copy(setup.name[:uinputMaxNameSize-1], spec.name)
+	if err := ioctl(fd, uiDevSetup, unsafe.Pointer(&setup)); err != nil {
+		return nil, fmt.Errorf("failed to set up device: %v", err)
+	}
+
+	if err := ioctl(fd, uiDevCreate, nil); err != nil {
+		return nil, fmt.Errorf("failed to create device: %v", err)
+	}
+
+	ok = true
+	return &uinputDevice{fd: fd}, nil
+}
+
+func (d *uinputDevice) write(events []event) error {
+	buf := make([]inputEvent, len(events))
+	for i, event := range events {
+		buf[i] = inputEvent{type_: event.type_, code: event.code, value: event.value}
+	}
+	size := len(buf) * int(unsafe.Sizeof(inputEvent{}))
+	b := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(buf))), size)
+	n, err := unix.Write(d.fd, b)
+	if err == unix.EAGAIN {
+		return &droppedError{err: err}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write events: %v", err)
+	}
+	if n != size {
+		return &droppedError{err: fmt.Errorf("short write: %d of %d bytes", n, size)}
+	}
+	return nil
+}
+
+func (d *uinputDevice) close() {
+	ioctl(d.fd, uiDevDestroy, nil)
+	unix.Close(d.fd)
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
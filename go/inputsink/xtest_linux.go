@@ -0,0 +1,108 @@
+//go:build linux && cgo && xtest
+
+package inputsink
+
+/*
+#cgo pkg-config: x11 xtst
+#include <X11/Xlib.h>
+#include <X11/extensions/XTest.h>
+*/
+import "C"
+
+import (
+	"errors"
+
+	"kafji.net/terong/inputsink/internal/evcode"
+)
+
+// https://www.x.org/releases/current/doc/libXtst/xtestlib.html
+
+// X keycodes under the evdev and libinput drivers are evdev codes shifted
+// by this offset.
+const xKeycodeOffset = 8
+
+type xtestDevice struct {
+	display *C.Display
+	// pending relative motion until the next SYN_REPORT
+	dx, dy C.int
+}
+
+func createXTestDevice() (device, error) {
+	display := C.XOpenDisplay(nil)
+	if display == nil {
+		return nil, errors.New("failed to open display")
+	}
+
+	var eventBase, errorBase, major, minor C.int
+	if C.XTestQueryExtension(display, &eventBase, &errorBase, &major, &minor) == 0 {
+		C.XCloseDisplay(display)
+		return nil, errors.New("XTest extension is not available")
+	}
+
+	return &xtestDevice{display: display}, nil
+}
+
+func (d *xtestDevice) write(events []event) error {
+	for _, event := range events {
+		switch event.type_ {
+		case evcode.EV_REL:
+			switch event.code {
+			case evcode.REL_X:
+				d.dx += C.int(event.value)
+			case evcode.REL_Y:
+				d.dy += C.int(event.value)
+			case evcode.REL_WHEEL:
+				button := C.uint(4)
+				count := event.value
+				if count < 0 {
+					button = 5
+					count = -count
+				}
+				for range count {
+					C.XTestFakeButtonEvent(d.display, button, C.True, C.CurrentTime)
+					C.XTestFakeButtonEvent(d.display, button, C.False, C.CurrentTime)
+				}
+			}
+
+		case evcode.EV_KEY:
+			// XTest has no repeat, a repeat is sent as another press.
+			press := C.Bool(C.False)
+			if event.value != 0 {
+				press = C.True
+			}
+			if button, ok := evKeyToXButton(event.code); ok {
+				C.XTestFakeButtonEvent(d.display, button, press, C.CurrentTime)
+			} else {
+				C.XTestFakeKeyEvent(d.display, C.uint(event.code)+xKeycodeOffset, press, C.CurrentTime)
+			}
+
+		case evcode.EV_SYN:
+			if d.dx != 0 || d.dy != 0 {
+				C.XTestFakeRelativeMotionEvent(d.display, d.dx, d.dy, C.CurrentTime)
+				d.dx, d.dy = 0, 0
+			}
+			C.XFlush(d.display)
+		}
+	}
+	return nil
+}
+
+func (d *xtestDevice) close() {
+	C.XCloseDisplay(d.display)
+}
+
+func evKeyToXButton(code uint16) (C.uint, bool) {
+	switch code {
+	case evcode.BTN_LEFT:
+		return 1, true
+	case evcode.BTN_MIDDLE:
+		return 2, true
+	case evcode.BTN_RIGHT:
+		return 3, true
+	case evcode.BTN_SIDE:
+		return 8, true
+	case evcode.BTN_EXTRA:
+		return 9, true
+	}
+	return 0, false
+}
@@ -0,0 +1,9 @@
+//go:build linux && !(cgo && xtest)
+
+package inputsink
+
+import "errors"
+
+func createXTestDevice() (device, error) {
+	return nil, errors.New("XTest backend requires building with cgo and -tags xtest")
+}
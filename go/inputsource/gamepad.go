@@ -0,0 +1,72 @@
+package inputsource
+
+import "kafji.net/terong/inputevent"
+
+// gamepadState is a snapshot of a controller, laid out as XINPUT_GAMEPAD.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/xinput/ns-xinput-xinput_gamepad
+type gamepadState struct {
+	buttons      uint16
+	leftTrigger  uint8
+	rightTrigger uint8
+	thumbLX      int16
+	thumbLY      int16
+	thumbRX      int16
+	thumbRY      int16
+}
+
+// XINPUT_GAMEPAD button bits
+var gamepadButtonBits = []struct {
+	bit    uint16
+	button inputevent.GamepadButton
+}{
+	{0x0001, inputevent.GamepadButtonDPadUp},
+	{0x0002, inputevent.GamepadButtonDPadDown},
+	{0x0004, inputevent.GamepadButtonDPadLeft},
+	{0x0008, inputevent.GamepadButtonDPadRight},
+	{0x0010, inputevent.GamepadButtonStart},
+	{0x0020, inputevent.GamepadButtonBack},
+	{0x0040, inputevent.GamepadButtonLeftThumb},
+	{0x0080, inputevent.GamepadButtonRightThumb},
+	{0x0100, inputevent.GamepadButtonLeftShoulder},
+	{0x0200, inputevent.GamepadButtonRightShoulder},
+	{0x1000, inputevent.GamepadButtonSouth},
+	{0x2000, inputevent.GamepadButtonEast},
+	{0x4000, inputevent.GamepadButtonWest},
+	{0x8000, inputevent.GamepadButtonNorth},
+}
+
+// gamepadInputs appends the inputs that turn prev into cur.
+func gamepadInputs(inputs []inputevent.InputEvent, prev, cur gamepadState) []inputevent.InputEvent {
+	if changed := prev.buttons ^ cur.buttons; changed != 0 {
+		for _, b := range gamepadButtonBits {
+			if changed&b.bit == 0 {
+				continue
+			}
+			action := inputevent.GamepadButtonActionUp
+			if cur.buttons&b.bit != 0 {
+				action = inputevent.GamepadButtonActionDown
+			}
+			inputs = append(inputs, inputevent.GamepadButtonPress{Button: b.button, Action: action})
+		}
+	}
+
+	axes := []struct {
+		axis      inputevent.GamepadAxis
+		prev, cur int16
+	}{
+		{inputevent.GamepadAxisLeftX, prev.thumbLX, cur.thumbLX},
+		{inputevent.GamepadAxisLeftY, prev.thumbLY, cur.thumbLY},
+		{inputevent.GamepadAxisRightX, prev.thumbRX, cur.thumbRX},
+		{inputevent.GamepadAxisRightY, prev.thumbRY, cur.thumbRY},
+		{inputevent.GamepadAxisLeftTrigger, int16(prev.leftTrigger), int16(cur.leftTrigger)},
+		{inputevent.GamepadAxisRightTrigger, int16(prev.rightTrigger), int16(cur.rightTrigger)},
+	}
+	for _, a := range axes {
+		if a.prev != a.cur {
+			inputs = append(inputs, inputevent.GamepadAxisMove{Axis: a.axis, Value: a.cur})
+		}
+	}
+
+	return inputs
+}
@@ -0,0 +1,21 @@
+package inputsource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestGamepadInputs(t *testing.T) {
+	assert.Empty(t, gamepadInputs(nil, gamepadState{}, gamepadState{}))
+
+	prev := gamepadState{buttons: 0x1000 | 0x0001, thumbLX: 100}
+	cur := gamepadState{buttons: 0x1000 | 0x2000, thumbLX: 100, thumbRY: -32768, rightTrigger: 255}
+	assert.Equal(t, []inputevent.InputEvent{
+		inputevent.GamepadButtonPress{Button: inputevent.GamepadButtonDPadUp, Action: inputevent.GamepadButtonActionUp},
+		inputevent.GamepadButtonPress{Button: inputevent.GamepadButtonEast, Action: inputevent.GamepadButtonActionDown},
+		inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisRightY, Value: -32768},
+		inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisRightTrigger, Value: 255},
+	}, gamepadInputs(nil, prev, cur))
+}
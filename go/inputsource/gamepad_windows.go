@@ -0,0 +1,100 @@
+package inputsource
+
+import (
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"kafji.net/terong/inputevent"
+)
+
+var (
+	xinput = windows.NewLazySystemDLL("xinput1_4.dll")
+
+	procXInputGetState = xinput.NewProc("XInputGetState")
+)
+
+const (
+	// the first controller is relayed
+	gamepadUser = 0
+	// how often a connected controller is polled
+	gamepadPollInterval = 8 * time.Millisecond
+	// XInputGetState is slow for a disconnected controller, so it's polled
+	// less often then
+	gamepadReconnectInterval = time.Second
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/api/xinput/ns-xinput-xinput_state
+type xinputState struct {
+	packetNumber uint32
+	gamepad      gamepadState
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/xinput/nf-xinput-xinputgetstate
+func xinputGetState(user uint32) (xinputState, error) {
+	var state xinputState
+	ret, _, _ := procXInputGetState.Call(uintptr(user), uintptr(unsafe.Pointer(&state)))
+	if ret != 0 {
+		return xinputState{}, windows.Errno(ret)
+	}
+	return state, nil
+}
+
+// pollGamepad sends the inputs of the first XInput controller while inputs
+// are captured, until stop is closed. XInput has no hook, the controller also
+// keeps driving the server.
+func pollGamepad(h *Handle, stop <-chan struct{}) {
+	if err := procXInputGetState.Find(); err != nil {
+		slog.Warn("gamepad relay is unavailable", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(gamepadPollInterval)
+	defer ticker.Stop()
+
+	var prev gamepadState
+	var inputs []inputevent.InputEvent
+	connected := false
+	var lastAttempt time.Time
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if !h.Capturing() {
+			// the client releases everything when relay stops, so the next
+			// capture starts from a neutral controller
+			prev = gamepadState{}
+			continue
+		}
+
+		if !connected && time.Since(lastAttempt) < gamepadReconnectInterval {
+			continue
+		}
+
+		state, err := xinputGetState(gamepadUser)
+		if err != nil {
+			if connected {
+				slog.Info("gamepad disconnected", "error", err)
+			}
+			connected = false
+			lastAttempt = time.Now()
+		} else if !connected {
+			slog.Info("gamepad connected")
+			connected = true
+		}
+
+		inputs = gamepadInputs(inputs[:0], prev, state.gamepad)
+		prev = state.gamepad
+		for _, input := range inputs {
+			select {
+			case h.inputs <- input:
+			default:
+				droppedInputs.Add(inputevent.TypeName(input), 1)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package inputsource
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// The hook procedures run on the message loop thread while it waits in
+// GetMessageW. They must return as fast as possible, so they only copy the
+// event into hookEvents and post a message for the loop to process it.
+
+type hookEvent struct {
+	code      uintptr
+	pt        point
+	mouseData uint32
+	vkCode    uint32
+}
+
+const hookEventsLen = 256
+
+// hookState is shared between the hook procedures and the message loop. It
+// is only accessed from the message loop thread.
+type hookState struct {
+	events [hookEventsLen]hookEvent
+	next   uintptr
+
+	// inputs are not passed on while captured
+	eatMouse    bool
+	eatKeyboard bool
+	// drop the mouse events Windows emulates from the pen, the pen is
+	// relayed on its own
+	ignorePen bool
+
+	mouseProcLatency    latencyHistogram
+	keyboardProcLatency latencyHistogram
+}
+
+var hooks hookState
+
+// Mouse events emulated from pen and touch carry this signature in their extra
+// info.
+//
+// https://learn.microsoft.com/en-us/windows/win32/tablet/system-events-and-mouse-messages
+const (
+	penSignatureMask = 0xFFFFFF00
+	penSignature     = 0xFF515700
+)
+
+var qpcFrequency = sync.OnceValue(queryPerformanceFrequency)
+
+func qpcDuration(ticks int64) time.Duration {
+	return time.Duration(ticks * int64(time.Second) / qpcFrequency())
+}
+
+// Callbacks are created once because their number is limited for the
+// lifetime of the process.
+var (
+	mouseHookProc    = sync.OnceValue(func() uintptr { return windows.NewCallback(mouseHookProcFn) })
+	keyboardHookProc = sync.OnceValue(func() uintptr { return windows.NewCallback(keyboardHookProcFn) })
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/winmsg/lowlevelmouseproc
+func mouseHookProcFn(nCode int32, wParam uintptr, lParam uintptr) uintptr {
+	if nCode < 0 {
+		return callNextHookEx(nCode, wParam, lParam)
+	}
+
+	t0 := queryPerformanceCounter()
+
+	details := *(**msllHookStruct)(unsafe.Pointer(&lParam))
+
+	if hooks.ignorePen && details.dwExtraInfo&penSignatureMask == penSignature {
+		if hooks.eatMouse {
+			return 1
+		}
+		return callNextHookEx(nCode, wParam, lParam)
+	}
+
+	i := hooks.next % hookEventsLen
+	hooks.next++
+	hooks.events[i] = hookEvent{code: wParam, pt: details.pt, mouseData: details.mouseData}
+
+	postMessage(messageCodeHookEvent, whMouseLL, i)
+
+	hooks.mouseProcLatency.record(qpcDuration(queryPerformanceCounter() - t0))
+
+	if hooks.eatMouse {
+		return 1
+	}
+	return callNextHookEx(nCode, wParam, lParam)
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/winmsg/lowlevelkeyboardproc
+func keyboardHookProcFn(nCode int32, wParam uintptr, lParam uintptr) uintptr {
+	if nCode < 0 {
+		return callNextHookEx(nCode, wParam, lParam)
+	}
+
+	t0 := queryPerformanceCounter()
+
+	details := *(**kbdllHookStruct)(unsafe.Pointer(&lParam))
+
+	i := hooks.next % hookEventsLen
+	hooks.next++
+	hooks.events[i] = hookEvent{code: wParam, vkCode: details.vkCode}
+
+	postMessage(messageCodeHookEvent, whKeyboardLL, i)
+
+	hooks.keyboardProcLatency.record(qpcDuration(queryPerformanceCounter() - t0))
+
+	if hooks.eatKeyboard {
+		return 1
+	}
+	return callNextHookEx(nCode, wParam, lParam)
+}
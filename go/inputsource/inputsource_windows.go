@@ -0,0 +1,801 @@
+package inputsource
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+)
+
+var slog = logging.NewLogger("inputsource")
+
+// droppedInputs counts inputs dropped because the inputs channel was full.
+var droppedInputs = metrics.NewCounterMap("inputsource_dropped_inputs")
+
+const (
+	// instrumentation runs once every sampleInterval messages
+	sampleInterval = 128
+	// only one of every debugInputSampleRate inputs is logged at debug level
+	debugInputSampleRate = 64
+	// hook procedures whose 99th percentile latency is above this are
+	// reported
+	hookProcLatencyThreshold = 5 * time.Millisecond
+	// hook procedure latencies are summarized over this period
+	hookProcLatencyPeriod = 10 * time.Second
+)
+
+// HeartbeatInterval is how often the message loop wakes up when idle.
+const HeartbeatInterval = time.Second
+
+// current is the handle reported by the status endpoint.
+var current atomic.Pointer[Handle]
+
+func init() {
+	metrics.PublishFunc("inputsource", func() any {
+		h := current.Load()
+		if h == nil {
+			return nil
+		}
+		return map[string]any{
+			"capturing":        h.Capturing(),
+			"last_message_age": time.Since(h.LastMessageAt()).String(),
+			"stalled":          h.Stalled(),
+		}
+	})
+}
+
+type Config struct {
+	// MouseDeadZone drops mouse moves whose deltas on both axes are below it.
+	MouseDeadZone uint16
+	// RecenterInterval is how often the cursor is moved back to the screen
+	// center while capturing, in case something else moved it. Zero disables
+	// periodic recentering.
+	RecenterInterval time.Duration
+	// SkipRecenterMove drops the first mouse move after the cursor is moved
+	// back to the screen center.
+	SkipRecenterMove bool
+	// Gamepad captures the first XInput controller too.
+	Gamepad bool
+	// Touchpad captures the fingers on precision touchpads too.
+	Touchpad bool
+	// Pen captures pen digitizers too.
+	Pen bool
+	// HighPriority runs the message loop at time critical thread priority,
+	// and the process at high priority class, so hooks are answered in time
+	// under load.
+	HighPriority bool
+}
+
+// Capture is the kinds of inputs captured, see [Handle.SetCapture].
+type Capture uint8
+
+const (
+	CaptureMouse Capture = 1 << iota
+	CaptureKeyboard
+
+	CaptureAll = CaptureMouse | CaptureKeyboard
+)
+
+type Handle struct {
+	cfg Config
+
+	mu       sync.Mutex
+	threadID uint32
+	stopped  bool
+	err      error
+
+	inputs  chan inputevent.InputEvent
+	capture Capture
+
+	// whether anything is captured, for other goroutines
+	capturing atomic.Bool
+	// when the message loop last processed a message, in Unix nanoseconds
+	lastMessageAt atomic.Int64
+	// the last watchdog probe processed by the message loop
+	watchdogAck atomic.Uintptr
+	// whether the watchdog found the message loop blocked
+	stalled atomic.Bool
+}
+
+// Capturing reports whether inputs are currently captured, as applied by the
+// message loop.
+func (h *Handle) Capturing() bool {
+	return h.capturing.Load()
+}
+
+// LastMessageAt is when the message loop last processed a message. The loop
+// wakes up at least every [HeartbeatInterval] while running, an older time
+// means it is stuck or has stopped.
+func (h *Handle) LastMessageAt() time.Time {
+	return time.Unix(0, h.lastMessageAt.Load())
+}
+
+// Stalled reports whether the message loop is blocked, as found by its
+// watchdog.
+func (h *Handle) Stalled() bool {
+	return h.stalled.Load()
+}
+
+// Dropped returns the number of inputs dropped because the inputs channel was
+// full.
+func (h *Handle) Dropped() int64 {
+	return droppedInputs.Total()
+}
+
+func Start(cfg Config) *Handle {
+	h := &Handle{cfg: cfg, inputs: make(chan inputevent.InputEvent, 10_000)}
+	h.lastMessageAt.Store(time.Now().UnixNano())
+	current.Store(h)
+	h.mu.Lock() // lock 'a
+	go func() {
+		defer crash.Recover()
+		runtime.LockOSThread()
+		h.threadID = windows.GetCurrentThreadId()
+		h.mu.Unlock() // unlock 'a
+
+		if cfg.HighPriority {
+			raisePriority()
+		}
+
+		hooks.ignorePen = cfg.Pen
+
+		stopGamepad := make(chan struct{})
+		gamepadDone := make(chan struct{})
+		go func() {
+			defer crash.Recover()
+			defer close(gamepadDone)
+			if cfg.Gamepad {
+				pollGamepad(h, stopGamepad)
+			}
+		}()
+
+		stopTouchpad := func() {}
+		if cfg.Touchpad {
+			stopTouchpad = startTouchpad(h)
+		}
+		stopPen := func() {}
+		if cfg.Pen {
+			stopPen = startPen(h)
+		}
+
+		stopWatchdog := make(chan struct{})
+		watchdogDone := make(chan struct{})
+		go func() {
+			defer crash.Recover()
+			defer close(watchdogDone)
+			watchdog(h, h.threadID, stopWatchdog)
+		}()
+
+		err := run(h)
+		runtime.UnlockOSThread()
+
+		close(stopWatchdog)
+		<-watchdogDone
+
+		// the pollers send to inputs, they must be gone before inputs is closed
+		close(stopGamepad)
+		<-gamepadDone
+		stopTouchpad()
+		stopPen()
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.stopped = true
+		h.err = err
+		close(h.inputs)
+	}()
+	return h
+}
+
+func (h *Handle) Inputs() <-chan inputevent.InputEvent {
+	return h.inputs
+}
+
+func (h *Handle) Error() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+func (h *Handle) Stop() {
+	if h.stopped {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.stopped {
+		return
+	}
+	postThreadMessage(h.threadID, messageCodeControlCommand, controlCommandStop, 0)
+}
+
+func (h *Handle) SetCaptureInputs(flag bool) {
+	if flag {
+		h.SetCapture(CaptureAll)
+	} else {
+		h.SetCapture(0)
+	}
+}
+
+// SetCapture captures the kinds of inputs in c, and releases the others.
+// Captured inputs are relayed instead of reaching the server. Inputs of the
+// other kinds are still sent, except mouse moves.
+func (h *Handle) SetCapture(c Capture) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	postThreadMessage(h.threadID, messageCodeSetCaptureInputs, uintptr(c), 0)
+}
+
+// raisePriority raises the priority of the calling thread and its process.
+// Failing is not fatal, inputs are only captured with more latency under
+// load.
+func raisePriority() {
+	if err := windows.SetPriorityClass(windows.CurrentProcess(), windows.HIGH_PRIORITY_CLASS); err != nil {
+		slog.Warn("failed to raise process priority", "error", err)
+	}
+	if err := setThreadPriority(threadPriorityTimeCritical); err != nil {
+		slog.Warn("failed to raise message loop priority", "error", err)
+		return
+	}
+	slog.Info("message loop runs at time critical priority")
+}
+
+// Check installs the hooks and removes them right away, to tell whether
+// inputs can be captured without starting a source.
+func Check() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var moduleHandle windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &moduleHandle); err != nil {
+		return err
+	}
+	mouseHook, err := setWindowsHookEx(whMouseLL, mouseHookProc(), moduleHandle)
+	if err != nil {
+		return errs.Mark(fmt.Errorf("failed to hook mouse: %v", err), errs.ErrDeviceUnavailable)
+	}
+	unhookWindowsHookEx(mouseHook)
+	keyboardHook, err := setWindowsHookEx(whKeyboardLL, keyboardHookProc(), moduleHandle)
+	if err != nil {
+		return errs.Mark(fmt.Errorf("failed to hook keyboard: %v", err), errs.ErrDeviceUnavailable)
+	}
+	unhookWindowsHookEx(keyboardHook)
+	return nil
+}
+
+func run(handle *Handle) error {
+	var err error
+
+	// https://learn.microsoft.com/en-us/windows/win32/api/libloaderapi/nf-libloaderapi-getmodulehandleexw
+	var moduleHandle windows.Handle
+	err = windows.GetModuleHandleEx(0, nil, &moduleHandle)
+	if err != nil {
+		return err
+	}
+
+	hooks.eatMouse, hooks.eatKeyboard = false, false
+	defer func() {
+		hooks.eatMouse, hooks.eatKeyboard = false, false
+	}()
+
+	// https://learn.microsoft.com/en-us/windows/win32/winmsg/lowlevelmouseproc
+	mouseHook, err := setWindowsHookEx(whMouseLL, mouseHookProc(), moduleHandle)
+	if err != nil {
+		return errs.Mark(err, errs.ErrDeviceUnavailable)
+	}
+	defer unhookWindowsHookEx(mouseHook)
+
+	// https://learn.microsoft.com/en-us/windows/win32/winmsg/lowlevelkeyboardproc
+	keyboardHook, err := setWindowsHookEx(whKeyboardLL, keyboardHookProc(), moduleHandle)
+	if err != nil {
+		return errs.Mark(err, errs.ErrDeviceUnavailable)
+	}
+	defer unhookWindowsHookEx(keyboardHook)
+
+	normalizer := inputevent.Normalizer{}
+
+	screenCenter, err := screenCenter()
+	if err != nil {
+		return err
+	}
+
+	var oldCursorPos *point
+	defer func() {
+		// Leave capture on every exit path, including errors, so the user
+		// gets their input and cursor back.
+		hooks.eatMouse, hooks.eatKeyboard = false, false
+		handle.capture = 0
+		handle.capturing.Store(false)
+		if oldCursorPos != nil {
+			if err := setCursorPos(*oldCursorPos); err != nil {
+				slog.Warn("failed to restore cursor position", "error", err)
+			}
+		}
+	}()
+
+	heartbeatTimer, err := setTimer(uint32(HeartbeatInterval / time.Millisecond))
+	if err != nil {
+		return err
+	}
+	defer killTimer(heartbeatTimer)
+
+	var recenterTimer uintptr
+	defer func() {
+		if recenterTimer != 0 {
+			killTimer(recenterTimer)
+		}
+	}()
+
+	deadZone := int32(handle.cfg.MouseDeadZone)
+	// the next mouse move is dropped, see [Config.SkipRecenterMove]
+	skipMove := false
+	// wheel distances short of a notch, see [wheelAccumulator]
+	wheel := wheelAccumulator{notch: wheelDelta}
+
+	hooks.mouseProcLatency.reset()
+	hooks.keyboardProcLatency.reset()
+	latencyPeriodStart := time.Now()
+
+	// Refreshed on every sample so the level check stays out of the
+	// per-message path.
+	debug := slog.DebugEnabled()
+	var inputCount uint
+
+	// https://learn.microsoft.com/en-us/windows/win32/winmsg/using-messages-and-message-queues
+	for count := uint(1); ; count++ {
+		// Achtung!
+		//
+		// This message loop must never be blocked.
+		//
+		// When this loop get blocked the user's input will get incredibly choppy.
+		//
+		// Past cases where this message loop get blocked were:
+		//
+		// 1. Sending to unbuffered channel.
+		// 2. Writing to stdio + QuickEdit.
+		//
+		// The watchdog reports when it happens again.
+
+		// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-getmessagew
+		var msg winMsg
+		ret, err := getMessage(&msg)
+		if ret < 0 {
+			return err
+		}
+		if ret == 0 {
+			return nil
+		}
+
+		handle.lastMessageAt.Store(time.Now().UnixNano())
+
+		// sample every hundred or so messages
+		if count%sampleInterval == 0 {
+			debug = slog.DebugEnabled()
+
+			if time.Since(latencyPeriodStart) >= hookProcLatencyPeriod {
+				if hooks.mouseProcLatency.percentile(0.99) > hookProcLatencyThreshold {
+					slog.Warn("mouse hook proc latency is high", "latency", &hooks.mouseProcLatency)
+				}
+				if hooks.keyboardProcLatency.percentile(0.99) > hookProcLatencyThreshold {
+					slog.Warn("keyboard hook proc latency is high", "latency", &hooks.keyboardProcLatency)
+				}
+				if debug {
+					slog.Debug("hook proc latencies", "mouse", &hooks.mouseProcLatency, "keyboard", &hooks.keyboardProcLatency)
+				}
+				hooks.mouseProcLatency.reset()
+				hooks.keyboardProcLatency.reset()
+				latencyPeriodStart = time.Now()
+			}
+		}
+
+		switch msg.message {
+		case messageCodeHookEvent:
+			hookEvent := hooks.events[msg.lParam%hookEventsLen]
+			var input inputevent.InputEvent
+			switch msg.wParam {
+			case whMouseLL:
+				switch hookEvent.code {
+				case wmMouseMove:
+					if handle.capture&CaptureMouse == 0 {
+						continue
+					}
+					if skipMove {
+						skipMove = false
+						continue
+					}
+					dx := hookEvent.pt.x - screenCenter.x
+					dy := -(hookEvent.pt.y - screenCenter.y)
+					if abs(dx) < deadZone && abs(dy) < deadZone {
+						continue
+					}
+					input = inputevent.MouseMove{DX: int16(dx), DY: int16(dy)}
+
+				case wmLButtonDown:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionDown}
+
+				case wmLButtonUp:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionUp}
+
+				case wmRButtonDown:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonRight, Action: inputevent.MouseButtonActionDown}
+
+				case wmRButtonUp:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonRight, Action: inputevent.MouseButtonActionUp}
+
+				case wmMButtonDown:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonMiddle, Action: inputevent.MouseButtonActionDown}
+
+				case wmMButtonUp:
+					input = inputevent.MouseClick{Button: inputevent.MouseButtonMiddle, Action: inputevent.MouseButtonActionUp}
+
+				case wmXButtonDown:
+					button := xbuttonToMouseButton(uint16(hookEvent.mouseData >> 16))
+					if button != 0 {
+						input = inputevent.MouseClick{Button: button, Action: inputevent.MouseButtonActionDown}
+					}
+
+				case wmXButtonUp:
+					button := xbuttonToMouseButton(uint16(hookEvent.mouseData >> 16))
+					if button != 0 {
+						input = inputevent.MouseClick{Button: button, Action: inputevent.MouseButtonActionUp}
+					}
+
+				case wmMouseWheel:
+					distance := int16(hookEvent.mouseData >> 16)
+					if scroll, ok := wheel.add(int(distance)); ok {
+						input = scroll
+					}
+				}
+
+			case whKeyboardLL:
+				switch hookEvent.code {
+				case wmKeyDown:
+					fallthrough
+				case wmSysKeyDown:
+					key := keyCodeToVirtualKey(hookEvent.vkCode)
+					input = inputevent.KeyPress{Key: key, Action: inputevent.KeyActionDown}
+
+				case wmKeyUp:
+					fallthrough
+				case wmSysKeyUp:
+					key := keyCodeToVirtualKey(hookEvent.vkCode)
+					input = inputevent.KeyPress{Key: key, Action: inputevent.KeyActionUp}
+				}
+			}
+
+			if input != nil {
+				input = normalizer.Normalize(input)
+				inputCount++
+				if debug && inputCount%debugInputSampleRate == 0 {
+					slog.Debug("sending input", "input", input, "count", inputCount)
+				}
+				select {
+				case handle.inputs <- input:
+				default:
+					// logging here may block this loop, drops are logged
+					// periodically by metrics instead
+					droppedInputs.Add(inputevent.TypeName(input), 1)
+				}
+			}
+
+		case messageCodeWatchdogProbe:
+			handle.watchdogAck.Store(msg.wParam)
+
+		case messageCodeControlCommand:
+			switch msg.wParam {
+			case controlCommandStop:
+				handle.mu.Lock()
+				handle.stopped = true
+				handle.mu.Unlock()
+				return nil
+			}
+
+		case messageCodeSetCaptureInputs:
+			was := handle.capture
+			handle.capture = Capture(msg.wParam)
+			handle.capturing.Store(handle.capture != 0)
+			hooks.eatMouse = handle.capture&CaptureMouse != 0
+			hooks.eatKeyboard = handle.capture&CaptureKeyboard != 0
+			if handle.capture&CaptureMouse == was&CaptureMouse {
+				continue
+			}
+			wheel.reset()
+			if handle.capture&CaptureMouse != 0 {
+				// capture current mouse position
+				pos, err := getCursorPos()
+				if err != nil {
+					return err
+				}
+				oldCursorPos = &pos
+				// set mouse position to center of screen
+				if err := setCursorPos(screenCenter); err != nil {
+					return err
+				}
+				skipMove = handle.cfg.SkipRecenterMove
+				if handle.cfg.RecenterInterval > 0 && recenterTimer == 0 {
+					// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-settimer
+					recenterTimer, err = setTimer(uint32(handle.cfg.RecenterInterval / time.Millisecond))
+					if err != nil {
+						return err
+					}
+				}
+			} else {
+				if recenterTimer != 0 {
+					killTimer(recenterTimer)
+					recenterTimer = 0
+				}
+				if oldCursorPos != nil {
+					// restore previous mouse position
+					if err := setCursorPos(*oldCursorPos); err != nil {
+						return err
+					}
+					oldCursorPos = nil
+				}
+			}
+
+		case wmTimer:
+			if msg.wParam != recenterTimer || handle.capture&CaptureMouse == 0 {
+				continue
+			}
+			if err := setCursorPos(screenCenter); err != nil {
+				return err
+			}
+			skipMove = handle.cfg.SkipRecenterMove
+		} // switch
+	} // for
+}
+
+func abs(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func screenSize() (point, error) {
+	rect := windows.Rect{}
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/nf-winuser-systemparametersinfow
+	ret, _, err := procSystemParametersInfoW.Call(spiGetWorkArea, 0, uintptr(unsafe.Pointer(&rect)), 0)
+	if ret == 0 {
+		return point{}, err
+	}
+	return point{x: rect.Right - rect.Left, y: rect.Bottom - rect.Top}, nil
+}
+
+func screenCenter() (point, error) {
+	screen, err := screenSize()
+	if err != nil {
+		return point{}, err
+	}
+	return point{x: screen.x / 2, y: screen.y / 2}, nil
+}
+
+func xbuttonToMouseButton(xbutton uint16) inputevent.MouseButton {
+	var button inputevent.MouseButton
+	switch xbutton {
+	case xbutton1:
+		button = inputevent.MouseButtonMouse4
+	case xbutton2:
+		button = inputevent.MouseButtonMouse5
+	}
+	return button
+}
+
+// keyCodeToVirtualKey converts Windows virtual key codes as defined in https://docs.microsoft.com/en-us/windows/win32/inputdev/virtual-key-codes to [inputevent.KeyCode].
+func keyCodeToVirtualKey(virtualKey uint32) inputevent.KeyCode {
+
+	// todo(kfj): codegen?
+
+	switch virtualKey {
+	case vkEscape:
+		return inputevent.Escape
+
+	case vkF1:
+		return inputevent.F1
+	case vkF2:
+		return inputevent.F2
+	case vkF3:
+		return inputevent.F3
+	case vkF4:
+		return inputevent.F4
+	case vkF5:
+		return inputevent.F5
+	case vkF6:
+		return inputevent.F6
+	case vkF7:
+		return inputevent.F7
+	case vkF8:
+		return inputevent.F8
+	case vkF9:
+		return inputevent.F9
+	case vkF10:
+		return inputevent.F10
+	case vkF11:
+		return inputevent.F11
+	case vkF12:
+		return inputevent.F12
+
+	case vkSnapshot:
+		return inputevent.PrintScreen
+	case vkScroll:
+		return inputevent.ScrollLock
+	case vkPause:
+		return inputevent.PauseBreak
+
+	case vkOEM3:
+		return inputevent.Grave
+
+	case 0x31:
+		return inputevent.D1
+	case 0x32:
+		return inputevent.D2
+	case 0x33:
+		return inputevent.D3
+	case 0x34:
+		return inputevent.D4
+	case 0x35:
+		return inputevent.D5
+	case 0x36:
+		return inputevent.D6
+	case 0x37:
+		return inputevent.D7
+	case 0x38:
+		return inputevent.D8
+	case 0x39:
+		return inputevent.D9
+	case 0x30:
+		return inputevent.D0
+
+	case vkOEMMinus:
+		return inputevent.Minus
+	case vkOEMPlus:
+		return inputevent.Equal
+
+	case 0x41:
+		return inputevent.A
+	case 0x42:
+		return inputevent.B
+	case 0x43:
+		return inputevent.C
+	case 0x44:
+		return inputevent.D
+	case 0x45:
+		return inputevent.E
+	case 0x46:
+		return inputevent.F
+	case 0x47:
+		return inputevent.G
+	case 0x48:
+		return inputevent.H
+	case 0x49:
+		return inputevent.I
+	case 0x4A:
+		return inputevent.J
+	case 0x4B:
+		return inputevent.K
+	case 0x4C:
+		return inputevent.L
+	case 0x4D:
+		return inputevent.M
+	case 0x4E:
+		return inputevent.N
+	case 0x4F:
+		return inputevent.O
+	case 0x50:
+		return inputevent.P
+	case 0x51:
+		return inputevent.Q
+	case 0x52:
+		return inputevent.R
+	case 0x53:
+		return inputevent.S
+	case 0x54:
+		return inputevent.T
+	case 0x55:
+		return inputevent.U
+	case 0x56:
+		return inputevent.V
+	case 0x57:
+		return inputevent.W
+	case 0x58:
+		return inputevent.X
+	case 0x59:
+		return inputevent.Y
+	case 0x5A:
+		return inputevent.Z
+
+	case vkOEM4:
+		return inputevent.LeftBrace
+	case vkOEM6:
+		return inputevent.RightBrace
+
+	case vkOEM1:
+		return inputevent.SemiColon
+	case vkOEM7:
+		return inputevent.Apostrophe
+
+	case vkOEMComma:
+		return inputevent.Comma
+	case vkOEMPeriod:
+		return inputevent.Dot
+	case vkOEM2:
+		return inputevent.Slash
+
+	case vkBack:
+		return inputevent.Backspace
+	case vkOEM5:
+		return inputevent.BackSlash
+	case vkReturn:
+		return inputevent.Enter
+
+	case vkSpace:
+		return inputevent.Space
+
+	case vkTab:
+		return inputevent.Tab
+	case vkCapital:
+		return inputevent.CapsLock
+
+	case vkLShift:
+		return inputevent.LeftShift
+	case vkRShift:
+		return inputevent.RightShift
+
+	case vkLControl:
+		return inputevent.LeftCtrl
+	case vkRControl:
+		return inputevent.RightCtrl
+
+	case vkLMenu:
+		return inputevent.LeftAlt
+	case vkRMenu:
+		return inputevent.RightAlt
+
+	case vkLWin:
+		return inputevent.LeftMeta
+	case vkRWin:
+		return inputevent.RightMeta
+
+	case vkInsert:
+		return inputevent.Insert
+	case vkDelete:
+		return inputevent.Delete
+
+	case vkHome:
+		return inputevent.Home
+	case vkEnd:
+		return inputevent.End
+
+	case vkPrior:
+		return inputevent.PageUp
+	case vkNext:
+		return inputevent.PageDown
+
+	case vkUp:
+		return inputevent.Up
+	case vkLeft:
+		return inputevent.Left
+	case vkDown:
+		return inputevent.Down
+	case vkRight:
+		return inputevent.Right
+
+	case vkNumLock:
+		return inputevent.NumLock
+	case vkApps:
+		return inputevent.Menu
+	case vkSleep:
+		return inputevent.Sleep
+	}
+
+	return 0
+}
@@ -0,0 +1,77 @@
+package inputsource
+
+import (
+	// aliased, slog is the package logger
+	stdslog "log/slog"
+	"math/bits"
+	"time"
+)
+
+// latencyBuckets is the number of histogram buckets. Bucket i counts
+// durations below 2^i microseconds, the last one counts everything else.
+const latencyBuckets = 20
+
+// latencyHistogram summarizes hook procedure durations.
+type latencyHistogram struct {
+	count   uint64
+	sum     time.Duration
+	worst   time.Duration
+	buckets [latencyBuckets]uint64
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.count++
+	h.sum += d
+	if d > h.worst {
+		h.worst = d
+	}
+	i := bits.Len64(uint64(d / time.Microsecond))
+	if i >= latencyBuckets {
+		i = latencyBuckets - 1
+	}
+	h.buckets[i]++
+}
+
+func (h *latencyHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// percentile returns the upper bound of the bucket containing the p-th
+// percentile, with p in (0, 1].
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(p * float64(h.count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.buckets {
+		seen += n
+		if seen >= rank {
+			if i == latencyBuckets-1 {
+				return h.worst
+			}
+			return time.Duration(1<<i) * time.Microsecond
+		}
+	}
+	return h.worst
+}
+
+func (h *latencyHistogram) reset() {
+	*h = latencyHistogram{}
+}
+
+func (h *latencyHistogram) LogValue() stdslog.Value {
+	return stdslog.GroupValue(
+		stdslog.Uint64("count", h.count),
+		stdslog.Duration("mean", h.mean()),
+		stdslog.Duration("p50", h.percentile(0.50)),
+		stdslog.Duration("p99", h.percentile(0.99)),
+		stdslog.Duration("worst", h.worst),
+	)
+}
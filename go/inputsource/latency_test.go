@@ -0,0 +1,30 @@
+package inputsource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	assert.Equal(t, time.Duration(0), h.mean())
+	assert.Equal(t, time.Duration(0), h.percentile(0.99))
+
+	for range 98 {
+		h.record(10 * time.Microsecond)
+	}
+	h.record(3 * time.Millisecond)
+	h.record(2 * time.Second)
+
+	assert.Equal(t, uint64(100), h.count)
+	assert.Equal(t, 2*time.Second, h.worst)
+	assert.Equal(t, 16*time.Microsecond, h.percentile(0.5))
+	assert.Equal(t, 4096*time.Microsecond, h.percentile(0.99))
+	assert.Equal(t, 2*time.Second, h.percentile(1))
+	assert.Equal(t, (98*10*time.Microsecond+3*time.Millisecond+2*time.Second)/100, h.mean())
+
+	h.reset()
+	assert.Equal(t, uint64(0), h.count)
+}
@@ -0,0 +1,32 @@
+package inputsource
+
+import "kafji.net/terong/inputevent"
+
+// signExtend reads the low bits of v as a two's complement number.
+func signExtend(v uint32, bits uint16) int32 {
+	if bits == 0 || bits >= 32 {
+		return int32(v)
+	}
+	shift := 32 - bits
+	return int32(v<<shift) >> shift
+}
+
+// scalePenPressure scales v in the logical range of the pressure to 0 to
+// [inputevent.MaxPenPressure].
+func scalePenPressure(v int32, logicalMin, logicalMax int32) uint16 {
+	if logicalMax <= logicalMin {
+		return 0
+	}
+	v = max(min(v, logicalMax), logicalMin)
+	return uint16(int64(v-logicalMin) * inputevent.MaxPenPressure / int64(logicalMax-logicalMin))
+}
+
+// scalePenTilt scales v in the logical range of a tilt to degrees. Pens
+// report their tilt over -90 to 90 degrees.
+func scalePenTilt(v int32, logicalMin, logicalMax int32) int8 {
+	if logicalMax <= logicalMin {
+		return 0
+	}
+	v = max(min(v, logicalMax), logicalMin)
+	return int8(int64(v-logicalMin)*180/int64(logicalMax-logicalMin) - 90)
+}
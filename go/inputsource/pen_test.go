@@ -0,0 +1,28 @@
+package inputsource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestSignExtend(t *testing.T) {
+	assert.Equal(t, int32(-1), signExtend(0xffff, 16))
+	assert.Equal(t, int32(0x7fff), signExtend(0x7fff, 16))
+	assert.Equal(t, int32(-9000), signExtend(0xdcd8, 16))
+	assert.Equal(t, int32(5), signExtend(5, 0))
+}
+
+func TestScalePenPressure(t *testing.T) {
+	assert.Equal(t, uint16(0), scalePenPressure(0, 0, 4095))
+	assert.Equal(t, uint16(inputevent.MaxPenPressure), scalePenPressure(4095, 0, 4095))
+	assert.Equal(t, uint16(inputevent.MaxPenPressure), scalePenPressure(5000, 0, 4095))
+}
+
+func TestScalePenTilt(t *testing.T) {
+	assert.Equal(t, int8(-90), scalePenTilt(-9000, -9000, 9000))
+	assert.Equal(t, int8(0), scalePenTilt(0, -9000, 9000))
+	assert.Equal(t, int8(90), scalePenTilt(9000, -9000, 9000))
+	assert.Equal(t, int8(45), scalePenTilt(4500, -9000, 9000))
+}
@@ -0,0 +1,119 @@
+package inputsource
+
+import (
+	"errors"
+	"slices"
+
+	"kafji.net/terong/inputevent"
+)
+
+const (
+	usagePen          = 0x02
+	usageTipPressure  = 0x30
+	usageInRange      = 0x32
+	usageInvert       = 0x3C
+	usageXTilt        = 0x3D
+	usageYTilt        = 0x3E
+	usageBarrelSwitch = 0x44
+	usageEraser       = 0x45
+)
+
+// penReader reads pen digitizers. WM_POINTER only reaches the window under
+// the pen, raw input reaches the server wherever the pen is.
+type penReader struct {
+	layouts map[*hidDevice]*penLayout
+	last    inputevent.PenState
+	buttons []uint16
+}
+
+// penLayout is the values of a pen, which are in the link collection of the
+// stylus.
+type penLayout struct {
+	link     uint16
+	x        hidpValueCaps
+	y        hidpValueCaps
+	pressure hidpValueCaps
+	tiltX    hidpValueCaps
+	tiltY    hidpValueCaps
+	// the pen reports no pressure or tilt
+	noPressure bool
+	noTilt     bool
+}
+
+func newPenReader() *penReader {
+	return &penReader{layouts: make(map[*hidDevice]*penLayout), buttons: make([]uint16, 0, 16)}
+}
+
+func loadPenLayout(d *hidDevice) (*penLayout, error) {
+	x, okX := d.value(usagePageGeneric, usageX)
+	y, okY := d.value(usagePageGeneric, usageY)
+	if !okX || !okY {
+		return nil, errors.New("not a pen")
+	}
+	l := &penLayout{link: x.linkCollection, x: x, y: y}
+	var ok bool
+	l.pressure, ok = d.value(usagePageDigitizer, usageTipPressure)
+	l.noPressure = !ok
+	var okTiltX, okTiltY bool
+	l.tiltX, okTiltX = d.value(usagePageDigitizer, usageXTilt)
+	l.tiltY, okTiltY = d.value(usagePageDigitizer, usageYTilt)
+	l.noTilt = !okTiltX || !okTiltY
+	return l, nil
+}
+
+func (r *penReader) read(inputs []inputevent.InputEvent, d *hidDevice, report []byte) ([]inputevent.InputEvent, error) {
+	l, ok := r.layouts[d]
+	if !ok {
+		var err error
+		l, err = loadPenLayout(d)
+		if err != nil {
+			return inputs, err
+		}
+		r.layouts[d] = l
+	}
+
+	r.buttons = d.usages(usagePageDigitizer, l.link, report, r.buttons)
+
+	var state inputevent.PenState
+	if slices.Contains(r.buttons, usageInRange) {
+		x, _ := d.usageValue(usagePageGeneric, l.link, usageX, report)
+		y, _ := d.usageValue(usagePageGeneric, l.link, usageY, report)
+		state = inputevent.PenState{
+			X:       scaleTouchAxis(int32(x), l.x.logicalMin, l.x.logicalMax),
+			Y:       scaleTouchAxis(int32(y), l.y.logicalMin, l.y.logicalMax),
+			InRange: true,
+			// the eraser switch is the tip of the eraser end
+			Tip:    slices.Contains(r.buttons, usageTipSwitch) || slices.Contains(r.buttons, usageEraser),
+			Barrel: slices.Contains(r.buttons, usageBarrelSwitch),
+			Eraser: slices.Contains(r.buttons, usageInvert) || slices.Contains(r.buttons, usageEraser),
+		}
+		if !l.noPressure {
+			v, _ := d.usageValue(usagePageDigitizer, l.link, usageTipPressure, report)
+			state.Pressure = scalePenPressure(signExtend(v, l.pressure.bitSize), l.pressure.logicalMin, l.pressure.logicalMax)
+		} else if state.Tip {
+			state.Pressure = inputevent.MaxPenPressure
+		}
+		if !l.noTilt {
+			tx, _ := d.usageValue(usagePageDigitizer, l.link, usageXTilt, report)
+			ty, _ := d.usageValue(usagePageDigitizer, l.link, usageYTilt, report)
+			state.TiltX = scalePenTilt(signExtend(tx, l.tiltX.bitSize), l.tiltX.logicalMin, l.tiltX.logicalMax)
+			state.TiltY = scalePenTilt(signExtend(ty, l.tiltY.bitSize), l.tiltY.logicalMin, l.tiltY.logicalMax)
+		}
+	}
+
+	// digitizers keep reporting a pen that holds still
+	if state == r.last {
+		return inputs, nil
+	}
+	r.last = state
+	return append(inputs, state), nil
+}
+
+func (r *penReader) reset() {
+	r.last = inputevent.PenState{}
+}
+
+// startPen sends the inputs of pen digitizers.
+func startPen(h *Handle) (stop func()) {
+	return startRawInput(h, "pen", usagePageDigitizer, usagePen, newPenReader())
+}
@@ -0,0 +1,398 @@
+package inputsource
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/inputevent"
+)
+
+var (
+	hid = windows.NewLazySystemDLL("hid.dll")
+
+	procHidPGetCaps       = hid.NewProc("HidP_GetCaps")
+	procHidPGetValueCaps  = hid.NewProc("HidP_GetValueCaps")
+	procHidPGetUsageValue = hid.NewProc("HidP_GetUsageValue")
+	procHidPGetUsages     = hid.NewProc("HidP_GetUsages")
+
+	procRegisterClassExW        = user32.NewProc("RegisterClassExW")
+	procCreateWindowExW         = user32.NewProc("CreateWindowExW")
+	procDestroyWindow           = user32.NewProc("DestroyWindow")
+	procDefWindowProcW          = user32.NewProc("DefWindowProcW")
+	procDispatchMessageW        = user32.NewProc("DispatchMessageW")
+	procRegisterRawInputDevices = user32.NewProc("RegisterRawInputDevices")
+	procGetRawInputData         = user32.NewProc("GetRawInputData")
+	procGetRawInputDeviceInfoW  = user32.NewProc("GetRawInputDeviceInfoW")
+)
+
+const (
+	wmQuit  = 0x0012
+	wmInput = 0x00FF
+
+	// HWND_MESSAGE
+	hwndMessage = ^uintptr(2)
+
+	ridevInputSink    = 0x00000100
+	ridInput          = 0x10000003
+	ridiPreparsedData = 0x20000005
+	rimTypeHID        = 2
+
+	errorClassAlreadyExists = windows.Errno(1410)
+
+	hidpInput         = 0
+	hidpStatusSuccess = 0x00110000
+
+	// https://www.usb.org/sites/default/files/hut1_4.pdf
+	usagePageGeneric   = 0x01
+	usagePageDigitizer = 0x0D
+	usageX             = 0x30
+	usageY             = 0x31
+)
+
+const rawInputWindowClass = "TerongRawInput"
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-wndclassexw
+type wndClassEx struct {
+	size       uint32
+	style      uint32
+	wndProc    uintptr
+	clsExtra   int32
+	wndExtra   int32
+	instance   uintptr
+	icon       uintptr
+	cursor     uintptr
+	background uintptr
+	menuName   *uint16
+	className  *uint16
+	iconSm     uintptr
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-rawinputdevice
+type rawInputDevice struct {
+	usagePage uint16
+	usage     uint16
+	flags     uint32
+	target    uintptr
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-rawinputheader
+type rawInputHeader struct {
+	type_  uint32
+	size   uint32
+	device uintptr
+	wParam uintptr
+}
+
+// https://learn.microsoft.com/en-us/windows-hardware/drivers/ddi/hidpi/ns-hidpi-_hidp_caps
+type hidpCaps struct {
+	usage                     uint16
+	usagePage                 uint16
+	inputReportByteLength     uint16
+	outputReportByteLength    uint16
+	featureReportByteLength   uint16
+	reserved                  [17]uint16
+	numberLinkCollectionNodes uint16
+	numberInputButtonCaps     uint16
+	numberInputValueCaps      uint16
+	numberInputDataIndices    uint16
+	numberOutputButtonCaps    uint16
+	numberOutputValueCaps     uint16
+	numberOutputDataIndices   uint16
+	numberFeatureButtonCaps   uint16
+	numberFeatureValueCaps    uint16
+	numberFeatureDataIndices  uint16
+}
+
+// https://learn.microsoft.com/en-us/windows-hardware/drivers/ddi/hidpi/ns-hidpi-_hidp_value_caps
+type hidpValueCaps struct {
+	usagePage         uint16
+	reportID          uint8
+	isAlias           uint8
+	bitField          uint16
+	linkCollection    uint16
+	linkUsage         uint16
+	linkUsagePage     uint16
+	isRange           uint8
+	isStringRange     uint8
+	isDesignatorRange uint8
+	isAbsolute        uint8
+	hasNull           uint8
+	reserved          uint8
+	bitSize           uint16
+	reportCount       uint16
+	reserved2         [5]uint16
+	unitsExp          uint32
+	units             uint32
+	logicalMin        int32
+	logicalMax        int32
+	physicalMin       int32
+	physicalMax       int32
+	// usage, or usageMin when isRange
+	usage uint16
+	_     [7]uint16
+}
+
+// hidDevice is a HID device read through raw input.
+type hidDevice struct {
+	preparsed []byte
+	// input values that are not ranges
+	values []hidpValueCaps
+}
+
+func loadHIDDevice(device uintptr) (*hidDevice, error) {
+	var size uint32
+	procGetRawInputDeviceInfoW.Call(device, ridiPreparsedData, 0, uintptr(unsafe.Pointer(&size)))
+	if size == 0 {
+		return nil, errors.New("no preparsed data")
+	}
+	preparsed := make([]byte, size)
+	ret, _, err := procGetRawInputDeviceInfoW.Call(device, ridiPreparsedData, uintptr(unsafe.Pointer(&preparsed[0])), uintptr(unsafe.Pointer(&size)))
+	if int32(ret) < 0 {
+		return nil, fmt.Errorf("failed to get preparsed data: %v", err)
+	}
+
+	var caps hidpCaps
+	status, _, _ := procHidPGetCaps.Call(uintptr(unsafe.Pointer(&preparsed[0])), uintptr(unsafe.Pointer(&caps)))
+	if status != hidpStatusSuccess {
+		return nil, fmt.Errorf("failed to get caps: status %#x", status)
+	}
+	if caps.numberInputValueCaps == 0 {
+		return nil, errors.New("no input values")
+	}
+
+	n := caps.numberInputValueCaps
+	valueCaps := make([]hidpValueCaps, n)
+	status, _, _ = procHidPGetValueCaps.Call(hidpInput, uintptr(unsafe.Pointer(&valueCaps[0])), uintptr(unsafe.Pointer(&n)), uintptr(unsafe.Pointer(&preparsed[0])))
+	if status != hidpStatusSuccess {
+		return nil, fmt.Errorf("failed to get value caps: status %#x", status)
+	}
+
+	d := &hidDevice{preparsed: preparsed}
+	for _, vc := range valueCaps[:n] {
+		if vc.isRange == 0 {
+			d.values = append(d.values, vc)
+		}
+	}
+	return d, nil
+}
+
+// value returns the caps of a value in any link collection.
+func (d *hidDevice) value(page uint16, usage uint16) (hidpValueCaps, bool) {
+	for _, vc := range d.values {
+		if vc.usagePage == page && vc.usage == usage {
+			return vc, true
+		}
+	}
+	return hidpValueCaps{}, false
+}
+
+func (d *hidDevice) usageValue(page uint16, link uint16, usage uint16, report []byte) (uint32, bool) {
+	var v uint32
+	status, _, _ := procHidPGetUsageValue.Call(
+		hidpInput,
+		uintptr(page),
+		uintptr(link),
+		uintptr(usage),
+		uintptr(unsafe.Pointer(&v)),
+		uintptr(unsafe.Pointer(&d.preparsed[0])),
+		uintptr(unsafe.Pointer(&report[0])),
+		uintptr(len(report)),
+	)
+	return v, status == hidpStatusSuccess
+}
+
+// usages returns the buttons that are down.
+func (d *hidDevice) usages(page uint16, link uint16, report []byte, usages []uint16) []uint16 {
+	n := uint32(cap(usages))
+	if n == 0 {
+		return usages
+	}
+	usages = usages[:n]
+	status, _, _ := procHidPGetUsages.Call(
+		hidpInput,
+		uintptr(page),
+		uintptr(link),
+		uintptr(unsafe.Pointer(&usages[0])),
+		uintptr(unsafe.Pointer(&n)),
+		uintptr(unsafe.Pointer(&d.preparsed[0])),
+		uintptr(unsafe.Pointer(&report[0])),
+		uintptr(len(report)),
+	)
+	if status != hidpStatusSuccess {
+		return usages[:0]
+	}
+	return usages[:n]
+}
+
+// hidReader turns the reports of a kind of HID device into inputs.
+type hidReader interface {
+	// read appends the inputs of a report. A device it fails on is ignored
+	// from then on.
+	read(inputs []inputevent.InputEvent, device *hidDevice, report []byte) ([]inputevent.InputEvent, error)
+	// reset drops the state carried between reports, reports are not read
+	// while inputs are not captured.
+	reset()
+}
+
+func createMessageWindow() (uintptr, error) {
+	className, err := windows.UTF16PtrFromString(rawInputWindowClass)
+	if err != nil {
+		return 0, err
+	}
+	var instance windows.Handle
+	if err := windows.GetModuleHandleEx(0, nil, &instance); err != nil {
+		return 0, err
+	}
+
+	wc := wndClassEx{wndProc: procDefWindowProcW.Addr(), instance: uintptr(instance), className: className}
+	wc.size = uint32(unsafe.Sizeof(wc))
+	if ret, _, err := procRegisterClassExW.Call(uintptr(unsafe.Pointer(&wc))); ret == 0 && err != errorClassAlreadyExists {
+		return 0, fmt.Errorf("failed to register window class: %v", err)
+	}
+
+	hwnd, _, err := procCreateWindowExW.Call(0, uintptr(unsafe.Pointer(className)), 0, 0, 0, 0, 0, 0, hwndMessage, 0, uintptr(instance), 0)
+	if hwnd == 0 {
+		return 0, fmt.Errorf("failed to create window: %v", err)
+	}
+	return hwnd, nil
+}
+
+// startRawInput sends the inputs read from HID devices of a top-level
+// collection while inputs are captured, until the returned stop is called.
+// Raw input is delivered to a window, so it runs on its own thread. The
+// devices keep driving the server.
+func startRawInput(h *Handle, name string, usagePage uint16, usage uint16, reader hidReader) (stop func()) {
+	threadID := make(chan uint32, 1)
+	done := make(chan struct{})
+	go func() {
+		defer crash.Recover()
+		defer close(done)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if err := runRawInput(h, usagePage, usage, reader, threadID); err != nil {
+			slog.Warn("relay is unavailable", "device", name, "error", err)
+		}
+	}()
+
+	return func() {
+		select {
+		case id := <-threadID:
+			postThreadMessage(id, wmQuit, 0, 0)
+		case <-done:
+		}
+		<-done
+	}
+}
+
+// runRawInput reports the thread ID once its message queue exists.
+func runRawInput(h *Handle, usagePage uint16, usage uint16, reader hidReader, threadID chan<- uint32) error {
+	if err := hid.Load(); err != nil {
+		return err
+	}
+
+	hwnd, err := createMessageWindow()
+	if err != nil {
+		return err
+	}
+	defer procDestroyWindow.Call(hwnd)
+
+	rid := rawInputDevice{usagePage: usagePage, usage: usage, flags: ridevInputSink, target: hwnd}
+	if ret, _, err := procRegisterRawInputDevices.Call(uintptr(unsafe.Pointer(&rid)), 1, unsafe.Sizeof(rid)); ret == 0 {
+		return fmt.Errorf("failed to register raw input device: %v", err)
+	}
+
+	threadID <- windows.GetCurrentThreadId()
+
+	// nil for ignored devices, so they are not loaded again
+	devices := make(map[uintptr]*hidDevice)
+	var inputs []inputevent.InputEvent
+
+	for {
+		var msg winMsg
+		ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(&msg)), 0, 0, 0)
+		if int32(ret) < 0 {
+			return fmt.Errorf("failed to get message: %v", err)
+		}
+		if ret == 0 {
+			return nil
+		}
+
+		if msg.message == wmInput {
+			if h.Capturing() {
+				inputs = readRawInput(inputs[:0], msg.lParam, devices, reader)
+				for _, input := range inputs {
+					select {
+					case h.inputs <- input:
+					default:
+						droppedInputs.Add(inputevent.TypeName(input), 1)
+					}
+				}
+			} else {
+				// the client releases everything when relay stops
+				reader.reset()
+			}
+		}
+
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&msg)))
+	}
+}
+
+// readRawInput appends the inputs of a WM_INPUT message. Devices are loaded on
+// their first report.
+func readRawInput(inputs []inputevent.InputEvent, rawInput uintptr, devices map[uintptr]*hidDevice, reader hidReader) []inputevent.InputEvent {
+	headerSize := unsafe.Sizeof(rawInputHeader{})
+
+	var size uint32
+	procGetRawInputData.Call(rawInput, ridInput, 0, uintptr(unsafe.Pointer(&size)), headerSize)
+	if size < uint32(headerSize)+8 {
+		return inputs
+	}
+	buf := make([]byte, size)
+	ret, _, _ := procGetRawInputData.Call(rawInput, ridInput, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), headerSize)
+	if int32(ret) <= 0 {
+		return inputs
+	}
+
+	header := (*rawInputHeader)(unsafe.Pointer(&buf[0]))
+	if header.type_ != rimTypeHID {
+		return inputs
+	}
+
+	device, ok := devices[header.device]
+	if !ok {
+		var err error
+		device, err = loadHIDDevice(header.device)
+		if err != nil {
+			slog.Warn("ignoring raw input device", "error", err)
+		}
+		devices[header.device] = device
+	}
+	if device == nil {
+		return inputs
+	}
+
+	// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-rawhid
+	raw := buf[headerSize:]
+	reportSize := int(binary.LittleEndian.Uint32(raw[0:4]))
+	count := int(binary.LittleEndian.Uint32(raw[4:8]))
+	data := raw[8:]
+	if reportSize == 0 || len(data) < reportSize*count {
+		return inputs
+	}
+
+	for i := range count {
+		var err error
+		inputs, err = reader.read(inputs, device, data[i*reportSize:(i+1)*reportSize])
+		if err != nil {
+			slog.Warn("ignoring raw input device", "error", err)
+			devices[header.device] = nil
+			break
+		}
+	}
+	return inputs
+}
@@ -0,0 +1,64 @@
+package inputsource
+
+import (
+	"slices"
+
+	"kafji.net/terong/inputevent"
+)
+
+// touchContact is a finger as read from a report of a precision touchpad.
+type touchContact struct {
+	id  uint8
+	x   uint16
+	y   uint16
+	tip bool
+}
+
+// touchAssembler collects the contacts of a frame, which a touchpad in
+// hybrid mode splits over several reports.
+type touchAssembler struct {
+	// contacts still expected in the current frame
+	remaining int
+	contacts  []inputevent.TouchContact
+}
+
+// push adds the contacts of a report. count is the contact count of the
+// report, which is only set in the first report of a frame. It returns the
+// frame once all its contacts have arrived.
+func (a *touchAssembler) push(count int, contacts []touchContact) (inputevent.TouchFrame, bool) {
+	if count > 0 {
+		a.remaining = count
+		a.contacts = a.contacts[:0]
+	}
+	if a.remaining == 0 {
+		return inputevent.TouchFrame{}, false
+	}
+
+	for _, c := range contacts[:min(len(contacts), a.remaining)] {
+		a.remaining--
+		// a contact without tip is a finger being lifted
+		if c.tip && len(a.contacts) < inputevent.MaxTouchContacts {
+			a.contacts = append(a.contacts, inputevent.TouchContact{ID: c.id, X: c.x, Y: c.y})
+		}
+	}
+	if a.remaining > 0 {
+		return inputevent.TouchFrame{}, false
+	}
+	return inputevent.TouchFrame{Contacts: slices.Clone(a.contacts)}, true
+}
+
+// reset drops the frame being collected.
+func (a *touchAssembler) reset() {
+	a.remaining = 0
+	a.contacts = a.contacts[:0]
+}
+
+// scaleTouchAxis scales v in the logical range of a touchpad axis to 0 to
+// 65535.
+func scaleTouchAxis(v int32, logicalMin, logicalMax int32) uint16 {
+	if logicalMax <= logicalMin {
+		return 0
+	}
+	v = max(min(v, logicalMax), logicalMin)
+	return uint16(int64(v-logicalMin) * 65535 / int64(logicalMax-logicalMin))
+}
@@ -0,0 +1,41 @@
+package inputsource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestTouchAssembler(t *testing.T) {
+	var a touchAssembler
+
+	// a frame in one report
+	frame, ok := a.push(2, []touchContact{{id: 1, x: 10, y: 20, tip: true}, {id: 2, x: 30, y: 40, tip: true}})
+	assert.True(t, ok)
+	assert.Equal(t, []inputevent.TouchContact{{ID: 1, X: 10, Y: 20}, {ID: 2, X: 30, Y: 40}}, frame.Contacts)
+
+	// a frame split over two reports, the second without contact count
+	_, ok = a.push(3, []touchContact{{id: 1, tip: true}, {id: 2, tip: true}})
+	assert.False(t, ok)
+	frame, ok = a.push(0, []touchContact{{id: 3, tip: true}, {id: 0}})
+	assert.True(t, ok)
+	assert.Len(t, frame.Contacts, 3)
+
+	// lifting the last finger
+	frame, ok = a.push(1, []touchContact{{id: 1, tip: false}})
+	assert.True(t, ok)
+	assert.Empty(t, frame.Contacts)
+
+	// a stray follow-up report
+	_, ok = a.push(0, []touchContact{{id: 1, tip: true}})
+	assert.False(t, ok)
+}
+
+func TestScaleTouchAxis(t *testing.T) {
+	assert.Equal(t, uint16(0), scaleTouchAxis(0, 0, 1000))
+	assert.Equal(t, uint16(65535), scaleTouchAxis(1000, 0, 1000))
+	assert.Equal(t, uint16(32767), scaleTouchAxis(500, 0, 1000))
+	assert.Equal(t, uint16(65535), scaleTouchAxis(2000, 0, 1000))
+	assert.Equal(t, uint16(0), scaleTouchAxis(5, 10, 10))
+}
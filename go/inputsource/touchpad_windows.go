@@ -0,0 +1,103 @@
+package inputsource
+
+import (
+	"errors"
+	"slices"
+
+	"kafji.net/terong/inputevent"
+)
+
+const (
+	usageTouchPad     = 0x05
+	usageTipSwitch    = 0x42
+	usageContactID    = 0x51
+	usageContactCount = 0x54
+)
+
+// touchpadReader reads precision touchpads, which report every finger in its
+// own link collection.
+type touchpadReader struct {
+	fingers   map[*hidDevice][]touchFinger
+	assembler touchAssembler
+	contacts  []touchContact
+	buttons   []uint16
+}
+
+type touchFinger struct {
+	link uint16
+	minX int32
+	maxX int32
+	minY int32
+	maxY int32
+}
+
+func newTouchpadReader() *touchpadReader {
+	return &touchpadReader{fingers: make(map[*hidDevice][]touchFinger), buttons: make([]uint16, 0, 16)}
+}
+
+// touchFingers finds the link collections with a position.
+func touchFingers(d *hidDevice) []touchFinger {
+	ys := make(map[uint16]hidpValueCaps)
+	for _, vc := range d.values {
+		if vc.usagePage == usagePageGeneric && vc.usage == usageY {
+			ys[vc.linkCollection] = vc
+		}
+	}
+
+	var fingers []touchFinger
+	for _, x := range d.values {
+		if x.usagePage != usagePageGeneric || x.usage != usageX {
+			continue
+		}
+		y, ok := ys[x.linkCollection]
+		if !ok {
+			continue
+		}
+		fingers = append(fingers, touchFinger{link: x.linkCollection, minX: x.logicalMin, maxX: x.logicalMax, minY: y.logicalMin, maxY: y.logicalMax})
+	}
+	return fingers
+}
+
+func (r *touchpadReader) read(inputs []inputevent.InputEvent, d *hidDevice, report []byte) ([]inputevent.InputEvent, error) {
+	fingers, ok := r.fingers[d]
+	if !ok {
+		fingers = touchFingers(d)
+		if len(fingers) == 0 {
+			return inputs, errors.New("not a precision touchpad")
+		}
+		r.fingers[d] = fingers
+	}
+
+	count, _ := d.usageValue(usagePageDigitizer, 0, usageContactCount, report)
+	r.contacts = r.contacts[:0]
+	for _, f := range fingers {
+		id, ok := d.usageValue(usagePageDigitizer, f.link, usageContactID, report)
+		if !ok {
+			continue
+		}
+		x, _ := d.usageValue(usagePageGeneric, f.link, usageX, report)
+		y, _ := d.usageValue(usagePageGeneric, f.link, usageY, report)
+		r.buttons = d.usages(usagePageDigitizer, f.link, report, r.buttons)
+		r.contacts = append(r.contacts, touchContact{
+			id:  uint8(id),
+			x:   scaleTouchAxis(int32(x), f.minX, f.maxX),
+			y:   scaleTouchAxis(int32(y), f.minY, f.maxY),
+			tip: slices.Contains(r.buttons, usageTipSwitch),
+		})
+	}
+
+	if frame, ok := r.assembler.push(int(count), r.contacts); ok {
+		inputs = append(inputs, frame)
+	}
+	return inputs, nil
+}
+
+func (r *touchpadReader) reset() {
+	r.assembler.reset()
+}
+
+// startTouchpad sends the fingers on precision touchpads. Windows gestures
+// still fire on the server.
+func startTouchpad(h *Handle) (stop func()) {
+	return startRawInput(h, "touchpad", usagePageDigitizer, usageTouchPad, newTouchpadReader())
+}
@@ -0,0 +1,247 @@
+package inputsource
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32   = windows.NewLazySystemDLL("user32.dll")
+	kernel32 = windows.NewLazySystemDLL("kernel32.dll")
+
+	procSetWindowsHookExW     = user32.NewProc("SetWindowsHookExW")
+	procUnhookWindowsHookEx   = user32.NewProc("UnhookWindowsHookEx")
+	procCallNextHookEx        = user32.NewProc("CallNextHookEx")
+	procGetMessageW           = user32.NewProc("GetMessageW")
+	procPostMessageW          = user32.NewProc("PostMessageW")
+	procPostThreadMessageW    = user32.NewProc("PostThreadMessageW")
+	procGetCursorPos          = user32.NewProc("GetCursorPos")
+	procSetCursorPos          = user32.NewProc("SetCursorPos")
+	procSystemParametersInfoW = user32.NewProc("SystemParametersInfoW")
+	procSetTimer              = user32.NewProc("SetTimer")
+	procKillTimer             = user32.NewProc("KillTimer")
+
+	procQueryPerformanceCounter   = kernel32.NewProc("QueryPerformanceCounter")
+	procQueryPerformanceFrequency = kernel32.NewProc("QueryPerformanceFrequency")
+	procSetThreadPriority         = kernel32.NewProc("SetThreadPriority")
+)
+
+const (
+	whKeyboardLL = 13
+	whMouseLL    = 14
+
+	wmKeyDown     = 0x0100
+	wmKeyUp       = 0x0101
+	wmSysKeyDown  = 0x0104
+	wmSysKeyUp    = 0x0105
+	wmTimer       = 0x0113
+	wmMouseMove   = 0x0200
+	wmLButtonDown = 0x0201
+	wmLButtonUp   = 0x0202
+	wmRButtonDown = 0x0204
+	wmRButtonUp   = 0x0205
+	wmMButtonDown = 0x0207
+	wmMButtonUp   = 0x0208
+	wmMouseWheel  = 0x020A
+	wmXButtonDown = 0x020B
+	wmXButtonUp   = 0x020C
+	wmApp         = 0x8000
+
+	xbutton1 = 0x0001
+	xbutton2 = 0x0002
+
+	wheelDelta = 120
+
+	spiGetWorkArea = 0x0030
+)
+
+// https://learn.microsoft.com/en-us/windows/win32/inputdev/virtual-key-codes
+const (
+	vkBack      = 0x08
+	vkTab       = 0x09
+	vkReturn    = 0x0D
+	vkPause     = 0x13
+	vkCapital   = 0x14
+	vkEscape    = 0x1B
+	vkSpace     = 0x20
+	vkPrior     = 0x21
+	vkNext      = 0x22
+	vkEnd       = 0x23
+	vkHome      = 0x24
+	vkLeft      = 0x25
+	vkUp        = 0x26
+	vkRight     = 0x27
+	vkDown      = 0x28
+	vkSnapshot  = 0x2C
+	vkInsert    = 0x2D
+	vkDelete    = 0x2E
+	vkLWin      = 0x5B
+	vkRWin      = 0x5C
+	vkApps      = 0x5D
+	vkSleep     = 0x5F
+	vkF1        = 0x70
+	vkF2        = 0x71
+	vkF3        = 0x72
+	vkF4        = 0x73
+	vkF5        = 0x74
+	vkF6        = 0x75
+	vkF7        = 0x76
+	vkF8        = 0x77
+	vkF9        = 0x78
+	vkF10       = 0x79
+	vkF11       = 0x7A
+	vkF12       = 0x7B
+	vkNumLock   = 0x90
+	vkScroll    = 0x91
+	vkLShift    = 0xA0
+	vkRShift    = 0xA1
+	vkLControl  = 0xA2
+	vkRControl  = 0xA3
+	vkLMenu     = 0xA4
+	vkRMenu     = 0xA5
+	vkOEM1      = 0xBA
+	vkOEMPlus   = 0xBB
+	vkOEMComma  = 0xBC
+	vkOEMMinus  = 0xBD
+	vkOEMPeriod = 0xBE
+	vkOEM2      = 0xBF
+	vkOEM3      = 0xC0
+	vkOEM4      = 0xDB
+	vkOEM5      = 0xDC
+	vkOEM6      = 0xDD
+	vkOEM7      = 0xDE
+)
+
+const (
+	messageCodeHookEvent = wmApp + iota
+	messageCodeControlCommand
+	messageCodeSetCaptureInputs
+	messageCodeWatchdogProbe
+)
+
+const (
+	controlCommandStop = 1
+)
+
+type point struct {
+	x int32
+	y int32
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-msg
+type winMsg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       point
+	lPrivate uint32
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-msllhookstruct
+type msllHookStruct struct {
+	pt          point
+	mouseData   uint32
+	flags       uint32
+	time        uint32
+	dwExtraInfo uintptr
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/winuser/ns-winuser-kbdllhookstruct
+type kbdllHookStruct struct {
+	vkCode      uint32
+	scanCode    uint32
+	flags       uint32
+	time        uint32
+	dwExtraInfo uintptr
+}
+
+// https://learn.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-setthreadpriority
+const threadPriorityTimeCritical = 15
+
+// setThreadPriority sets the priority of the calling thread.
+func setThreadPriority(priority int32) error {
+	ok, _, err := procSetThreadPriority.Call(uintptr(windows.CurrentThread()), uintptr(priority))
+	if ok == 0 {
+		return err
+	}
+	return nil
+}
+
+func setWindowsHookEx(idHook int, fn uintptr, module windows.Handle) (uintptr, error) {
+	hook, _, err := procSetWindowsHookExW.Call(uintptr(idHook), fn, uintptr(module), 0)
+	if hook == 0 {
+		return 0, err
+	}
+	return hook, nil
+}
+
+func unhookWindowsHookEx(hook uintptr) {
+	procUnhookWindowsHookEx.Call(hook)
+}
+
+func callNextHookEx(nCode int32, wParam uintptr, lParam uintptr) uintptr {
+	ret, _, _ := procCallNextHookEx.Call(0, uintptr(nCode), wParam, lParam)
+	return ret
+}
+
+// getMessage retrieves only thread messages.
+func getMessage(m *winMsg) (int32, error) {
+	ret, _, err := procGetMessageW.Call(uintptr(unsafe.Pointer(m)), ^uintptr(0), 0, 0)
+	if int32(ret) < 0 {
+		return int32(ret), err
+	}
+	return int32(ret), nil
+}
+
+// postMessage posts a message to the calling thread.
+func postMessage(message uint32, wParam uintptr, lParam uintptr) {
+	procPostMessageW.Call(0, uintptr(message), wParam, lParam)
+}
+
+func postThreadMessage(threadID uint32, message uint32, wParam uintptr, lParam uintptr) {
+	procPostThreadMessageW.Call(uintptr(threadID), uintptr(message), wParam, lParam)
+}
+
+func getCursorPos() (point, error) {
+	var p point
+	ret, _, err := procGetCursorPos.Call(uintptr(unsafe.Pointer(&p)))
+	if ret == 0 {
+		return point{}, err
+	}
+	return p, nil
+}
+
+func setCursorPos(p point) error {
+	ret, _, err := procSetCursorPos.Call(uintptr(p.x), uintptr(p.y))
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+func setTimer(elapseMillis uint32) (uintptr, error) {
+	id, _, err := procSetTimer.Call(0, 0, uintptr(elapseMillis), 0)
+	if id == 0 {
+		return 0, err
+	}
+	return id, nil
+}
+
+func killTimer(id uintptr) {
+	procKillTimer.Call(0, id)
+}
+
+func queryPerformanceCounter() int64 {
+	var v int64
+	procQueryPerformanceCounter.Call(uintptr(unsafe.Pointer(&v)))
+	return v
+}
+
+func queryPerformanceFrequency() int64 {
+	var v int64
+	procQueryPerformanceFrequency.Call(uintptr(unsafe.Pointer(&v)))
+	return v
+}
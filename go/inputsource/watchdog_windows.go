@@ -0,0 +1,61 @@
+package inputsource
+
+import (
+	"time"
+
+	"kafji.net/terong/metrics"
+)
+
+const (
+	// how often the watchdog probes the message loop
+	watchdogInterval = time.Second
+	// the message loop is considered blocked when a probe isn't processed
+	// within this long
+	watchdogThreshold = 250 * time.Millisecond
+)
+
+// messageLoopStalls counts the times the message loop was found blocked.
+var messageLoopStalls = metrics.NewCounterMap("inputsource_message_loop_stalls")
+
+// watchdog posts a no-op message to the message loop every watchdogInterval
+// and reports the loop as blocked when the message isn't processed in time.
+// Unlike the heartbeat timer, the probe queues up behind hook events, so it
+// also catches a loop that is only falling behind.
+func watchdog(h *Handle, threadID uint32, stop <-chan struct{}) {
+	ticker := time.NewTicker(watchdogThreshold)
+	defer ticker.Stop()
+
+	var seq uintptr
+	var sentAt time.Time
+	stalled := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if h.watchdogAck.Load() != seq {
+			if blocked := time.Since(sentAt); !stalled && blocked > watchdogThreshold {
+				stalled = true
+				h.stalled.Store(true)
+				messageLoopStalls.Add("stalls", 1)
+				slog.Error("message loop is blocked", "blocked_for", blocked, "capturing", h.Capturing())
+			}
+			continue
+		}
+
+		if stalled {
+			stalled = false
+			h.stalled.Store(false)
+			slog.Info("message loop recovered", "blocked_for", time.Since(sentAt))
+		}
+		if time.Since(sentAt) < watchdogInterval {
+			continue
+		}
+		seq++
+		sentAt = time.Now()
+		postThreadMessage(threadID, messageCodeWatchdogProbe, seq, 0)
+	}
+}
@@ -0,0 +1,40 @@
+package inputsource
+
+import (
+	"math"
+
+	"kafji.net/terong/inputevent"
+)
+
+// wheelAccumulator sums wheel distances until they make a notch. Precision
+// touchpads and free-spinning wheels deliver distances smaller than a notch,
+// which would scroll nothing on their own.
+type wheelAccumulator struct {
+	// notch is the distance of one scroll, wheelDelta on Windows
+	notch int
+	// rest is the distance short of a notch carried to the next add
+	rest int
+}
+
+// add adds distance, positive scrolling up, and returns the scroll of the
+// notches accumulated, if any. Turning the other way drops the rest.
+func (a *wheelAccumulator) add(distance int) (inputevent.MouseScroll, bool) {
+	if (distance > 0 && a.rest < 0) || (distance < 0 && a.rest > 0) {
+		a.rest = 0
+	}
+	a.rest += distance
+	count := a.rest / a.notch
+	if count == 0 {
+		return inputevent.MouseScroll{}, false
+	}
+	a.rest -= count * a.notch
+	if count > 0 {
+		return inputevent.MouseScroll{Count: uint8(min(count, math.MaxUint8)), Direction: inputevent.MouseScrollUp}, true
+	}
+	return inputevent.MouseScroll{Count: uint8(min(-count, math.MaxUint8)), Direction: inputevent.MouseScrollDown}, true
+}
+
+// reset drops the rest, e.g. when the wheel starts being relayed.
+func (a *wheelAccumulator) reset() {
+	a.rest = 0
+}
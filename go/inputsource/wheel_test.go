@@ -0,0 +1,37 @@
+package inputsource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestWheelAccumulator(t *testing.T) {
+	a := wheelAccumulator{notch: 120}
+
+	// a full notch
+	scroll, ok := a.add(120)
+	assert.True(t, ok)
+	assert.Equal(t, inputevent.MouseScroll{Count: 1, Direction: inputevent.MouseScrollUp}, scroll)
+
+	// fractions add up to a notch, keeping what's past it
+	_, ok = a.add(50)
+	assert.False(t, ok)
+	_, ok = a.add(50)
+	assert.False(t, ok)
+	scroll, ok = a.add(50)
+	assert.True(t, ok)
+	assert.Equal(t, inputevent.MouseScroll{Count: 1, Direction: inputevent.MouseScrollUp}, scroll)
+	assert.Equal(t, 30, a.rest)
+
+	// turning the other way drops the rest
+	_, ok = a.add(-100)
+	assert.False(t, ok)
+	scroll, ok = a.add(-260)
+	assert.True(t, ok)
+	assert.Equal(t, inputevent.MouseScroll{Count: 3, Direction: inputevent.MouseScrollDown}, scroll)
+
+	a.reset()
+	assert.Zero(t, a.rest)
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// guardWindow is the period the time spent logging debug records is
+	// measured over.
+	guardWindow = time.Second
+	// guardBudget is how much of guardWindow debug records may take to log
+	// before they're sampled, so they don't hold up the loops logging them.
+	guardBudget = guardWindow / 10
+	// guardSampleEvery is one of how many debug records are logged while
+	// sampled.
+	guardSampleEvery = 100
+)
+
+var guard debugGuard
+
+var guardLog = NewLogger("logging")
+
+// debugGuard samples debug records once logging them takes more than
+// guardBudget of a guardWindow, until the log level is set again.
+type debugGuard struct {
+	// start of the current window, in Unix nanoseconds
+	windowStart atomic.Int64
+	// time spent logging in the current window
+	spent   atomic.Int64
+	sampled atomic.Bool
+	count   atomic.Uint64
+}
+
+// admit reports whether the next debug record is logged.
+func (g *debugGuard) admit() bool {
+	if !g.sampled.Load() {
+		return true
+	}
+	return g.count.Add(1)%guardSampleEvery == 0
+}
+
+// spend adds d spent logging a debug record at now. It reports whether the
+// budget was just exceeded, and records are sampled from now on.
+func (g *debugGuard) spend(now time.Time, d time.Duration) bool {
+	if g.sampled.Load() {
+		return false
+	}
+	start := g.windowStart.Load()
+	if now.UnixNano()-start >= int64(guardWindow) && g.windowStart.CompareAndSwap(start, now.UnixNano()) {
+		g.spent.Store(0)
+	}
+	if g.spent.Add(int64(d)) <= int64(guardBudget) {
+		return false
+	}
+	return g.sampled.CompareAndSwap(false, true)
+}
+
+func (g *debugGuard) reset() {
+	g.sampled.Store(false)
+	g.spent.Store(0)
+	g.windowStart.Store(0)
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugGuard(t *testing.T) {
+	var g debugGuard
+	now := time.Unix(1_700_000_000, 0)
+
+	// under budget in every window
+	for i := 0; i < 5; i++ {
+		assert.False(t, g.spend(now, guardBudget/2))
+		now = now.Add(guardWindow)
+	}
+	assert.True(t, g.admit())
+
+	assert.False(t, g.spend(now, guardBudget/2))
+	assert.True(t, g.spend(now.Add(guardWindow/2), guardBudget/2+time.Millisecond))
+	assert.False(t, g.spend(now, guardBudget), "already sampled")
+
+	admitted := 0
+	for i := 0; i < 10*guardSampleEvery; i++ {
+		if g.admit() {
+			admitted++
+		}
+	}
+	assert.Equal(t, 10, admitted)
+
+	g.reset()
+	assert.True(t, g.admit())
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// historySize is how many records [History] keeps.
+const historySize = 500
+
+var history historyRing
+
+// historyRing keeps the latest records of every logger, down to debug level
+// whatever the log level, so they can be dumped after the fact. Records are
+// only formatted when dumped.
+type historyRing struct {
+	mu      sync.Mutex
+	records [historySize]slog.Record
+	next    int
+	full    bool
+}
+
+func (h *historyRing) add(r slog.Record) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records[h.next] = r
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *historyRing) lines() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	records := h.records[:h.next]
+	if h.full {
+		records = append(h.records[h.next:len(h.records):len(h.records)], records...)
+	}
+	lines := make([]string, 0, len(records))
+	for _, r := range records {
+		lines = append(lines, formatRecord(r, "15:04:05.000", nil))
+	}
+	return lines
+}
+
+// History returns the latest records of every level, oldest first, e.g. for
+// crash reports. Debug records are kept even when not logged, except those
+// skipped by checking [Logger.DebugEnabled].
+func History() []string {
+	return history.lines()
+}
+
+func remember(level slog.Level, msg string, args []any) {
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.Add(args...)
+	history.add(r)
+}
@@ -0,0 +1,21 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	l := NewLogger("history_test")
+	for i := range historySize + 2 {
+		l.Debug("record", "i", i)
+	}
+
+	lines := History()
+	require.Len(t, lines, historySize)
+	assert.Contains(t, lines[0], "DEBUG history_test: record i=2")
+	assert.Contains(t, lines[len(lines)-1], fmt.Sprintf("i=%d", historySize+1))
+}
@@ -1,8 +1,10 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"time"
 )
 
 var Filter = func(namespace string) bool { return true }
@@ -12,6 +14,11 @@ type Logger interface {
 	Info(msg string, args ...any)
 	Warn(msg string, args ...any)
 	Error(msg string, args ...any)
+	// DebugEnabled reports whether Debug records will be emitted. Check it
+	// before logging in hot paths to avoid building the arguments.
+	DebugEnabled() bool
+	// With returns a logger that includes args in every record.
+	With(args ...any) Logger
 }
 
 func NewLogger(namespace string) Logger {
@@ -20,21 +27,41 @@ func NewLogger(namespace string) Logger {
 
 type logger struct {
 	namespace string
+	args      []any
 }
 
 func (l *logger) filterMap(msg string, args []any) (string, []any, bool) {
 	if !Filter(l.namespace) {
 		return "", nil, false
 	}
+	if len(l.args) > 0 {
+		args = append(l.args[:len(l.args):len(l.args)], args...)
+	}
 	return fmt.Sprintf("%s: %s", l.namespace, msg), args, true
 }
 
+func (l *logger) With(args ...any) Logger {
+	return &logger{namespace: l.namespace, args: append(l.args[:len(l.args):len(l.args)], args...)}
+}
+
+func (l *logger) DebugEnabled() bool {
+	return Filter(l.namespace) && slog.Default().Enabled(context.Background(), slog.LevelDebug)
+}
+
 func (l *logger) Debug(msg string, args ...any) {
 	msg, args, ok := l.filterMap(msg, args)
 	if !ok {
 		return
 	}
+	remember(slog.LevelDebug, msg, args)
+	if !guard.admit() {
+		return
+	}
+	start := time.Now()
 	slog.Debug(msg, args...)
+	if guard.spend(start, time.Since(start)) {
+		guardLog.Warn("debug logging is slowing down the program, logging only some debug records", "sample_every", guardSampleEvery, "budget", guardBudget, "window", guardWindow)
+	}
 }
 
 func (l *logger) Info(msg string, args ...any) {
@@ -42,6 +69,7 @@ func (l *logger) Info(msg string, args ...any) {
 	if !ok {
 		return
 	}
+	remember(slog.LevelInfo, msg, args)
 	slog.Info(msg, args...)
 }
 
@@ -50,6 +78,7 @@ func (l *logger) Warn(msg string, args ...any) {
 	if !ok {
 		return
 	}
+	remember(slog.LevelWarn, msg, args)
 	slog.Warn(msg, args...)
 }
 
@@ -58,10 +87,14 @@ func (l *logger) Error(msg string, args ...any) {
 	if !ok {
 		return
 	}
+	remember(slog.LevelError, msg, args)
 	slog.Error(msg, args...)
 }
 
+// SetLogLevel sets the level of records logged. Debug records that were
+// sampled for taking too long to log are logged in full again.
 func SetLogLevel(level string) {
+	guard.reset()
 	switch level {
 	case "debug":
 		slog.SetLogLoggerLevel(slog.LevelDebug)
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Recent keeps the latest warning and error records as formatted lines.
+type Recent struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+}
+
+// Capture makes the default logger keep the last n warnings and errors in
+// the returned Recent and discard everything else, for when the terminal
+// shows a dashboard instead of log lines.
+func Capture(n int) *Recent {
+	r := &Recent{max: n}
+	slog.SetDefault(slog.New(&recentHandler{recent: r}))
+	return r
+}
+
+// Lines returns the kept lines, oldest first.
+func (r *Recent) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.lines...)
+}
+
+func (r *Recent) add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, line)
+	if len(r.lines) > r.max {
+		r.lines = r.lines[len(r.lines)-r.max:]
+	}
+}
+
+type recentHandler struct {
+	recent *Recent
+	attrs  []slog.Attr
+}
+
+func (h *recentHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn
+}
+
+func (h *recentHandler) Handle(_ context.Context, r slog.Record) error {
+	h.recent.add(formatRecord(r, "15:04:05", h.attrs))
+	return nil
+}
+
+func formatRecord(r slog.Record, layout string, attrs []slog.Attr) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", r.Time.Format(layout), r.Level, r.Message)
+	write := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range attrs {
+		write(a)
+	}
+	r.Attrs(write)
+	return b.String()
+}
+
+func (h *recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recentHandler{recent: h.recent, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *recentHandler) WithGroup(string) slog.Handler {
+	return h
+}
@@ -0,0 +1,229 @@
+// Package metrics keeps process wide counters. Counters are published with
+// expvar and served by the status endpoint.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"kafji.net/terong/logging"
+)
+
+var slog = logging.NewLogger("metrics")
+
+// SummaryInterval is how often changed counters are logged.
+const SummaryInterval = time.Minute
+
+var (
+	registryMu sync.Mutex
+	registry   []*CounterMap
+	handlers   = make(map[string]http.Handler)
+)
+
+// CounterMap is a named set of counters, e.g. dropped inputs by input type.
+type CounterMap struct {
+	name string
+	m    *expvar.Map
+	// activity counts normal operation, summarized at info level instead of
+	// warn
+	activity bool
+
+	mu   sync.Mutex
+	last map[string]int64
+}
+
+// NewCounterMap publishes a new counter map of something going wrong, e.g.
+// dropped inputs. Increases are logged as warnings. It panics if name is
+// already published.
+func NewCounterMap(name string) *CounterMap {
+	return newCounterMap(name, false)
+}
+
+// NewActivityCounterMap publishes a new counter map of normal operation, e.g.
+// relayed inputs. Increases are logged at info level. It panics if name is
+// already published.
+func NewActivityCounterMap(name string) *CounterMap {
+	return newCounterMap(name, true)
+}
+
+func newCounterMap(name string, activity bool) *CounterMap {
+	c := &CounterMap{name: name, m: expvar.NewMap(name), activity: activity, last: make(map[string]int64)}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, c)
+	return c
+}
+
+func (c *CounterMap) Name() string {
+	return c.name
+}
+
+func (c *CounterMap) Add(key string, delta int64) {
+	c.m.Add(key, delta)
+}
+
+// Label is a published string, e.g. the name of the connected client.
+type Label struct {
+	v *expvar.String
+}
+
+// NewLabel publishes a new label. It panics if name is already published.
+func NewLabel(name string) *Label {
+	return &Label{v: expvar.NewString(name)}
+}
+
+func (l *Label) Set(v string) {
+	l.v.Set(v)
+}
+
+// PublishFunc publishes the value returned by f, evaluated on every status
+// request. It panics if name is already published.
+func PublishFunc(name string, f func() any) {
+	expvar.Publish(name, expvar.Func(f))
+}
+
+// Totals returns the total of every counter map by name.
+func Totals() map[string]int64 {
+	registryMu.Lock()
+	maps := append([]*CounterMap(nil), registry...)
+	registryMu.Unlock()
+	totals := make(map[string]int64, len(maps))
+	for _, c := range maps {
+		totals[c.name] = c.Total()
+	}
+	return totals
+}
+
+// Total returns the sum of all counters.
+func (c *CounterMap) Total() int64 {
+	var total int64
+	for _, v := range c.Snapshot() {
+		total += v
+	}
+	return total
+}
+
+// Snapshot returns the current totals.
+func (c *CounterMap) Snapshot() map[string]int64 {
+	s := make(map[string]int64)
+	c.m.Do(func(kv expvar.KeyValue) {
+		if v, ok := kv.Value.(*expvar.Int); ok {
+			s[kv.Key] = v.Value()
+		}
+	})
+	return s
+}
+
+// delta returns the increments since the previous call.
+func (c *CounterMap) delta() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := make(map[string]int64)
+	for k, v := range c.Snapshot() {
+		if v != c.last[k] {
+			d[k] = v - c.last[k]
+			c.last[k] = v
+		}
+	}
+	return d
+}
+
+// LogSummaries logs the counters that changed, every interval, until ctx is
+// done.
+func LogSummaries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logSummary()
+		}
+	}
+}
+
+func logSummary() {
+	registryMu.Lock()
+	counters := append([]*CounterMap(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range counters {
+		d := c.delta()
+		if len(d) == 0 {
+			continue
+		}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		args := make([]any, 0, len(keys)*2)
+		for _, k := range keys {
+			args = append(args, k, d[k])
+		}
+		if c.activity {
+			slog.Info(fmt.Sprintf("%s increased", c.name), args...)
+		} else {
+			slog.Warn(fmt.Sprintf("%s increased", c.name), args...)
+		}
+	}
+}
+
+// Handle serves handler at pattern on the status endpoint, next to /status.
+// It must be called before Serve.
+func Handle(pattern string, handler http.Handler) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	handlers[pattern] = handler
+}
+
+// Serve serves the published variables as JSON at /status on addr until ctx
+// is done. The endpoint is unauthenticated and controls the process, so addr
+// must be a loopback address unless allowRemote is set.
+func Serve(ctx context.Context, addr string, allowRemote bool) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, addr, allowRemote)
+	}()
+	return done
+}
+
+func serve(ctx context.Context, addr string, allowRemote bool) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %v", err)
+	}
+	if tcpAddr, ok := listener.Addr().(*net.TCPAddr); ok && !tcpAddr.IP.IsLoopback() && !allowRemote {
+		listener.Close()
+		return fmt.Errorf("%s is not a loopback address", addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/status", expvar.Handler())
+	registryMu.Lock()
+	for pattern, handler := range handlers {
+		mux.Handle(pattern, handler)
+	}
+	registryMu.Unlock()
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	slog.Info("serving status", "address", listener.Addr())
+	err = server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		return context.Cause(ctx)
+	}
+	return err
+}
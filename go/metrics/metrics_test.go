@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/logging"
+)
+
+func TestCounterMapDelta(t *testing.T) {
+	c := NewCounterMap("test_counter_map_delta")
+	c.Add("MouseMove", 3)
+	c.Add("KeyPress", 1)
+	require.Equal(t, map[string]int64{"MouseMove": 3, "KeyPress": 1}, c.delta())
+
+	require.Empty(t, c.delta())
+
+	c.Add("MouseMove", 2)
+	require.Equal(t, map[string]int64{"MouseMove": 2}, c.delta())
+	require.Equal(t, map[string]int64{"MouseMove": 5, "KeyPress": 1}, c.Snapshot())
+	require.Equal(t, int64(6), c.Total())
+}
+
+func TestLogSummaryLevel(t *testing.T) {
+	NewCounterMap("test_log_summary_dropped").Add("MouseMove", 1)
+	NewActivityCounterMap("test_log_summary_relayed").Add("MouseMove", 1)
+	logSummary()
+
+	var dropped, relayed string
+	for _, line := range logging.History() {
+		switch {
+		case strings.Contains(line, "test_log_summary_dropped increased"):
+			dropped = line
+		case strings.Contains(line, "test_log_summary_relayed increased"):
+			relayed = line
+		}
+	}
+	assert.Contains(t, dropped, "WARN")
+	assert.Contains(t, relayed, "INFO")
+}
+
+func TestServeLoopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := <-Serve(ctx, "0.0.0.0:0", false)
+	assert.ErrorContains(t, err, "not a loopback address")
+
+	done := Serve(ctx, "127.0.0.1:0", false)
+	select {
+	case err := <-done:
+		t.Fatalf("loopback address refused: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,307 @@
+// Package acme obtains and renews the server certificate from an ACME CA,
+// e.g. Let's Encrypt, with DNS-01 challenges, so clients can verify the
+// server by its domain name instead of pinning its certificate. Client
+// certificates are still pinned.
+package acme
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/logging"
+)
+
+var slog = logging.NewLogger("terong/acme")
+
+const (
+	// defaultRenewBefore is how long before it expires the certificate is
+	// renewed if RenewBefore isn't set.
+	defaultRenewBefore = 30 * 24 * time.Hour
+	// retryInterval is how long until a failed renewal is tried again.
+	retryInterval = time.Hour
+)
+
+type Config struct {
+	// Domain is the name the certificate is for.
+	Domain string
+	// Email is given to the CA for expiry notices, if set.
+	Email string
+	// DirectoryURL is the directory of the CA. Empty uses Let's Encrypt.
+	DirectoryURL string
+	// AccountKeyPath is where the account key is kept, created if missing.
+	AccountKeyPath string
+	// DNSHook is run as `hook present <fqdn> <value>` to publish the TXT
+	// record of a challenge, returning once it's visible to the CA, and as
+	// `hook cleanup <fqdn> <value>` to remove it.
+	DNSHook string
+	// RenewBefore is how long before it expires the certificate is renewed.
+	RenewBefore time.Duration
+	// The certificate chain and its key are written to these.
+	CertPath string
+	KeyPath  string
+}
+
+func (c *Config) check() error {
+	switch {
+	case c.AccountKeyPath == "":
+		return errors.New("acme requires account key path")
+	case c.DNSHook == "":
+		return errors.New("acme requires dns hook")
+	case c.CertPath == "" || c.KeyPath == "":
+		return errors.New("acme requires tls cert path and tls key path")
+	}
+	return nil
+}
+
+// Obtain gets a certificate unless the one at cfg.CertPath is for the domain
+// and not due for renewal.
+func Obtain(ctx context.Context, cfg *Config) error {
+	if err := cfg.check(); err != nil {
+		return errs.Mark(err, errs.ErrConfigInvalid)
+	}
+	if time.Now().Before(renewAt(cfg)) {
+		return nil
+	}
+	return issue(ctx, cfg)
+}
+
+// Renew obtains the certificate again whenever it's due, until ctx is done.
+// A failed renewal is tried again after retryInterval.
+func Renew(ctx context.Context, cfg *Config) {
+	for {
+		wait := time.Until(renewAt(cfg))
+		if wait <= 0 {
+			if err := issue(ctx, cfg); err != nil {
+				slog.Warn("failed to renew certificate", "domain", cfg.Domain, "error", err)
+				wait = retryInterval
+			} else {
+				continue
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// renewAt returns when the certificate at cfg.CertPath is due for renewal,
+// zero if it can't be read or isn't for the domain.
+func renewAt(cfg *Config) time.Time {
+	b, err := os.ReadFile(cfg.CertPath)
+	if err != nil {
+		return time.Time{}
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return time.Time{}
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || cert.VerifyHostname(cfg.Domain) != nil {
+		return time.Time{}
+	}
+	before := cfg.RenewBefore
+	if before <= 0 {
+		before = defaultRenewBefore
+	}
+	return cert.NotAfter.Add(-before)
+}
+
+// issue orders a certificate for the domain and writes it.
+func issue(ctx context.Context, cfg *Config) error {
+	key, err := accountKey(cfg.AccountKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load account key: %v", err)
+	}
+	client := &acme.Client{Key: key, DirectoryURL: cfg.DirectoryURL}
+
+	account := &acme.Account{}
+	if cfg.Email != "" {
+		account.Contact = []string{"mailto:" + cfg.Email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("failed to register account: %v", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(cfg.Domain))
+	if err != nil {
+		return fmt.Errorf("failed to create order: %v", err)
+	}
+	for _, url := range order.AuthzURLs {
+		if err := authorize(ctx, client, cfg.DNSHook, url); err != nil {
+			return err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return fmt.Errorf("failed to wait for order: %v", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{cfg.Domain}}, certKey)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate request: %v", err)
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("failed to finalize order: %v", err)
+	}
+
+	if err := writeCert(cfg, chain, certKey); err != nil {
+		return err
+	}
+	if cert, err := x509.ParseCertificate(chain[0]); err == nil {
+		slog.Info("certificate obtained", "domain", cfg.Domain, "expires", cert.NotAfter)
+	}
+	return nil
+}
+
+// authorize answers the DNS-01 challenge of the authorization at url, unless
+// it's valid already.
+func authorize(ctx context.Context, client *acme.Client, hook string, url string) error {
+	authz, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to get authorization: %v", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == "dns-01" {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", authz.Identifier.Value)
+	}
+
+	value, err := client.DNS01ChallengeRecord(challenge.Token)
+	if err != nil {
+		return fmt.Errorf("failed to compute challenge record: %v", err)
+	}
+	fqdn := "_acme-challenge." + authz.Identifier.Value + "."
+	if err := runHook(ctx, hook, "present", fqdn, value); err != nil {
+		return err
+	}
+	defer func() {
+		if err := runHook(context.WithoutCancel(ctx), hook, "cleanup", fqdn, value); err != nil {
+			slog.Warn("failed to clean up challenge record", "fqdn", fqdn, "error", err)
+		}
+	}()
+
+	if _, err := client.Accept(ctx, challenge); err != nil {
+		return fmt.Errorf("failed to accept challenge: %v", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+		return fmt.Errorf("failed to wait for authorization: %v", err)
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, hook string, action string, fqdn string, value string) error {
+	out, err := exec.CommandContext(ctx, hook, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("dns hook %s failed: %v: %s", action, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// accountKey reads the account key at path, or creates it if missing.
+func accountKey(path string) (*ecdsa.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		b, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(path, b, 0o600); err != nil {
+			return nil, err
+		}
+		slog.Info("account key created", "path", path)
+		return key, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM key", path)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+// writeCert writes chain and key to the paths of cfg, key first, so the pair
+// only mismatches until the certificate is written too.
+func writeCert(cfg *Config, chain [][]byte, key *ecdsa.PrivateKey) error {
+	b, err := encodeKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %v", err)
+	}
+	if err := writeFile(cfg.KeyPath, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write key: %v", err)
+	}
+	var certs []byte
+	for _, der := range chain {
+		certs = append(certs, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	if err := writeFile(cfg.CertPath, certs, 0o644); err != nil {
+		return fmt.Errorf("failed to write certificate: %v", err)
+	}
+	return nil
+}
+
+// writeFile replaces the file at path atomically, so a reader never sees it
+// half written.
+func writeFile(path string, b []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,226 @@
+package acme
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+	"kafji.net/terong/errs"
+)
+
+const testDomain = "terong.example"
+
+// fakeCA is an ACME CA that skips verifying requests, and validates the
+// challenge once the hook published its record.
+type fakeCA struct {
+	t       *testing.T
+	url     string
+	hookLog string
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	validated bool
+	issued    int
+	leaf      []byte
+}
+
+func newFakeCA(t *testing.T, hookLog string) *fakeCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	ca := &fakeCA{t: t, hookLog: hookLog, cert: cert, key: key}
+	srv := httptest.NewServer(ca)
+	t.Cleanup(srv.Close)
+	ca.url = srv.URL
+	return ca
+}
+
+func (ca *fakeCA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	status := "pending"
+	if ca.validated {
+		status = "valid"
+	}
+	reply := func(code int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	}
+	order := func(status string) map[string]any {
+		v := map[string]any{
+			"status":         status,
+			"identifiers":    []map[string]string{{"type": "dns", "value": testDomain}},
+			"authorizations": []string{ca.url + "/authz"},
+			"finalize":       ca.url + "/finalize",
+		}
+		if status == "valid" {
+			v["certificate"] = ca.url + "/cert"
+		}
+		return v
+	}
+
+	switch r.URL.Path {
+	case "/dir":
+		reply(http.StatusOK, map[string]string{
+			"newNonce":   ca.url + "/nonce",
+			"newAccount": ca.url + "/account",
+			"newOrder":   ca.url + "/new-order",
+		})
+	case "/nonce":
+	case "/account":
+		w.Header().Set("Location", ca.url+"/account/1")
+		reply(http.StatusCreated, map[string]string{"status": "valid"})
+	case "/new-order":
+		w.Header().Set("Location", ca.url+"/order")
+		reply(http.StatusCreated, order(status))
+	case "/order":
+		w.Header().Set("Location", ca.url+"/order")
+		if status == "valid" {
+			status = "ready"
+		}
+		reply(http.StatusOK, order(status))
+	case "/authz":
+		reply(http.StatusOK, map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": testDomain},
+			"challenges": []map[string]string{{"type": "dns-01", "url": ca.url + "/challenge", "token": "token", "status": status}},
+		})
+	case "/challenge":
+		b, _ := os.ReadFile(ca.hookLog)
+		ca.validated = strings.HasPrefix(string(b), "present ")
+		reply(http.StatusOK, map[string]string{"type": "dns-01", "url": ca.url + "/challenge", "token": "token", "status": "processing"})
+	case "/finalize":
+		var req struct{ CSR string }
+		require.NoError(ca.t, json.Unmarshal(payload(ca.t, r), &req))
+		der, err := base64.RawURLEncoding.DecodeString(req.CSR)
+		require.NoError(ca.t, err)
+		csr, err := x509.ParseCertificateRequest(der)
+		require.NoError(ca.t, err)
+		ca.issued++
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(ca.issued + 1)),
+			DNSNames:     csr.DNSNames,
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}
+		leaf, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, csr.PublicKey, ca.key)
+		require.NoError(ca.t, err)
+		ca.leaf = leaf
+		w.Header().Set("Location", ca.url+"/order")
+		reply(http.StatusOK, order("valid"))
+	case "/cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.leaf})
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// payload returns the payload of the JWS request r.
+func payload(t *testing.T, r *http.Request) []byte {
+	var jws struct{ Payload string }
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&jws))
+	b, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	require.NoError(t, err)
+	return b
+}
+
+func TestObtain(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is a shell script")
+	}
+	dir := t.TempDir()
+	hookLog := filepath.Join(dir, "hook.log")
+	hook := filepath.Join(dir, "hook.sh")
+	require.NoError(t, os.WriteFile(hook, []byte("#!/bin/sh\necho \"$@\" >> "+hookLog+"\n"), 0o755))
+	ca := newFakeCA(t, hookLog)
+	cfg := &Config{
+		Domain:         testDomain,
+		DirectoryURL:   ca.url + "/dir",
+		AccountKeyPath: filepath.Join(dir, "account.key"),
+		DNSHook:        hook,
+		CertPath:       filepath.Join(dir, "server.crt"),
+		KeyPath:        filepath.Join(dir, "server.key"),
+	}
+	ctx := context.Background()
+
+	require.NoError(t, Obtain(ctx, cfg))
+	assert.Equal(t, 1, ca.issued)
+
+	// the record was published before the challenge was accepted, then
+	// removed
+	key, err := accountKey(cfg.AccountKeyPath)
+	require.NoError(t, err)
+	value, err := (&acme.Client{Key: key}).DNS01ChallengeRecord("token")
+	require.NoError(t, err)
+	b, err := os.ReadFile(hookLog)
+	require.NoError(t, err)
+	fqdn := "_acme-challenge." + testDomain + "."
+	assert.Equal(t, "present "+fqdn+" "+value+"\ncleanup "+fqdn+" "+value+"\n", string(b))
+
+	pair, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+	require.NoError(t, err)
+	require.Len(t, pair.Certificate, 2)
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: testDomain})
+	assert.NoError(t, err)
+
+	// not due yet
+	require.NoError(t, Obtain(ctx, cfg))
+	assert.Equal(t, 1, ca.issued)
+
+	// due, the authorization is still valid
+	cfg.RenewBefore = 90 * 24 * time.Hour
+	require.NoError(t, Obtain(ctx, cfg))
+	assert.Equal(t, 2, ca.issued)
+
+	// another domain
+	cfg.Domain = "other.example"
+	assert.True(t, renewAt(cfg).IsZero())
+}
+
+func TestObtainConfig(t *testing.T) {
+	err := Obtain(context.Background(), &Config{Domain: testDomain, DNSHook: "hook", CertPath: "a", KeyPath: "b"})
+	assert.ErrorIs(t, err, errs.ErrConfigInvalid)
+}
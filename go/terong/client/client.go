@@ -4,88 +4,248 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"time"
 
+	"kafji.net/terong/crash"
+	"kafji.net/terong/gctune"
 	"kafji.net/terong/inputevent"
 	"kafji.net/terong/inputsink"
 	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
 	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/ctl"
+	"kafji.net/terong/terong/diagnostics"
+	"kafji.net/terong/terong/events"
+	"kafji.net/terong/terong/shutdown"
+	"kafji.net/terong/terong/transport"
 	"kafji.net/terong/terong/transport/client"
+	"kafji.net/terong/terong/transport/server"
+	"kafji.net/terong/tracing"
+	"kafji.net/terong/wol"
 )
 
 var slog = logging.NewLogger("terong/client")
 
-func Start(ctx context.Context) {
+func init() {
+	metrics.Handle(ctl.EventsPath, events.Handler())
+	metrics.Handle(ctl.DiagnosticsPath, diagnostics.Handler(live.config.Load))
+}
+
+type Options struct {
+	// TUI shows a status dashboard instead of log lines.
+	TUI bool
+}
+
+// Start runs the client until ctx is done or it fails. It returns the reason
+// it stopped, see [shutdown.Code].
+func Start(ctx context.Context, opts Options) error {
 	cfg, err := config.ReadConfig()
 	if err != nil {
 		slog.Error("failed to read config file", "error", err)
-		return
+		return &shutdown.ConfigError{Err: err}
 	}
 
-	watcher := config.Watch(ctx)
+	if opts.TUI {
+		runDashboard(ctx)
+	}
+
+	watcher := config.Watch(ctx, cfg)
 
 restart:
+	live.config.Store(cfg)
 	logging.SetLogLevel(cfg.LogLevel)
+	gctune.Apply(gctune.Config{Percent: cfg.GCPercent, MemoryLimit: cfg.MemoryLimitMiB << 20})
 
-	slog.Info("starting client", "config", cfg)
-	runCtx, cancelRun := context.WithCancel(ctx)
-	runDone := run(runCtx, cfg)
-	defer cancelRun()
+	slog.Info("starting client", "config", cfg.Redacted())
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	// certificates changed alone are swapped by the run loop, so the
+	// active session isn't dropped
+	certs := make(chan *config.Config, 1)
+	runDone := run(runCtx, cfg, certs)
+	defer cancelRun(nil)
 
-	var ok bool
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Error("context error", "error", err)
-			return
+			cause := context.Cause(ctx)
+			slog.Info("stopping client", "cause", cause)
+			return cause
 
 		case err := <-runDone:
 			slog.Error("error", "error", err)
-			return
+			return err
 
-		case cfg, ok = <-watcher.Configs():
+		case next, ok := <-watcher.Configs():
 			if !ok {
 				slog.Error("config watcher error", "error", watcher.Err())
-				return
+				return watcher.Err()
+			}
+			prev := cfg
+			cfg = next
+			live.config.Store(cfg)
+			for _, c := range config.Diff(prev, cfg) {
+				slog.Info("config changed", "key", c.Key, "old", c.Old, "new", c.New)
+			}
+			if cfg.OnlyCertsChanged(prev) {
+				select {
+				case certs <- cfg:
+					slog.Info("certificates changed, reloading them without restarting")
+					continue
+				default:
+				}
 			}
-			slog.Info("configurations changed", "config", cfg)
-			cancelRun()
+			slog.Info("configurations changed, restarting client")
+			cancelRun(shutdown.ErrConfigChanged)
 			goto restart
+
+		case <-watcher.Certs():
+			select {
+			case certs <- cfg:
+				slog.Info("certificate files changed, reloading them")
+			default:
+				// a reload is pending, it reads the files after
+			}
 		}
 	}
 }
 
-func run(ctx context.Context, cfg *config.Config) <-chan error {
+func run(ctx context.Context, cfg *config.Config, certs <-chan *config.Config) <-chan error {
 	done := make(chan error, 1)
 
 	go func() {
+		defer crash.Recover()
 		err := func() error {
 			inputs := make(chan inputevent.InputEvent)
 			defer close(inputs)
 
+			go metrics.LogSummaries(ctx, metrics.SummaryInterval)
+
+			if cfg.Tracing.Endpoint != "" {
+				tracing.Start(ctx, tracing.Config{
+					Endpoint:    cfg.Tracing.Endpoint,
+					ServiceName: "terong-client",
+					Node:        cfg.Name(),
+					SampleEvery: cfg.Tracing.SampleEvery,
+				})
+			}
+
+			var statusDone <-chan error
+			if cfg.StatusAddr != "" {
+				statusDone = metrics.Serve(ctx, cfg.StatusAddr, cfg.StatusAllowRemote)
+			}
+
+			if cfg.Client.ServerMAC != "" {
+				if err := wakeServer(cfg.Client.ServerMAC, cfg.Client.WakeAddr); err != nil {
+					slog.Warn("failed to wake server", "error", err)
+				}
+			}
+
 			transportCfg := &client.Config{
 				Addr:              cfg.Client.ServerAddr,
 				TLSCertPath:       cfg.Client.TLSCertPath,
 				TLSKeyPath:        cfg.Client.TLSKeyPath,
 				ServerTLSCertPath: cfg.Client.ServerTLSCertPath,
+				ServerName:        cfg.Client.ServerName,
+				TCP: transport.TCPConfig{
+					NoDelay:         cfg.Client.TCP.NoDelay,
+					KeepAlivePeriod: cfg.Client.TCP.KeepAlivePeriod,
+					ReadBufferSize:  cfg.Client.TCP.ReadBufferSize,
+					WriteBufferSize: cfg.Client.TCP.WriteBufferSize,
+				},
+				Session: transport.SessionConfig{
+					Checksum:         cfg.Client.FrameChecksum,
+					MaxMessageLength: cfg.Client.MaxMessageLength,
+					Trace:            cfg.Tracing.Endpoint != "",
+					LatencyAlert:     cfg.LatencyAlert,
+				},
+				Name:         cfg.Name(),
+				JoinCode:     cfg.Client.JoinCode,
+				UnmappedKeys: inputsink.UnmappedKeys,
+				Geometry:     geometry(cfg.Screens),
 			}
 			transport := client.Start(ctx, transportCfg)
+			live.transport.Store(transport)
+			live.relay.Store(false)
+
+			if cfg.Client.Downstream.Port != 0 {
+				return relay(ctx, cfg, transport, certs)
+			}
 
-			sinkDone := inputsink.Start(ctx, inputs)
+			sinkCfg := sinkConfig(cfg)
+			sink, stopSink := startSink(ctx, sinkCfg, inputs)
+			defer func() { stopSink() }()
+
+			var adjuster inputevent.Adjuster
+			queue := newQueue(cfg.Client.InputMaxAge)
 
 			for {
+				next := queue.head(time.Now())
+				var sinkInputs chan<- inputevent.InputEvent
+				if next != nil {
+					sinkInputs = inputs
+				}
+
 				select {
 				case <-ctx.Done():
-					return ctx.Err()
+					return context.Cause(ctx)
+
+				case err := <-statusDone:
+					slog.Warn("status endpoint stopped", "error", err)
+					statusDone = nil
 
-				case err := <-sinkDone:
+				case err := <-sink.Done():
 					return err
 
+				case enabled := <-transport.RelayStates():
+					live.relay.Store(enabled)
+					events.Publish(events.Event{Kind: events.RelayToggled, Peer: transport.Server(), Relay: &enabled})
+					if enabled {
+						slog.Info("relay enabled")
+					} else {
+						slog.Info("relay disabled")
+						sink.ReleaseAll()
+					}
+
+				case <-transport.Pauses():
+					sink.ReleaseAll()
+
+				case settings := <-transport.Settings():
+					adjuster.PointerScale = settings.PointerScale
+					if settings.InvertScroll != nil {
+						adjuster.InvertScroll = *settings.InvertScroll
+					}
+					repeatCfg := sinkCfg
+					if settings.KeyRepeatDelay > 0 && settings.KeyRepeatPeriod > 0 {
+						repeatCfg.RepeatDelay = settings.KeyRepeatDelay
+						repeatCfg.RepeatPeriod = settings.KeyRepeatPeriod
+					}
+					if repeatCfg != sinkCfg {
+						slog.Info("restarting sink to apply key repeat", "delay", repeatCfg.RepeatDelay, "period", repeatCfg.RepeatPeriod)
+						stopSink()
+						<-sink.Done()
+						sinkCfg = repeatCfg
+						sink, stopSink = startSink(ctx, sinkCfg, inputs)
+					}
+
+				case cmd := <-transport.Commands():
+					runCommand(ctx, cmd)
+
+				case next := <-certs:
+					reloadCerts(transport, next)
+
 				case input, ok := <-transport.Inputs():
 					if !ok {
 						return transport.Err()
 					}
-					slog.Debug("input received", "input", input)
-					inputs <- input
+					if slog.DebugEnabled() {
+						slog.Debug("input received", "input", input)
+					}
+					queue.push(adjuster.Adjust(input), time.Now())
+
+				case sinkInputs <- next:
+					queue.pop()
 				}
 			}
 		}()
@@ -95,3 +255,116 @@ func run(ctx context.Context, cfg *config.Config) <-chan error {
 
 	return done
 }
+
+// wakeServer sends a Wake-on-LAN packet to the server with mac.
+func wakeServer(mac string, addr string) error {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("failed to parse server mac: %v", err)
+	}
+	slog.Info("waking server", "mac", hw)
+	return wol.Send(hw, addr)
+}
+
+// geometry returns the geometry of screens, or nil if there are none.
+func geometry(screens []config.Screen) *transport.Geometry {
+	if len(screens) == 0 {
+		return nil
+	}
+	g := &transport.Geometry{Screens: make([]transport.Screen, len(screens))}
+	for i, s := range screens {
+		g.Screens[i] = transport.Screen(s)
+	}
+	return g
+}
+
+// reloadCerts swaps the certificates of transport for the ones of cfg.
+func reloadCerts(transport *client.Handle, cfg *config.Config) {
+	err := transport.ReloadTLS(cfg.Client.TLSCertPath, cfg.Client.TLSKeyPath, cfg.Client.ServerTLSCertPath)
+	if err != nil {
+		slog.Error("failed to reload certificates", "error", err)
+		return
+	}
+	slog.Info("certificates reloaded")
+}
+
+// sinkConfig returns the configurations of the sink from cfg.
+func sinkConfig(cfg *config.Config) inputsink.Config {
+	return inputsink.Config{
+		Backend:      inputsink.Backend(cfg.Client.SinkBackend),
+		RepeatDelay:  cfg.Client.KeyRepeatDelay,
+		RepeatPeriod: cfg.Client.KeyRepeatPeriod,
+
+		DoubleClickAssist:   cfg.Client.DoubleClickAssist,
+		DoubleClickInterval: cfg.Client.DoubleClickInterval,
+
+		MaxEventRate: cfg.Client.MaxEventRate,
+		EventBurst:   cfg.Client.EventBurst,
+
+		HighPriority: cfg.Client.HighPriority,
+	}
+}
+
+// startSink starts a sink that can be stopped independently of ctx.
+func startSink(ctx context.Context, cfg inputsink.Config, inputs <-chan inputevent.InputEvent) (*inputsink.Handle, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	return inputsink.Start(ctx, cfg, inputs), cancel
+}
+
+// relay forwards inputs received from upstream to a downstream client.
+func relay(ctx context.Context, cfg *config.Config, upstream *client.Handle, certs <-chan *config.Config) error {
+	inputs := make(chan inputevent.InputEvent)
+
+	downstreamCfg := &server.Config{
+		Addr:              fmt.Sprintf(":%d", cfg.Client.Downstream.Port),
+		TLSCertPath:       cfg.Client.Downstream.TLSCertPath,
+		TLSKeyPath:        cfg.Client.Downstream.TLSKeyPath,
+		ClientTLSCertPath: cfg.Client.Downstream.ClientTLSCertPath,
+		Name:              cfg.Name(),
+		Route:             upstream.Route,
+		Session:           transport.SessionConfig{LatencyAlert: cfg.LatencyAlert},
+	}
+	downstream := server.Start(ctx, downstreamCfg, inputs)
+
+	slog.Info("relaying inputs downstream", "port", cfg.Client.Downstream.Port)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+
+		case err := <-downstream.Done():
+			return err
+
+		case enabled := <-upstream.RelayStates():
+			live.relay.Store(enabled)
+			events.Publish(events.Event{Kind: events.RelayToggled, Peer: upstream.Server(), Relay: &enabled})
+			slog.Info("relay state changed", "enabled", enabled)
+			downstream.SetRelayState(enabled)
+
+		case <-upstream.Pauses():
+			// downstream is paused through the relay state
+
+		case settings := <-upstream.Settings():
+			// settings tune the local sink, which a relay doesn't have
+			slog.Info("ignoring pushed settings while relaying", "settings", settings)
+
+		case cmd := <-upstream.Commands():
+			// commands are meant for the machine inputs are relayed to
+			downstream.SendCommand(cmd)
+
+		case next := <-certs:
+			reloadCerts(upstream, next)
+
+		case input, ok := <-upstream.Inputs():
+			if !ok {
+				return upstream.Err()
+			}
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case inputs <- input:
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"kafji.net/terong/crash"
+	"kafji.net/terong/terong/transport"
+)
+
+// commandTimeout bounds how long a command may run.
+const commandTimeout = 10 * time.Second
+
+// commandArgs returns the program and arguments carrying out action.
+//
+// Locking and suspending go through systemd-logind. Turning off the displays
+// goes through DPMS, which only works on X11.
+func commandArgs(action string) ([]string, error) {
+	switch action {
+	case transport.CommandLock:
+		if id := os.Getenv("XDG_SESSION_ID"); id != "" {
+			return []string{"loginctl", "lock-session", id}, nil
+		}
+		// not started from a login session, e.g. as a user service
+		return []string{"loginctl", "lock-sessions"}, nil
+	case transport.CommandSuspend:
+		return []string{"systemctl", "suspend"}, nil
+	case transport.CommandDisplayOff:
+		return []string{"xset", "dpms", "force", "off"}, nil
+	}
+	return nil, fmt.Errorf("unknown command action: %s", action)
+}
+
+// runCommand carries out cmd in the background.
+func runCommand(ctx context.Context, cmd transport.Command) {
+	args, err := commandArgs(cmd.Action)
+	if err != nil {
+		slog.Warn("failed to run command", "error", err)
+		return
+	}
+	go func() {
+		defer crash.Recover()
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		slog.Info("running command", "action", cmd.Action, "args", args)
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+		if err != nil {
+			slog.Warn("failed to run command", "action", cmd.Action, "error", err, "output", string(out))
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"kafji.net/terong/crash"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/transport/client"
+	"kafji.net/terong/terong/tui"
+)
+
+// how many warnings the dashboard shows
+const dashboardWarnings = 8
+
+// live is the state shown by the dashboard, updated by the run loop.
+var live struct {
+	relay     atomic.Bool
+	transport atomic.Pointer[client.Handle]
+	// config in use, for diagnostics
+	config atomic.Pointer[config.Config]
+}
+
+// runDashboard shows the dashboard instead of log lines until ctx is done.
+func runDashboard(ctx context.Context) {
+	d := tui.Dashboard{
+		Title:  "terong client",
+		Fields: dashboardFields,
+		Hint:   "double tap Right Ctrl on the server to toggle relay",
+		Recent: logging.Capture(dashboardWarnings),
+	}
+	go func() {
+		defer crash.Recover()
+		d.Run(ctx, os.Stdout)
+	}()
+}
+
+func dashboardFields() []tui.Field {
+	server, fingerprint, route := "disconnected", "", ""
+	if t := live.transport.Load(); t != nil {
+		if v := t.Server(); v != "" {
+			server = v
+		}
+		fingerprint = t.ServerFingerprint()
+		route = strings.Join(t.Route(), " > ")
+	}
+	relay := "off"
+	if live.relay.Load() {
+		relay = "on"
+	}
+	fields := []tui.Field{{Label: "server", Value: server}}
+	if fingerprint != "" {
+		fields = append(fields, tui.Field{Label: "server certificate", Value: fingerprint})
+	}
+	fields = append(fields, tui.Field{Label: "relay", Value: relay})
+	if route != "" {
+		fields = append(fields, tui.Field{Label: "route", Value: route})
+	}
+	return fields
+}
@@ -0,0 +1,89 @@
+//go:build linux
+
+package client
+
+import (
+	"time"
+
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/metrics"
+)
+
+const (
+	// queueCapacity is how many received inputs are queued before droppable
+	// ones are dropped.
+	queueCapacity = 1024
+	// defaultInputMaxAge is how long mouse moves wait for the sink if
+	// input_max_age isn't set.
+	defaultInputMaxAge = 100 * time.Millisecond
+)
+
+var (
+	// queueStale counts mouse moves dropped for waiting on the sink longer
+	// than the max age.
+	queueStale = metrics.NewCounterMap("sink_queue_stale_inputs")
+	// queueDropped counts inputs dropped because the queue was full.
+	queueDropped = metrics.NewCounterMap("sink_queue_dropped_inputs")
+)
+
+type queued struct {
+	input    inputevent.InputEvent
+	received time.Time
+}
+
+// queue holds inputs received from the server until the sink takes them, so a
+// stalled sink doesn't stall reading from the server and delay its pings.
+// Inputs stay in order. A mouse move that waited longer than the max age is
+// dropped, the pointer jumping late is worse than it not moving. Key presses,
+// mouse clicks and gamepad button presses are never dropped, other inputs are
+// dropped while the queue is full.
+//
+// It is only used from the run loop, which sends [queue.head] to the sink and
+// then calls [queue.pop].
+type queue struct {
+	maxAge time.Duration
+	items  *inputevent.Queue[queued]
+}
+
+func newQueue(maxAge time.Duration) *queue {
+	if maxAge <= 0 {
+		maxAge = defaultInputMaxAge
+	}
+	return &queue{maxAge: maxAge, items: inputevent.NewQueue[queued](queueCapacity)}
+}
+
+func (q *queue) push(input inputevent.InputEvent, now time.Time) {
+	if q.items.Len() >= queueCapacity && droppable(input) {
+		queueDropped.Add(inputevent.TypeName(input), 1)
+		return
+	}
+	q.items.Push(queued{input: input, received: now})
+}
+
+// head returns the oldest queued input, after dropping the mouse moves that
+// are stale at now. It returns nil when nothing is queued.
+func (q *queue) head(now time.Time) inputevent.InputEvent {
+	for q.items.Len() > 0 {
+		item := q.items.Head()
+		if _, ok := item.input.(inputevent.MouseMove); !ok || now.Sub(item.received) <= q.maxAge {
+			return item.input
+		}
+		queueStale.Add(inputevent.TypeName(item.input), 1)
+		q.pop()
+	}
+	return nil
+}
+
+// pop removes the head once it was sent.
+func (q *queue) pop() {
+	q.items.Pop()
+}
+
+// droppable reports whether input may be dropped when the queue is full.
+func droppable(input inputevent.InputEvent) bool {
+	switch input.(type) {
+	case inputevent.KeyPress, inputevent.MouseClick, inputevent.GamepadButtonPress:
+		return false
+	}
+	return true
+}
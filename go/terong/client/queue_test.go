@@ -0,0 +1,69 @@
+//go:build linux
+
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestQueueHead(t *testing.T) {
+	start := time.Unix(0, 0)
+	move := inputevent.MouseMove{DX: 1}
+	key := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionDown}
+	click := inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionDown}
+
+	for _, tc := range []struct {
+		name   string
+		inputs []inputevent.InputEvent
+		// age of the inputs when taken
+		age  time.Duration
+		want []inputevent.InputEvent
+	}{
+		{"fresh moves are kept", []inputevent.InputEvent{move, move}, defaultInputMaxAge, []inputevent.InputEvent{move, move}},
+		{"stale moves are dropped", []inputevent.InputEvent{move, key, move}, defaultInputMaxAge + 1, []inputevent.InputEvent{key}},
+		{"stale keys and clicks are kept", []inputevent.InputEvent{key, click}, time.Hour, []inputevent.InputEvent{key, click}},
+	} {
+		q := newQueue(0)
+		for _, input := range tc.inputs {
+			q.push(input, start)
+		}
+		var got []inputevent.InputEvent
+		for input := q.head(start.Add(tc.age)); input != nil; input = q.head(start.Add(tc.age)) {
+			got = append(got, input)
+			q.pop()
+		}
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestQueueMaxAge(t *testing.T) {
+	start := time.Unix(0, 0)
+	q := newQueue(time.Second)
+	q.push(inputevent.MouseMove{DX: 1}, start)
+	assert.NotNil(t, q.head(start.Add(time.Second)))
+	assert.Nil(t, q.head(start.Add(time.Second+1)))
+}
+
+func TestQueueFull(t *testing.T) {
+	start := time.Unix(0, 0)
+	scroll := inputevent.MouseScroll{Direction: inputevent.MouseScrollUp, Count: 1}
+	key := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp}
+	click := inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionUp}
+	button := inputevent.GamepadButtonPress{Button: inputevent.GamepadButtonSouth, Action: inputevent.GamepadButtonActionUp}
+
+	q := newQueue(0)
+	for i := 0; i < queueCapacity; i++ {
+		q.push(scroll, start)
+	}
+	for _, input := range []inputevent.InputEvent{key, scroll, inputevent.MouseMove{DX: 1}, click, inputevent.GamepadAxisMove{}, button} {
+		q.push(input, start)
+	}
+	assert.Len(t, q.items.Items(), queueCapacity+3)
+	for i, input := range []inputevent.InputEvent{key, click, button} {
+		assert.Equal(t, input, q.items.Items()[queueCapacity+i].input)
+	}
+}
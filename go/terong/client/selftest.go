@@ -0,0 +1,49 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"kafji.net/terong/inputsink"
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/selftest"
+	"kafji.net/terong/terong/shutdown"
+	"kafji.net/terong/terong/transport/client"
+	"kafji.net/terong/terong/transport/server"
+)
+
+// SelfTest checks what the client needs to run, writing a line per check to
+// w: the config file, the certificates, and the virtual device, which is
+// created and destroyed right away. A relaying client has its downstream
+// certificates and port checked instead of the device. It returns an error if
+// a check failed, see [shutdown.Code].
+func SelfTest(ctx context.Context, w io.Writer) error {
+	cfg, err := config.ReadConfig()
+	if !selftest.Report(w, "config", err) {
+		return &shutdown.ConfigError{Err: err}
+	}
+
+	ok := selftest.Report(w, "tls", client.CheckTLS(&client.Config{
+		TLSCertPath:       cfg.Client.TLSCertPath,
+		TLSKeyPath:        cfg.Client.TLSKeyPath,
+		ServerTLSCertPath: cfg.Client.ServerTLSCertPath,
+		ServerName:        cfg.Client.ServerName,
+	}))
+	if d := cfg.Client.Downstream; d.Port != 0 {
+		ok = selftest.Report(w, "downstream tls", server.CheckTLS(&server.Config{
+			TLSCertPath:       d.TLSCertPath,
+			TLSKeyPath:        d.TLSKeyPath,
+			ClientTLSCertPath: d.ClientTLSCertPath,
+		})) && ok
+		ok = selftest.Report(w, fmt.Sprintf("port %d", d.Port), selftest.CheckPort(ctx, fmt.Sprintf(":%d", d.Port))) && ok
+	} else {
+		ok = selftest.Report(w, "sink device", inputsink.Check(sinkConfig(cfg))) && ok
+	}
+	if !ok {
+		return selftest.ErrFailed
+	}
+	return nil
+}
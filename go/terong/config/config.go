@@ -1,9 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"reflect"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/inputevent"
 	"kafji.net/terong/logging"
 )
 
@@ -12,16 +17,249 @@ var slog = logging.NewLogger("config")
 const filePath = "./terong.toml"
 
 type Config struct {
+	// LogLevel is the least severe level logged, "debug", "info" (default),
+	// "warn", or "error".
 	LogLevel string `toml:"log_level"`
-	Server   Server `toml:"server"`
-	Client   Client `toml:"client"`
+	// StatusAddr is where the status endpoint listens, e.g. "127.0.0.1:59002".
+	// Empty disables the endpoint. It must be a loopback address unless
+	// StatusAllowRemote is set.
+	StatusAddr string `toml:"status_addr"`
+	// StatusAllowRemote lets the status endpoint listen on other addresses.
+	// It's unauthenticated, anyone reaching it can control relay and
+	// download diagnostics.
+	StatusAllowRemote bool `toml:"status_allow_remote"`
+	// NodeName identifies this machine in relay routes. Defaults to the host
+	// name.
+	NodeName string `toml:"node_name"`
+	// LatencyAlert publishes a latency_alert event when the round-trip time
+	// to the peer rises above it, e.g. "50ms". Zero disables the alert.
+	LatencyAlert time.Duration `toml:"latency_alert"`
+	// GCPercent is as GOGC, e.g. 200 collects half as often on twice the
+	// heap. Zero uses the default of 100.
+	GCPercent int `toml:"gc_percent"`
+	// MemoryLimitMiB is the soft memory limit, as GOMEMLIMIT, that makes
+	// collections more frequent near it. Zero uses the default of 256.
+	MemoryLimitMiB int64 `toml:"memory_limit_mib"`
+	// Screens are the displays of this machine, sent to the peer for
+	// features that map coordinates between machines, e.g. screens =
+	// [{x = 0, y = 0, width = 2560, height = 1440}]. Empty sends none.
+	Screens []Screen `toml:"screens"`
+	// Tracing exports spans of sampled inputs to an OpenTelemetry
+	// collector. Both ends need it to trace the whole path.
+	Tracing Tracing `toml:"tracing"`
+	Server  Server  `toml:"server"`
+	Client  Client  `toml:"client"`
+}
+
+// Screen is a display in the desktop coordinates of this machine, in pixels.
+type Screen struct {
+	X      int32 `toml:"x"`
+	Y      int32 `toml:"y"`
+	Width  int32 `toml:"width"`
+	Height int32 `toml:"height"`
+}
+
+type Tracing struct {
+	// Endpoint is the OTLP/HTTP traces endpoint of the collector, e.g.
+	// "http://localhost:4318/v1/traces". Empty disables tracing.
+	Endpoint string `toml:"endpoint"`
+	// SampleEvery traces one of every this many inputs relayed by the
+	// server. Zero traces one of every 100.
+	SampleEvery int `toml:"sample_every"`
+}
+
+// Name returns NodeName, or the host name if it's empty.
+func (c *Config) Name() string {
+	if c.NodeName != "" {
+		return c.NodeName
+	}
+	name, err := os.Hostname()
+	if err != nil {
+		slog.Warn("failed to get host name", "error", err)
+	}
+	return name
+}
+
+// OnlyCertsChanged reports whether c differs from prev only in the
+// certificate paths of the server or the client, which are swapped without
+// restarting.
+func (c *Config) OnlyCertsChanged(prev *Config) bool {
+	a, b := *c, *prev
+	for _, cfg := range []*Config{&a, &b} {
+		cfg.Server.TLSCertPath, cfg.Server.TLSKeyPath, cfg.Server.ClientTLSCertPath = "", "", ""
+		cfg.Client.TLSCertPath, cfg.Client.TLSKeyPath, cfg.Client.ServerTLSCertPath = "", "", ""
+	}
+	return !reflect.DeepEqual(c, prev) && reflect.DeepEqual(a, b)
+}
+
+// certPaths returns the certificate and key paths that are set.
+func (c *Config) certPaths() []string {
+	var paths []string
+	for _, path := range []string{
+		c.Server.TLSCertPath, c.Server.TLSKeyPath, c.Server.ClientTLSCertPath,
+		c.Client.TLSCertPath, c.Client.TLSKeyPath, c.Client.ServerTLSCertPath,
+	} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
 }
 
 type Server struct {
-	Port              uint16 `toml:"port"`
+	Port uint16 `toml:"port"`
+	// The certificate files are reloaded without dropping the session when
+	// their content changes, e.g. renewed over acme, or by an ACME client
+	// such as certbot or lego.
 	TLSCertPath       string `toml:"tls_cert_path"`
 	TLSKeyPath        string `toml:"tls_key_path"`
 	ClientTLSCertPath string `toml:"client_tls_cert_path"`
+	TCP               TCP    `toml:"tcp"`
+	ACME              ACME   `toml:"acme"`
+
+	// Inputs written within this delay share a single TCP write. Zero
+	// disables coalescing.
+	WriteCoalesceDelay  time.Duration `toml:"write_coalesce_delay"`
+	WriteCoalesceFrames int           `toml:"write_coalesce_frames"`
+
+	// FrameChecksum agrees to checksum every frame when the client offers
+	// it. TLS already detects corruption, this is for transports without
+	// integrity of their own.
+	FrameChecksum bool `toml:"frame_checksum"`
+
+	// MaxMessageLength is the largest message accepted from the client in
+	// bytes. Zero uses the default of 1 MiB.
+	MaxMessageLength int `toml:"max_message_length"`
+
+	// Sessions older than this are closed and the client has to reconnect,
+	// with a full TLS handshake as sessions aren't resumed when set.
+	// Zero means no limit.
+	MaxSessionLifetime time.Duration `toml:"max_session_lifetime"`
+	// SessionPolicy is what to do with a connection arriving while a client
+	// is connected: "reject" (default) it, or "takeover" the session. Two
+	// running clients with takeover will keep superseding each other.
+	SessionPolicy string `toml:"session_policy"`
+
+	// ClientNames maps client certificate SHA-256 fingerprints to names used
+	// in logs and the status endpoint, e.g. "AB:CD:..." = "laptop".
+	ClientNames map[string]string `toml:"client_names"`
+
+	// ClientAllowlistPath is a file of trusted client certificate
+	// fingerprints, one per line, accepted in addition to
+	// ClientTLSCertPath. Clients joining with a join code are added to it.
+	ClientAllowlistPath string `toml:"client_allowlist_path"`
+	// JoinCodeLifetime mints a one-time join code at start, valid for this
+	// long, which lets a new client be trusted without copying its
+	// certificate over. Requires ClientAllowlistPath. Zero mints none.
+	JoinCodeLifetime time.Duration `toml:"join_code_lifetime"`
+
+	// StateFile is where the relay state is saved whenever it changes. Empty
+	// disables saving.
+	StateFile string `toml:"state_file"`
+	// RestoreRelay turns relay back on at start if it was on when the state
+	// was saved. Otherwise the server always starts with relay off.
+	RestoreRelay bool `toml:"restore_relay"`
+	// StatsFile is where daily aggregates of relaying are kept, shown by
+	// terongctl stats and the dashboard. When it's changed, recording moves
+	// on to the new file, and the old one keeps the days so far. Empty
+	// disables them.
+	StatsFile string `toml:"stats_file"`
+
+	// Mouse moves below this many pixels on both axes are not relayed.
+	MouseDeadZone uint16 `toml:"mouse_dead_zone"`
+	// Mouse moves of up to this many pixels that reverse the previous one
+	// within the window are not relayed, to hide the jitter of a worn mouse.
+	// Zero disables the filter.
+	MouseJitterThreshold uint16        `toml:"mouse_jitter_threshold"`
+	MouseJitterWindow    time.Duration `toml:"mouse_jitter_window"`
+
+	// RelayGamepad relays the first XInput controller along with the mouse
+	// and keyboard. The controller keeps driving the server too.
+	RelayGamepad bool `toml:"relay_gamepad"`
+	// RelayTouchpad relays the fingers on precision touchpads so the client
+	// desktop recognizes gestures. Windows gestures still fire on the server.
+	RelayTouchpad bool `toml:"relay_touchpad"`
+	// RelayPen relays pen tablets, with pressure and tilt, to the client's
+	// virtual tablet.
+	RelayPen bool `toml:"relay_pen"`
+	// HighPriority runs the hook message loop at time critical priority,
+	// and the server at high priority class, against latency spikes under
+	// load.
+	HighPriority bool `toml:"high_priority"`
+	// How often the cursor is moved back to the screen center while relaying.
+	// Zero disables periodic recentering.
+	MouseRecenterInterval time.Duration `toml:"mouse_recenter_interval"`
+	// MouseRampIn scales mouse moves up from zero over this long when relay
+	// turns on, so the client cursor doesn't jump, e.g. 100ms. Zero disables
+	// the ramp.
+	MouseRampIn time.Duration `toml:"mouse_ramp_in"`
+	// MouseSkipRecenterMove drops the first mouse move after the cursor is
+	// moved back to the screen center, as it may still be measured from where
+	// the cursor was before.
+	MouseSkipRecenterMove bool `toml:"mouse_skip_recenter_move"`
+
+	// Double tapping KeyboardRelayKey relays only the keyboard, and double
+	// tapping MouseRelayKey only the mouse, while the other keeps driving the
+	// server. Double tapping again turns relay off. Keys are named as in
+	// inputevent, e.g. "RightShift". Empty disables the hotkey.
+	KeyboardRelayKey inputevent.KeyCode `toml:"keyboard_relay_key,omitzero"`
+	MouseRelayKey    inputevent.KeyCode `toml:"mouse_relay_key,omitzero"`
+	// ToggleGracePeriod is how long nothing is relayed after relay turns
+	// on, so the trailing events of the toggle hotkey stay on the server,
+	// e.g. 50ms. Keys held when relay turns on never have their release
+	// relayed either way.
+	ToggleGracePeriod time.Duration `toml:"toggle_grace_period"`
+	// CommandKeys maps commands carried out by the client, "lock",
+	// "suspend", or "display_off", to keys that send them when double tapped,
+	// e.g. lock = "PauseBreak".
+	CommandKeys map[string]inputevent.KeyCode `toml:"command_keys"`
+
+	// ClientSettings are pushed to the client at session start and override
+	// its own.
+	ClientSettings ClientSettings `toml:"client_settings"`
+
+	// StatsOverlay shows the round-trip time, event rate, and dropped inputs
+	// in the console window title while relaying.
+	StatsOverlay bool `toml:"stats_overlay"`
+
+	// Relay is suspended while a window matching any of these rules is in the
+	// foreground.
+	RelayExceptions []WindowRule `toml:"relay_exceptions"`
+
+	// Sessions are refused and relay can't be enabled outside of these
+	// windows. Empty allows any time.
+	Schedule []ScheduleWindow `toml:"schedule"`
+
+	// ClientMAC is the hardware address of the client, e.g.
+	// "01:23:45:67:89:ab". When set, turning relay on while no client is
+	// connected sends it a Wake-on-LAN packet.
+	ClientMAC string `toml:"client_mac"`
+	// WakeAddr is where Wake-on-LAN packets are sent. Empty sends them to
+	// 255.255.255.255:9.
+	WakeAddr string `toml:"wake_addr"`
+}
+
+// ClientSettings are client settings tuned on the server. Zero values leave
+// the client's own settings in place.
+type ClientSettings struct {
+	PointerScale    float64       `toml:"pointer_scale"`
+	InvertScroll    *bool         `toml:"invert_scroll"`
+	KeyRepeatDelay  time.Duration `toml:"key_repeat_delay"`
+	KeyRepeatPeriod time.Duration `toml:"key_repeat_period"`
+}
+
+// WindowRule matches windows by process name, window class, or both.
+type WindowRule struct {
+	ProcessName string `toml:"process_name"`
+	WindowClass string `toml:"window_class"`
+}
+
+// ScheduleWindow is a daily period of time, e.g. days = ["sat", "sun"],
+// start = "09:00", end = "21:00". No days means every day.
+type ScheduleWindow struct {
+	Days  []string `toml:"days"`
+	Start string   `toml:"start"`
+	End   string   `toml:"end"`
 }
 
 type Client struct {
@@ -29,21 +267,158 @@ type Client struct {
 	TLSCertPath       string `toml:"tls_cert_path"`
 	TLSKeyPath        string `toml:"tls_key_path"`
 	ServerTLSCertPath string `toml:"server_tls_cert_path"`
+	TCP               TCP    `toml:"tcp"`
+
+	// ServerName, when set, verifies the server certificate as issued for
+	// this name by a CA, e.g. over ACME, see acme of the server, instead of
+	// pinning it. The CA is one of the system's, or the one at
+	// ServerTLSCertPath if set.
+	ServerName string `toml:"server_name"`
+
+	// JoinCode is presented to a server that doesn't trust this client's
+	// certificate yet, see join_code_lifetime of the server. It can be
+	// removed once the client joined.
+	JoinCode string `toml:"join_code" redact:"true"`
+
+	// FrameChecksum offers the server to checksum every frame. Corrupted
+	// frames are dropped and counted instead of decoded.
+	FrameChecksum bool `toml:"frame_checksum"`
+
+	// MaxMessageLength is the largest message accepted from the server in
+	// bytes. Zero uses the default of 1 MiB.
+	MaxMessageLength int `toml:"max_message_length"`
+
+	// SinkBackend is how inputs are injected, "uinput" (default) or "xtest".
+	// xtest needs a client built with -tags xtest.
+	SinkBackend string `toml:"sink_backend"`
+
+	// When both are set, the uinput device repeats held keys by itself
+	// after KeyRepeatDelay, every KeyRepeatPeriod.
+	KeyRepeatDelay  time.Duration `toml:"key_repeat_delay"`
+	KeyRepeatPeriod time.Duration `toml:"key_repeat_period"`
+
+	// Two clicks further apart than DoubleClickInterval, the desktop's
+	// setting, but within DoubleClickAssist are injected as a double-click,
+	// in case network delay split one. Zero DoubleClickAssist disables it.
+	DoubleClickAssist   time.Duration `toml:"double_click_assist"`
+	DoubleClickInterval time.Duration `toml:"double_click_interval"`
+
+	// MaxEventRate limits injected inputs to this many per second, with
+	// bursts of up to EventBurst, for slow uinput consumers or compositors.
+	// Mouse moves over the limit are merged into the next one, keys and
+	// clicks always pass. Zero disables the limit.
+	MaxEventRate float64 `toml:"max_event_rate"`
+	EventBurst   int     `toml:"event_burst"`
+
+	// InputMaxAge is how long a mouse move waits for a stalled sink before
+	// it's dropped. Keys and clicks are never dropped. Zero uses the default
+	// of 100ms.
+	InputMaxAge time.Duration `toml:"input_max_age"`
+
+	// HighPriority injects inputs from a SCHED_FIFO thread, or one at nice
+	// -10 if that isn't permitted, against latency spikes under load. It
+	// needs CAP_SYS_NICE or a matching RLIMIT_RTPRIO or RLIMIT_NICE.
+	HighPriority bool `toml:"high_priority"`
+
+	// Downstream makes this client relay the inputs it receives to a further
+	// client instead of injecting them.
+	Downstream Downstream `toml:"downstream"`
+
+	// ServerMAC is the hardware address of the server. When set, the client
+	// sends it a Wake-on-LAN packet on start.
+	ServerMAC string `toml:"server_mac"`
+	// WakeAddr is where Wake-on-LAN packets are sent. Empty sends them to
+	// 255.255.255.255:9.
+	WakeAddr string `toml:"wake_addr"`
 }
 
+// Downstream is the server side of a relaying client. Zero Port disables it.
+type Downstream struct {
+	Port              uint16 `toml:"port"`
+	TLSCertPath       string `toml:"tls_cert_path"`
+	TLSKeyPath        string `toml:"tls_key_path"`
+	ClientTLSCertPath string `toml:"client_tls_cert_path"`
+}
+
+// ACME obtains and renews the certificate at tls_cert_path and tls_key_path
+// of the server from an ACME CA with DNS-01 challenges. Clients verify it by
+// their server_name. Client certificates are still pinned.
+type ACME struct {
+	// Domain is the name the certificate is for. Empty disables ACME.
+	Domain string `toml:"domain"`
+	// Email is given to the CA for expiry notices, if set.
+	Email string `toml:"email" redact:"true"`
+	// DirectoryURL is the directory of the CA. Empty uses Let's Encrypt.
+	DirectoryURL string `toml:"directory_url"`
+	// AccountKeyPath is where the account key is kept, created if missing.
+	AccountKeyPath string `toml:"account_key_path"`
+	// DNSHook is a program run as `hook present <fqdn> <value>` to publish
+	// the TXT record of a challenge, returning once it's visible, and as
+	// `hook cleanup <fqdn> <value>` to remove it, e.g. with nsupdate.
+	DNSHook string `toml:"dns_hook"`
+	// RenewBefore is how long before it expires the certificate is renewed.
+	// Zero is 30 days.
+	RenewBefore time.Duration `toml:"renew_before"`
+}
+
+type TCP struct {
+	NoDelay         *bool         `toml:"no_delay"`
+	KeepAlivePeriod time.Duration `toml:"keep_alive_period"`
+	ReadBufferSize  int           `toml:"read_buffer_size"`
+	WriteBufferSize int           `toml:"write_buffer_size"`
+}
+
+// ProfileEnv names the profile to use if none is selected with
+// SelectProfile.
+const ProfileEnv = "TERONG_PROFILE"
+
+var profile = os.Getenv(ProfileEnv)
+
+// SelectProfile makes ReadConfig apply the profile with name. Empty name
+// applies none.
+func SelectProfile(name string) {
+	profile = name
+}
+
+// file is the config file. Every profile is a table of the same shape as
+// Config, e.g. [profiles.office.client], whose keys override the top level
+// ones when the profile is selected.
+type file struct {
+	Config
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+}
+
+// ReadConfig reads the config file. Its errors are [errs.ErrConfigInvalid].
 func ReadConfig() (*Config, error) {
 	file, err := os.ReadFile(filePath)
 	if err != nil {
-		return nil, err
+		return nil, errs.Mark(err, errs.ErrConfigInvalid)
 	}
-	return readConfigString(string(file))
+	cfg, err := readProfileString(string(file), profile)
+	return cfg, errs.Mark(err, errs.ErrConfigInvalid)
 }
 
 func readConfigString(s string) (*Config, error) {
-	var c Config
-	err := toml.Unmarshal([]byte(s), &c)
+	return readProfileString(s, "")
+}
+
+// readProfileString reads s with the profile applied, none if profile is
+// empty.
+func readProfileString(s string, profile string) (*Config, error) {
+	var f file
+	md, err := toml.Decode(s, &f)
 	if err != nil {
 		return nil, err
 	}
-	return &c, nil
+	if profile == "" {
+		return &f.Config, nil
+	}
+	p, ok := f.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", profile)
+	}
+	if err := md.PrimitiveDecode(p, &f.Config); err != nil {
+		return nil, fmt.Errorf("failed to decode profile %q: %v", profile, err)
+	}
+	return &f.Config, nil
 }
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
 )
 
 func TestReadEmptyConfig(t *testing.T) {
@@ -51,3 +55,415 @@ server_tls_cert_path = "./server_cert.pem"
 		ServerTLSCertPath: "./server_cert.pem",
 	}}, *c)
 }
+
+func TestReadWriteCoalescing(t *testing.T) {
+	c, err := readConfigString(`[server]
+write_coalesce_delay = "2ms"
+write_coalesce_frames = 8
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{
+		WriteCoalesceDelay:  2 * time.Millisecond,
+		WriteCoalesceFrames: 8,
+	}}, *c)
+}
+
+func TestReadSinkBackend(t *testing.T) {
+	c, err := readConfigString(`[client]
+sink_backend = "xtest"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Client: Client{SinkBackend: "xtest"}}, *c)
+}
+
+func TestReadMaxSessionLifetime(t *testing.T) {
+	c, err := readConfigString(`[server]
+max_session_lifetime = "8h"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{MaxSessionLifetime: 8 * time.Hour}}, *c)
+}
+
+func TestReadSessionPolicy(t *testing.T) {
+	c, err := readConfigString(`[server]
+session_policy = "takeover"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{SessionPolicy: "takeover"}}, *c)
+}
+
+func TestReadKeyRepeat(t *testing.T) {
+	c, err := readConfigString(`[client]
+key_repeat_delay = "300ms"
+key_repeat_period = "25ms"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Client: Client{
+		KeyRepeatDelay:  300 * time.Millisecond,
+		KeyRepeatPeriod: 25 * time.Millisecond,
+	}}, *c)
+}
+
+func TestReadRelayState(t *testing.T) {
+	c, err := readConfigString(`[server]
+state_file = "./terong-state.json"
+restore_relay = true
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{
+		StateFile:    "./terong-state.json",
+		RestoreRelay: true,
+	}}, *c)
+}
+
+func TestReadFrameChecksum(t *testing.T) {
+	c, err := readConfigString(`[server]
+frame_checksum = true
+
+[client]
+frame_checksum = true
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{
+		Server: Server{FrameChecksum: true},
+		Client: Client{FrameChecksum: true},
+	}, *c)
+}
+
+func TestReadMaxMessageLength(t *testing.T) {
+	c, err := readConfigString(`[server]
+max_message_length = 65536
+
+[client]
+max_message_length = 65536
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{
+		Server: Server{MaxMessageLength: 65536},
+		Client: Client{MaxMessageLength: 65536},
+	}, *c)
+}
+
+func TestReadStatsOverlay(t *testing.T) {
+	c, err := readConfigString(`[server]
+stats_overlay = true
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{StatsOverlay: true}}, *c)
+}
+
+func TestReadToggleGracePeriod(t *testing.T) {
+	c, err := readConfigString(`[server]
+toggle_grace_period = "50ms"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{ToggleGracePeriod: 50 * time.Millisecond}}, *c)
+}
+
+func TestReadStatsFile(t *testing.T) {
+	c, err := readConfigString(`[server]
+stats_file = "./stats.json"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{StatsFile: "./stats.json"}}, *c)
+}
+
+func TestReadHighPriority(t *testing.T) {
+	c, err := readConfigString(`[server]
+high_priority = true
+
+[client]
+high_priority = true
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{
+		Server: Server{HighPriority: true},
+		Client: Client{HighPriority: true},
+	}, *c)
+}
+
+func TestReadTCPConfig(t *testing.T) {
+	c, err := readConfigString(`[server.tcp]
+no_delay = false
+keep_alive_period = "30s"
+read_buffer_size = 65536
+write_buffer_size = 32768
+`)
+	assert.NoError(t, err)
+	noDelay := false
+	require.Equal(t, Config{Server: Server{TCP: TCP{
+		NoDelay:         &noDelay,
+		KeepAlivePeriod: 30 * time.Second,
+		ReadBufferSize:  65536,
+		WriteBufferSize: 32768,
+	}}}, *c)
+}
+
+func TestReadACMEConfig(t *testing.T) {
+	c, err := readConfigString(`[server.acme]
+domain = "terong.example"
+email = "admin@terong.example"
+account_key_path = "./acme.key"
+dns_hook = "./dns-hook.sh"
+renew_before = "720h"
+
+[client]
+server_name = "terong.example"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{
+		Server: Server{ACME: ACME{
+			Domain:         "terong.example",
+			Email:          "admin@terong.example",
+			AccountKeyPath: "./acme.key",
+			DNSHook:        "./dns-hook.sh",
+			RenewBefore:    720 * time.Hour,
+		}},
+		Client: Client{ServerName: "terong.example"},
+	}, *c)
+}
+
+func TestReadRelayExceptions(t *testing.T) {
+	c, err := readConfigString(`[[server.relay_exceptions]]
+process_name = "KeePassXC.exe"
+
+[[server.relay_exceptions]]
+window_class = "Credential Dialog Xaml Host"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{RelayExceptions: []WindowRule{
+		{ProcessName: "KeePassXC.exe"},
+		{WindowClass: "Credential Dialog Xaml Host"},
+	}}}, *c)
+}
+
+func TestReadSchedule(t *testing.T) {
+	c, err := readConfigString(`[[server.schedule]]
+days = ["sat", "sun"]
+start = "09:00"
+end = "21:00"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{Schedule: []ScheduleWindow{
+		{Days: []string{"sat", "sun"}, Start: "09:00", End: "21:00"},
+	}}}, *c)
+}
+
+func TestReadClientSettings(t *testing.T) {
+	c, err := readConfigString(`[server.client_settings]
+pointer_scale = 1.25
+invert_scroll = true
+key_repeat_delay = "250ms"
+key_repeat_period = "30ms"
+`)
+	assert.NoError(t, err)
+	invert := true
+	require.Equal(t, Config{Server: Server{ClientSettings: ClientSettings{
+		PointerScale:    1.25,
+		InvertScroll:    &invert,
+		KeyRepeatDelay:  250 * time.Millisecond,
+		KeyRepeatPeriod: 30 * time.Millisecond,
+	}}}, *c)
+}
+
+func TestReadRelayKeys(t *testing.T) {
+	c, err := readConfigString(`[server]
+keyboard_relay_key = "RightShift"
+mouse_relay_key = "rightalt"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{
+		KeyboardRelayKey: inputevent.RightShift,
+		MouseRelayKey:    inputevent.RightAlt,
+	}}, *c)
+
+	_, err = readConfigString(`[server]
+keyboard_relay_key = "Hyper"
+`)
+	assert.Error(t, err)
+}
+
+func TestReadCommandKeys(t *testing.T) {
+	c, err := readConfigString(`[server.command_keys]
+lock = "PauseBreak"
+display_off = "ScrollLock"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{CommandKeys: map[string]inputevent.KeyCode{
+		"lock":        inputevent.PauseBreak,
+		"display_off": inputevent.ScrollLock,
+	}}}, *c)
+}
+
+func TestReadTracing(t *testing.T) {
+	c, err := readConfigString(`[tracing]
+endpoint = "http://localhost:4318/v1/traces"
+sample_every = 10
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Tracing: Tracing{Endpoint: "http://localhost:4318/v1/traces", SampleEvery: 10}}, *c)
+}
+
+func TestReadScreens(t *testing.T) {
+	c, err := readConfigString(`screens = [
+  {x = 0, y = 0, width = 2560, height = 1440},
+  {x = -1920, y = 200, width = 1920, height = 1080},
+]
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Screens: []Screen{
+		{Width: 2560, Height: 1440},
+		{X: -1920, Y: 200, Width: 1920, Height: 1080},
+	}}, *c)
+}
+
+func TestReadClientNames(t *testing.T) {
+	c, err := readConfigString(`[server.client_names]
+"AB:CD:EF" = "laptop"
+0123abcd = "htpc"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{Server: Server{ClientNames: map[string]string{
+		"AB:CD:EF": "laptop",
+		"0123abcd": "htpc",
+	}}}, *c)
+}
+
+func TestReadJoinCode(t *testing.T) {
+	c, err := readConfigString(`[server]
+client_allowlist_path = "./clients.txt"
+join_code_lifetime = "10m"
+
+[client]
+join_code = "7KQM-X2PD"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{
+		Server: Server{ClientAllowlistPath: "./clients.txt", JoinCodeLifetime: 10 * time.Minute},
+		Client: Client{JoinCode: "7KQM-X2PD"},
+	}, *c)
+}
+
+func TestReadDownstreamConfig(t *testing.T) {
+	c, err := readConfigString(`node_name = "htpc"
+
+[client.downstream]
+port = 59001
+tls_cert_path = "./downstream_cert.pem"
+tls_key_path = "./downstream_key.pem"
+client_tls_cert_path = "./downstream_client_cert.pem"
+`)
+	assert.NoError(t, err)
+	require.Equal(t, Config{NodeName: "htpc", Client: Client{Downstream: Downstream{
+		Port:              59001,
+		TLSCertPath:       "./downstream_cert.pem",
+		TLSKeyPath:        "./downstream_key.pem",
+		ClientTLSCertPath: "./downstream_client_cert.pem",
+	}}}, *c)
+	assert.Equal(t, "htpc", c.Name())
+}
+
+const profilesConfig = `[client]
+server_addr = "192.168.0.1:59001"
+tls_cert_path = "./client_cert.pem"
+
+[profiles.office.client]
+server_addr = "10.0.0.5:59001"
+server_tls_cert_path = "./office_server_cert.pem"
+
+[profiles.office.client.tcp]
+keep_alive_period = "30s"
+`
+
+func TestReadProfile(t *testing.T) {
+	c, err := readProfileString(profilesConfig, "office")
+	assert.NoError(t, err)
+	require.Equal(t, Config{Client: Client{
+		ServerAddr:        "10.0.0.5:59001",
+		TLSCertPath:       "./client_cert.pem",
+		ServerTLSCertPath: "./office_server_cert.pem",
+		TCP:               TCP{KeepAlivePeriod: 30 * time.Second},
+	}}, *c)
+}
+
+func TestReadWithoutProfile(t *testing.T) {
+	c, err := readProfileString(profilesConfig, "")
+	assert.NoError(t, err)
+	require.Equal(t, Config{Client: Client{
+		ServerAddr:  "192.168.0.1:59001",
+		TLSCertPath: "./client_cert.pem",
+	}}, *c)
+}
+
+func TestReadUnknownProfile(t *testing.T) {
+	_, err := readProfileString(profilesConfig, "home")
+	assert.EqualError(t, err, `unknown profile "home"`)
+}
+
+func TestOnlyCertsChanged(t *testing.T) {
+	prev := &Config{Server: Server{Port: 59001, TLSCertPath: "./server_cert.pem"}}
+
+	c := *prev
+	assert.False(t, c.OnlyCertsChanged(prev), "unchanged")
+
+	c.Server.TLSCertPath = "./new_server_cert.pem"
+	assert.True(t, c.OnlyCertsChanged(prev))
+
+	c.Server.Port = 59002
+	assert.False(t, c.OnlyCertsChanged(prev))
+}
+
+func TestWriteReference(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, WriteReference(&b))
+	ref := b.String()
+
+	c, err := readConfigString(ref)
+	require.NoError(t, err)
+	assert.Equal(t, Config{}, *c)
+
+	assert.Contains(t, ref, "# StatusAddr is where the status endpoint listens")
+	assert.Contains(t, ref, "\n# status_addr = \"\"\n")
+	assert.Contains(t, ref, "\n[server.tcp]\n# no_delay = false\n")
+	assert.Contains(t, ref, "\n# [[server.schedule]]\n# days = []\n")
+	assert.Contains(t, ref, "\n# input_max_age = \"0s\"\n")
+
+	// every value but the empty strings, which key codes reject, is valid
+	// once uncommented
+	assignment := regexp.MustCompile(`^# [a-z_]+ = `)
+	header := regexp.MustCompile(`^# \[\[?[a-z_.]+\]\]?$`)
+	lines := strings.Split(ref, "\n")
+	for i, line := range lines {
+		if (assignment.MatchString(line) && !strings.HasSuffix(line, `""`)) || header.MatchString(line) {
+			lines[i] = strings.TrimPrefix(line, "# ")
+		}
+	}
+	_, err = readConfigString(strings.Join(lines, "\n"))
+	assert.NoError(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	invert := true
+	prev := &Config{LogLevel: "info", Server: Server{Port: 59001}, Client: Client{JoinCode: "ABCD-EFGH"}}
+	next := &Config{
+		LogLevel: "debug",
+		Server:   Server{Port: 59001, TCP: TCP{KeepAlivePeriod: time.Minute}, ClientSettings: ClientSettings{InvertScroll: &invert}},
+		Client:   Client{JoinCode: "WXYZ-QRST"},
+	}
+
+	assert.Empty(t, Diff(prev, prev))
+	assert.Equal(t, []Change{
+		{Key: "log_level", Old: `"info"`, New: `"debug"`},
+		{Key: "server.tcp.keep_alive_period", Old: "0s", New: "1m0s"},
+		{Key: "server.client_settings.invert_scroll", Old: "<unset>", New: "true"},
+		{Key: "client.join_code", Old: "[redacted]", New: "[redacted]"},
+	}, Diff(prev, next))
+}
+
+func TestRedacted(t *testing.T) {
+	c := &Config{Server: Server{Port: 59001}, Client: Client{JoinCode: "ABCD-EFGH"}}
+	r := c.Redacted()
+	assert.Equal(t, &Config{Server: Server{Port: 59001}, Client: Client{JoinCode: "[redacted]"}}, r)
+	assert.Equal(t, "ABCD-EFGH", c.Client.JoinCode)
+	assert.Empty(t, (&Config{}).Redacted().Client.JoinCode)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the values of options tagged `redact:"true"` in changes
+// and in [Config.Redacted].
+const redacted = "[redacted]"
+
+// Change is an option that differs between two configurations.
+type Change struct {
+	// Key is the dotted path of the option, e.g. "server.port".
+	Key string
+	Old string
+	New string
+}
+
+// Diff returns the options that differ from prev in next, in the order of
+// their fields. Arrays of tables and maps are compared whole.
+func Diff(prev, next *Config) []Change {
+	var changes []Change
+	diff(&changes, nil, reflect.ValueOf(*prev), reflect.ValueOf(*next))
+	return changes
+}
+
+func diff(changes *[]Change, path []string, prev, next reflect.Value) {
+	t := prev.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		if key == "" {
+			continue
+		}
+		p, n := prev.Field(i), next.Field(i)
+		if isTable(field.Type) {
+			diff(changes, appendKey(path, field), p, n)
+			continue
+		}
+		if reflect.DeepEqual(p.Interface(), n.Interface()) {
+			continue
+		}
+		c := Change{Key: strings.Join(appendKey(path, field), "."), Old: redacted, New: redacted}
+		if field.Tag.Get("redact") != "true" {
+			c.Old, c.New = formatValue(p), formatValue(n)
+		}
+		*changes = append(*changes, c)
+	}
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "<unset>"
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// Redacted returns a copy of c with the values of options tagged
+// `redact:"true"` replaced, e.g. to share it.
+func (c *Config) Redacted() *Config {
+	r := *c
+	redact(reflect.ValueOf(&r).Elem())
+	return &r
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		switch {
+		case isTable(field.Type):
+			redact(v.Field(i))
+		case field.Tag.Get("redact") == "true" && field.Type.Kind() == reflect.String && v.Field(i).String() != "":
+			v.Field(i).SetString(redacted)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	_ "embed"
+	"encoding"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// source is this package's config.go, read for the doc comments of the
+// options, so the reference never drifts from the struct.
+//
+//go:embed config.go
+var source string
+
+const referenceHeader = `# terong.toml reference. Every option is listed with its default, commented
+# out. Uncomment and edit the ones to change.
+`
+
+const referenceFooter = `
+# Profiles are tables of the same shape as the top level, whose keys override
+# it when selected with -profile or ` + ProfileEnv + `, e.g.
+#
+# [profiles.office.client]
+# server_addr = "office-pc:59001"
+`
+
+// WriteReference writes a terong.toml with every option at its default,
+// commented out, and documented with the comments of its field.
+func WriteReference(w io.Writer) error {
+	docs, err := fieldDocs()
+	if err != nil {
+		return fmt.Errorf("failed to parse config source: %v", err)
+	}
+	var b strings.Builder
+	b.WriteString(referenceHeader)
+	writeTable(&b, docs, nil, reflect.TypeOf(Config{}), false)
+	b.WriteString(referenceFooter)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// InitFile writes the reference to the config file, failing if it exists.
+func InitFile() error {
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := WriteReference(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// fieldDocs returns the doc comments of the struct fields in source, by type
+// and field name, e.g. "Server.Port".
+func fieldDocs() (map[string]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "config.go", source, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]string)
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.TypeSpec)
+		if !ok {
+			return true
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok {
+			return false
+		}
+		for _, field := range st.Fields.List {
+			for _, name := range field.Names {
+				if field.Doc != nil {
+					docs[spec.Name.Name+"."+name.Name] = field.Doc.Text()
+				}
+			}
+		}
+		return false
+	})
+	return docs, nil
+}
+
+// writeTable writes the header of the table at path, unless it's the top
+// level, then its fields.
+func writeTable(b *strings.Builder, docs map[string]string, path []string, t reflect.Type, commented bool) {
+	if len(path) > 0 {
+		comment := ""
+		if commented {
+			comment = "# "
+		}
+		fmt.Fprintf(b, "%s[%s]\n", comment, strings.Join(path, "."))
+	}
+	writeFields(b, docs, path, t, commented)
+}
+
+// writeFields writes the keys of t, then its tables, then its arrays of
+// tables. The headers of arrays of tables, and of anything in them, are
+// commented out as uncommenting them adds an element.
+func writeFields(b *strings.Builder, docs map[string]string, path []string, t reflect.Type, commented bool) {
+	var tables, arrays []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := tomlKey(field)
+		if key == "" {
+			continue
+		}
+		switch {
+		case isTable(field.Type):
+			tables = append(tables, field)
+			continue
+		case field.Type.Kind() == reflect.Slice && isTable(field.Type.Elem()):
+			arrays = append(arrays, field)
+			continue
+		}
+		if doc := docs[t.Name()+"."+field.Name]; doc != "" {
+			b.WriteString("\n")
+			writeDoc(b, doc)
+		}
+		fmt.Fprintf(b, "# %s = %s\n", key, zeroValue(field.Type))
+	}
+
+	for _, field := range tables {
+		b.WriteString("\n")
+		if doc := docs[t.Name()+"."+field.Name]; doc != "" {
+			writeDoc(b, doc)
+		}
+		writeTable(b, docs, appendKey(path, field), field.Type, commented)
+	}
+	for _, field := range arrays {
+		b.WriteString("\n")
+		if doc := docs[t.Name()+"."+field.Name]; doc != "" {
+			writeDoc(b, doc)
+		}
+		elemPath := appendKey(path, field)
+		fmt.Fprintf(b, "# [[%s]]\n", strings.Join(elemPath, "."))
+		writeFields(b, docs, elemPath, field.Type.Elem(), true)
+	}
+}
+
+// appendKey returns the path of field in the table at path.
+func appendKey(path []string, field reflect.StructField) []string {
+	return append(path[:len(path):len(path)], tomlKey(field))
+}
+
+func writeDoc(b *strings.Builder, doc string) {
+	for _, line := range strings.Split(strings.TrimSpace(doc), "\n") {
+		b.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+	}
+}
+
+func tomlKey(field reflect.StructField) string {
+	key, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+var textUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isTable reports whether values of t are written as TOML tables.
+func isTable(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshaler)
+}
+
+// zeroValue returns the TOML of the zero value of t.
+func zeroValue(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return `"0s"`
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return `""`
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return zeroValue(t.Elem())
+	case reflect.Bool:
+		return "false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "0"
+	case reflect.Float32, reflect.Float64:
+		return "0.0"
+	case reflect.Slice, reflect.Array:
+		return "[]"
+	case reflect.Map:
+		return "{}"
+	}
+	return `""`
+}
@@ -2,29 +2,48 @@ package config
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"kafji.net/terong/crash"
 )
 
+// watchDebounce is how long the watched files must stay unchanged before
+// they're read, as editors and certificate renewals write them in steps.
+const watchDebounce = 3 * time.Second
+
 type Watcher struct {
-	cfgs chan *Config
-	err  error
+	cfgs  chan *Config
+	certs chan struct{}
+	err   error
 }
 
 func (w *Watcher) Configs() <-chan *Config {
 	return w.cfgs
 }
 
+// Certs receives when the content of the certificate or key files of the
+// latest config changed, e.g. renewed by an ACME client, with their paths
+// unchanged.
+func (w *Watcher) Certs() <-chan struct{} {
+	return w.certs
+}
+
 func (w *Watcher) Err() error {
 	return w.err
 }
 
-func Watch(ctx context.Context) *Watcher {
-	w := &Watcher{cfgs: make(chan *Config)}
+// Watch watches the config file, and the certificate files of cfg, then of
+// the configs read since.
+func Watch(ctx context.Context, cfg *Config) *Watcher {
+	w := &Watcher{cfgs: make(chan *Config), certs: make(chan struct{})}
 
 	go func() {
+		defer crash.Recover()
 		defer close(w.cfgs)
 
 		watcher, err := createWatcher()
@@ -34,13 +53,17 @@ func Watch(ctx context.Context) *Watcher {
 		}
 		defer watcher.Close()
 
+		certs := newCertFiles(watcher)
+		certs.watch(cfg)
+
 		// Where did that bring you? Back to me. - RxJava
 		var debounce <-chan time.Time
+		var certsDebounce <-chan time.Time
 
 		for {
 			select {
 			case <-ctx.Done():
-				err := ctx.Err()
+				err := context.Cause(ctx)
 				slog.Debug("context error", "error", err)
 				w.err = err
 				return
@@ -56,10 +79,17 @@ func Watch(ctx context.Context) *Watcher {
 					return
 				}
 				slog.Debug("watcher event", "event", event)
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+					continue
+				}
+				if certs.has(event.Name) {
+					certsDebounce = time.After(watchDebounce)
+					continue
+				}
 				if !event.Has(fsnotify.Write) || event.Name != "terong.toml" {
 					continue
 				}
-				debounce = time.After(3 * time.Second)
+				debounce = time.After(watchDebounce)
 
 			case <-debounce:
 				slog.Debug("reading config")
@@ -68,9 +98,18 @@ func Watch(ctx context.Context) *Watcher {
 					slog.Warn("failed to read config", "error", err)
 					continue
 				}
+				certs.watch(cfg)
 				slog.Debug("sending config")
 				w.cfgs <- cfg
 				debounce = nil
+
+			case <-certsDebounce:
+				certsDebounce = nil
+				if !certs.changed() {
+					continue
+				}
+				slog.Debug("sending certificates changed")
+				w.certs <- struct{}{}
 			}
 		}
 	}()
@@ -89,3 +128,73 @@ func createWatcher() (*fsnotify.Watcher, error) {
 	}
 	return watcher, nil
 }
+
+// certFiles are the certificate and key files of a config. Their directories
+// are watched rather than the files, as renewals usually replace the files,
+// which ends watching them.
+type certFiles struct {
+	watcher *fsnotify.Watcher
+	// sums of the files by cleaned path, zero if unreadable
+	sums map[string][sha256.Size]byte
+	dirs map[string]bool
+}
+
+func newCertFiles(watcher *fsnotify.Watcher) *certFiles {
+	return &certFiles{watcher: watcher, sums: make(map[string][sha256.Size]byte), dirs: make(map[string]bool)}
+}
+
+// watch watches the files of cfg instead.
+func (c *certFiles) watch(cfg *Config) {
+	paths := cfg.certPaths()
+	sums := make(map[string][sha256.Size]byte, len(paths))
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		path = filepath.Clean(path)
+		sums[path] = sumFile(path)
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range c.dirs {
+		if !dirs[dir] {
+			c.watcher.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if c.dirs[dir] {
+			continue
+		}
+		if err := c.watcher.Add(dir); err != nil {
+			slog.Warn("failed to watch certificate directory", "path", dir, "error", err)
+			delete(dirs, dir)
+		}
+	}
+	c.sums, c.dirs = sums, dirs
+}
+
+func (c *certFiles) has(path string) bool {
+	_, ok := c.sums[filepath.Clean(path)]
+	return ok
+}
+
+// changed reports whether the content of a file changed since last checked.
+// Files that can't be read, e.g. halfway through a renewal, are left to the
+// next check.
+func (c *certFiles) changed() bool {
+	changed := false
+	for path, prev := range c.sums {
+		sum := sumFile(path)
+		if sum == ([sha256.Size]byte{}) || sum == prev {
+			continue
+		}
+		c.sums[path] = sum
+		changed = true
+	}
+	return changed
+}
+
+func sumFile(path string) [sha256.Size]byte {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(b)
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(cert, []byte("cert"), 0o600))
+	require.NoError(t, os.WriteFile(key, []byte("key"), 0o600))
+
+	watcher, err := fsnotify.NewWatcher()
+	require.NoError(t, err)
+	defer watcher.Close()
+	c := newCertFiles(watcher)
+	c.watch(&Config{Server: Server{TLSCertPath: cert, TLSKeyPath: key}})
+	assert.Equal(t, []string{dir}, watcher.WatchList())
+	assert.True(t, c.has(cert))
+	assert.False(t, c.has(filepath.Join(dir, "other")))
+	assert.False(t, c.changed())
+
+	// rewritten with the same content
+	require.NoError(t, os.WriteFile(cert, []byte("cert"), 0o600))
+	assert.False(t, c.changed())
+
+	// renewed by replacing the file
+	renewed := filepath.Join(dir, "server.crt.new")
+	require.NoError(t, os.WriteFile(renewed, []byte("renewed"), 0o600))
+	require.NoError(t, os.Rename(renewed, cert))
+	assert.True(t, c.changed())
+	assert.False(t, c.changed())
+
+	// halfway through a renewal
+	require.NoError(t, os.Remove(key))
+	assert.False(t, c.changed())
+
+	c.watch(&Config{})
+	assert.Empty(t, watcher.WatchList())
+	assert.False(t, c.has(cert))
+}
@@ -0,0 +1,302 @@
+// Package ctl controls a running server through the control endpoint served
+// next to its status endpoint. It is the implementation of terongctl, meant to
+// be bound to desktop shortcuts.
+package ctl
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/stats"
+)
+
+const (
+	// RelayPath reports the relay state on GET and changes it on POST with an
+	// action form value.
+	RelayPath = "/control/relay"
+	// EventsPath streams connection events as JSON lines on GET, see
+	// package events.
+	EventsPath = "/control/events"
+	// DiagnosticsPath serves a zip of the version, the redacted config, the
+	// recent logs and the metrics on GET, see package diagnostics.
+	DiagnosticsPath = "/control/diagnostics"
+	// Header must be set on POST requests so that web pages can't submit
+	// them.
+	Header = "X-Terong-Control"
+
+	ActionToggle = "toggle"
+	ActionOn     = "on"
+	ActionOff    = "off"
+)
+
+// Exit codes of Run.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+const (
+	requestTimeout = 2 * time.Second
+	// diagnosticsTimeout is longer as the zip carries the log history.
+	diagnosticsTimeout = 10 * time.Second
+)
+
+// RelayState is the reply of the control endpoint.
+type RelayState struct {
+	Relay bool `json:"relay"`
+	// Suspended is true while a relay exception window is in the foreground.
+	Suspended bool `json:"suspended"`
+	// Peer is the connected client, empty if none.
+	Peer string `json:"peer"`
+}
+
+func (s RelayState) String() string {
+	var b strings.Builder
+	if s.Relay {
+		b.WriteString("relay on")
+	} else {
+		b.WriteString("relay off")
+	}
+	if s.Suspended {
+		b.WriteString(" (suspended)")
+	}
+	if s.Peer != "" {
+		fmt.Fprintf(&b, ", client %s", s.Peer)
+	} else {
+		b.WriteString(", no client")
+	}
+	return b.String()
+}
+
+const usage = `usage: terongctl [-addr host:port] [-profile name] status|toggle|on|off|events|stats|dump-diagnostics
+       terongctl config init|print-default
+
+The address defaults to status_addr of terong.toml. events prints connection
+events as JSON lines until interrupted. stats prints the daily aggregates in
+stats_file of terong.toml, without reaching the server. dump-diagnostics saves
+a zip in the current directory to attach to bug reports, with the version, the
+config with secrets redacted, the recent logs and the metrics of the running
+server or client. config print-default prints a terong.toml documenting every
+option at its default, and config init writes it to terong.toml unless that
+exists.
+`
+
+// Run runs terongctl with args, writing the relay state to stdout and errors
+// to stderr, and returns the exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("terongctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	addr := flags.String("addr", "", "address of the status endpoint")
+	profile := flags.String("profile", os.Getenv(config.ProfileEnv), "config profile to apply")
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	config.SelectProfile(*profile)
+	if flags.NArg() == 2 && flags.Arg(0) == "config" {
+		return runConfig(flags.Arg(1), stdout, stderr, flags.Usage)
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	var action string
+	cmd := flags.Arg(0)
+	switch cmd {
+	case "status", "events", "dump-diagnostics":
+	case "stats":
+		if err := printStats(stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitFailure
+		}
+		return ExitOK
+	case ActionToggle, ActionOn, ActionOff:
+		action = cmd
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n", cmd)
+		flags.Usage()
+		return ExitUsage
+	}
+
+	if *addr == "" {
+		cfg, err := config.ReadConfig()
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to read config file: %v\n", err)
+			return ExitFailure
+		}
+		if cfg.StatusAddr == "" {
+			fmt.Fprintln(stderr, "status_addr is not configured")
+			return ExitFailure
+		}
+		*addr = cfg.StatusAddr
+	}
+
+	if cmd == "events" {
+		if err := streamEvents(*addr, stdout); err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitFailure
+		}
+		return ExitOK
+	}
+
+	if cmd == "dump-diagnostics" {
+		path, err := dumpDiagnostics(*addr, time.Now())
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return ExitFailure
+		}
+		fmt.Fprintln(stdout, "wrote "+path)
+		return ExitOK
+	}
+
+	state, err := request(*addr, action)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return ExitFailure
+	}
+	fmt.Fprintln(stdout, state)
+	return ExitOK
+}
+
+// runConfig runs the config subcommand cmd.
+func runConfig(cmd string, stdout, stderr io.Writer, usage func()) int {
+	var err error
+	switch cmd {
+	case "print-default":
+		err = config.WriteReference(stdout)
+	case "init":
+		err = config.InitFile()
+		if err == nil {
+			fmt.Fprintln(stdout, "wrote terong.toml")
+		}
+	default:
+		fmt.Fprintf(stderr, "unknown config command %q\n", cmd)
+		usage()
+		return ExitUsage
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to write config: %v\n", err)
+		return ExitFailure
+	}
+	return ExitOK
+}
+
+// printStats prints the days in the stats file, oldest first.
+func printStats(w io.Writer) error {
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	if cfg.Server.StatsFile == "" {
+		return errors.New("stats_file is not configured")
+	}
+	s, err := stats.Load(cfg.Server.StatsFile)
+	if err != nil {
+		return fmt.Errorf("failed to load stats: %v", err)
+	}
+	for _, date := range s.Dates() {
+		fmt.Fprintf(w, "%s  %v\n", date, s[date])
+	}
+	return nil
+}
+
+// request changes the relay state with action, or only gets it if action is
+// empty.
+func request(addr string, action string) (RelayState, error) {
+	u := "http://" + addr + RelayPath
+
+	var req *http.Request
+	var err error
+	if action == "" {
+		req, err = http.NewRequest(http.MethodGet, u, nil)
+	} else {
+		form := url.Values{"action": {action}}
+		req, err = http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set(Header, "1")
+		}
+	}
+	if err != nil {
+		return RelayState{}, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := (&http.Client{Timeout: requestTimeout}).Do(req)
+	if err != nil {
+		return RelayState{}, fmt.Errorf("failed to reach server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		msg := strings.TrimSpace(string(body))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return RelayState{}, errors.New("server refused: " + msg)
+	}
+
+	var state RelayState
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return RelayState{}, fmt.Errorf("failed to decode relay state: %v", err)
+	}
+	return state, nil
+}
+
+// streamEvents copies the event stream to w until the server closes it.
+func streamEvents(addr string, w io.Writer) error {
+	// no timeout, the stream stays open
+	resp, err := http.Get("http://" + addr + EventsPath)
+	if err != nil {
+		return fmt.Errorf("failed to reach server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("server refused: " + resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to read events: %v", err)
+	}
+	return nil
+}
+
+// dumpDiagnostics saves the diagnostics zip to the current directory, named
+// after now, and returns its path.
+func dumpDiagnostics(addr string, now time.Time) (string, error) {
+	resp, err := (&http.Client{Timeout: diagnosticsTimeout}).Get("http://" + addr + DiagnosticsPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("server refused: " + resp.Status)
+	}
+
+	path := "terong-diagnostics-" + now.Format("20060102-150405") + ".zip"
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create diagnostics file: %v", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("failed to read diagnostics: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write diagnostics file: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,159 @@
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/terong/stats"
+)
+
+func TestRun(t *testing.T) {
+	state := RelayState{Peer: "laptop"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, RelayPath, r.URL.Path)
+		if r.Method == http.MethodPost {
+			require.NotEmpty(t, r.Header.Get(Header))
+			switch r.FormValue("action") {
+			case ActionToggle:
+				state.Relay = !state.Relay
+			case ActionOn:
+				state.Relay = true
+			case ActionOff:
+				state.Relay = false
+			}
+		}
+		json.NewEncoder(w).Encode(state)
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	for _, tc := range []struct {
+		cmd  string
+		want string
+	}{
+		{"status", "relay off, client laptop\n"},
+		{"toggle", "relay on, client laptop\n"},
+		{"on", "relay on, client laptop\n"},
+		{"off", "relay off, client laptop\n"},
+	} {
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{"-addr", addr, tc.cmd}, &stdout, &stderr)
+		assert.Equal(t, ExitOK, code, tc.cmd)
+		assert.Equal(t, tc.want, stdout.String(), tc.cmd)
+		assert.Empty(t, stderr.String(), tc.cmd)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, ExitUsage, Run(nil, &stdout, &stderr))
+	assert.Equal(t, ExitUsage, Run([]string{"-addr", "127.0.0.1:1", "switch"}, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+}
+
+func TestRunServerRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "server is not running", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"-addr", strings.TrimPrefix(srv.URL, "http://"), "toggle"}, &stdout, &stderr)
+	assert.Equal(t, ExitFailure, code)
+	assert.Equal(t, "server refused: server is not running\n", stderr.String())
+}
+
+func TestRelayStateString(t *testing.T) {
+	assert.Equal(t, "relay on (suspended), no client", RelayState{Relay: true, Suspended: true}.String())
+}
+
+func TestRunEvents(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, EventsPath, r.URL.Path)
+		w.Write([]byte("{\"kind\":\"connected\",\"peer\":\"laptop\"}\n"))
+	}))
+	defer srv.Close()
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"-addr", strings.TrimPrefix(srv.URL, "http://"), "events"}, &stdout, &stderr)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "{\"kind\":\"connected\",\"peer\":\"laptop\"}\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestRunStats(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	require.NoError(t, os.WriteFile("terong.toml", []byte("[server]\nstats_file = \"stats.json\"\n"), 0o644))
+	require.NoError(t, stats.Save("stats.json", stats.Stats{
+		"2024-06-02": {Relaying: time.Hour, Relayed: 10},
+		"2024-06-01": {Disconnects: 2},
+	}))
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"stats"}, &stdout, &stderr)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "2024-06-01  relaying 0s, 0 inputs, 2 disconnects, rtt -\n2024-06-02  relaying 1h0m0s, 10 inputs, 0 disconnects, rtt -\n", stdout.String())
+	assert.Empty(t, stderr.String())
+}
+
+func TestRunConfigInit(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, ExitOK, Run([]string{"config", "init"}, &stdout, &stderr))
+	assert.Equal(t, "wrote terong.toml\n", stdout.String())
+
+	var ref bytes.Buffer
+	assert.Equal(t, ExitOK, Run([]string{"config", "print-default"}, &ref, &stderr))
+	written, err := os.ReadFile("terong.toml")
+	require.NoError(t, err)
+	assert.Equal(t, ref.String(), string(written))
+	assert.Empty(t, stderr.String())
+
+	// an existing config is never overwritten
+	assert.Equal(t, ExitFailure, Run([]string{"config", "init"}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "failed to write config")
+
+	assert.Equal(t, ExitUsage, Run([]string{"config", "show"}, &stdout, &stderr))
+}
+
+func TestRunDumpDiagnostics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, DiagnosticsPath, r.URL.Path)
+		w.Write([]byte("zip"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	var stdout, stderr bytes.Buffer
+	code := Run([]string{"-addr", strings.TrimPrefix(srv.URL, "http://"), "dump-diagnostics"}, &stdout, &stderr)
+	require.Equal(t, ExitOK, code, stderr.String())
+	path, ok := strings.CutPrefix(strings.TrimSpace(stdout.String()), "wrote ")
+	require.True(t, ok)
+	assert.Regexp(t, `^terong-diagnostics-\d{8}-\d{6}\.zip$`, path)
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "zip", string(written))
+}
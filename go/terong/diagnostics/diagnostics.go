@@ -0,0 +1,127 @@
+// Package diagnostics bundles what a maintainer needs to look into an issue
+// into a zip, generated by the running server or client and fetched with
+// terongctl dump-diagnostics.
+package diagnostics
+
+import (
+	"archive/zip"
+	"bytes"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+	"kafji.net/terong/terong/config"
+)
+
+var slog = logging.NewLogger("terong/diagnostics")
+
+// Write writes the zip to w, with the configurations cfg in use, redacted, or
+// none if cfg is nil.
+func Write(w io.Writer, cfg *config.Config, now time.Time) error {
+	z := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"version.txt", func(w io.Writer) error { return writeVersion(w, now) }},
+		{"config.toml", func(w io.Writer) error { return writeConfig(w, cfg) }},
+		{"logs.txt", writeLogs},
+		{"status.json", writeStatus},
+		{"metrics.txt", writeMetrics},
+	}
+	for _, f := range files {
+		fw, err := z.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return fmt.Errorf("failed to write %s: %v", f.name, err)
+		}
+	}
+	return z.Close()
+}
+
+// Handler serves the zip on GET, with the configurations returned by cfg.
+func Handler(cfg func() *config.Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// built whole first, so a failure can still be replied
+		var b bytes.Buffer
+		if err := Write(&b, cfg(), time.Now()); err != nil {
+			slog.Warn("failed to write diagnostics", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Write(b.Bytes())
+	})
+}
+
+func writeVersion(w io.Writer, now time.Time) error {
+	_, err := fmt.Fprintf(w, "program: %s\nversion: %s\ngo: %s %s/%s\ntime: %s\n",
+		filepath.Base(os.Args[0]), crash.Version(), runtime.Version(), runtime.GOOS, runtime.GOARCH, now.Format(time.RFC3339))
+	return err
+}
+
+func writeConfig(w io.Writer, cfg *config.Config) error {
+	if cfg == nil {
+		_, err := io.WriteString(w, "# not loaded\n")
+		return err
+	}
+	return toml.NewEncoder(w).Encode(cfg.Redacted())
+}
+
+func writeLogs(w io.Writer) error {
+	for _, line := range logging.History() {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStatus writes the published variables as served at /status.
+func writeStatus(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			b.WriteString(",\n")
+		}
+		first = false
+		fmt.Fprintf(&b, "%q: %s", kv.Key, kv.Value)
+	})
+	b.WriteString("\n}\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// writeMetrics writes the total of every counter map, by name.
+func writeMetrics(w io.Writer) error {
+	totals := metrics.Totals()
+	names := make([]string, 0, len(totals))
+	for name := range totals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := fmt.Fprintf(w, "%s %d\n", name, totals[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
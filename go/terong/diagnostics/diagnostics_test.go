@@ -0,0 +1,55 @@
+package diagnostics
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/terong/config"
+)
+
+func TestHandler(t *testing.T) {
+	cfg := &config.Config{Client: config.Client{ServerAddr: "office-pc:59001", JoinCode: "ABCD-EFGH"}}
+	rec := httptest.NewRecorder()
+	Handler(func() *config.Config { return cfg }).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+
+	z, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		files[f.Name] = string(b)
+	}
+	assert.ElementsMatch(t, []string{"version.txt", "config.toml", "logs.txt", "status.json", "metrics.txt"}, keys(files))
+	assert.Contains(t, files["config.toml"], `server_addr = "office-pc:59001"`)
+	assert.Contains(t, files["config.toml"], `join_code = "[redacted]"`)
+	assert.NotContains(t, files["config.toml"], "ABCD-EFGH")
+
+	rec = httptest.NewRecorder()
+	Handler(func() *config.Config { return cfg }).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestWriteWithoutConfig(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, Write(&b, nil, time.Now()))
+}
+
+func keys(m map[string]string) []string {
+	var ks []string
+	for k := range m {
+		ks = append(ks, k)
+	}
+	return ks
+}
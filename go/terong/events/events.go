@@ -0,0 +1,132 @@
+// Package events streams connection events of the running server or client,
+// so tray icons, dashboards, and scripts don't have to parse logs. Events are
+// published in process and served as JSON lines at ctl.EventsPath of the
+// control endpoint.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"kafji.net/terong/metrics"
+)
+
+type Kind string
+
+const (
+	// Connected is published when a session with Peer is established.
+	Connected Kind = "connected"
+	// Disconnected is published when the session with Peer ends, for
+	// Reason.
+	Disconnected Kind = "disconnected"
+	// RelayToggled is published when relay is turned on or off.
+	RelayToggled Kind = "relay_toggled"
+	// LatencyAlert is published when the round-trip time to Peer rises above
+	// latency_alert. It isn't published again until it falls back below.
+	LatencyAlert Kind = "latency_alert"
+)
+
+type Event struct {
+	Kind Kind      `json:"kind"`
+	Time time.Time `json:"time"`
+	Peer string    `json:"peer,omitempty"`
+	// Fingerprint is of the certificate of Peer, set on Connected, so users
+	// can confirm who they're connected to.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Reason is why the session ended, set on Disconnected.
+	Reason string `json:"reason,omitempty"`
+	// Relay is the new relay state, set on RelayToggled.
+	Relay *bool `json:"relay,omitempty"`
+	// RTT is the round-trip time, set on LatencyAlert.
+	RTT time.Duration `json:"rtt,omitempty"`
+}
+
+// Disconnect returns the Disconnected event of the session with peer ended by
+// err.
+func Disconnect(peer string, err error) Event {
+	e := Event{Kind: Disconnected, Peer: peer}
+	if err != nil {
+		e.Reason = err.Error()
+	}
+	return e
+}
+
+// subscriberBuffer is how many events a subscriber can fall behind before
+// events are dropped for it.
+const subscriberBuffer = 64
+
+// dropped counts events dropped for subscribers that fell behind, by kind.
+var dropped = metrics.NewCounterMap("events_dropped")
+
+var (
+	mu          sync.Mutex
+	subscribers = make(map[chan Event]struct{})
+)
+
+// Publish sends e to every subscriber. It never blocks, subscribers that fell
+// behind miss it. A zero Time is set to now.
+func Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for ch := range subscribers {
+		select {
+		case ch <- e:
+		default:
+			dropped.Add(string(e.Kind), 1)
+		}
+	}
+}
+
+// Subscribe receives the events published from now until ctx is done, when
+// the channel is closed.
+func Subscribe(ctx context.Context) <-chan Event {
+	ch := make(chan Event, subscriberBuffer)
+	mu.Lock()
+	subscribers[ch] = struct{}{}
+	mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		mu.Lock()
+		defer mu.Unlock()
+		delete(subscribers, ch)
+		close(ch)
+	}()
+	return ch
+}
+
+// Handler streams events as JSON lines on GET until the request is done.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// subscribe before replying, so nothing published after the reply
+		// is missed
+		sub := Subscribe(r.Context())
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		enc := json.NewEncoder(w)
+		for e := range sub {
+			if err := enc.Encode(e); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := Subscribe(ctx)
+
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	Publish(Event{Kind: Connected, Time: at, Peer: "laptop"})
+	Publish(Event{Kind: LatencyAlert, Peer: "laptop", RTT: 80 * time.Millisecond})
+
+	assert.Equal(t, Event{Kind: Connected, Time: at, Peer: "laptop"}, <-sub)
+	e := <-sub
+	assert.Equal(t, LatencyAlert, e.Kind)
+	assert.False(t, e.Time.IsZero())
+
+	cancel()
+	_, ok := <-sub
+	assert.False(t, ok)
+}
+
+func TestPublishSlowSubscriber(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := Subscribe(ctx)
+
+	before := dropped.Total()
+	for range subscriberBuffer + 3 {
+		Publish(Event{Kind: Disconnected, Reason: "EOF"})
+	}
+	assert.Len(t, sub, subscriberBuffer)
+	assert.Equal(t, int64(3), dropped.Total()-before)
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	relay := true
+	Publish(Event{Kind: RelayToggled, Relay: &relay})
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	var e Event
+	require.NoError(t, json.Unmarshal(lines.Bytes(), &e))
+	assert.Equal(t, RelayToggled, e.Kind)
+	require.NotNil(t, e.Relay)
+	assert.True(t, *e.Relay)
+}
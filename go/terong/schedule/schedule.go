@@ -0,0 +1,99 @@
+// Package schedule restricts when remote control is possible.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily period of time on a set of weekdays. A window whose end is
+// not after its start runs past midnight, its days are the days it starts on.
+type Window struct {
+	Days  []time.Weekday
+	Start time.Duration
+	End   time.Duration
+}
+
+// Schedule is a set of windows. An empty schedule allows any time.
+type Schedule []Window
+
+func (s Schedule) Allows(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, w := range s {
+		if w.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Window) contains(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.Start < w.End {
+		return w.onDay(t.Weekday()) && w.Start <= clock && clock < w.End
+	}
+	// runs past midnight
+	if clock >= w.Start {
+		return w.onDay(t.Weekday())
+	}
+	return clock < w.End && w.onDay((t.Weekday()+6)%7)
+}
+
+func (w Window) onDay(d time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, v := range w.Days {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWindow parses a window from three-letter day names, e.g. "mon", and
+// "15:04" formatted start and end times. No days means every day.
+func ParseWindow(days []string, start, end string) (Window, error) {
+	var w Window
+
+	for _, d := range days {
+		v, ok := weekdays[strings.ToLower(d)]
+		if !ok {
+			return Window{}, fmt.Errorf("invalid day %q", d)
+		}
+		w.Days = append(w.Days, v)
+	}
+
+	var err error
+	w.Start, err = parseClock(start)
+	if err != nil {
+		return Window{}, fmt.Errorf("failed to parse start: %v", err)
+	}
+	w.End, err = parseClock(end)
+	if err != nil {
+		return Window{}, fmt.Errorf("failed to parse end: %v", err)
+	}
+
+	return w, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func at(day int, clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		panic(err)
+	}
+	// 2024-06-03 is a Monday
+	return time.Date(2024, 6, 3+day, t.Hour(), t.Minute(), 0, 0, time.Local)
+}
+
+func TestEmptyScheduleAllowsAnyTime(t *testing.T) {
+	assert.True(t, Schedule{}.Allows(at(0, "03:00")))
+}
+
+func TestScheduleAllows(t *testing.T) {
+	w, err := ParseWindow([]string{"mon", "Tue"}, "08:00", "20:00")
+	require.NoError(t, err)
+	s := Schedule{w}
+
+	assert.True(t, s.Allows(at(0, "08:00")))
+	assert.True(t, s.Allows(at(1, "19:59")))
+	assert.False(t, s.Allows(at(1, "20:00")))
+	assert.False(t, s.Allows(at(0, "07:59")))
+	assert.False(t, s.Allows(at(2, "12:00")))
+}
+
+func TestScheduleAllowsPastMidnight(t *testing.T) {
+	w, err := ParseWindow([]string{"fri"}, "22:00", "02:00")
+	require.NoError(t, err)
+	s := Schedule{w}
+
+	assert.True(t, s.Allows(at(4, "23:00")))
+	assert.True(t, s.Allows(at(5, "01:00")))
+	assert.False(t, s.Allows(at(5, "02:00")))
+	assert.False(t, s.Allows(at(4, "01:00")))
+}
+
+func TestParseWindowErrors(t *testing.T) {
+	_, err := ParseWindow([]string{"monday"}, "08:00", "20:00")
+	assert.Error(t, err)
+
+	_, err = ParseWindow(nil, "8am", "20:00")
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+// Package selftest holds what the server and client self-tests share.
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"kafji.net/terong/terong/transport"
+)
+
+// ErrFailed is returned by a self-test if a check failed.
+var ErrFailed = errors.New("self-test failed")
+
+// Report writes the outcome of the check with name to w, and reports whether
+// it passed.
+func Report(w io.Writer, name string, err error) bool {
+	if err != nil {
+		fmt.Fprintf(w, "%-12s failed: %v\n", name, err)
+		return false
+	}
+	fmt.Fprintf(w, "%-12s ok\n", name)
+	return true
+}
+
+// CheckPort binds addr and closes it right away.
+func CheckPort(ctx context.Context, addr string) error {
+	listener, err := transport.ListenTCP(ctx, addr, &transport.TCPConfig{})
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
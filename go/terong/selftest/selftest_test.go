@@ -0,0 +1,29 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	var b strings.Builder
+	assert.True(t, Report(&b, "config", nil))
+	assert.False(t, Report(&b, "port 7070", errors.New("address already in use")))
+	assert.Equal(t, "config       ok\nport 7070    failed: address already in use\n", b.String())
+}
+
+func TestCheckPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+
+	assert.Error(t, CheckPort(context.Background(), addr))
+	require.NoError(t, listener.Close())
+	assert.NoError(t, CheckPort(context.Background(), addr))
+}
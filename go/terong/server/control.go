@@ -0,0 +1,88 @@
+//go:build windows
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"kafji.net/terong/metrics"
+	"kafji.net/terong/terong/ctl"
+	"kafji.net/terong/terong/diagnostics"
+	"kafji.net/terong/terong/events"
+)
+
+// relayRequest asks the run loop to change the relay state, or only to report
+// it if action is empty.
+type relayRequest struct {
+	action string
+	reply  chan ctl.RelayState
+}
+
+// relayRequests are served by the run loop.
+var relayRequests = make(chan relayRequest)
+
+// relayRequestTimeout bounds the wait for the run loop, e.g. while it's
+// restarting.
+const relayRequestTimeout = time.Second
+
+func init() {
+	metrics.Handle(ctl.RelayPath, http.HandlerFunc(handleRelay))
+	metrics.Handle(ctl.EventsPath, events.Handler())
+	metrics.Handle(ctl.DiagnosticsPath, diagnostics.Handler(live.config.Load))
+}
+
+// handleRelay reports the relay state on GET and changes it on POST. POST
+// requires the ctl.Header header so that web pages can't submit it.
+func handleRelay(w http.ResponseWriter, r *http.Request) {
+	var action string
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if r.Header.Get(ctl.Header) == "" {
+			http.Error(w, "missing "+ctl.Header+" header", http.StatusForbidden)
+			return
+		}
+		action = r.FormValue("action")
+		switch action {
+		case ctl.ActionToggle, ctl.ActionOn, ctl.ActionOff:
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if action != "" {
+		slog.Info("relay requested", "action", action, "remote_addr", r.RemoteAddr)
+	}
+
+	state, err := requestRelay(action)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		slog.Debug("failed to write relay state", "error", err)
+	}
+}
+
+var errNotRunning = errors.New("server is not running")
+
+// requestRelay sends a relay request to the run loop and returns the relay
+// state after it.
+func requestRelay(action string) (ctl.RelayState, error) {
+	req := relayRequest{action: action, reply: make(chan ctl.RelayState, 1)}
+	select {
+	case relayRequests <- req:
+	case <-time.After(relayRequestTimeout):
+		return ctl.RelayState{}, errNotRunning
+	}
+	return <-req.reply, nil
+}
@@ -0,0 +1,119 @@
+//go:build windows
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/ctl"
+	"kafji.net/terong/terong/stats"
+	"kafji.net/terong/terong/transport"
+	"kafji.net/terong/terong/transport/server"
+	"kafji.net/terong/terong/tui"
+)
+
+// how many warnings the dashboard shows
+const dashboardWarnings = 8
+
+// live is the state shown by the dashboard, updated by the run loop.
+var live struct {
+	relay     atomic.Bool
+	suspended atomic.Bool
+	transport atomic.Pointer[server.Handle]
+	joinCode  atomic.Pointer[transport.JoinCode]
+	stats     atomic.Pointer[stats.Recorder]
+	// config in use, for diagnostics
+	config atomic.Pointer[config.Config]
+}
+
+// runDashboard shows the dashboard instead of log lines until ctx is done.
+// Pressing Enter toggles relay.
+func runDashboard(ctx context.Context) {
+	if err := enableVirtualTerminal(); err != nil {
+		slog.Warn("failed to enable virtual terminal", "error", err)
+	}
+
+	d := tui.Dashboard{
+		Title:  "terong server",
+		Fields: dashboardFields,
+		Hint:   "double tap Right Ctrl or press Enter to toggle relay",
+		Recent: logging.Capture(dashboardWarnings),
+	}
+	go func() {
+		defer crash.Recover()
+		d.Run(ctx, os.Stdout)
+	}()
+
+	go func() {
+		defer crash.Recover()
+		lines := bufio.NewScanner(os.Stdin)
+		for lines.Scan() {
+			if _, err := requestRelay(ctl.ActionToggle); err != nil {
+				slog.Warn("failed to toggle relay", "error", err)
+			}
+		}
+	}()
+}
+
+func dashboardFields() []tui.Field {
+	relay := "off"
+	if live.relay.Load() {
+		relay = "on"
+		if live.suspended.Load() {
+			relay = "on (suspended)"
+		}
+	}
+	fields := []tui.Field{{Label: "relay", Value: relay}}
+
+	client, fingerprint, rtt, screens := "none", "", "-", ""
+	if t := live.transport.Load(); t != nil {
+		if peer := t.Peer(); peer != "" {
+			client = peer
+		}
+		fingerprint = t.PeerFingerprint()
+		if v := t.RTT(); v > 0 {
+			rtt = v.String()
+		}
+		if g := t.ClientGeometry(); g != nil {
+			screens = g.String()
+		}
+	}
+	fields = append(fields, tui.Field{Label: "client", Value: client})
+	if fingerprint != "" {
+		fields = append(fields, tui.Field{Label: "client certificate", Value: fingerprint})
+	}
+	fields = append(fields, tui.Field{Label: "rtt", Value: rtt})
+	if screens != "" {
+		fields = append(fields, tui.Field{Label: "client screens", Value: screens})
+	}
+
+	if c := live.joinCode.Load(); c != nil && c.Valid(time.Now()) {
+		fields = append(fields, tui.Field{Label: "join code", Value: c.String() + " until " + c.Expires().Format(time.TimeOnly)})
+	}
+	if r := live.stats.Load(); r != nil {
+		fields = append(fields, tui.Field{Label: "today", Value: r.Today().String()})
+	}
+	return fields
+}
+
+// enableVirtualTerminal makes the console interpret the escape sequences
+// used to redraw the dashboard.
+func enableVirtualTerminal() error {
+	handle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE)
+	if err != nil {
+		return err
+	}
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return err
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
@@ -0,0 +1,67 @@
+//go:build windows
+
+package server
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// how often the overlay is updated
+const overlayInterval = time.Second
+
+const overlayIdleTitle = "terong"
+
+var procSetConsoleTitleW = windows.NewLazySystemDLL("kernel32.dll").NewProc("SetConsoleTitleW")
+
+// overlay shows live statistics in the console window title, where they stay
+// visible without scrolling through the logs.
+type overlay struct {
+	title string
+	// inputs relayed since the last update
+	relayed  int
+	lastTick time.Time
+}
+
+// update shows the statistics of the last interval while relaying, or the
+// idle title otherwise.
+func (o *overlay) update(relaying bool, peer string, rtt time.Duration, dropped int64) {
+	now := time.Now()
+	title := overlayIdleTitle
+	if relaying {
+		rate := 0.0
+		if elapsed := now.Sub(o.lastTick); !o.lastTick.IsZero() && elapsed > 0 {
+			rate = float64(o.relayed) / elapsed.Seconds()
+		}
+		if peer == "" {
+			peer = "no client"
+		}
+		title = fmt.Sprintf("terong | %s | rtt %v | %.0f events/s | %d dropped", peer, rtt.Round(100*time.Microsecond), rate, dropped)
+	}
+	o.relayed = 0
+	o.lastTick = now
+
+	if title == o.title {
+		return
+	}
+	if err := setConsoleTitle(title); err != nil {
+		slog.Debug("failed to set console title", "error", err)
+		return
+	}
+	o.title = title
+}
+
+func setConsoleTitle(title string) error {
+	p, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return err
+	}
+	ret, _, err := procSetConsoleTitleW.Call(uintptr(unsafe.Pointer(p)))
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"math"
+	"slices"
+
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/metrics"
+)
+
+// pipelineCapacity is how many inputs are queued before droppable ones are
+// dropped.
+const pipelineCapacity = 1024
+
+var (
+	// pipelineInputs counts inputs queued for the transport by input type.
+	pipelineInputs = metrics.NewActivityCounterMap("pipeline_inputs")
+	// pipelineCoalesced counts inputs merged into the input queued before
+	// them.
+	pipelineCoalesced = metrics.NewActivityCounterMap("pipeline_coalesced_inputs")
+	// pipelineDropped counts inputs dropped because the queue was full.
+	pipelineDropped = metrics.NewCounterMap("pipeline_dropped_inputs")
+)
+
+// pipeline queues inputs for the transport, so the run loop never waits on a
+// congested client. Inputs stay in order, and what happens to them under
+// congestion depends on their type:
+//
+//   - a mouse move is added to a mouse move queued right before it
+//   - a gamepad axis move, touch frame or pen state replaces one queued right
+//     before it that it only updates
+//   - key presses, mouse clicks and gamepad button presses are never dropped,
+//     a lost release would leave them stuck down
+//   - other inputs are dropped while the queue is full
+//
+// It is only used from the run loop, which sends [pipeline.head] to
+// [pipeline.out] and then calls [pipeline.pop].
+type pipeline struct {
+	dst   chan<- inputevent.InputEvent
+	queue *inputevent.Queue[inputevent.InputEvent]
+}
+
+func newPipeline(out chan<- inputevent.InputEvent) *pipeline {
+	return &pipeline{dst: out, queue: inputevent.NewQueue[inputevent.InputEvent](pipelineCapacity)}
+}
+
+func (p *pipeline) push(input inputevent.InputEvent) {
+	name := inputevent.TypeName(input)
+	if p.queue.Len() > 0 {
+		last := p.queue.Last()
+		if v, ok := coalesce(*last, input); ok {
+			*last = v
+			pipelineCoalesced.Add(name, 1)
+			return
+		}
+	}
+	if p.queue.Len() >= pipelineCapacity && droppable(input) {
+		pipelineDropped.Add(name, 1)
+		return
+	}
+	p.queue.Push(input)
+	pipelineInputs.Add(name, 1)
+}
+
+// out returns the channel to send the head to, nil when nothing is queued.
+func (p *pipeline) out() chan<- inputevent.InputEvent {
+	if p.queue.Len() == 0 {
+		return nil
+	}
+	return p.dst
+}
+
+// head returns the oldest queued input.
+func (p *pipeline) head() inputevent.InputEvent {
+	if p.queue.Len() == 0 {
+		return nil
+	}
+	return p.queue.Head()
+}
+
+// pop removes the head once it was sent.
+func (p *pipeline) pop() {
+	p.queue.Pop()
+}
+
+// Dropped returns the number of inputs dropped because the queue was full.
+func (p *pipeline) Dropped() int64 {
+	return pipelineDropped.Total()
+}
+
+// coalesce merges input into the last queued input, if input only adds to
+// it or updates it.
+func coalesce(last inputevent.InputEvent, input inputevent.InputEvent) (inputevent.InputEvent, bool) {
+	switch v := input.(type) {
+	case inputevent.MouseMove:
+		l, ok := last.(inputevent.MouseMove)
+		if !ok {
+			return nil, false
+		}
+		dx, dy := int32(l.DX)+int32(v.DX), int32(l.DY)+int32(v.DY)
+		if dx < math.MinInt16 || dx > math.MaxInt16 || dy < math.MinInt16 || dy > math.MaxInt16 {
+			return nil, false
+		}
+		return inputevent.MouseMove{DX: int16(dx), DY: int16(dy)}, true
+
+	case inputevent.GamepadAxisMove:
+		l, ok := last.(inputevent.GamepadAxisMove)
+		return v, ok && l.Axis == v.Axis
+
+	case inputevent.TouchFrame:
+		// fingers touching down or lifting must reach the client
+		l, ok := last.(inputevent.TouchFrame)
+		return v, ok && slices.EqualFunc(l.Contacts, v.Contacts, func(a, b inputevent.TouchContact) bool { return a.ID == b.ID })
+
+	case inputevent.PenState:
+		// as must the pen touching down, lifting or changing buttons
+		l, ok := last.(inputevent.PenState)
+		return v, ok && l.InRange == v.InRange && l.Tip == v.Tip && l.Barrel == v.Barrel && l.Eraser == v.Eraser
+	}
+	return nil, false
+}
+
+// droppable reports whether input may be dropped under congestion.
+func droppable(input inputevent.InputEvent) bool {
+	switch input.(type) {
+	case inputevent.KeyPress, inputevent.MouseClick, inputevent.GamepadButtonPress:
+		return false
+	}
+	return true
+}
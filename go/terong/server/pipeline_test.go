@@ -0,0 +1,130 @@
+package server
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestPipelineCoalesce(t *testing.T) {
+	touch := func(ids ...uint8) inputevent.TouchFrame {
+		f := inputevent.TouchFrame{}
+		for _, id := range ids {
+			f.Contacts = append(f.Contacts, inputevent.TouchContact{ID: id, X: uint16(id) * 10})
+		}
+		return f
+	}
+	key := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionDown}
+	click := inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionDown}
+	button := inputevent.GamepadButtonPress{Button: inputevent.GamepadButtonSouth, Action: inputevent.GamepadButtonActionDown}
+
+	for _, tc := range []struct {
+		name   string
+		inputs []inputevent.InputEvent
+		want   []inputevent.InputEvent
+	}{
+		{
+			"mouse moves add up",
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: 1, DY: 2}, inputevent.MouseMove{DX: 3, DY: -4}},
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: 4, DY: -2}},
+		},
+		{
+			"mouse moves that would overflow are kept apart",
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: math.MaxInt16}, inputevent.MouseMove{DX: 1}},
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: math.MaxInt16}, inputevent.MouseMove{DX: 1}},
+		},
+		{
+			"mouse moves around a click are kept apart",
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: 1}, click, inputevent.MouseMove{DX: 1}},
+			[]inputevent.InputEvent{inputevent.MouseMove{DX: 1}, click, inputevent.MouseMove{DX: 1}},
+		},
+		{
+			"axis moves of the same axis are replaced",
+			[]inputevent.InputEvent{
+				inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftX, Value: 1},
+				inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftX, Value: 2},
+				inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftY, Value: 3},
+			},
+			[]inputevent.InputEvent{
+				inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftX, Value: 2},
+				inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftY, Value: 3},
+			},
+		},
+		{
+			"touch frames with the same fingers are replaced",
+			[]inputevent.InputEvent{touch(1, 2), touch(1, 2), touch(1)},
+			[]inputevent.InputEvent{touch(1, 2), touch(1)},
+		},
+		{
+			"pen states are replaced until the tip changes",
+			[]inputevent.InputEvent{
+				inputevent.PenState{X: 1, InRange: true},
+				inputevent.PenState{X: 2, InRange: true},
+				inputevent.PenState{X: 3, InRange: true, Tip: true},
+			},
+			[]inputevent.InputEvent{
+				inputevent.PenState{X: 2, InRange: true},
+				inputevent.PenState{X: 3, InRange: true, Tip: true},
+			},
+		},
+		{
+			"keys, clicks and buttons are never merged",
+			[]inputevent.InputEvent{key, key, click, click, button, button},
+			[]inputevent.InputEvent{key, key, click, click, button, button},
+		},
+	} {
+		p := newPipeline(make(chan inputevent.InputEvent))
+		for _, input := range tc.inputs {
+			p.push(input)
+		}
+		assert.Equal(t, tc.want, p.queue.Items(), tc.name)
+	}
+}
+
+func TestPipelineFull(t *testing.T) {
+	p := newPipeline(make(chan inputevent.InputEvent))
+	scroll := inputevent.MouseScroll{Direction: inputevent.MouseScrollUp, Count: 1}
+	for i := 0; i < pipelineCapacity; i++ {
+		p.push(scroll)
+	}
+
+	key := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp}
+	click := inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionUp}
+	button := inputevent.GamepadButtonPress{Button: inputevent.GamepadButtonSouth, Action: inputevent.GamepadButtonActionUp}
+	for _, input := range []inputevent.InputEvent{
+		key,
+		scroll,
+		inputevent.MouseMove{DX: 1},
+		inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxisLeftX},
+		inputevent.TouchFrame{},
+		inputevent.PenState{},
+		click,
+		button,
+	} {
+		p.push(input)
+	}
+	assert.Len(t, p.queue.Items(), pipelineCapacity+3)
+	assert.Equal(t, []inputevent.InputEvent{key, click, button}, p.queue.Items()[pipelineCapacity:])
+
+	// coalescing still works while full
+	p.push(inputevent.MouseMove{DX: 1})
+	assert.Len(t, p.queue.Items(), pipelineCapacity+3)
+}
+
+func TestPipelineOut(t *testing.T) {
+	out := make(chan inputevent.InputEvent)
+	p := newPipeline(out)
+	assert.Nil(t, p.out())
+	assert.Nil(t, p.head())
+
+	p.push(inputevent.MouseMove{DX: 1})
+	p.push(inputevent.MouseScroll{Direction: inputevent.MouseScrollUp, Count: 1})
+	assert.Equal(t, chan<- inputevent.InputEvent(out), p.out())
+	assert.Equal(t, inputevent.MouseMove{DX: 1}, p.head())
+	p.pop()
+	assert.Equal(t, inputevent.MouseScroll{Direction: inputevent.MouseScrollUp, Count: 1}, p.head())
+	p.pop()
+	assert.Nil(t, p.out())
+}
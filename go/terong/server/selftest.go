@@ -0,0 +1,38 @@
+//go:build windows
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"kafji.net/terong/inputsource"
+	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/selftest"
+	"kafji.net/terong/terong/shutdown"
+	"kafji.net/terong/terong/transport/server"
+)
+
+// SelfTest checks what the server needs to run, writing a line per check to
+// w: the config file, the certificates, the port, and the input hooks, which
+// are installed and removed right away. It returns an error if a check
+// failed, see [shutdown.Code].
+func SelfTest(ctx context.Context, w io.Writer) error {
+	cfg, err := config.ReadConfig()
+	if !selftest.Report(w, "config", err) {
+		return &shutdown.ConfigError{Err: err}
+	}
+
+	ok := selftest.Report(w, "tls", server.CheckTLS(&server.Config{
+		TLSCertPath:       cfg.Server.TLSCertPath,
+		TLSKeyPath:        cfg.Server.TLSKeyPath,
+		ClientTLSCertPath: cfg.Server.ClientTLSCertPath,
+	}))
+	ok = selftest.Report(w, fmt.Sprintf("port %d", cfg.Server.Port), selftest.CheckPort(ctx, fmt.Sprintf(":%d", cfg.Server.Port))) && ok
+	ok = selftest.Report(w, "input hooks", inputsource.Check()) && ok
+	if !ok {
+		return selftest.ErrFailed
+	}
+	return nil
+}
@@ -4,21 +4,107 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"slices"
 	"time"
 
 	"golang.org/x/sys/windows"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/foreground"
+	"kafji.net/terong/gctune"
+	"kafji.net/terong/hotkey"
 	"kafji.net/terong/inputevent"
 	"kafji.net/terong/inputsource"
 	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+	"kafji.net/terong/terong/acme"
 	"kafji.net/terong/terong/config"
+	"kafji.net/terong/terong/ctl"
+	"kafji.net/terong/terong/events"
+	"kafji.net/terong/terong/schedule"
+	"kafji.net/terong/terong/shutdown"
+	"kafji.net/terong/terong/state"
+	"kafji.net/terong/terong/stats"
+	"kafji.net/terong/terong/transport"
 	"kafji.net/terong/terong/transport/server"
+	"kafji.net/terong/tracing"
+	"kafji.net/terong/wol"
 )
 
-var slog = logging.NewLogger("terong/server")
+const (
+	// how often the foreground window is checked against relay exceptions
+	foregroundPollInterval = 250 * time.Millisecond
+	// how often relay is checked against the schedule
+	scheduleCheckInterval = time.Minute
+	// the toggle hotkey must be completed within this window
+	toggleWindow = 300 * time.Millisecond
+	// how long stopping waits for pending inputs and the close to reach the
+	// client
+	shutdownTimeout = 2 * time.Second
+)
+
+var errOutsideSchedule = errors.New("outside of schedule")
+
+// relayMode is which inputs are relayed while relay is on.
+type relayMode uint8
+
+const (
+	relayAll relayMode = iota
+	// relayKeyboard relays keys while the mouse keeps driving the server.
+	relayKeyboard
+	// relayMouse relays the mouse, touchpad and pen while the keyboard keeps
+	// driving the server.
+	relayMouse
+)
+
+func (m relayMode) String() string {
+	switch m {
+	case relayKeyboard:
+		return "keyboard"
+	case relayMouse:
+		return "mouse"
+	}
+	return "all"
+}
+
+func (m relayMode) capture() inputsource.Capture {
+	switch m {
+	case relayKeyboard:
+		return inputsource.CaptureKeyboard
+	case relayMouse:
+		return inputsource.CaptureMouse
+	}
+	return inputsource.CaptureAll
+}
 
-func Start(ctx context.Context) {
+// relays reports whether input is relayed in this mode.
+func (m relayMode) relays(input inputevent.InputEvent) bool {
+	switch m {
+	case relayKeyboard:
+		_, ok := input.(inputevent.KeyPress)
+		return ok
+	case relayMouse:
+		switch input.(type) {
+		case inputevent.MouseMove, inputevent.MouseClick, inputevent.MouseScroll, inputevent.TouchFrame, inputevent.PenState:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+type Options struct {
+	// TUI shows a status dashboard instead of log lines.
+	TUI bool
+}
+
+// Start runs the server until ctx is done or it fails. It returns the reason
+// it stopped, see [shutdown.Code].
+func Start(ctx context.Context, opts Options) error {
 	err := disableQuickEdit()
 	if err != nil {
 		slog.Warn("failed to disable quick edit", "error", err)
@@ -27,90 +113,549 @@ func Start(ctx context.Context) {
 	cfg, err := config.ReadConfig()
 	if err != nil {
 		slog.Error("failed to read config file", "error", err)
-		return
+		return &shutdown.ConfigError{Err: err}
 	}
 
-	watcher := config.Watch(ctx)
+	if opts.TUI {
+		runDashboard(ctx)
+	}
+
+	recorder := &statsRecorder{cfg: stats.Config{
+		Relayed: func() int64 {
+			return pipelineInputs.Total() + pipelineCoalesced.Total()
+		},
+		RTT: func() time.Duration {
+			if t := live.transport.Load(); t != nil {
+				return t.RTT()
+			}
+			return 0
+		},
+	}}
+	defer recorder.close()
+
+	watcher := config.Watch(ctx, cfg)
 
 restart:
+	live.config.Store(cfg)
+	live.stats.Store(recorder.update(ctx, cfg.Server.StatsFile))
 	logging.SetLogLevel(cfg.LogLevel)
+	gctune.Apply(gctune.Config{Percent: cfg.GCPercent, MemoryLimit: cfg.MemoryLimitMiB << 20})
 
-	slog.Info("starting server", "config", cfg)
-	runCtx, cancelRun := context.WithCancel(ctx)
-	runDone := run(runCtx, cfg)
-	defer cancelRun()
+	slog.Info("starting server", "config", cfg.Redacted())
+	runCtx, cancelRun := context.WithCancelCause(ctx)
+	// certificates changed alone are swapped by the run loop, so the
+	// active session isn't dropped
+	certs := make(chan *config.Config, 1)
+	runDone := run(runCtx, cfg, certs)
+	defer cancelRun(nil)
 
-	var ok bool
-loop:
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Error("context error", "error", err)
-			break loop
+			cause := context.Cause(ctx)
+			slog.Info("stopping server", "cause", cause)
+			return cause
 
 		case err := <-runDone:
 			slog.Error("error", "error", err)
-			break loop
+			return err
 
-		case cfg, ok = <-watcher.Configs():
+		case next, ok := <-watcher.Configs():
 			if !ok {
 				slog.Error("config watcher error", "error", watcher.Err())
-				break loop
+				return watcher.Err()
+			}
+			prev := cfg
+			cfg = next
+			live.config.Store(cfg)
+			for _, c := range config.Diff(prev, cfg) {
+				slog.Info("config changed", "key", c.Key, "old", c.Old, "new", c.New)
+			}
+			if cfg.OnlyCertsChanged(prev) {
+				select {
+				case certs <- cfg:
+					slog.Info("certificates changed, reloading them without restarting")
+					continue
+				default:
+				}
 			}
-			slog.Info("configurations changed", "config", cfg)
-			cancelRun()
+			slog.Info("configurations changed, restarting server")
+			cancelRun(shutdown.ErrConfigChanged)
+			// so its last events come before the next run's
+			<-runDone
 			goto restart
+
+		case <-watcher.Certs():
+			select {
+			case certs <- cfg:
+				slog.Info("certificate files changed, reloading them")
+			default:
+				// a reload is pending, it reads the files after
+			}
 		}
 	}
 }
 
-func run(ctx context.Context, cfg *config.Config) <-chan error {
+func run(ctx context.Context, cfg *config.Config, certs <-chan *config.Config) <-chan error {
 	done := make(chan error, 1)
 
 	go func() {
+		defer crash.Recover()
 		err := func() error {
-			source := inputsource.Start()
+			var sched schedule.Schedule
+			for _, w := range cfg.Server.Schedule {
+				v, err := schedule.ParseWindow(w.Days, w.Start, w.End)
+				if err != nil {
+					return errs.Mark(fmt.Errorf("failed to parse schedule: %v", err), errs.ErrConfigInvalid)
+				}
+				sched = append(sched, v)
+			}
+
+			var clientMAC net.HardwareAddr
+			if cfg.Server.ClientMAC != "" {
+				v, err := net.ParseMAC(cfg.Server.ClientMAC)
+				if err != nil {
+					return errs.Mark(fmt.Errorf("failed to parse client mac: %v", err), errs.ErrConfigInvalid)
+				}
+				clientMAC = v
+			}
+
+			var sessionPolicy server.SessionPolicy
+			switch cfg.Server.SessionPolicy {
+			case "", "reject":
+				sessionPolicy = server.PolicyReject
+			case "takeover":
+				sessionPolicy = server.PolicyTakeover
+			default:
+				return errs.Mark(fmt.Errorf("unknown session policy %q", cfg.Server.SessionPolicy), errs.ErrConfigInvalid)
+			}
+
+			commandKeys := make(map[transport.Command]*hotkey.Matcher, len(cfg.Server.CommandKeys))
+			for action, key := range cfg.Server.CommandKeys {
+				if !slices.Contains(transport.Commands, action) {
+					return errs.Mark(fmt.Errorf("unknown command %q", action), errs.ErrConfigInvalid)
+				}
+				commandKeys[transport.Command{Action: action}] = hotkey.NewMatcher(hotkey.DoubleTap(key), toggleWindow)
+			}
+
+			clientNames := make(map[string]string, len(cfg.Server.ClientNames))
+			for fingerprint, name := range cfg.Server.ClientNames {
+				clientNames[transport.NormalizeFingerprint(fingerprint)] = name
+			}
+
+			var allowlist *transport.Allowlist
+			if cfg.Server.ClientAllowlistPath != "" {
+				v, err := transport.LoadAllowlist(cfg.Server.ClientAllowlistPath)
+				if err != nil {
+					return errs.Mark(fmt.Errorf("failed to load client allowlist: %v", err), errs.ErrConfigInvalid)
+				}
+				allowlist = v
+			}
+
+			var joinCode *transport.JoinCode
+			if cfg.Server.JoinCodeLifetime > 0 {
+				if allowlist == nil {
+					return errs.Mark(errors.New("join code lifetime requires client allowlist path"), errs.ErrConfigInvalid)
+				}
+				v, err := transport.NewJoinCode(time.Now(), cfg.Server.JoinCodeLifetime)
+				if err != nil {
+					return fmt.Errorf("failed to mint join code: %v", err)
+				}
+				joinCode = v
+				slog.Info("join code minted", "code", joinCode, "expires", joinCode.Expires())
+			}
+			live.joinCode.Store(joinCode)
+
+			source := inputsource.Start(inputsource.Config{
+				MouseDeadZone:    cfg.Server.MouseDeadZone,
+				RecenterInterval: cfg.Server.MouseRecenterInterval,
+				SkipRecenterMove: cfg.Server.MouseSkipRecenterMove,
+				Gamepad:          cfg.Server.RelayGamepad,
+				Touchpad:         cfg.Server.RelayTouchpad,
+				Pen:              cfg.Server.RelayPen,
+				HighPriority:     cfg.Server.HighPriority,
+			})
 			defer source.Stop()
 
-			events := make(chan inputevent.InputEvent)
+			jitter := inputevent.JitterFilter{
+				Threshold: int16(min(cfg.Server.MouseJitterThreshold, math.MaxInt16)),
+				Window:    cfg.Server.MouseJitterWindow,
+			}
+			ramp := inputevent.RampIn{Duration: cfg.Server.MouseRampIn}
+
+			go metrics.LogSummaries(ctx, metrics.SummaryInterval)
+
+			if cfg.Tracing.Endpoint != "" {
+				tracing.Start(ctx, tracing.Config{
+					Endpoint:    cfg.Tracing.Endpoint,
+					ServiceName: "terong-server",
+					Node:        cfg.Name(),
+					SampleEvery: cfg.Tracing.SampleEvery,
+				})
+			}
+
+			var statusDone <-chan error
+			if cfg.StatusAddr != "" {
+				statusDone = metrics.Serve(ctx, cfg.StatusAddr, cfg.StatusAllowRemote)
+			}
+
+			if a := cfg.Server.ACME; a.Domain != "" {
+				acmeCfg := &acme.Config{
+					Domain:         a.Domain,
+					Email:          a.Email,
+					DirectoryURL:   a.DirectoryURL,
+					AccountKeyPath: a.AccountKeyPath,
+					DNSHook:        a.DNSHook,
+					RenewBefore:    a.RenewBefore,
+					CertPath:       cfg.Server.TLSCertPath,
+					KeyPath:        cfg.Server.TLSKeyPath,
+				}
+				// renewals are picked up by the certificate watcher
+				if err := acme.Obtain(ctx, acmeCfg); err != nil {
+					return errs.Mark(fmt.Errorf("failed to obtain certificate: %v", err), errs.Kind(err))
+				}
+				go func() {
+					defer crash.Recover()
+					acme.Renew(ctx, acmeCfg)
+				}()
+			}
+
+			inputs := make(chan inputevent.InputEvent)
+			pipe := newPipeline(inputs)
 
 			transportCfg := &server.Config{
 				Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
 				TLSCertPath:       cfg.Server.TLSCertPath,
 				TLSKeyPath:        cfg.Server.TLSKeyPath,
 				ClientTLSCertPath: cfg.Server.ClientTLSCertPath,
+				TCP: transport.TCPConfig{
+					NoDelay:         cfg.Server.TCP.NoDelay,
+					KeepAlivePeriod: cfg.Server.TCP.KeepAlivePeriod,
+					ReadBufferSize:  cfg.Server.TCP.ReadBufferSize,
+					WriteBufferSize: cfg.Server.TCP.WriteBufferSize,
+				},
+				Session: transport.SessionConfig{
+					CoalesceDelay:    cfg.Server.WriteCoalesceDelay,
+					CoalesceFrames:   cfg.Server.WriteCoalesceFrames,
+					PeerNames:        clientNames,
+					Checksum:         cfg.Server.FrameChecksum,
+					MaxMessageLength: cfg.Server.MaxMessageLength,
+					Trace:            cfg.Tracing.Endpoint != "",
+					LatencyAlert:     cfg.LatencyAlert,
+				},
+				Admit: func() error {
+					if !sched.Allows(time.Now()) {
+						return errOutsideSchedule
+					}
+					return nil
+				},
+				Settings:           clientSettings(cfg.Server.ClientSettings),
+				Geometry:           geometry(cfg.Screens),
+				Dropped:            pipe.Dropped,
+				MaxSessionLifetime: cfg.Server.MaxSessionLifetime,
+				SessionPolicy:      sessionPolicy,
+				Name:               cfg.Name(),
+				Allowlist:          allowlist,
+				JoinCode:           joinCode,
 			}
-			transport := server.Start(ctx, transportCfg, events)
+			// the transport outlives ctx, so it can be shut down after
+			// capture stops
+			transportCtx, stopTransport := context.WithCancel(context.WithoutCancel(ctx))
+			defer stopTransport()
+			transport := server.Start(transportCtx, transportCfg, inputs)
+			live.transport.Store(transport)
+			transportStopped := false
+			defer func() {
+				if transportStopped {
+					return
+				}
+				shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+				defer cancel()
+				stopInOrder(shutdownCtx, source, pipe, transport)
+			}()
 
-			buffer := keyBuffer{}
+			toggle := hotkey.NewMatcher(hotkey.DoubleTap(inputevent.RightCtrl), toggleWindow)
+			modeToggles := make(map[relayMode]*hotkey.Matcher)
+			if key := cfg.Server.KeyboardRelayKey; key != 0 {
+				modeToggles[relayKeyboard] = hotkey.NewMatcher(hotkey.DoubleTap(key), toggleWindow)
+			}
+			if key := cfg.Server.MouseRelayKey; key != 0 {
+				modeToggles[relayMouse] = hotkey.NewMatcher(hotkey.DoubleTap(key), toggleWindow)
+			}
 			relay := false
-			toggledAt := time.Time{}
+			mode := relayAll
+			suppress := newSuppressor(cfg.Server.ToggleGracePeriod)
+			matchers := []*hotkey.Matcher{toggle}
+			for _, m := range modeToggles {
+				matchers = append(matchers, m)
+			}
+			for _, m := range commandKeys {
+				matchers = append(matchers, m)
+			}
+			hold := newWithholder(toggleWindow, matchers...)
 
-			source.SetCaptureInputs(relay)
+			var stats overlay
+			send := func(input inputevent.InputEvent) {
+				pipe.push(input)
+				hold.sent(input)
+				stats.relayed++
+			}
+
+			exceptions := make([]foreground.Rule, 0, len(cfg.Server.RelayExceptions))
+			for _, r := range cfg.Server.RelayExceptions {
+				exceptions = append(exceptions, foreground.Rule{ProcessName: r.ProcessName, Class: r.WindowClass})
+			}
+			var foregroundTick <-chan time.Time
+			if len(exceptions) > 0 {
+				ticker := time.NewTicker(foregroundPollInterval)
+				defer ticker.Stop()
+				foregroundTick = ticker.C
+			}
+			// relay is suspended while an excepted window is in the foreground
+			suspended := false
+
+			var scheduleTick <-chan time.Time
+			if len(sched) > 0 {
+				ticker := time.NewTicker(scheduleCheckInterval)
+				defer ticker.Stop()
+				scheduleTick = ticker.C
+			}
+
+			relaying := func() bool {
+				return relay && !suspended
+			}
+			capture := func() inputsource.Capture {
+				if !relaying() {
+					return 0
+				}
+				return mode.capture()
+			}
+			updateRelaying := func(was inputsource.Capture) {
+				live.relay.Store(relay)
+				live.suspended.Store(suspended)
+				now := capture()
+				if now == was {
+					return
+				}
+				source.SetCapture(now)
+				if (now != 0) != (was != 0) {
+					transport.SetRelayState(now != 0)
+					enabled := now != 0
+					events.Publish(events.Event{Kind: events.RelayToggled, Peer: transport.Peer(), Relay: &enabled})
+				}
+				if now&inputsource.CaptureMouse != 0 && was&inputsource.CaptureMouse == 0 {
+					ramp.Start(time.Now())
+				}
+				if was == 0 {
+					suppress.relayStarted(time.Now())
+				}
+				if now&inputsource.CaptureKeyboard != 0 && was&inputsource.CaptureKeyboard == 0 {
+					suppress.keyboardStarted()
+				}
+				if now&inputsource.CaptureKeyboard == 0 && was&inputsource.CaptureKeyboard != 0 {
+					for _, up := range hold.drop() {
+						send(up)
+					}
+				}
+			}
+
+			// saveState persists the relay state if a state file is
+			// configured
+			saveState := func() {
+				if cfg.Server.StateFile == "" {
+					return
+				}
+				st := state.State{Relay: relay, Target: transport.Peer()}
+				if err := state.Save(cfg.Server.StateFile, st); err != nil {
+					slog.Warn("failed to save state", "error", err)
+				}
+			}
+
+			if cfg.Server.StateFile != "" && cfg.Server.RestoreRelay {
+				st, err := state.Load(cfg.Server.StateFile)
+				if err != nil {
+					slog.Warn("failed to load state", "error", err)
+				} else if st.Relay && sched.Allows(time.Now()) {
+					slog.Info("restoring relay", "target", st.Target)
+					relay = true
+				}
+			}
+
+			// setRelay turns relay on in mode m or off as requested by the
+			// hotkeys or the control endpoint
+			setRelay := func(v bool, m relayMode) {
+				was := capture()
+				relay, mode = v, m
+				if relay && mode != relayAll {
+					slog.Info("relaying only some inputs", "mode", mode)
+				}
+				if relay && !sched.Allows(time.Now()) {
+					slog.Info("relay is not allowed outside of schedule")
+					relay = false
+				}
+				if relay && suspended {
+					slog.Info("relay is suspended by foreground window")
+				}
+				if relay && clientMAC != nil && transport.Peer() == "" {
+					slog.Info("waking client", "mac", clientMAC)
+					if err := wol.Send(clientMAC, cfg.Server.WakeAddr); err != nil {
+						slog.Warn("failed to wake client", "error", err)
+					}
+				}
+				updateRelaying(was)
+				saveState()
+			}
+
+			var overlayTick <-chan time.Time
+			if cfg.Server.StatsOverlay {
+				ticker := time.NewTicker(overlayInterval)
+				defer ticker.Stop()
+				overlayTick = ticker.C
+				defer setConsoleTitle(overlayIdleTitle)
+			}
+
+			live.relay.Store(relay)
+			live.suspended.Store(false)
+			source.SetCapture(capture())
+			if relay {
+				transport.SetRelayState(relay)
+				enabled := true
+				events.Publish(events.Event{Kind: events.RelayToggled, Relay: &enabled})
+				ramp.Start(time.Now())
+			}
+			defer func() {
+				// the next run starts with relay off, unless restored
+				if capture() != 0 {
+					enabled := false
+					events.Publish(events.Event{Kind: events.RelayToggled, Relay: &enabled})
+				}
+			}()
 
 			for {
 				select {
 				case <-ctx.Done():
-					return ctx.Err()
+					return context.Cause(ctx)
+
+				case err := <-statusDone:
+					slog.Warn("status endpoint stopped", "error", err)
+					statusDone = nil
 
 				case input, ok := <-source.Inputs():
 					if !ok {
 						return source.Error()
 					}
-					slog.Debug("input received", "input", input)
-					if relay {
-						events <- input
+					if slog.DebugEnabled() {
+						slog.Debug("input received", "input", input)
 					}
+					suppress.observe(input)
+
+					// whether it's relayed is decided before it toggles relay,
+					// but the presses of a hotkey are never relayed
+					relayed := relaying() && mode.relays(input)
 					if v, ok := input.(inputevent.KeyPress); ok {
-						buffer.push(v)
-						if yes, at := buffer.toggleKeyStrokeExists(toggledAt); yes {
+						fired := false
+						if toggle.Push(v, time.Now()) {
 							slog.Debug("toggling relay")
-							relay = !relay
-							toggledAt = at
-							source.SetCaptureInputs(relay)
+							fired = true
+							setRelay(!relay || mode != relayAll, relayAll)
 						}
+						for m, matcher := range modeToggles {
+							if matcher.Push(v, time.Now()) {
+								slog.Debug("toggling relay", "mode", m)
+								fired = true
+								setRelay(!relay || mode != m, m)
+							}
+						}
+						for cmd, matcher := range commandKeys {
+							if matcher.Push(v, time.Now()) {
+								slog.Info("sending command", "action", cmd.Action)
+								fired = true
+								transport.SendCommand(cmd)
+							}
+						}
+						if fired {
+							if relayed {
+								hold.hold(v)
+							}
+							for _, up := range hold.drop() {
+								send(up)
+							}
+							relayed = false
+						}
+					}
+
+					if relayed && suppress.allow(input, time.Now()) && jitter.Allow(input, time.Now()) {
+						if v, ok := input.(inputevent.KeyPress); ok && hold.withhold(v) {
+							continue
+						}
+						for _, held := range hold.release() {
+							send(held)
+						}
+						if ramped, ok := ramp.Apply(input, time.Now()); ok {
+							send(ramped)
+						}
+					}
+
+				case <-hold.expired():
+					for _, held := range hold.release() {
+						send(held)
+					}
+
+				case pipe.out() <- pipe.head():
+					pipe.pop()
+
+				case req := <-relayRequests:
+					switch req.action {
+					case ctl.ActionToggle:
+						setRelay(!relay, relayAll)
+					case ctl.ActionOn:
+						setRelay(true, relayAll)
+					case ctl.ActionOff:
+						setRelay(false, relayAll)
+					}
+					req.reply <- ctl.RelayState{Relay: relay, Suspended: relay && suspended, Peer: transport.Peer()}
+
+				case <-overlayTick:
+					stats.update(relaying(), transport.Peer(), transport.RTT(), source.Dropped()+pipe.Dropped()+transport.Dropped())
+
+				case <-scheduleTick:
+					if relay && !sched.Allows(time.Now()) {
+						slog.Info("schedule ended, disabling relay")
+						was := capture()
+						relay = false
+						updateRelaying(was)
+						saveState()
+					}
+
+				case <-foregroundTick:
+					w, err := foreground.Current()
+					if err != nil {
+						slog.Debug("failed to get foreground window", "error", err)
+						continue
+					}
+					_, match := foreground.MatchAny(exceptions, w)
+					if match == suspended {
+						continue
+					}
+					was := capture()
+					suspended = match
+					if suspended {
+						slog.Info("excepted window in foreground, relay suspended", "process_name", w.ProcessName, "window_class", w.Class)
+					} else {
+						slog.Info("excepted window left foreground, relay unsuspended")
+					}
+					updateRelaying(was)
+
+				case next := <-certs:
+					err := transport.ReloadTLS(next.Server.TLSCertPath, next.Server.TLSKeyPath, next.Server.ClientTLSCertPath)
+					if err != nil {
+						slog.Error("failed to reload certificates", "error", err)
+						continue
 					}
-				case err := <-transport:
+					slog.Info("certificates reloaded")
+
+				case err := <-transport.Done():
+					transportStopped = true
 					return err
 				}
 			}
@@ -122,55 +667,30 @@ func run(ctx context.Context, cfg *config.Config) <-chan error {
 	return done
 }
 
-type keyBufferEntry struct {
-	k inputevent.KeyPress
-	t time.Time
-}
-
-type keyBuffer struct {
-	buf []keyBufferEntry
-}
-
-func (b *keyBuffer) push(k inputevent.KeyPress) {
-	if k.Action != inputevent.KeyActionDown && k.Action != inputevent.KeyActionUp {
-		return
+// clientSettings returns the settings to push to clients, or nil if none are
+// configured.
+func clientSettings(s config.ClientSettings) *transport.Settings {
+	if s == (config.ClientSettings{}) {
+		return nil
+	}
+	return &transport.Settings{
+		PointerScale:    s.PointerScale,
+		InvertScroll:    s.InvertScroll,
+		KeyRepeatDelay:  s.KeyRepeatDelay,
+		KeyRepeatPeriod: s.KeyRepeatPeriod,
 	}
-	i, _ := slices.BinarySearchFunc(
-		b.buf,
-		time.Now().Add(-300*time.Millisecond),
-		func(e keyBufferEntry, t2 time.Time) int {
-			t1 := e.t
-			return int(t1.UnixMilli() - t2.UnixMilli())
-		},
-	)
-	b.buf = append(b.buf[i:], keyBufferEntry{k: k, t: time.Now()})
 }
 
-func (b *keyBuffer) toggleKeyStrokeExists(after time.Time) (bool, time.Time) {
-	c := 1
-	var t time.Time
-	for i := len(b.buf) - 1; i >= 0; i-- {
-		e := b.buf[i]
-		if e.k.Key != inputevent.RightCtrl {
-			continue
-		}
-		if e.t.UnixMilli() <= after.UnixMilli() {
-			return false, time.Time{}
-		}
-		switch {
-		case c == 1 && e.k.Action == inputevent.KeyActionUp:
-			t = e.t
-			fallthrough
-		case c%2 != 0 && e.k.Action == inputevent.KeyActionUp:
-			c++
-		case c%2 == 0 && e.k.Action == inputevent.KeyActionDown:
-			c++
-		}
-		if c/2 == 2 {
-			return true, t
-		}
+// geometry returns the geometry of screens, or nil if there are none.
+func geometry(screens []config.Screen) *transport.Geometry {
+	if len(screens) == 0 {
+		return nil
+	}
+	g := &transport.Geometry{Screens: make([]transport.Screen, len(screens))}
+	for i, s := range screens {
+		g.Screens[i] = transport.Screen(s)
 	}
-	return false, time.Time{}
+	return g
 }
 
 func disableQuickEdit() error {
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+
+	"kafji.net/terong/terong/stats"
+)
+
+// statsRecorder records to the stats_file of the configs in use, restarting
+// when it changes.
+type statsRecorder struct {
+	cfg      stats.Config
+	stop     context.CancelFunc
+	recorder *stats.Recorder
+}
+
+// update records to path from now, stopping the recorder of a previous path
+// first, and returns the recorder, nil if path is empty.
+func (s *statsRecorder) update(ctx context.Context, path string) *stats.Recorder {
+	if s.recorder != nil && s.cfg.Path == path {
+		return s.recorder
+	}
+	s.close()
+	s.cfg.Path = path
+	if path == "" {
+		return nil
+	}
+	ctx, s.stop = context.WithCancel(ctx)
+	s.recorder = stats.Start(ctx, s.cfg)
+	return s.recorder
+}
+
+// close stops the recorder and waits for it to save the aggregates.
+func (s *statsRecorder) close() {
+	if s.recorder == nil {
+		return
+	}
+	s.stop()
+	<-s.recorder.Done()
+	s.recorder = nil
+}
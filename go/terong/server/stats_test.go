@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/terong/events"
+	"kafji.net/terong/terong/stats"
+)
+
+func TestStatsRecorderRestart(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.json"), filepath.Join(dir, "second.json")
+	r := &statsRecorder{cfg: stats.Config{
+		Relayed: func() int64 { return 0 },
+		RTT:     func() time.Duration { return 0 },
+	}}
+	defer r.close()
+
+	recorder := r.update(context.Background(), first)
+	require.NotNil(t, recorder)
+	assert.Same(t, recorder, r.update(context.Background(), first))
+
+	// relay on, then off as a run relaying stops for a restart
+	t0 := time.Now().Add(-2 * time.Minute)
+	on, off := true, false
+	events.Publish(events.Event{Kind: events.RelayToggled, Time: t0, Relay: &on})
+	events.Publish(events.Event{Kind: events.RelayToggled, Time: t0.Add(time.Minute), Relay: &off})
+
+	// the restart moved on to another file
+	require.NotNil(t, r.update(context.Background(), second))
+	s, err := stats.Load(first)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, s[t0.Add(time.Minute).Format(time.DateOnly)].Relaying)
+
+	assert.Nil(t, r.update(context.Background(), ""))
+	s, err = stats.Load(second)
+	require.NoError(t, err)
+	for _, d := range s {
+		assert.Zero(t, d.Relaying)
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"context"
+
+	"kafji.net/terong/logging"
+)
+
+var slog = logging.NewLogger("terong/server")
+
+// stoppable is the input source as seen by [stopInOrder].
+type stoppable interface {
+	Stop()
+}
+
+// shutdownable is the transport as seen by [stopInOrder].
+type shutdownable interface {
+	Done() <-chan error
+	Shutdown(ctx context.Context) error
+}
+
+// stopInOrder stops capture, relays the inputs still in pipe, then closes the
+// session and the listener, in that order, giving up on what's left once ctx
+// is done.
+func stopInOrder(ctx context.Context, source stoppable, pipe *pipeline, transport shutdownable) {
+	source.Stop()
+flush:
+	for pipe.head() != nil {
+		select {
+		case pipe.out() <- pipe.head():
+			pipe.pop()
+		case <-ctx.Done():
+			slog.Warn("inputs not relayed on shutdown", "error", context.Cause(ctx))
+			break flush
+		case <-transport.Done():
+			return
+		}
+	}
+	if err := transport.Shutdown(ctx); err != nil {
+		slog.Warn("failed to shut down transport", "error", err)
+	}
+}
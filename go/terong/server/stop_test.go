@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+// stopLog records the steps of stopping, in order.
+type stopLog struct {
+	steps []string
+	done  chan error
+	// relayed is where the pipeline sends to
+	relayed chan inputevent.InputEvent
+}
+
+func (l *stopLog) add(step string) {
+	l.steps = append(l.steps, step)
+}
+
+func (l *stopLog) Stop() {
+	l.add("stop source")
+}
+
+func (l *stopLog) Done() <-chan error {
+	return l.done
+}
+
+func (l *stopLog) Shutdown(context.Context) error {
+	l.add(fmt.Sprintf("shut down transport, %d relayed", len(l.relayed)))
+	return nil
+}
+
+func TestStopInOrder(t *testing.T) {
+	log := &stopLog{done: make(chan error), relayed: make(chan inputevent.InputEvent, 2)}
+	pipe := newPipeline(log.relayed)
+	pipe.push(inputevent.MouseMove{DX: 1})
+	pipe.push(inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp})
+
+	stopInOrder(context.Background(), log, pipe, log)
+
+	assert.Equal(t, []string{"stop source", "shut down transport, 2 relayed"}, log.steps)
+	assert.Equal(t, inputevent.MouseMove{DX: 1}, <-log.relayed)
+	assert.Equal(t, inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp}, <-log.relayed)
+}
+
+func TestStopInOrderTimeout(t *testing.T) {
+	log := &stopLog{done: make(chan error)}
+	// nothing takes the inputs
+	pipe := newPipeline(log.relayed)
+	pipe.push(inputevent.MouseMove{DX: 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	stopInOrder(ctx, log, pipe, log)
+
+	assert.Equal(t, []string{"stop source", "shut down transport, 0 relayed"}, log.steps)
+}
+
+func TestStopInOrderTransportStopped(t *testing.T) {
+	log := &stopLog{done: make(chan error, 1)}
+	log.done <- nil
+	pipe := newPipeline(make(chan inputevent.InputEvent))
+	pipe.push(inputevent.MouseMove{DX: 1})
+
+	stopInOrder(context.Background(), log, pipe, log)
+
+	assert.Equal(t, []string{"stop source"}, log.steps)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"time"
+
+	"kafji.net/terong/inputevent"
+)
+
+// suppressor keeps the tail of the hotkey that turned relay on from reaching
+// the client. Keys held when the keyboard starts being relayed, like the keys
+// of the hotkey itself, have their repeats and release swallowed, as the
+// client never saw them pressed. And nothing is relayed for a grace period
+// after relay turns on, while the input source starts capturing.
+//
+// It is only used from the run loop.
+type suppressor struct {
+	grace time.Duration
+	until time.Time
+
+	// keys held down on the server
+	held map[inputevent.KeyCode]struct{}
+	// keys whose repeats and next release are not relayed
+	swallowed map[inputevent.KeyCode]struct{}
+}
+
+func newSuppressor(grace time.Duration) *suppressor {
+	return &suppressor{
+		grace:     grace,
+		held:      make(map[inputevent.KeyCode]struct{}),
+		swallowed: make(map[inputevent.KeyCode]struct{}),
+	}
+}
+
+// observe tracks the keys held down. It's called with every input before
+// hotkeys are matched.
+func (s *suppressor) observe(input inputevent.InputEvent) {
+	k, ok := input.(inputevent.KeyPress)
+	if !ok {
+		return
+	}
+	switch k.Action {
+	case inputevent.KeyActionDown:
+		s.held[k.Key] = struct{}{}
+	case inputevent.KeyActionUp:
+		delete(s.held, k.Key)
+	}
+}
+
+// relayStarted starts the grace period.
+func (s *suppressor) relayStarted(now time.Time) {
+	s.until = now.Add(s.grace)
+}
+
+// keyboardStarted swallows the keys held while the keyboard wasn't relayed.
+// Keys swallowed the last time but released meanwhile are relayed again.
+func (s *suppressor) keyboardStarted() {
+	clear(s.swallowed)
+	for k := range s.held {
+		s.swallowed[k] = struct{}{}
+	}
+}
+
+// allow reports whether input may be relayed.
+func (s *suppressor) allow(input inputevent.InputEvent, now time.Time) bool {
+	if k, ok := input.(inputevent.KeyPress); ok {
+		if _, ok := s.swallowed[k.Key]; ok {
+			if k.Action == inputevent.KeyActionUp {
+				delete(s.swallowed, k.Key)
+			}
+			return false
+		}
+	}
+	return !now.Before(s.until)
+}
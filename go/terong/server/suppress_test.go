@@ -0,0 +1,52 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/inputevent"
+)
+
+func TestSuppressorSwallowsHeldKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	down := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionDown}
+	up := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp}
+	s := newSuppressor(0)
+
+	// held when the keyboard starts being relayed
+	s.observe(down)
+	s.keyboardStarted()
+	assert.False(t, s.allow(inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionRepeat}, now))
+	s.observe(up)
+	assert.False(t, s.allow(up, now))
+	s.observe(down)
+	assert.True(t, s.allow(down, now))
+}
+
+func TestSuppressorForgetsKeysReleasedWhileNotRelayed(t *testing.T) {
+	now := time.Unix(0, 0)
+	down := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionDown}
+	up := inputevent.KeyPress{Key: inputevent.A, Action: inputevent.KeyActionUp}
+	s := newSuppressor(0)
+
+	s.observe(down)
+	s.keyboardStarted()
+	// relay turns off, the key is released on the server, relay turns on
+	s.observe(up)
+	s.keyboardStarted()
+
+	s.observe(down)
+	assert.True(t, s.allow(down, now))
+	s.observe(up)
+	assert.True(t, s.allow(up, now))
+}
+
+func TestSuppressorGracePeriod(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := newSuppressor(50 * time.Millisecond)
+	s.relayStarted(now)
+	move := inputevent.MouseMove{DX: 1}
+	assert.False(t, s.allow(move, now.Add(49*time.Millisecond)))
+	assert.True(t, s.allow(move, now.Add(50*time.Millisecond)))
+}
@@ -0,0 +1,113 @@
+//go:build windows
+
+package server
+
+import (
+	"time"
+
+	"kafji.net/terong/hotkey"
+	"kafji.net/terong/inputevent"
+)
+
+// withholder keeps the presses of hotkeys from reaching the client. Presses
+// that may be the start of a hotkey are held back until the hotkey completes,
+// when they are dropped, or until it can no longer complete, when they are
+// relayed in order. Releases of keys the client saw pressed are never
+// dropped, so no key is left stuck on the client.
+//
+// It is only used from the run loop.
+type withholder struct {
+	matchers []*hotkey.Matcher
+	window   time.Duration
+	timer    *time.Timer
+
+	held []inputevent.InputEvent
+	// keys held down on the client
+	down map[inputevent.KeyCode]struct{}
+}
+
+func newWithholder(window time.Duration, matchers ...*hotkey.Matcher) *withholder {
+	timer := time.NewTimer(window)
+	timer.Stop()
+	return &withholder{
+		matchers: matchers,
+		window:   window,
+		timer:    timer,
+		down:     make(map[inputevent.KeyCode]struct{}),
+	}
+}
+
+// withhold holds k back if it may be part of a hotkey in progress and reports
+// whether it did. k must have been pushed to the matchers.
+func (w *withholder) withhold(k inputevent.KeyPress) bool {
+	for _, m := range w.matchers {
+		if m.Contains(k.Key) && m.Pending() {
+			w.hold(k)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *withholder) hold(k inputevent.KeyPress) {
+	if len(w.held) == 0 {
+		w.timer.Reset(w.window)
+	}
+	w.held = append(w.held, k)
+}
+
+// expired receives when the held presses were held for the window, so the
+// hotkey can no longer complete. It's nil while nothing is held.
+func (w *withholder) expired() <-chan time.Time {
+	if len(w.held) == 0 {
+		return nil
+	}
+	return w.timer.C
+}
+
+// release returns the held presses to be relayed.
+func (w *withholder) release() []inputevent.InputEvent {
+	held := w.held
+	w.clear()
+	return held
+}
+
+// drop drops the held presses and returns the releases of the keys held down
+// on the client among them, to be relayed.
+func (w *withholder) drop() []inputevent.InputEvent {
+	var ups []inputevent.InputEvent
+	for _, input := range w.held {
+		k := input.(inputevent.KeyPress)
+		if _, ok := w.down[k.Key]; ok && k.Action == inputevent.KeyActionUp {
+			ups = append(ups, k)
+			delete(w.down, k.Key)
+		}
+	}
+	w.clear()
+	return ups
+}
+
+func (w *withholder) clear() {
+	w.held = nil
+	if !w.timer.Stop() {
+		select {
+		case <-w.timer.C:
+		default:
+		}
+	}
+}
+
+// sent tracks the keys held down on the client. It's called with every input
+// relayed.
+func (w *withholder) sent(input inputevent.InputEvent) {
+	k, ok := input.(inputevent.KeyPress)
+	if !ok {
+		return
+	}
+	switch k.Action {
+	case inputevent.KeyActionDown:
+		w.down[k.Key] = struct{}{}
+	case inputevent.KeyActionUp:
+		delete(w.down, k.Key)
+	}
+}
@@ -0,0 +1,77 @@
+// Package shutdown names the reasons the process stops and maps them to exit
+// codes, so service managers can tell a requested stop from a failure.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"kafji.net/terong/errs"
+)
+
+const (
+	// ExitOK is a requested stop.
+	ExitOK = 0
+	// ExitFailure is any other error.
+	ExitFailure = 1
+	// ExitConfig is unusable configurations, see [errs.ErrConfigInvalid].
+	// Restarting won't help.
+	ExitConfig = 2
+)
+
+var (
+	// ErrSignal is the cause of the context of Context being done after the
+	// process is asked to stop.
+	ErrSignal = errors.New("stop signal received")
+	// ErrConfigChanged is the cause of a run being stopped to restart it with
+	// new configurations.
+	ErrConfigChanged = errors.New("configurations changed")
+)
+
+// ConfigError is an error of reading configurations.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return "failed to read config file: " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// Context returns a copy of parent that is done with ErrSignal as the cause
+// on interrupt or termination signal. Calling stop releases the signals.
+func Context(parent context.Context) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel(ErrSignal)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(context.Canceled)
+	}
+}
+
+// Code returns the exit code of the process stopped by err.
+func Code(err error) int {
+	var configErr *ConfigError
+	switch {
+	case err == nil, errors.Is(err, ErrSignal):
+		return ExitOK
+	case errors.As(err, &configErr), errors.Is(err, errs.ErrConfigInvalid):
+		return ExitConfig
+	default:
+		return ExitFailure
+	}
+}
@@ -0,0 +1,28 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/errs"
+)
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, ExitOK, Code(nil))
+	assert.Equal(t, ExitOK, Code(ErrSignal))
+	assert.Equal(t, ExitOK, Code(fmt.Errorf("run: %w", ErrSignal)))
+	assert.Equal(t, ExitConfig, Code(&ConfigError{Err: errors.New("bad toml")}))
+	assert.Equal(t, ExitConfig, Code(errs.Mark(errors.New("unknown session policy"), errs.ErrConfigInvalid)))
+	assert.Equal(t, ExitFailure, Code(errors.New("failed to listen")))
+	assert.Equal(t, ExitFailure, Code(context.Canceled))
+}
+
+func TestContextStop(t *testing.T) {
+	ctx, stop := Context(context.Background())
+	stop()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, context.Cause(ctx))
+}
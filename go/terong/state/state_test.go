@@ -0,0 +1,41 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, err)
+	assert.Equal(t, State{}, s)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	require.NoError(t, Save(path, State{Relay: true, Target: "laptop"}))
+	s, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, State{Relay: true, Target: "laptop"}, s)
+
+	require.NoError(t, Save(path, State{}))
+	s, err = Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, State{}, s)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temp files are cleaned up")
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o644))
+	_, err := Load(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+// Package stats keeps daily aggregates of relaying in a small file, so usage
+// can be looked back on across restarts with terongctl stats.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"kafji.net/terong/crash"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/terong/events"
+)
+
+var slog = logging.NewLogger("terong/stats")
+
+// flushInterval is how often the aggregates are sampled and saved.
+const flushInterval = time.Minute
+
+// Day aggregates a day of relaying, in local time.
+type Day struct {
+	// Relaying is how long relay was on.
+	Relaying time.Duration `json:"relaying"`
+	// Relayed is how many inputs were relayed.
+	Relayed int64 `json:"relayed"`
+	// Disconnects is how many sessions ended.
+	Disconnects int `json:"disconnects"`
+	// The round-trip time is sampled every flushInterval while connected.
+	RTTTotal   time.Duration `json:"rtt_total"`
+	RTTSamples int64         `json:"rtt_samples"`
+}
+
+// AvgRTT returns the average round-trip time, or zero if none was sampled.
+func (d Day) AvgRTT() time.Duration {
+	if d.RTTSamples == 0 {
+		return 0
+	}
+	return d.RTTTotal / time.Duration(d.RTTSamples)
+}
+
+func (d Day) String() string {
+	rtt := "-"
+	if v := d.AvgRTT(); v > 0 {
+		rtt = v.Round(10 * time.Microsecond).String()
+	}
+	return fmt.Sprintf("relaying %v, %d inputs, %d disconnects, rtt %s", d.Relaying.Round(time.Second), d.Relayed, d.Disconnects, rtt)
+}
+
+// Stats are days keyed by date, e.g. "2024-06-01".
+type Stats map[string]Day
+
+// Dates returns the dates of s, oldest first.
+func (s Stats) Dates() []string {
+	dates := make([]string, 0, len(s))
+	for d := range s {
+		dates = append(dates, d)
+	}
+	slices.Sort(dates)
+	return dates
+}
+
+func date(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
+
+// Load reads the stats at path. A missing file is empty Stats.
+func Load(path string) (Stats, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Stats{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := Stats{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse stats: %v", err)
+	}
+	return s, nil
+}
+
+// Save writes s to path. The file is replaced atomically so a crash never
+// leaves it half written.
+func Save(path string, s Stats) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temp file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace stats file: %v", err)
+	}
+	return nil
+}
+
+type Config struct {
+	// Path is the stats file. Days already in it are kept.
+	Path string
+	// Relayed returns how many inputs were relayed since start.
+	Relayed func() int64
+	// RTT returns the latest round-trip time, or zero if disconnected.
+	RTT func() time.Duration
+}
+
+// Recorder aggregates relay toggles and disconnects published to package
+// events, and samples Config.Relayed and Config.RTT.
+type Recorder struct {
+	cfg  Config
+	done chan struct{}
+
+	mu    sync.Mutex
+	stats Stats
+	// relay is on since then, zero while off
+	relaySince time.Time
+	relayed    int64
+}
+
+func newRecorder(cfg Config, stats Stats) *Recorder {
+	return &Recorder{cfg: cfg, done: make(chan struct{}), stats: stats, relayed: cfg.Relayed()}
+}
+
+// Done is closed when the recorder stopped and saved the aggregates.
+func (r *Recorder) Done() <-chan struct{} {
+	return r.done
+}
+
+// Today returns the aggregates of today as of the latest sample.
+func (r *Recorder) Today() Day {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats[date(time.Now())]
+}
+
+// Start records the aggregates until ctx is done, saving them every
+// flushInterval and when stopping.
+func Start(ctx context.Context, cfg Config) *Recorder {
+	stats, err := Load(cfg.Path)
+	if err != nil {
+		slog.Warn("failed to load stats, starting over", "error", err)
+		stats = Stats{}
+	}
+	r := newRecorder(cfg, stats)
+	sub := events.Subscribe(ctx)
+
+	go func() {
+		defer crash.Recover()
+		defer close(r.done)
+
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case e, ok := <-sub:
+				if !ok {
+					r.sample(time.Now())
+					r.save()
+					return
+				}
+				r.handle(e)
+
+			case now := <-ticker.C:
+				r.sample(now)
+				r.save()
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *Recorder) handle(e events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e.Kind {
+	case events.RelayToggled:
+		if e.Relay == nil {
+			return
+		}
+		r.accrue(e.Time)
+		if *e.Relay {
+			r.relaySince = e.Time
+		} else {
+			r.relaySince = time.Time{}
+		}
+	case events.Disconnected:
+		d := r.stats[date(e.Time)]
+		d.Disconnects++
+		r.stats[date(e.Time)] = d
+	}
+}
+
+// accrue adds the time relay was on until now to the day of now.
+func (r *Recorder) accrue(now time.Time) {
+	if r.relaySince.IsZero() || !now.After(r.relaySince) {
+		return
+	}
+	d := r.stats[date(now)]
+	d.Relaying += now.Sub(r.relaySince)
+	r.stats[date(now)] = d
+	r.relaySince = now
+}
+
+func (r *Recorder) sample(now time.Time) {
+	relayed, rtt := r.cfg.Relayed(), r.cfg.RTT()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accrue(now)
+	d := r.stats[date(now)]
+	d.Relayed += relayed - r.relayed
+	r.relayed = relayed
+	if rtt > 0 {
+		d.RTTTotal += rtt
+		d.RTTSamples++
+	}
+	r.stats[date(now)] = d
+}
+
+func (r *Recorder) save() {
+	r.mu.Lock()
+	stats := maps.Clone(r.stats)
+	r.mu.Unlock()
+	if err := Save(r.cfg.Path, stats); err != nil {
+		slog.Warn("failed to save stats", "error", err)
+	}
+}
@@ -0,0 +1,64 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/terong/events"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "stats.json"))
+	require.NoError(t, err)
+	assert.Empty(t, s)
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	want := Stats{
+		"2024-06-02": {Relaying: time.Hour, Relayed: 10},
+		"2024-06-01": {Disconnects: 2, RTTTotal: 3 * time.Millisecond, RTTSamples: 2},
+	}
+	require.NoError(t, Save(path, want))
+
+	got, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, []string{"2024-06-01", "2024-06-02"}, got.Dates())
+}
+
+func TestDay(t *testing.T) {
+	assert.Zero(t, Day{}.AvgRTT())
+	d := Day{Relaying: 90 * time.Minute, Relayed: 1234, Disconnects: 1, RTTTotal: 3 * time.Millisecond, RTTSamples: 2}
+	assert.Equal(t, 1500*time.Microsecond, d.AvgRTT())
+	assert.Equal(t, "relaying 1h30m0s, 1234 inputs, 1 disconnects, rtt 1.5ms", d.String())
+}
+
+func TestRecorder(t *testing.T) {
+	relayed, rtt := int64(5), time.Duration(0)
+	r := newRecorder(Config{
+		Relayed: func() int64 { return relayed },
+		RTT:     func() time.Duration { return rtt },
+	}, Stats{})
+
+	t0 := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	on, off := true, false
+	r.handle(events.Event{Kind: events.RelayToggled, Time: t0, Relay: &on})
+	relayed, rtt = 25, 2*time.Millisecond
+	r.sample(t0.Add(time.Minute))
+	r.handle(events.Event{Kind: events.RelayToggled, Time: t0.Add(90 * time.Second), Relay: &off})
+	r.handle(events.Event{Kind: events.Disconnected, Time: t0.Add(2 * time.Minute)})
+	rtt = 0
+	r.sample(t0.Add(3 * time.Minute))
+
+	assert.Equal(t, Stats{"2024-06-01": {
+		Relaying:    90 * time.Second,
+		Relayed:     20,
+		Disconnects: 1,
+		RTTTotal:    2 * time.Millisecond,
+		RTTSamples:  1,
+	}}, r.stats)
+}
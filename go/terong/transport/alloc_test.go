@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
+)
+
+// allocInputs are relayed at a high rate, so sending them must not allocate.
+var allocInputs = []inputevent.InputEvent{
+	inputevent.MouseMove{DX: -120, DY: 45},
+	inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionDown},
+	inputevent.MouseScroll{Direction: inputevent.MouseScrollDown, Count: 3},
+	inputevent.KeyPress{Key: inputevent.RightCtrl, Action: inputevent.KeyActionDown},
+	inputevent.GamepadAxisMove{Axis: inputevent.GamepadAxes()[0], Value: -3000},
+	inputevent.TouchFrame{Contacts: []inputevent.TouchContact{{ID: 1, X: 1000, Y: 2000}, {ID: 2, X: 3000, Y: 4000}}},
+	inputevent.PenState{X: 100, Y: 200, Pressure: 300, TiltX: -10, TiltY: 20, InRange: true, Tip: true},
+}
+
+func TestAppendInputAllocs(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	for _, input := range allocInputs {
+		allocs := testing.AllocsPerRun(100, func() {
+			_, buf, _ = AppendInput(CompactCBORCodec, buf[:0], input)
+		})
+		assert.Zero(t, allocs, inputevent.TypeName(input))
+	}
+}
+
+// discardConn is a connection that discards writes and blocks reads until
+// it's closed.
+type discardConn struct {
+	closed chan struct{}
+}
+
+func (c *discardConn) Read(b []byte) (int, error) {
+	<-c.closed
+	return 0, net.ErrClosed
+}
+
+func (c *discardConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (c *discardConn) Close() error                       { close(c.closed); return nil }
+func (c *discardConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *discardConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *discardConn) SetDeadline(t time.Time) error      { return nil }
+func (c *discardConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *discardConn) SetWriteDeadline(t time.Time) error { return nil }
+
+func TestSessionWriteInputAllocs(t *testing.T) {
+	for _, cfg := range []struct {
+		name string
+		cfg  SessionConfig
+	}{
+		{"NoCoalesce", SessionConfig{}},
+		{"Coalesce", SessionConfig{CoalesceDelay: time.Millisecond, CoalesceFrames: 16}},
+		{"Checksum", SessionConfig{Checksum: true}},
+	} {
+		t.Run(cfg.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sess := NewSession(ctx, &discardConn{closed: make(chan struct{})}, cfg.cfg)
+			defer sess.Close()
+			sess.EnableCapabilities(sess.Capabilities())
+
+			// grow the buffers
+			for _, input := range allocInputs {
+				require.NoError(t, sess.WriteInput(CompactCBORCodec, input))
+			}
+			for _, input := range allocInputs {
+				allocs := testing.AllocsPerRun(100, func() {
+					if err := sess.WriteInput(CompactCBORCodec, input); err != nil {
+						t.Fatal(err)
+					}
+				})
+				assert.Zero(t, allocs, inputevent.TypeName(input))
+			}
+		})
+	}
+}
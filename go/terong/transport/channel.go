@@ -0,0 +1,103 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Channel multiplexes independent streams of frames over a session. Frames
+// on a channel other than ChannelDefault are flagged with TagFlagChannel and
+// carry the channel in a byte between the tag and the length.
+type Channel uint8
+
+const (
+	// ChannelDefault carries inputs and control messages. It is not flow
+	// controlled.
+	ChannelDefault Channel = iota
+	ChannelClipboard
+	ChannelFileTransfer
+)
+
+const TagFlagChannel Tag = 0x0800
+
+// CapabilityChannels is the [Hello] capability of channels other than
+// ChannelDefault.
+const CapabilityChannels = "channels"
+
+// ChannelWindow is the number of bytes a peer may send on a channel before it
+// is granted more with a [ChannelCredit].
+const ChannelWindow = 64 << 10
+
+var (
+	ErrChannelsDisabled = errors.New("channels are not negotiated")
+	ErrChannelBlocked   = errors.New("channel has no credit")
+)
+
+// ChannelCredit grants the peer more bytes to send on Channel. It is sent on
+// ChannelDefault.
+type ChannelCredit struct {
+	Channel Channel `json:"channel"`
+	Bytes   uint32  `json:"bytes"`
+}
+
+// WriteChannel writes tag and value on ch, fragmented if value doesn't fit in
+// a frame. It returns ErrChannelBlocked if the peer hasn't granted credit for
+// ch, the caller may retry once it has.
+func (s *Session) WriteChannel(ch Channel, tag Tag, value []byte) error {
+	if ch == ChannelDefault {
+		return s.WriteMessage(tag, value)
+	}
+	if !s.channels {
+		return ErrChannelsDisabled
+	}
+	if s.credit(ch) <= 0 {
+		return ErrChannelBlocked
+	}
+	s.credits[ch] -= len(value)
+	for _, frm := range Fragment(tag, value) {
+		frm.Channel = ch
+		if err := s.WriteFrame(frm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// credit returns the number of bytes that can be written on ch.
+func (s *Session) credit(ch Channel) int {
+	if s.credits == nil {
+		s.credits = make(map[Channel]int)
+	}
+	if _, ok := s.credits[ch]; !ok {
+		s.credits[ch] = ChannelWindow
+	}
+	return s.credits[ch]
+}
+
+// GrantCredit lets the peer send n more bytes on ch. Receivers call it once
+// they consumed frames of ch.
+func (s *Session) GrantCredit(ch Channel, n int) error {
+	frm, err := EncodeMessage(TagChannelCredit, ChannelCredit{Channel: ch, Bytes: uint32(n)})
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(frm)
+}
+
+// HandleCredit adds the credit carried by frm.
+func (s *Session) HandleCredit(frm Frame) error {
+	var msg ChannelCredit
+	if err := DecodeMessage(frm, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal channel credit: %v", err)
+	}
+	s.credits[msg.Channel] = s.credit(msg.Channel) + int(msg.Bytes)
+	return nil
+}
+
+// SkipChannel drops a frame of a channel nothing reads, granting its credit
+// back to the peer.
+func (s *Session) SkipChannel(frm Frame) error {
+	s.log.Debug("skipping frame of unused channel", "channel", frm.Channel, "tag", frm.Tag)
+	unknownFrames.Add(fmt.Sprintf("%v/%v", frm.Channel, frm.Tag), 1)
+	return s.GrantCredit(frm.Channel, len(frm.Value))
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelFrameRoundTrip(t *testing.T) {
+	frm := Frame{Tag: TagStatus, Channel: ChannelClipboard, Length: 2, Value: []byte{1, 2}}
+
+	for _, write := range []func(*bytes.Buffer, Frame) error{
+		func(buf *bytes.Buffer, frm Frame) error { return WriteFrame(buf, frm) },
+		func(buf *bytes.Buffer, frm Frame) error { return WriteFrameChecksum(buf, frm) },
+	} {
+		var buf bytes.Buffer
+		require.NoError(t, write(&buf, frm))
+		got, err := ReadFrame(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, frm, got)
+	}
+}
+
+func TestDefaultChannelFrameIsUnchanged(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, Frame{Tag: TagPing}))
+	assert.Equal(t, []byte{0, byte(TagPing), 0, 0}, buf.Bytes())
+}
+
+func TestWriteChannelFlowControl(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	sender := NewSession(ctx, a, SessionConfig{})
+	defer sender.Close()
+	receiver := NewSession(ctx, b, SessionConfig{})
+	defer receiver.Close()
+
+	value := make([]byte, ChannelWindow/2)
+	assert.Equal(t, ErrChannelsDisabled, sender.WriteChannel(ChannelClipboard, TagStatus, value))
+
+	sender.EnableCapabilities(sender.Capabilities())
+
+	received := make(chan Frame, 3)
+	go func() {
+		for frm := range receiver.Inbox() {
+			received <- frm
+		}
+	}()
+
+	require.NoError(t, sender.WriteChannel(ChannelClipboard, TagStatus, value))
+	require.NoError(t, sender.WriteChannel(ChannelClipboard, TagStatus, value))
+	assert.Equal(t, ErrChannelBlocked, sender.WriteChannel(ChannelClipboard, TagStatus, value))
+	require.NoError(t, sender.WriteChannel(ChannelFileTransfer, TagStatus, value))
+
+	for range 3 {
+		frm := <-received
+		assert.NotEqual(t, ChannelDefault, frm.Channel)
+		assert.Len(t, frm.Value, len(value))
+	}
+
+	credit, err := EncodeMessage(TagChannelCredit, ChannelCredit{Channel: ChannelClipboard, Bytes: uint32(len(value))})
+	require.NoError(t, err)
+	require.NoError(t, sender.HandleCredit(credit))
+	require.NoError(t, sender.WriteChannel(ChannelClipboard, TagStatus, value))
+}
@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"slices"
+)
+
+// TagFlagChecksum marks a frame followed by the CRC-32 (IEEE) of its header
+// and value. It is only set once both peers negotiated
+// [CapabilityChecksum].
+const TagFlagChecksum Tag = 0x8000
+
+// CapabilityChecksum is the [Hello] capability of frame checksums.
+const CapabilityChecksum = "crc32"
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// WriteFrameChecksum writes frm flagged with TagFlagChecksum and followed by
+// its checksum.
+func WriteFrameChecksum(w io.Writer, frm Frame) error {
+	buf := appendFrame(make([]byte, 0, 5+int(frm.Length)+4), frm, true)
+	_, err := w.Write(buf)
+	return err
+}
+
+// verifyChecksum reads the checksum following frm and compares it to the one
+// of the frame.
+func verifyChecksum(r io.Reader, frm Frame) error {
+	var sum [4]byte
+	if _, err := io.ReadFull(r, sum[:]); err != nil {
+		return err
+	}
+	header := appendHeader(nil, frm, TagFlagChecksum)
+	crc := crc32.Update(crc32.ChecksumIEEE(header), crc32.IEEETable, frm.Value)
+	if crc != binary.BigEndian.Uint32(sum[:]) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// NegotiateCapabilities returns the offered capabilities that are also
+// supported.
+func NegotiateCapabilities(offered, supported []string) []string {
+	var agreed []string
+	for _, c := range offered {
+		if slices.Contains(supported, c) && !slices.Contains(agreed, c) {
+			agreed = append(agreed, c)
+		}
+	}
+	return agreed
+}
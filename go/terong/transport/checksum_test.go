@@ -0,0 +1,42 @@
+package transport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumFrameRoundTrip(t *testing.T) {
+	frm := Frame{Tag: TagKeyPress, Length: 3, Value: []byte{1, 2, 3}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrameChecksum(&buf, frm))
+	assert.Equal(t, 4+3+4, buf.Len())
+
+	got, err := ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, frm, got)
+}
+
+func TestChecksumMismatchKeepsStreamAligned(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrameChecksum(&buf, Frame{Tag: TagKeyPress, Length: 3, Value: []byte{1, 2, 3}}))
+	buf.Bytes()[5] ^= 0xff
+	require.NoError(t, WriteFrameChecksum(&buf, Frame{Tag: TagPing}))
+
+	frm, err := ReadFrame(&buf)
+	assert.Equal(t, ErrChecksumMismatch, err)
+	assert.Equal(t, TagKeyPress, frm.Tag)
+
+	frm, err = ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, TagPing, frm.Tag)
+}
+
+func TestNegotiateCapabilities(t *testing.T) {
+	assert.Equal(t, []string{CapabilityChecksum}, NegotiateCapabilities([]string{"unknown", CapabilityChecksum}, []string{CapabilityChecksum}))
+	assert.Empty(t, NegotiateCapabilities([]string{CapabilityChecksum}, nil))
+	assert.Empty(t, NegotiateCapabilities(nil, []string{CapabilityChecksum}))
+}
@@ -8,45 +8,164 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"sync/atomic"
 	"time"
 
-	"github.com/fxamacker/cbor/v2"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/errs"
 	"kafji.net/terong/inputevent"
 	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+	"kafji.net/terong/terong/events"
 	"kafji.net/terong/terong/transport"
+	"kafji.net/terong/tracing"
 )
 
 var slog = logging.NewLogger("terong/transport/client")
 
+// discardedInputs counts received inputs that were not passed on, by reason.
+var discardedInputs = metrics.NewCounterMap("transport_discarded_inputs")
+
+// sessionCacheSize is how many TLS sessions are kept for resumption, one per
+// server address.
+const sessionCacheSize = 4
+
+// serverFingerprint is the certificate fingerprint of the connected server.
+var serverFingerprint = metrics.NewLabel("transport_server_fingerprint")
+
+// peerStatus is the latest status received from the server.
+var peerStatus atomic.Value
+
+func init() {
+	metrics.PublishFunc("transport_server_status", func() any {
+		return peerStatus.Load()
+	})
+}
+
 type Handle struct {
-	inputs chan inputevent.InputEvent
-	err    error
+	cfg         *Config
+	tlsCfg      atomic.Pointer[tls.Config]
+	inputs      chan inputevent.InputEvent
+	relayStates chan bool
+	pauses      chan struct{}
+	settings    chan transport.Settings
+	commands    chan transport.Command
+	route       atomic.Value
+	server      atomic.Value
+	fingerprint atomic.Value
+	err         error
+}
+
+// Server returns the name of the connected server, or an empty string if
+// disconnected.
+func (h *Handle) Server() string {
+	server, _ := h.server.Load().(string)
+	return server
+}
+
+// ServerFingerprint returns the certificate fingerprint of the connected
+// server, or an empty string if disconnected.
+func (h *Handle) ServerFingerprint() string {
+	fingerprint, _ := h.fingerprint.Load().(string)
+	return fingerprint
+}
+
+// Route returns the route inputs take from the server to this client, as
+// announced by the server. See [transport.Hello].
+func (h *Handle) Route() []string {
+	route, _ := h.route.Load().([]string)
+	return route
 }
 
 func (h *Handle) Inputs() <-chan inputevent.InputEvent {
 	return h.inputs
 }
 
+// RelayStates receives the server's relay state whenever it changes. A
+// terminated session is reported as relay disabled.
+func (h *Handle) RelayStates() <-chan bool {
+	return h.relayStates
+}
+
+// Settings receives the settings pushed by the server at session start.
+func (h *Handle) Settings() <-chan transport.Settings {
+	return h.settings
+}
+
+// Commands receives the commands sent by the server.
+func (h *Handle) Commands() <-chan transport.Command {
+	return h.commands
+}
+
+// Pauses receives when the server asks to release every held key and button.
+// Inputs received while paused are discarded.
+func (h *Handle) Pauses() <-chan struct{} {
+	return h.pauses
+}
+
 func (h *Handle) Err() error {
 	return h.err
 }
 
+// ReloadTLS reads the certificates at the paths for connections made from now
+// on. The active session carries on with the ones it was established with.
+func (h *Handle) ReloadTLS(certPath, keyPath, serverCertPath string) error {
+	cfg := *h.cfg
+	cfg.TLSCertPath, cfg.TLSKeyPath, cfg.ServerTLSCertPath = certPath, keyPath, serverCertPath
+	tlsCfg, err := newTLSConfig(&cfg)
+	if err != nil {
+		return errs.Mark(err, errs.ErrConfigInvalid)
+	}
+	h.tlsCfg.Store(tlsCfg)
+	return nil
+}
+
 type Config struct {
 	Addr              string
 	TLSCertPath       string
 	TLSKeyPath        string
 	ServerTLSCertPath string
+	// ServerName, if set, verifies the server certificate as issued for this
+	// name by one of the system's CAs, or by the one at ServerTLSCertPath if
+	// set, instead of pinning the certificate.
+	ServerName string
+	TCP        transport.TCPConfig
+	Session    transport.SessionConfig
+	// Name identifies this node to detect routing loops.
+	Name string
+	// Geometry, if set, is sent to the server at session start.
+	Geometry *transport.Geometry
+	// UnmappedKeys, if set, returns the keys received that couldn't be
+	// injected. They're reported to the server with the status.
+	UnmappedKeys func() []inputevent.KeyCode
+	// JoinCode, if set, is sent on connecting to be trusted by a server that
+	// doesn't know this client's certificate yet, see [transport.Join].
+	JoinCode string
+}
+
+func (c *Config) clock() transport.Clock {
+	if c.Session.Clock == nil {
+		return transport.SystemClock
+	}
+	return c.Session.Clock
+}
+
+// CheckTLS reads and parses the certificates and key of cfg.
+func CheckTLS(cfg *Config) error {
+	_, err := newTLSConfig(cfg)
+	return errs.Mark(err, errs.ErrConfigInvalid)
 }
 
 func newTLSConfig(cfg *Config) (*tls.Config, error) {
 	cert, err := os.ReadFile(cfg.TLSCertPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tls cert file: %v", err)
+		return nil, fmt.Errorf("failed to read tls cert file %s: %v", cfg.TLSCertPath, err)
 	}
 
 	key, err := os.ReadFile(cfg.TLSKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tls cert file: %v", err)
+		return nil, fmt.Errorf("failed to read tls key file %s: %v", cfg.TLSKeyPath, err)
 	}
 
 	keyPair, err := tls.X509KeyPair(cert, key)
@@ -54,21 +173,40 @@ func newTLSConfig(cfg *Config) (*tls.Config, error) {
 		return nil, fmt.Errorf("failed to parse key pair: %v", err)
 	}
 
-	serverCert, err := os.ReadFile(cfg.ServerTLSCertPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read server tls cert file: %v", err)
-	}
+	var pool *x509.CertPool
+	if cfg.ServerTLSCertPath == "" && cfg.ServerName != "" {
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load system certificates: %v", err)
+		}
+	} else {
+		serverCert, err := os.ReadFile(cfg.ServerTLSCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read server tls cert file %s: %v", cfg.ServerTLSCertPath, err)
+		}
 
-	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(serverCert)
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(serverCert) {
+			return nil, fmt.Errorf("failed to parse server tls cert file %s: no PEM certificate", cfg.ServerTLSCertPath)
+		}
+	}
 
 	return &tls.Config{
 		Certificates:       []tls.Certificate{keyPair},
 		RootCAs:            pool,
+		ServerName:         cfg.ServerName,
 		InsecureSkipVerify: true,
+		// reconnects resume the session instead of doing a full handshake,
+		// a reload starts a new cache as sessions carry the certificates
+		ClientSessionCache: tls.NewLRUClientSessionCache(sessionCacheSize),
 		VerifyConnection: func(cs tls.ConnectionState) error {
 			opts := x509.VerifyOptions{
-				Roots: pool,
+				Roots:         pool,
+				DNSName:       cfg.ServerName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
 			}
 			_, err := cs.PeerCertificates[0].Verify(opts)
 			if err != nil {
@@ -80,18 +218,26 @@ func newTLSConfig(cfg *Config) (*tls.Config, error) {
 }
 
 func Start(ctx context.Context, cfg *Config) *Handle {
-	h := &Handle{inputs: make(chan inputevent.InputEvent)}
+	h := &Handle{
+		cfg:         cfg,
+		inputs:      make(chan inputevent.InputEvent),
+		relayStates: make(chan bool),
+		pauses:      make(chan struct{}),
+		settings:    make(chan transport.Settings),
+		commands:    make(chan transport.Command),
+	}
 
 	go func() {
+		defer crash.Recover()
 		defer close(h.inputs)
 
 		tlsCfg, err := newTLSConfig(cfg)
 		if err != nil {
-			h.err = err
+			h.err = errs.Mark(err, errs.ErrConfigInvalid)
 			return
 		}
-
-		dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: transport.ConnectTimeout}, Config: tlsCfg}
+		// certificates reloaded meanwhile are newer
+		h.tlsCfg.CompareAndSwap(nil, tlsCfg)
 
 		var sess *session
 		defer func() {
@@ -100,29 +246,72 @@ func Start(ctx context.Context, cfg *Config) *Handle {
 			}
 		}()
 
+		// incompatible is set while the server speaks no version this build
+		// speaks, to report it once rather than on every attempt
+		var incompatible bool
+
 		for {
+			delay := transport.ReconnectDelay
+
 			slog.Info("connecting to server", "address", cfg.Addr)
-			conn, err := dialer.DialContext(ctx, "tcp4", cfg.Addr)
+			conn, err := dial(ctx, cfg, h.tlsCfg.Load())
 			if err != nil {
-				slog.Error("failed to connect to server", "address", cfg.Addr)
+				slog.Error("failed to connect to server", "address", cfg.Addr, "error", err)
 				goto reconnect
 			}
 
 			slog.Info("connected to server", "address", conn.RemoteAddr())
-			sess = newSession(ctx, conn)
-			slog.Info("session established", "address", conn.RemoteAddr())
-			runSession(ctx, sess, h.inputs)
+			sess = newSession(ctx, conn, cfg.Session)
+			sess.name = cfg.Name
+			sess.unmappedKeys = cfg.UnmappedKeys
+			sess.geometry = cfg.Geometry
+			sess.log.Info("session established", "address", conn.RemoteAddr(), "peer_fingerprint", sess.Fingerprint())
+			h.server.Store(sess.Peer())
+			h.fingerprint.Store(sess.Fingerprint())
+			serverFingerprint.Set(sess.Fingerprint())
+			events.Publish(events.Event{Kind: events.Connected, Peer: sess.Peer(), Fingerprint: sess.Fingerprint()})
+			runSession(ctx, sess, h)
 			err = <-sess.done
-			slog.Error("session terminated", "error", err)
+			if verr := (*transport.VersionError)(nil); errors.As(err, &verr) {
+				if !incompatible {
+					sess.log.Error("incompatible server, upgrade either end", "client_version", verr.Local, "server_version", verr.Remote, "min_version", transport.MinProtocolVersion)
+				}
+				incompatible = true
+				delay = transport.IncompatibleReconnectDelay
+			} else {
+				sess.log.Error("session terminated", "error", err)
+				incompatible = false
+				if errors.Is(err, errs.ErrAuthRejected) {
+					// retrying won't help until the server trusts this client
+					delay = transport.IncompatibleReconnectDelay
+				}
+				// a busy or refusing server is not a failure of either end
+				if ctx.Err() == nil && !expired(err) && !closedWith(err, transport.CloseReasonShutdown) && !errors.Is(err, errs.ErrPeerBusy) && !errors.Is(err, errs.ErrAuthRejected) {
+					crash.DumpLogs(err)
+				}
+			}
 			sess.Close()
+			events.Publish(events.Disconnect(sess.Peer(), err))
+			h.server.Store("")
+			h.fingerprint.Store("")
+			serverFingerprint.Set("")
+			peerStatus.Store((*transport.Status)(nil))
+			if sess.relay {
+				select {
+				case <-ctx.Done():
+				case h.relayStates <- false:
+				}
+			}
+			if expired(err) {
+				// reconnect right away to authenticate again
+				continue
+			}
 
 		reconnect:
-			slog.Info(fmt.Sprintf("reconnecting to server in %d seconds", transport.ReconnectDelay/time.Second))
-			select {
-			case <-ctx.Done():
-				h.err = ctx.Err()
+			slog.Info(fmt.Sprintf("reconnecting to server in %d seconds", delay/time.Second))
+			if err := wait(ctx, cfg.clock(), delay); err != nil {
+				h.err = err
 				return
-			case <-time.After(transport.ReconnectDelay):
 			}
 		}
 	}()
@@ -130,28 +319,152 @@ func Start(ctx context.Context, cfg *Config) *Handle {
 	return h
 }
 
+// wait blocks for d on clock or until ctx is done.
+func wait(ctx context.Context, clock transport.Clock, d time.Duration) error {
+	timer := clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	case <-timer.C():
+		return nil
+	}
+}
+
+// expired reports whether err is of a session closed for exceeding its
+// lifetime.
+func expired(err error) bool {
+	return closedWith(err, transport.CloseReasonExpired)
+}
+
+// closedWith reports whether err is the server closing the session for
+// reason.
+func closedWith(err error, reason string) bool {
+	var closed *transport.ClosedError
+	return errors.As(err, &closed) && closed.Reason == reason
+}
+
+func dial(ctx context.Context, cfg *Config, tlsCfg *tls.Config) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, transport.ConnectTimeout)
+	defer cancel()
+
+	conn, err := transport.DialTCP(ctx, cfg.Addr, &cfg.TCP)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, tlsCfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if cfg.JoinCode != "" {
+		frm, err := transport.EncodeMessage(transport.TagJoin, transport.Join{Code: cfg.JoinCode})
+		if err == nil {
+			err = transport.WriteFrame(tlsConn, frm)
+		}
+		if err != nil {
+			tlsConn.Close()
+			return nil, fmt.Errorf("failed to write join: %v", err)
+		}
+	}
+
+	return tlsConn, nil
+}
+
 type session struct {
 	*transport.Session
-	done chan error
+	log    logging.Logger
+	done   chan error
+	relay  bool
+	paused bool
+	// codec of the negotiated protocol version
+	codec transport.Codec
+	// name of this node
+	name string
+	// negotiated protocol version
+	version uint16
+	// trace context of the next input frame
+	trace *transport.Trace
+	// see [Config.UnmappedKeys]
+	unmappedKeys func() []inputevent.KeyCode
+	// geometry sent after the hello
+	geometry *transport.Geometry
 }
 
-func newSession(ctx context.Context, conn net.Conn) *session {
+func newSession(ctx context.Context, conn net.Conn, cfg transport.SessionConfig) *session {
+	s := transport.NewSession(ctx, conn, cfg)
 	return &session{
-		Session: transport.NewSession(ctx, conn),
+		Session: s,
+		log:     slog.With("session", s.ID(), "peer", s.Peer()),
 		done:    make(chan error, 1),
+		codec:   transport.CBORCodec,
+	}
+}
+
+// writeStatus sends the state of this end, if the server understands it.
+func (s *session) writeStatus() error {
+	if s.version < transport.ProtocolVersionStatus {
+		return nil
+	}
+	status := transport.Status{
+		Relay:   s.relay,
+		Paused:  s.paused,
+		Dropped: discardedInputs.Total(),
+	}
+	if s.unmappedKeys != nil {
+		status.UnmappedKeys = s.unmappedKeys()
+	}
+	frm, err := transport.EncodeMessage(transport.TagStatus, status)
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(frm)
+}
+
+// writeGeometry sends the configured screen layout, if the server agreed to
+// the capability.
+func (s *session) writeGeometry(capabilities []string) error {
+	if s.geometry == nil || !slices.Contains(capabilities, transport.CapabilityGeometry) {
+		return nil
+	}
+	frm, err := transport.EncodeMessage(transport.TagGeometry, s.geometry)
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(frm)
+}
+
+// writeHello offers the latest protocol version and the configured
+// capabilities to the server.
+func (s *session) writeHello() error {
+	hello := transport.Hello{Version: transport.ProtocolVersion, Capabilities: s.Capabilities()}
+	frm, err := transport.EncodeMessage(transport.TagHello, hello)
+	if err != nil {
+		return err
 	}
+	return s.WriteFrame(frm)
 }
 
-func runSession(ctx context.Context, sess *session, inputs chan<- inputevent.InputEvent) {
+func runSession(ctx context.Context, sess *session, h *Handle) {
 	go func() {
+		defer crash.Recover()
 		err := func() error {
+			if err := sess.writeHello(); err != nil {
+				return fmt.Errorf("failed to write hello: %v", err)
+			}
+
 			for {
 				select {
 				case <-ctx.Done():
-					return ctx.Err()
+					return context.Cause(ctx)
 
 				case <-sess.SendPingDeadline():
-					slog.Debug("sending ping")
+					sess.log.Debug("sending ping")
+					if err := sess.writeStatus(); err != nil {
+						return fmt.Errorf("failed to write status: %v", err)
+					}
 					if err := sess.SendPing(); err != nil {
 						return fmt.Errorf("failed to write ping: %v", err)
 					}
@@ -164,7 +477,19 @@ func runSession(ctx context.Context, sess *session, inputs chan<- inputevent.Inp
 						return sess.InboxErr()
 					}
 
+					if frm.Channel != transport.ChannelDefault {
+						if err := sess.SkipChannel(frm); err != nil {
+							return fmt.Errorf("failed to write channel credit: %v", err)
+						}
+						break
+					}
+
 					switch frm.Tag {
+					case transport.TagChannelCredit:
+						if err := sess.HandleCredit(frm); err != nil {
+							return err
+						}
+
 					case transport.TagMouseMove:
 						fallthrough
 					case transport.TagMouseClick:
@@ -172,20 +497,172 @@ func runSession(ctx context.Context, sess *session, inputs chan<- inputevent.Inp
 					case transport.TagMouseScroll:
 						fallthrough
 					case transport.TagKeyPress:
-						event, err := unmarshalEvent(frm)
+						fallthrough
+					case transport.TagGamepadButton:
+						fallthrough
+					case transport.TagGamepadAxis:
+						fallthrough
+					case transport.TagTouchFrame:
+						fallthrough
+					case transport.TagPenState:
+						trace := sess.trace
+						sess.trace = nil
+						if sess.paused {
+							sess.log.Debug("discarding input, session is paused", "tag", frm.Tag)
+							discardedInputs.Add("paused", 1)
+							break
+						}
+						var span *tracing.Span
+						if trace != nil {
+							span = tracing.StartRemoteSpan(trace.TraceID, trace.SpanID, "receive", tracing.KindConsumer)
+						}
+						event, err := transport.DecodeInput(sess.codec, frm)
 						if err != nil {
-							slog.Warn("failed to unmarshal event", "error", err)
+							sess.log.Warn("failed to unmarshal event", "error", err)
+							discardedInputs.Add("invalid", 1)
 						} else {
-							slog.Debug("event received", "event", event)
-							inputs <- event
+							if sess.log.DebugEnabled() {
+								sess.log.Debug("event received", "event", event)
+							}
+							if span != nil {
+								span.SetAttr("input", inputevent.TypeName(event))
+								span.End()
+								// until the input is taken to be injected
+								span = tracing.StartRemoteSpan(trace.TraceID, trace.SpanID, "deliver", tracing.KindInternal)
+							}
+							// a restarting client stops reading inputs
+							select {
+							case <-ctx.Done():
+								if span != nil {
+									span.End()
+								}
+								return context.Cause(ctx)
+							case h.inputs <- event:
+							}
+							if span != nil {
+								span.End()
+							}
+						}
+
+					case transport.TagRelayState:
+						var state transport.RelayState
+						if err := transport.DecodeMessage(frm, &state); err != nil {
+							sess.log.Warn("failed to unmarshal relay state", "error", err)
+							break
+						}
+						sess.log.Info("relay state changed", "enabled", state.Enabled)
+						sess.relay = state.Enabled
+						select {
+						case <-ctx.Done():
+							return context.Cause(ctx)
+						case h.relayStates <- state.Enabled:
+						}
+
+					case transport.TagSettings:
+						var settings transport.Settings
+						if err := transport.DecodeMessage(frm, &settings); err != nil {
+							sess.log.Warn("failed to unmarshal settings", "error", err)
+							break
+						}
+						sess.log.Info("settings received", "settings", settings)
+						select {
+						case <-ctx.Done():
+							return context.Cause(ctx)
+						case h.settings <- settings:
+						}
+
+					case transport.TagTrace:
+						var trace transport.Trace
+						if err := transport.DecodeMessage(frm, &trace); err != nil {
+							sess.log.Warn("failed to unmarshal trace", "error", err)
+							break
+						}
+						sess.trace = &trace
+
+					case transport.TagCommand:
+						var cmd transport.Command
+						if err := transport.DecodeMessage(frm, &cmd); err != nil {
+							sess.log.Warn("failed to unmarshal command", "error", err)
+							break
+						}
+						sess.log.Info("command received", "action", cmd.Action)
+						select {
+						case <-ctx.Done():
+							return context.Cause(ctx)
+						case h.commands <- cmd:
 						}
 
+					case transport.TagPause:
+						sess.log.Debug("pause received")
+						sess.paused = true
+						select {
+						case <-ctx.Done():
+							return context.Cause(ctx)
+						case h.pauses <- struct{}{}:
+						}
+
+					case transport.TagResume:
+						sess.log.Debug("resume received")
+						sess.paused = false
+
 					case transport.TagPing:
-						slog.Debug("ping received")
+						sess.log.Debug("ping received")
 						sess.SetRecvPingDeadline()
+						if err := sess.HandlePing(frm); err != nil {
+							return fmt.Errorf("failed to write pong: %v", err)
+						}
+
+					case transport.TagPong:
+						sess.HandlePong(frm)
+
+					case transport.TagHello:
+						var hello transport.Hello
+						if err := transport.DecodeMessage(frm, &hello); err != nil {
+							return fmt.Errorf("failed to unmarshal hello: %v", err)
+						}
+						if sess.name != "" && slices.Contains(hello.Route, sess.name) {
+							sess.log.Error("this node is in the route", "route", hello.Route)
+							return transport.ErrRoutingLoop
+						}
+						if err := transport.CheckVersion(hello.Version); err != nil {
+							return err
+						}
+						sess.version = hello.Version
+						sess.codec = transport.CodecFor(hello.Version)
+						sess.EnableCapabilities(hello.Capabilities)
+						h.route.Store(hello.Route)
+						sess.log.Info("protocol negotiated", "version", hello.Version, "route", hello.Route, "capabilities", hello.Capabilities)
+						if err := sess.writeGeometry(hello.Capabilities); err != nil {
+							return fmt.Errorf("failed to write geometry: %v", err)
+						}
+
+					case transport.TagGeometry:
+						var geometry transport.Geometry
+						if err := transport.DecodeMessage(frm, &geometry); err != nil {
+							sess.log.Warn("failed to unmarshal geometry", "error", err)
+							break
+						}
+						sess.log.Info("server screens received", "screens", geometry)
+
+					case transport.TagStatus:
+						var status transport.Status
+						if err := transport.DecodeMessage(frm, &status); err != nil {
+							sess.log.Warn("failed to unmarshal status", "error", err)
+							break
+						}
+						peerStatus.Store(&status)
+
+					case transport.TagClose:
+						var msg transport.Close
+						if err := transport.DecodeMessage(frm, &msg); err != nil {
+							return fmt.Errorf("failed to unmarshal close: %v", err)
+						}
+						return transport.CloseError(msg)
 
 					default:
-						slog.Warn("unexpected tag", "tag", frm.Tag)
+						if err := sess.SkipUnknown(frm); err != nil {
+							return err
+						}
 					} // switch
 				} // select
 			} // for
@@ -194,27 +671,3 @@ func runSession(ctx context.Context, sess *session, inputs chan<- inputevent.Inp
 		sess.done <- err
 	}()
 }
-
-func unmarshalEvent(frm transport.Frame) (inputevent.InputEvent, error) {
-	var event inputevent.InputEvent
-	var err error
-	switch frm.Tag {
-	case transport.TagMouseMove:
-		event, err = unmarshal[inputevent.MouseMove](frm.Value)
-	case transport.TagMouseClick:
-		event, err = unmarshal[inputevent.MouseClick](frm.Value)
-	case transport.TagMouseScroll:
-		event, err = unmarshal[inputevent.MouseScroll](frm.Value)
-	case transport.TagKeyPress:
-		event, err = unmarshal[inputevent.KeyPress](frm.Value)
-	default:
-		return nil, errors.New("unexpected tag")
-	}
-	return event, err
-}
-
-func unmarshal[T any](r cbor.RawMessage) (T, error) {
-	var t T
-	err := cbor.Unmarshal(r, &t)
-	return t, err
-}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCert returns a certificate for name signed by parent, self-signed if
+// parent is nil.
+func newCert(t *testing.T, name string, parent *tls.Certificate) (*tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+		DNSNames:              []string{name},
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, cert
+}
+
+func writePEM(t *testing.T, path string, typ string, der []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+}
+
+func TestServerName(t *testing.T) {
+	dir := t.TempDir()
+	ca, caCert := newCert(t, "ca", nil)
+	_, server := newCert(t, "terong.example", ca)
+	client, _ := newCert(t, "client", nil)
+	keyDER, err := x509.MarshalECPrivateKey(client.PrivateKey.(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+
+	cfg := &Config{
+		TLSCertPath:       filepath.Join(dir, "client.crt"),
+		TLSKeyPath:        filepath.Join(dir, "client.key"),
+		ServerTLSCertPath: filepath.Join(dir, "ca.crt"),
+	}
+	writePEM(t, cfg.TLSCertPath, "CERTIFICATE", client.Certificate[0])
+	writePEM(t, cfg.TLSKeyPath, "EC PRIVATE KEY", keyDER)
+	writePEM(t, cfg.ServerTLSCertPath, "CERTIFICATE", caCert.Raw)
+	state := tls.ConnectionState{PeerCertificates: []*x509.Certificate{server}}
+
+	for _, tc := range []struct {
+		name string
+		ok   bool
+	}{
+		{"terong.example", true},
+		{"other.example", false},
+	} {
+		cfg.ServerName = tc.name
+		tlsCfg, err := newTLSConfig(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, tc.name, tlsCfg.ServerName)
+		err = tlsCfg.VerifyConnection(state)
+		if tc.ok {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.Error(t, err, tc.name)
+		}
+	}
+
+	// without a server tls cert the system's CAs are used, which don't know
+	// this one
+	cfg.ServerName, cfg.ServerTLSCertPath = "terong.example", ""
+	tlsCfg, err := newTLSConfig(cfg)
+	require.NoError(t, err)
+	assert.Error(t, tlsCfg.VerifyConnection(state))
+}
@@ -0,0 +1,134 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of sessions and of reconnect delays. Tests
+// replace [SystemClock] with a [ManualClock] to drive deadlines without
+// sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer fires once on C after its duration, like [time.Timer].
+type Timer interface {
+	C() <-chan time.Time
+	// Reset makes the timer fire after d from now. An expiry that wasn't
+	// received is discarded.
+	Reset(d time.Duration)
+	Stop()
+}
+
+// SystemClock is the monotonic clock of the operating system.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Reset(d time.Duration) {
+	t.Stop()
+	t.t.Reset(d)
+}
+
+func (t systemTimer) Stop() {
+	if !t.t.Stop() {
+		select {
+		case <-t.t.C:
+		default:
+		}
+	}
+}
+
+// ManualClock is a [Clock] that only moves forward with [ManualClock.Advance].
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+// NewManualClock returns a clock stopped at now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTimer{clock: c, c: make(chan time.Time, 1), at: c.now.Add(d), armed: true}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers that became
+// due.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		if t.armed && !t.at.After(c.now) {
+			t.armed = false
+			select {
+			case t.c <- c.now:
+			default:
+			}
+		}
+	}
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	c     chan time.Time
+	at    time.Time
+	armed bool
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTimer) Reset(d time.Duration) {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stop()
+	t.at = t.clock.now.Add(d)
+	t.armed = true
+}
+
+func (t *manualTimer) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stop()
+}
+
+func (t *manualTimer) stop() {
+	t.armed = false
+	select {
+	case <-t.c:
+	default:
+	}
+}
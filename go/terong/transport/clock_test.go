@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(100, 0)
+	clock := NewManualClock(start)
+	timer := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	assert.False(t, fired(timer.C()))
+	assert.Equal(t, start.Add(999*time.Millisecond), clock.Now())
+
+	clock.Advance(time.Millisecond)
+	assert.True(t, fired(timer.C()))
+
+	// fires once
+	clock.Advance(time.Hour)
+	assert.False(t, fired(timer.C()))
+}
+
+func TestManualClockStop(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	timer := clock.NewTimer(time.Second)
+
+	timer.Stop()
+	clock.Advance(time.Hour)
+	assert.False(t, fired(timer.C()))
+
+	timer.Reset(time.Second)
+	clock.Advance(time.Second)
+	assert.True(t, fired(timer.C()))
+}
+
+func TestSystemTimerReset(t *testing.T) {
+	timer := SystemClock.NewTimer(0)
+	time.Sleep(10 * time.Millisecond)
+
+	// the expiry that wasn't received is discarded
+	timer.Reset(time.Hour)
+	assert.False(t, fired(timer.C()))
+	timer.Stop()
+}
@@ -0,0 +1,329 @@
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/fxamacker/cbor/v2"
+	"kafji.net/terong/inputevent"
+)
+
+// Codec converts input events to and from frame values.
+type Codec interface {
+	Marshal(input inputevent.InputEvent) ([]byte, error)
+	Unmarshal(tag Tag, value []byte) (inputevent.InputEvent, error)
+}
+
+// CBORCodec encodes input events as CBOR maps keyed by field name.
+var CBORCodec Codec = cborCodec{}
+
+type cborCodec struct{}
+
+func (cborCodec) Marshal(input inputevent.InputEvent) ([]byte, error) {
+	return cbor.Marshal(&input)
+}
+
+func (cborCodec) Unmarshal(tag Tag, value []byte) (inputevent.InputEvent, error) {
+	switch tag {
+	case TagMouseMove:
+		return unmarshal[inputevent.MouseMove](value)
+	case TagMouseClick:
+		return unmarshal[inputevent.MouseClick](value)
+	case TagMouseScroll:
+		return unmarshal[inputevent.MouseScroll](value)
+	case TagKeyPress:
+		return unmarshal[inputevent.KeyPress](value)
+	case TagGamepadButton:
+		return unmarshal[inputevent.GamepadButtonPress](value)
+	case TagGamepadAxis:
+		return unmarshal[inputevent.GamepadAxisMove](value)
+	case TagTouchFrame:
+		return unmarshal[inputevent.TouchFrame](value)
+	case TagPenState:
+		return unmarshal[inputevent.PenState](value)
+	}
+	return nil, errors.New("unexpected tag")
+}
+
+func unmarshal[T inputevent.InputEvent](value []byte) (inputevent.InputEvent, error) {
+	var t T
+	err := cbor.Unmarshal(value, &t)
+	return t, err
+}
+
+// CompactCBORCodec encodes input events as CBOR maps keyed by small integers.
+// It is used from protocol version 2.
+var CompactCBORCodec Codec = compactCBORCodec{}
+
+type compactCBORCodec struct{}
+
+type compactMouseMove struct {
+	DX int16 `cbor:"1,keyasint"`
+	DY int16 `cbor:"2,keyasint"`
+}
+
+type compactMouseClick struct {
+	Button inputevent.MouseButton       `cbor:"1,keyasint"`
+	Action inputevent.MouseButtonAction `cbor:"2,keyasint"`
+}
+
+type compactMouseScroll struct {
+	Direction inputevent.MouseScrollDirection `cbor:"1,keyasint"`
+	Count     uint8                           `cbor:"2,keyasint"`
+}
+
+type compactKeyPress struct {
+	Key    inputevent.KeyCode   `cbor:"1,keyasint"`
+	Action inputevent.KeyAction `cbor:"2,keyasint"`
+}
+
+type compactGamepadButtonPress struct {
+	Button inputevent.GamepadButton       `cbor:"1,keyasint"`
+	Action inputevent.GamepadButtonAction `cbor:"2,keyasint"`
+}
+
+type compactGamepadAxisMove struct {
+	Axis  inputevent.GamepadAxis `cbor:"1,keyasint"`
+	Value int16                  `cbor:"2,keyasint"`
+}
+
+type compactTouchFrame struct {
+	Contacts []compactTouchContact `cbor:"1,keyasint"`
+}
+
+type compactTouchContact struct {
+	ID uint8  `cbor:"1,keyasint"`
+	X  uint16 `cbor:"2,keyasint"`
+	Y  uint16 `cbor:"3,keyasint"`
+}
+
+type compactPenState struct {
+	X        uint16 `cbor:"1,keyasint"`
+	Y        uint16 `cbor:"2,keyasint"`
+	Pressure uint16 `cbor:"3,keyasint"`
+	TiltX    int8   `cbor:"4,keyasint"`
+	TiltY    int8   `cbor:"5,keyasint"`
+	InRange  bool   `cbor:"6,keyasint"`
+	Tip      bool   `cbor:"7,keyasint"`
+	Barrel   bool   `cbor:"8,keyasint"`
+	Eraser   bool   `cbor:"9,keyasint"`
+}
+
+func (c compactCBORCodec) Marshal(input inputevent.InputEvent) ([]byte, error) {
+	return c.Append(nil, input)
+}
+
+// Append encodes input like cbor.Marshal would encode its compact struct,
+// without reflection, so encoding into a buffer with room doesn't allocate.
+func (compactCBORCodec) Append(buf []byte, input inputevent.InputEvent) ([]byte, error) {
+	switch v := input.(type) {
+	case inputevent.MouseMove:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.DX))
+		return appendCBORField(buf, 2, int64(v.DY)), nil
+	case inputevent.MouseClick:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.Button))
+		return appendCBORField(buf, 2, int64(v.Action)), nil
+	case inputevent.MouseScroll:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.Direction))
+		return appendCBORField(buf, 2, int64(v.Count)), nil
+	case inputevent.KeyPress:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.Key))
+		return appendCBORField(buf, 2, int64(v.Action)), nil
+	case inputevent.GamepadButtonPress:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.Button))
+		return appendCBORField(buf, 2, int64(v.Action)), nil
+	case inputevent.GamepadAxisMove:
+		buf = appendCBORHead(buf, cborMap, 2)
+		buf = appendCBORField(buf, 1, int64(v.Axis))
+		return appendCBORField(buf, 2, int64(v.Value)), nil
+	case inputevent.TouchFrame:
+		buf = appendCBORHead(buf, cborMap, 1)
+		buf = appendCBORInt(buf, 1)
+		if len(v.Contacts) == 0 {
+			// a nil slice, as cbor.Marshal encodes it
+			return append(buf, cborNull), nil
+		}
+		buf = appendCBORHead(buf, cborArray, uint64(len(v.Contacts)))
+		for _, c := range v.Contacts {
+			buf = appendCBORHead(buf, cborMap, 3)
+			buf = appendCBORField(buf, 1, int64(c.ID))
+			buf = appendCBORField(buf, 2, int64(c.X))
+			buf = appendCBORField(buf, 3, int64(c.Y))
+		}
+		return buf, nil
+	case inputevent.PenState:
+		buf = appendCBORHead(buf, cborMap, 9)
+		buf = appendCBORField(buf, 1, int64(v.X))
+		buf = appendCBORField(buf, 2, int64(v.Y))
+		buf = appendCBORField(buf, 3, int64(v.Pressure))
+		buf = appendCBORField(buf, 4, int64(v.TiltX))
+		buf = appendCBORField(buf, 5, int64(v.TiltY))
+		buf = appendCBORBoolField(buf, 6, v.InRange)
+		buf = appendCBORBoolField(buf, 7, v.Tip)
+		buf = appendCBORBoolField(buf, 8, v.Barrel)
+		return appendCBORBoolField(buf, 9, v.Eraser), nil
+	}
+	return buf, errors.New("unexpected input")
+}
+
+func (compactCBORCodec) Unmarshal(tag Tag, value []byte) (inputevent.InputEvent, error) {
+	switch tag {
+	case TagMouseMove:
+		v, err := unmarshalCompact[compactMouseMove](value)
+		return inputevent.MouseMove(v), err
+	case TagMouseClick:
+		v, err := unmarshalCompact[compactMouseClick](value)
+		return inputevent.MouseClick(v), err
+	case TagMouseScroll:
+		v, err := unmarshalCompact[compactMouseScroll](value)
+		return inputevent.MouseScroll(v), err
+	case TagKeyPress:
+		v, err := unmarshalCompact[compactKeyPress](value)
+		return inputevent.KeyPress(v), err
+	case TagGamepadButton:
+		v, err := unmarshalCompact[compactGamepadButtonPress](value)
+		return inputevent.GamepadButtonPress(v), err
+	case TagGamepadAxis:
+		v, err := unmarshalCompact[compactGamepadAxisMove](value)
+		return inputevent.GamepadAxisMove(v), err
+	case TagTouchFrame:
+		v, err := unmarshalCompact[compactTouchFrame](value)
+		var frame inputevent.TouchFrame
+		for _, c := range v.Contacts {
+			frame.Contacts = append(frame.Contacts, inputevent.TouchContact(c))
+		}
+		return frame, err
+	case TagPenState:
+		v, err := unmarshalCompact[compactPenState](value)
+		return inputevent.PenState(v), err
+	}
+	return nil, errors.New("unexpected tag")
+}
+
+func unmarshalCompact[T any](value []byte) (T, error) {
+	var t T
+	err := cbor.Unmarshal(value, &t)
+	return t, err
+}
+
+// CodecFor returns the codec of protocol version.
+func CodecFor(version uint16) Codec {
+	if version >= ProtocolVersionCompact {
+		return CompactCBORCodec
+	}
+	return CBORCodec
+}
+
+// Appender is implemented by codecs that can encode into a given buffer.
+type Appender interface {
+	Append(buf []byte, input inputevent.InputEvent) ([]byte, error)
+}
+
+// EncodeInput marshals input into a frame.
+func EncodeInput(codec Codec, input inputevent.InputEvent) (Frame, error) {
+	tag, err := TagFor(input)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to get tag: %v", err)
+	}
+
+	value, err := codec.Marshal(input)
+	if err != nil {
+		return Frame{}, fmt.Errorf("failed to marshal value: %v", err)
+	}
+
+	if len(value) > ValueMaxLength {
+		return Frame{}, ErrMaxLengthExceeded
+	}
+
+	return Frame{Tag: tag, Length: uint16(len(value)), Value: value}, nil
+}
+
+// AppendInput is [EncodeInput] into buf, whose room is reused. The value of
+// the frame is the appended part of the returned buffer, valid until the
+// buffer is reused. With an [Appender] codec, it doesn't allocate once buf
+// has room.
+func AppendInput(codec Codec, buf []byte, input inputevent.InputEvent) (Frame, []byte, error) {
+	tag, err := TagFor(input)
+	if err != nil {
+		return Frame{}, buf, fmt.Errorf("failed to get tag: %v", err)
+	}
+
+	start := len(buf)
+	if a, ok := codec.(Appender); ok {
+		buf, err = a.Append(buf, input)
+	} else {
+		var value []byte
+		value, err = codec.Marshal(input)
+		buf = append(buf, value...)
+	}
+	if err != nil {
+		return Frame{}, buf[:start], fmt.Errorf("failed to marshal value: %v", err)
+	}
+
+	value := buf[start:]
+	if len(value) > ValueMaxLength {
+		return Frame{}, buf[:start], ErrMaxLengthExceeded
+	}
+	return Frame{Tag: tag, Length: uint16(len(value)), Value: value}, buf, nil
+}
+
+// DecodeInput unmarshals the input carried by frm.
+func DecodeInput(codec Codec, frm Frame) (inputevent.InputEvent, error) {
+	return codec.Unmarshal(frm.Tag, frm.Value)
+}
+
+// CBOR major types and simple values, see RFC 8949.
+const (
+	cborUint  byte = 0
+	cborNint  byte = 1
+	cborArray byte = 4
+	cborMap   byte = 5
+
+	cborFalse byte = 0xf4
+	cborTrue  byte = 0xf5
+	cborNull  byte = 0xf6
+)
+
+// appendCBORHead appends the head of a data item of major type with argument
+// n, in its shortest form.
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
+
+func appendCBORInt(buf []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(buf, cborNint, uint64(-1-v))
+	}
+	return appendCBORHead(buf, cborUint, uint64(v))
+}
+
+// appendCBORField appends an integer keyed by key.
+func appendCBORField(buf []byte, key int64, v int64) []byte {
+	return appendCBORInt(appendCBORInt(buf, key), v)
+}
+
+func appendCBORBoolField(buf []byte, key int64, v bool) []byte {
+	buf = appendCBORInt(buf, key)
+	if v {
+		return append(buf, cborTrue)
+	}
+	return append(buf, cborFalse)
+}
@@ -0,0 +1,203 @@
+package transport
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
+)
+
+var codecs = []struct {
+	name  string
+	codec Codec
+}{
+	{"CBOR", CBORCodec},
+	{"CompactCBOR", CompactCBORCodec},
+}
+
+// randomInput generates a valid input event.
+func randomInput(r *rand.Rand) inputevent.InputEvent {
+	switch r.Intn(8) {
+	case 0:
+		return inputevent.MouseMove{DX: int16(r.Uint32()), DY: int16(r.Uint32())}
+	case 1:
+		buttons := inputevent.MouseButtons()
+		return inputevent.MouseClick{
+			Button: buttons[r.Intn(len(buttons))],
+			Action: inputevent.MouseButtonAction(r.Intn(2) + 1),
+		}
+	case 2:
+		return inputevent.MouseScroll{
+			Direction: inputevent.MouseScrollDirection(r.Intn(2) + 1),
+			Count:     uint8(r.Uint32()),
+		}
+	case 3:
+		buttons := inputevent.GamepadButtons()
+		return inputevent.GamepadButtonPress{
+			Button: buttons[r.Intn(len(buttons))],
+			Action: inputevent.GamepadButtonAction(r.Intn(2) + 1),
+		}
+	case 4:
+		axes := inputevent.GamepadAxes()
+		return inputevent.GamepadAxisMove{Axis: axes[r.Intn(len(axes))], Value: int16(r.Uint32())}
+	case 5:
+		var frame inputevent.TouchFrame
+		for i := range r.Intn(inputevent.MaxTouchContacts + 1) {
+			frame.Contacts = append(frame.Contacts, inputevent.TouchContact{
+				ID: uint8(i),
+				X:  uint16(r.Uint32()),
+				Y:  uint16(r.Uint32()),
+			})
+		}
+		return frame
+	case 6:
+		return inputevent.PenState{
+			X:        uint16(r.Uint32()),
+			Y:        uint16(r.Uint32()),
+			Pressure: uint16(r.Intn(inputevent.MaxPenPressure + 1)),
+			TiltX:    int8(r.Intn(181) - 90),
+			TiltY:    int8(r.Intn(181) - 90),
+			InRange:  true,
+			Tip:      r.Intn(2) == 0,
+			Barrel:   r.Intn(2) == 0,
+			Eraser:   r.Intn(2) == 0,
+		}
+	default:
+		keys := inputevent.KeyCodes()
+		return inputevent.KeyPress{
+			Key:    keys[r.Intn(len(keys))],
+			Action: inputevent.KeyAction(r.Intn(3) + 1),
+		}
+	}
+}
+
+type validInput struct {
+	inputevent.InputEvent
+}
+
+func (validInput) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(validInput{randomInput(r)})
+}
+
+func TestInputRoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			f := func(input validInput) bool {
+				frm, err := EncodeInput(c.codec, input.InputEvent)
+				require.NoError(t, err)
+
+				var buf bytes.Buffer
+				require.NoError(t, WriteFrame(&buf, frm))
+
+				frm, err = ReadFrame(&buf)
+				require.NoError(t, err)
+
+				got, err := DecodeInput(c.codec, frm)
+				require.NoError(t, err)
+
+				return assert.Equal(t, input.InputEvent, got)
+			}
+			require.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+		})
+	}
+}
+
+// TestCompactAppendMatchesMarshal checks the hand written encoder against
+// cbor.Marshal of the compact structs it replaced.
+func TestCompactAppendMatchesMarshal(t *testing.T) {
+	marshal := func(input inputevent.InputEvent) ([]byte, error) {
+		switch v := input.(type) {
+		case inputevent.MouseMove:
+			return cbor.Marshal(compactMouseMove(v))
+		case inputevent.MouseClick:
+			return cbor.Marshal(compactMouseClick(v))
+		case inputevent.MouseScroll:
+			return cbor.Marshal(compactMouseScroll(v))
+		case inputevent.KeyPress:
+			return cbor.Marshal(compactKeyPress(v))
+		case inputevent.GamepadButtonPress:
+			return cbor.Marshal(compactGamepadButtonPress(v))
+		case inputevent.GamepadAxisMove:
+			return cbor.Marshal(compactGamepadAxisMove(v))
+		case inputevent.TouchFrame:
+			var frame compactTouchFrame
+			for _, c := range v.Contacts {
+				frame.Contacts = append(frame.Contacts, compactTouchContact(c))
+			}
+			return cbor.Marshal(frame)
+		case inputevent.PenState:
+			return cbor.Marshal(compactPenState(v))
+		}
+		return nil, nil
+	}
+
+	f := func(input validInput) bool {
+		want, err := marshal(input.InputEvent)
+		require.NoError(t, err)
+		got, err := CompactCBORCodec.Marshal(input.InputEvent)
+		require.NoError(t, err)
+		return assert.Equal(t, want, got, "%#v", input.InputEvent)
+	}
+	require.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+}
+
+func TestRandomFrameNeverPanics(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			f := func(data []byte) bool {
+				frm, err := ReadFrame(bytes.NewReader(data))
+				if err != nil {
+					return true
+				}
+				DecodeInput(c.codec, frm)
+				return true
+			}
+			require.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+		})
+	}
+}
+
+func TestRandomValueNeverPanics(t *testing.T) {
+	tags := []Tag{TagMouseMove, TagMouseClick, TagMouseScroll, TagKeyPress, TagGamepadButton, TagGamepadAxis, TagTouchFrame, TagPenState}
+	for _, c := range codecs {
+		t.Run(c.name, func(t *testing.T) {
+			f := func(tagIndex uint8, value []byte) bool {
+				tag := tags[int(tagIndex)%len(tags)]
+				DecodeInput(c.codec, Frame{Tag: tag, Length: uint16(len(value)), Value: value})
+				return true
+			}
+			require.NoError(t, quick.Check(f, &quick.Config{MaxCount: 5000}))
+		})
+	}
+}
+
+func TestCompactCodecIsSmaller(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for range 100 {
+		input := randomInput(r)
+
+		full, err := CBORCodec.Marshal(input)
+		require.NoError(t, err)
+
+		compact, err := CompactCBORCodec.Marshal(input)
+		require.NoError(t, err)
+
+		assert.Less(t, len(compact), len(full), "%v", input)
+	}
+}
+
+func TestReadFrameRejectsOversizedLengthBeforeValue(t *testing.T) {
+	// the value is missing, so reading it would fail with another error
+	header := []byte{0x00, byte(TagMouseMove), 0xff, 0xff}
+	frm, err := ReadFrame(bytes.NewReader(header))
+	assert.Equal(t, ErrMaxLengthExceeded, err)
+	assert.Equal(t, TagMouseMove, frm.Tag)
+	assert.Equal(t, uint16(0xffff), frm.Length)
+	assert.Nil(t, frm.Value)
+}
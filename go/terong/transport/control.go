@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	stdslog "log/slog"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/inputevent"
+)
+
+const (
+	// ProtocolVersion1 is spoken by peers that don't send a [Hello].
+	ProtocolVersion1 uint16 = 1
+	// ProtocolVersionCompact encodes inputs with [CompactCBORCodec].
+	ProtocolVersionCompact uint16 = 2
+	// ProtocolVersionStatus adds [Status] frames sent along with pings.
+	ProtocolVersionStatus uint16 = 3
+
+	// ProtocolVersion is the latest version this build speaks.
+	ProtocolVersion = ProtocolVersionStatus
+	// MinProtocolVersion is the oldest version this build speaks.
+	MinProtocolVersion = ProtocolVersion1
+)
+
+// VersionError is the error of peers sharing no protocol version.
+type VersionError struct {
+	// Local and Remote are the latest versions spoken by this end and by the
+	// peer.
+	Local  uint16
+	Remote uint16
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("incompatible protocol version: this end speaks %d to %d, peer speaks up to %d", MinProtocolVersion, e.Local, e.Remote)
+}
+
+func (e *VersionError) Is(target error) bool {
+	return target == errs.ErrIncompatible
+}
+
+// CheckVersion returns a [*VersionError] if this build can't speak the peer's
+// latest version or any older one.
+func CheckVersion(remote uint16) error {
+	if remote < MinProtocolVersion {
+		return &VersionError{Local: ProtocolVersion, Remote: remote}
+	}
+	return nil
+}
+
+// Hello negotiates the protocol version. The client sends the latest version
+// it speaks right after connecting, the server replies with the version the
+// session uses from then on. Until the reply, version 1 is used.
+//
+// The server's hello also carries the route inputs take to reach the client,
+// the names of the relaying nodes, nearest last. A client finding its own
+// name in the route is part of a loop.
+//
+// Capabilities are optional features. The client offers the ones it wants,
+// the server replies with those it agrees to.
+type Hello struct {
+	Version      uint16   `json:"version"`
+	Route        []string `json:"route,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// ErrRoutingLoop is returned when a client finds itself in the route of the
+// server's hello.
+var ErrRoutingLoop = errors.New("routing loop detected")
+
+// Status is a summary of a peer's state, sent along with pings so either end
+// can show the health of both.
+type Status struct {
+	Relay  bool `json:"relay"`
+	Paused bool `json:"paused"`
+	// QueueDepth is the number of inputs waiting to be sent or injected.
+	QueueDepth int `json:"queue_depth"`
+	// Dropped is the number of inputs dropped since the peer started.
+	Dropped int64 `json:"dropped"`
+	// UnmappedKeys are the keys the client received but couldn't inject,
+	// e.g. being older than the server.
+	UnmappedKeys []inputevent.KeyCode `json:"unmapped_keys,omitempty"`
+}
+
+// CapabilitySettings is the [Hello] capability of applying [Settings].
+const CapabilitySettings = "settings"
+
+// Settings are client settings pushed by the server at session start, so
+// they can be tuned on the server alone. Zero values leave the client's own
+// settings in place.
+type Settings struct {
+	// PointerScale multiplies mouse moves.
+	PointerScale float64 `json:"pointer_scale,omitempty"`
+	// InvertScroll swaps scroll up and down.
+	InvertScroll *bool `json:"invert_scroll,omitempty"`
+	// KeyRepeatDelay and KeyRepeatPeriod make the client repeat held keys by
+	// itself, like key_repeat_delay and key_repeat_period of the client.
+	KeyRepeatDelay  time.Duration `json:"key_repeat_delay,omitempty"`
+	KeyRepeatPeriod time.Duration `json:"key_repeat_period,omitempty"`
+}
+
+func (s Settings) LogValue() stdslog.Value {
+	attrs := []stdslog.Attr{
+		stdslog.Float64("pointer_scale", s.PointerScale),
+		stdslog.Duration("key_repeat_delay", s.KeyRepeatDelay),
+		stdslog.Duration("key_repeat_period", s.KeyRepeatPeriod),
+	}
+	if s.InvertScroll != nil {
+		attrs = append(attrs, stdslog.Bool("invert_scroll", *s.InvertScroll))
+	}
+	return stdslog.GroupValue(attrs...)
+}
+
+// RelayState tells the client whether the server is relaying inputs to it.
+type RelayState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Close tells the peer why the session is being closed.
+type Close struct {
+	Reason string `json:"reason"`
+	// Version is the latest protocol version of the closing end, sent with
+	// CloseReasonIncompatible.
+	Version uint16 `json:"version,omitempty"`
+}
+
+const (
+	// CloseReasonExpired means the session outlived its maximum lifetime. The
+	// client should reconnect right away.
+	CloseReasonExpired = "expired"
+	// CloseReasonSuperseded means a new connection took over the session.
+	CloseReasonSuperseded = "superseded"
+	// CloseReasonIncompatible means the peers share no protocol version. The
+	// client should not retry until either end is upgraded.
+	CloseReasonIncompatible = "incompatible"
+	// CloseReasonBusy means the server is serving another client.
+	CloseReasonBusy = "busy"
+	// CloseReasonShutdown means the server is stopping. The client should
+	// reconnect as usual.
+	CloseReasonShutdown = "shutdown"
+)
+
+// ClosedError is the error of a session closed by the peer with a [Close].
+type ClosedError struct {
+	Reason string
+}
+
+func (e *ClosedError) Error() string {
+	return fmt.Sprintf("session closed by peer: %s", e.Reason)
+}
+
+// Is matches the kind of error of the reason, e.g. [errs.ErrPeerBusy] for
+// CloseReasonBusy.
+func (e *ClosedError) Is(target error) bool {
+	switch e.Reason {
+	case CloseReasonUnauthorized:
+		return target == errs.ErrAuthRejected
+	case CloseReasonBusy:
+		return target == errs.ErrPeerBusy
+	}
+	return false
+}
+
+// CloseError returns the error of a session closed by the peer with msg.
+func CloseError(msg Close) error {
+	if msg.Reason == CloseReasonIncompatible {
+		return &VersionError{Local: ProtocolVersion, Remote: msg.Version}
+	}
+	return &ClosedError{Reason: msg.Reason}
+}
+
+// CapabilityCommands is the [Hello] capability of carrying out [Command]s.
+const CapabilityCommands = "commands"
+
+// Command asks the client to act on the machine it runs on.
+//
+// Like the other control messages, commands carry no nonce of their own.
+// They are only sent over the mutually authenticated TLS connection, whose
+// per-connection keys and record sequence numbers already keep captured
+// traffic from being replayed. That holds for clients trusted with a join
+// code too, as the code is redeemed over the TLS connection, see [Join]. An
+// authentication mode without TLS would need a replay window on commands and
+// settings.
+type Command struct {
+	Action string `json:"action"`
+}
+
+const (
+	// CommandLock locks the session of the client.
+	CommandLock = "lock"
+	// CommandSuspend suspends the client machine.
+	CommandSuspend = "suspend"
+	// CommandDisplayOff turns off the displays of the client.
+	CommandDisplayOff = "display_off"
+)
+
+// Commands are the actions of a [Command].
+var Commands = []string{CommandLock, CommandSuspend, CommandDisplayOff}
+
+// EncodeMessage marshals a control message into a frame.
+func EncodeMessage(tag Tag, msg any) (Frame, error) {
+	value, err := cbor.Marshal(msg)
+	if err != nil {
+		return Frame{}, err
+	}
+	if len(value) > ValueMaxLength {
+		return Frame{}, ErrMaxLengthExceeded
+	}
+	return Frame{Tag: tag, Length: uint16(len(value)), Value: value}, nil
+}
+
+// DecodeMessage unmarshals the control message carried by frm into msg.
+func DecodeMessage(frm Frame, msg any) error {
+	return cbor.Unmarshal(frm.Value, msg)
+}
+
+// CapabilityGeometry is the [Hello] capability of exchanging [Geometry].
+const CapabilityGeometry = "geometry"
+
+// Geometry is the layout of the screens of a peer, sent by either end after
+// the hello if it's configured. It lets coordinates be mapped between
+// differently sized displays.
+type Geometry struct {
+	Screens []Screen `json:"screens"`
+}
+
+// Screen is a display in the desktop coordinates of its machine, in pixels.
+type Screen struct {
+	X      int32 `json:"x"`
+	Y      int32 `json:"y"`
+	Width  int32 `json:"width"`
+	Height int32 `json:"height"`
+}
+
+func (s Screen) String() string {
+	return fmt.Sprintf("%dx%d%+d%+d", s.Width, s.Height, s.X, s.Y)
+}
+
+func (g Geometry) String() string {
+	screens := make([]string, len(g.Screens))
+	for i, s := range g.Screens {
+		screens[i] = s.String()
+	}
+	return strings.Join(screens, ", ")
+}
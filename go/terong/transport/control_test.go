@@ -0,0 +1,52 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/errs"
+	"kafji.net/terong/inputevent"
+)
+
+func TestCheckVersion(t *testing.T) {
+	assert.NoError(t, CheckVersion(MinProtocolVersion))
+	assert.NoError(t, CheckVersion(ProtocolVersion+1))
+
+	err := CheckVersion(0)
+	var verr *VersionError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, VersionError{Local: ProtocolVersion, Remote: 0}, *verr)
+}
+
+func TestCloseError(t *testing.T) {
+	err := CloseError(Close{Reason: CloseReasonIncompatible, Version: 7})
+	var verr *VersionError
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, uint16(7), verr.Remote)
+	assert.ErrorIs(t, err, errs.ErrIncompatible)
+
+	err = CloseError(Close{Reason: CloseReasonExpired})
+	var closed *ClosedError
+	require.ErrorAs(t, err, &closed)
+	assert.Equal(t, CloseReasonExpired, closed.Reason)
+	assert.Nil(t, errs.Kind(err))
+
+	assert.ErrorIs(t, CloseError(Close{Reason: CloseReasonBusy}), errs.ErrPeerBusy)
+	assert.ErrorIs(t, CloseError(Close{Reason: CloseReasonUnauthorized}), errs.ErrAuthRejected)
+}
+
+func TestStatusUnmappedKeys(t *testing.T) {
+	want := Status{Relay: true, UnmappedKeys: []inputevent.KeyCode{inputevent.PauseBreak, inputevent.RightCtrl}}
+	frm, err := EncodeMessage(TagStatus, want)
+	require.NoError(t, err)
+
+	var got Status
+	require.NoError(t, DecodeMessage(frm, &got))
+	assert.Equal(t, want, got)
+}
+
+func TestGeometryString(t *testing.T) {
+	g := Geometry{Screens: []Screen{{Width: 2560, Height: 1440}, {X: -1920, Y: 200, Width: 1920, Height: 1080}}}
+	assert.Equal(t, "2560x1440+0+0, 1920x1080-1920+200", g.String())
+}
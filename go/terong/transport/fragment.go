@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"errors"
+)
+
+// Values larger than ValueMaxLength are sent as consecutive fragment frames
+// carrying the same tag number. Every fragment is flagged with
+// TagFlagFragment, the last one also with TagFlagFinal. Frames of other tags
+// may be interleaved with the fragments.
+const (
+	TagFlagFragment Tag = 0x2000
+	TagFlagFinal    Tag = 0x1000
+)
+
+// DefaultMaxMessageLength is the largest reassembled value accepted when
+// SessionConfig.MaxMessageLength is zero.
+const DefaultMaxMessageLength = 1 << 20
+
+var (
+	ErrMessageTooLarge      = errors.New("message is larger than the maximum length")
+	ErrInterleavedFragments = errors.New("fragments of different tags are interleaved")
+)
+
+// Fragment splits value into frames of tag. Values that fit in a frame are
+// not fragmented.
+func Fragment(tag Tag, value []byte) []Frame {
+	if len(value) <= ValueMaxLength {
+		return []Frame{{Tag: tag, Length: uint16(len(value)), Value: value}}
+	}
+	var frms []Frame
+	for len(value) > 0 {
+		n := min(len(value), ValueMaxLength)
+		flags := TagFlagFragment
+		if n == len(value) {
+			flags |= TagFlagFinal
+		}
+		frms = append(frms, Frame{Tag: tag | flags, Length: uint16(n), Value: value[:n]})
+		value = value[n:]
+	}
+	return frms
+}
+
+// Reassembler joins fragment frames back into a frame of the whole value.
+type Reassembler struct {
+	// MaxLength is the largest value reassembled. Zero means
+	// DefaultMaxMessageLength.
+	MaxLength int
+
+	tag        Tag
+	value      []byte
+	discarding bool
+}
+
+// Push adds frm. It returns the reassembled frame and true once the final
+// fragment is pushed, or frm itself and true if it isn't a fragment.
+// Reassembled frames have zero Length, their whole value is in Value.
+func (r *Reassembler) Push(frm Frame) (Frame, bool, error) {
+	if frm.Tag&TagFlagFragment == 0 {
+		return frm, true, nil
+	}
+
+	tag := frm.Tag &^ (TagFlagFragment | TagFlagFinal)
+	final := frm.Tag&TagFlagFinal != 0
+
+	if r.discarding {
+		r.discarding = !final
+		return Frame{}, false, nil
+	}
+
+	if r.value != nil && r.tag != tag {
+		r.reset()
+		return Frame{}, false, ErrInterleavedFragments
+	}
+
+	maxLength := r.MaxLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxMessageLength
+	}
+	if len(r.value)+len(frm.Value) > maxLength {
+		r.reset()
+		r.discarding = !final
+		return Frame{}, false, ErrMessageTooLarge
+	}
+
+	r.tag = tag
+	r.value = append(r.value, frm.Value...)
+	if !final {
+		return Frame{}, false, nil
+	}
+
+	value := r.value
+	r.reset()
+	return Frame{Tag: tag, Channel: frm.Channel, Value: value}, true, nil
+}
+
+// Discard drops the value being reassembled and its fragments yet to come,
+// e.g. after one of them is lost.
+func (r *Reassembler) Discard() {
+	if r.value != nil {
+		r.reset()
+		r.discarding = true
+	}
+}
+
+func (r *Reassembler) reset() {
+	r.tag = 0
+	r.value = nil
+}
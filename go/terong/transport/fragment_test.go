@@ -0,0 +1,110 @@
+package transport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFragmentSmallValue(t *testing.T) {
+	frms := Fragment(TagStatus, []byte{1, 2, 3})
+	require.Len(t, frms, 1)
+	assert.Equal(t, TagStatus, frms[0].Tag)
+}
+
+func TestFragmentRoundTrip(t *testing.T) {
+	value := bytes.Repeat([]byte{0, 1, 2, 3, 4, 5, 6}, 1000)
+	frms := Fragment(TagStatus, value)
+	require.Len(t, frms, 7)
+
+	var buf bytes.Buffer
+	for _, frm := range frms {
+		require.NoError(t, WriteFrame(&buf, frm))
+	}
+	require.NoError(t, WriteFrame(&buf, Frame{Tag: TagPing}))
+
+	var r Reassembler
+	var got []Frame
+	for buf.Len() > 0 {
+		frm, err := ReadFrame(&buf)
+		require.NoError(t, err)
+		frm, ok, err := r.Push(frm)
+		require.NoError(t, err)
+		if ok {
+			got = append(got, frm)
+		}
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, TagStatus, got[0].Tag)
+	assert.Equal(t, value, got[0].Value)
+	assert.Equal(t, TagPing, got[1].Tag)
+}
+
+func TestReassemblerPassesInterleavedFrames(t *testing.T) {
+	frms := Fragment(TagStatus, make([]byte, ValueMaxLength+1))
+	require.Len(t, frms, 2)
+
+	var r Reassembler
+	_, ok, err := r.Push(frms[0])
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	frm, ok, err := r.Push(Frame{Tag: TagPing})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, TagPing, frm.Tag)
+
+	frm, ok, err = r.Push(frms[1])
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, frm.Value, ValueMaxLength+1)
+}
+
+func TestReassemblerRejectsInterleavedFragments(t *testing.T) {
+	a := Fragment(TagStatus, make([]byte, ValueMaxLength+1))
+	b := Fragment(TagHello, make([]byte, ValueMaxLength+1))
+
+	var r Reassembler
+	_, _, err := r.Push(a[0])
+	require.NoError(t, err)
+	_, _, err = r.Push(b[0])
+	assert.Equal(t, ErrInterleavedFragments, err)
+}
+
+func TestReassemblerMaxLength(t *testing.T) {
+	r := Reassembler{MaxLength: ValueMaxLength + 1}
+
+	for _, frm := range Fragment(TagStatus, make([]byte, 3*ValueMaxLength)) {
+		_, ok, err := r.Push(frm)
+		assert.False(t, ok)
+		if err != nil {
+			assert.Equal(t, ErrMessageTooLarge, err)
+		}
+	}
+
+	frm, ok, err := r.Push(Fragment(TagStatus, make([]byte, ValueMaxLength+1))[0])
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Zero(t, frm)
+}
+
+func TestReassemblerDiscard(t *testing.T) {
+	frms := Fragment(TagStatus, make([]byte, 3*ValueMaxLength))
+
+	var r Reassembler
+	_, _, err := r.Push(frms[0])
+	require.NoError(t, err)
+	r.Discard()
+	for _, frm := range frms[1:] {
+		_, ok, err := r.Push(frm)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	frm, ok, err := r.Push(Frame{Tag: TagPing})
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, TagPing, frm.Tag)
+}
@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Join is sent by a client as its first frame, before the hello, to be
+// trusted by a server that doesn't know its certificate yet. Code is a join
+// code minted by the server, see [JoinCode].
+//
+// A join is bound to the TLS connection it arrives on. The server trusts the
+// certificate the client presented in that handshake, never one named in the
+// frame, and the code is spent once redeemed. TLS keeps the code from being
+// read on the wire, so a join can't be replayed, and it needs no nonce.
+type Join struct {
+	Code string `json:"code"`
+}
+
+// CloseReasonUnauthorized means the server doesn't trust the client's
+// certificate and the client presented no valid join code.
+const CloseReasonUnauthorized = "unauthorized"
+
+// joinCodeAlphabet leaves out letters easily mistaken for digits.
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// JoinCode is a short-lived code a client presents once to have its
+// certificate added to the server's [Allowlist].
+type JoinCode struct {
+	mu      sync.Mutex
+	code    string
+	expires time.Time
+}
+
+// NewJoinCode mints a code valid until now plus lifetime, e.g. "7KQM-X2PD".
+func NewJoinCode(now time.Time, lifetime time.Duration) (*JoinCode, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	for i := range b {
+		b[i] = joinCodeAlphabet[int(b[i])%len(joinCodeAlphabet)]
+	}
+	code := string(b[:4]) + "-" + string(b[4:])
+	return &JoinCode{code: code, expires: now.Add(lifetime)}, nil
+}
+
+func (c *JoinCode) String() string {
+	return c.code
+}
+
+func (c *JoinCode) Expires() time.Time {
+	return c.expires
+}
+
+// Valid reports whether the code can still be redeemed.
+func (c *JoinCode) Valid(now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.valid(now)
+}
+
+func (c *JoinCode) valid(now time.Time) bool {
+	return c.code != "" && !now.After(c.expires)
+}
+
+// Redeem reports whether code matches and is still valid. A code can only be
+// redeemed once.
+func (c *JoinCode) Redeem(code string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.valid(now) {
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(normalizeJoinCode(code)), []byte(normalizeJoinCode(c.code))) != 1 {
+		return false
+	}
+	c.code = ""
+	return true
+}
+
+func normalizeJoinCode(s string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), "-", ""))
+}
+
+// Allowlist is a file of trusted certificate fingerprints, one per line, see
+// [Fingerprint]. Empty lines and lines starting with # are ignored.
+type Allowlist struct {
+	path string
+
+	mu           sync.Mutex
+	fingerprints map[string]bool
+}
+
+// LoadAllowlist reads the allowlist at path. A missing file is an empty
+// allowlist.
+func LoadAllowlist(path string) (*Allowlist, error) {
+	a := &Allowlist{path: path, fingerprints: make(map[string]bool)}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		a.fingerprints[NormalizeFingerprint(line)] = true
+	}
+	if err := lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return a, nil
+}
+
+// Contains reports whether fingerprint is trusted.
+func (a *Allowlist) Contains(fingerprint string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.fingerprints[NormalizeFingerprint(fingerprint)]
+}
+
+// Add trusts fingerprint and appends it to the file, noting name.
+func (a *Allowlist) Add(fingerprint string, name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	fingerprint = NormalizeFingerprint(fingerprint)
+	if a.fingerprints[fingerprint] {
+		return nil
+	}
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "# %s\n%s\n", name, fingerprint); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	a.fingerprints[fingerprint] = true
+	return nil
+}
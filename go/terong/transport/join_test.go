@@ -0,0 +1,45 @@
+package transport
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinCode(t *testing.T) {
+	now := time.Unix(0, 0)
+	code, err := NewJoinCode(now, time.Minute)
+	require.NoError(t, err)
+	assert.Regexp(t, `^[A-Z2-9]{4}-[A-Z2-9]{4}$`, code.String())
+
+	assert.False(t, code.Redeem("AAAA-AAAA", now))
+	assert.False(t, code.Redeem(code.String(), now.Add(2*time.Minute)))
+
+	// codes are accepted without the dash and in lowercase, once
+	assert.True(t, code.Redeem(strings.ToLower(strings.ReplaceAll(code.String(), "-", "")), now))
+	assert.False(t, code.Redeem(code.String(), now))
+}
+
+func TestAllowlist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist")
+
+	a, err := LoadAllowlist(path)
+	require.NoError(t, err)
+	assert.False(t, a.Contains("ab:cd"))
+
+	require.NoError(t, a.Add("AB:CD", "laptop"))
+	assert.True(t, a.Contains("abcd"))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "# laptop\nabcd\n", string(b))
+
+	a, err = LoadAllowlist(path)
+	require.NoError(t, err)
+	assert.True(t, a.Contains("ab:cd"))
+}
@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newPingSession(t *testing.T) (*Session, *ManualClock) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	clock := NewManualClock(time.Unix(0, 0))
+	a, b := net.Pipe()
+	t.Cleanup(func() { b.Close() })
+	s := NewSession(ctx, a, SessionConfig{Clock: clock})
+	t.Cleanup(s.Close)
+	return s, clock
+}
+
+func fired(c <-chan time.Time) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestPingDeadlines(t *testing.T) {
+	s, clock := newPingSession(t)
+
+	clock.Advance(PingTimeout/2 - time.Millisecond)
+	assert.False(t, fired(s.SendPingDeadline()))
+
+	clock.Advance(time.Millisecond)
+	assert.True(t, fired(s.SendPingDeadline()))
+	assert.False(t, fired(s.RecvPingDeadline()))
+
+	clock.Advance(PingTimeout / 2)
+	assert.True(t, fired(s.RecvPingDeadline()))
+}
+
+func TestPingDeadlineReset(t *testing.T) {
+	s, clock := newPingSession(t)
+
+	// an expiry that wasn't received must not leak past a reset
+	clock.Advance(PingTimeout)
+	s.SetRecvPingDeadline()
+	assert.False(t, fired(s.RecvPingDeadline()))
+
+	clock.Advance(PingTimeout - time.Millisecond)
+	s.SetRecvPingDeadline()
+	clock.Advance(PingTimeout - time.Millisecond)
+	assert.False(t, fired(s.RecvPingDeadline()))
+
+	clock.Advance(time.Millisecond)
+	assert.True(t, fired(s.RecvPingDeadline()))
+}
+
+func TestPingDeadlineResetDoesNotLeak(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	defer b.Close()
+	s := NewSession(ctx, a, SessionConfig{})
+	defer s.Close()
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 1000; i++ {
+		s.SetSendPingDeadline()
+		s.SetRecvPingDeadline()
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestEmptySessionPingDeadlines(t *testing.T) {
+	s := EmptySession()
+	assert.Nil(t, s.SendPingDeadline())
+	assert.Nil(t, s.RecvPingDeadline())
+}
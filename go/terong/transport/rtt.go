@@ -0,0 +1,54 @@
+package transport
+
+import (
+	"encoding/binary"
+	"time"
+
+	"kafji.net/terong/terong/events"
+)
+
+// CapabilityRTT is the [Hello] capability of answering pings that carry a
+// timestamp with a TagPong echoing it, which measures the round-trip time.
+const CapabilityRTT = "rtt"
+
+// pingValue is the time since the session started, in nanoseconds.
+func (s *Session) pingValue() []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(s.clock.Now().Sub(s.started)))
+}
+
+// HandlePing answers a ping carrying a timestamp with a pong, if the peer
+// negotiated CapabilityRTT.
+func (s *Session) HandlePing(frm Frame) error {
+	if !s.rtt || len(frm.Value) != 8 {
+		return nil
+	}
+	if err := s.WriteFrame(Frame{Tag: TagPong, Length: frm.Length, Value: frm.Value}); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// HandlePong updates the round-trip time with the timestamp echoed by frm.
+func (s *Session) HandlePong(frm Frame) {
+	if len(frm.Value) != 8 {
+		return
+	}
+	sent := time.Duration(binary.BigEndian.Uint64(frm.Value))
+	rtt := s.clock.Now().Sub(s.started) - sent
+	s.lastRTT.Store(int64(rtt))
+
+	if s.cfg.LatencyAlert <= 0 {
+		return
+	}
+	above := rtt > s.cfg.LatencyAlert
+	if above && !s.latencyAlerted {
+		s.log.Warn("round-trip time above alert threshold", "rtt", rtt, "threshold", s.cfg.LatencyAlert)
+		events.Publish(events.Event{Kind: events.LatencyAlert, Peer: s.peer, RTT: rtt})
+	}
+	s.latencyAlerted = above
+}
+
+// RTT returns the latest round-trip time, or zero if it wasn't measured yet.
+func (s *Session) RTT() time.Duration {
+	return time.Duration(s.lastRTT.Load())
+}
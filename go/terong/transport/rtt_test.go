@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/terong/events"
+)
+
+func TestRTT(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	pinger := NewSession(ctx, a, SessionConfig{})
+	defer pinger.Close()
+	ponger := NewSession(ctx, b, SessionConfig{})
+	defer ponger.Close()
+
+	pinger.EnableCapabilities(pinger.Capabilities())
+	ponger.EnableCapabilities(ponger.Capabilities())
+
+	go func() {
+		frm := <-ponger.Inbox()
+		ponger.HandlePing(frm)
+	}()
+
+	require.NoError(t, pinger.SendPing())
+	frm := <-pinger.Inbox()
+	require.Equal(t, TagPong, frm.Tag)
+
+	assert.Zero(t, pinger.RTT())
+	pinger.HandlePong(frm)
+	assert.Positive(t, pinger.RTT())
+}
+
+func TestPingWithoutRTT(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	pinger := NewSession(ctx, a, SessionConfig{})
+	defer pinger.Close()
+	ponger := NewSession(ctx, b, SessionConfig{})
+	defer ponger.Close()
+
+	go pinger.SendPing()
+	frm := <-ponger.Inbox()
+	assert.Equal(t, TagPing, frm.Tag)
+	assert.Empty(t, frm.Value)
+}
+
+func TestLatencyAlert(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, _ := net.Pipe()
+	sess := NewSession(ctx, a, SessionConfig{LatencyAlert: time.Nanosecond})
+	defer sess.Close()
+	alerts := events.Subscribe(ctx)
+
+	pong := func(sent time.Duration) Frame {
+		return Frame{Tag: TagPong, Length: 8, Value: binary.BigEndian.AppendUint64(nil, uint64(sent))}
+	}
+
+	// alerted once while above
+	sess.HandlePong(pong(0))
+	sess.HandlePong(pong(0))
+	// back below
+	sess.HandlePong(pong(time.Hour))
+	// and above again
+	sess.HandlePong(pong(0))
+
+	for range 2 {
+		e := <-alerts
+		assert.Equal(t, events.LatencyAlert, e.Kind)
+		assert.Positive(t, e.RTT)
+	}
+	assert.Empty(t, alerts)
+}
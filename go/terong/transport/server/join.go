@@ -0,0 +1,81 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"kafji.net/terong/errs"
+	"kafji.net/terong/terong/transport"
+)
+
+var errUnauthorized = errs.Mark(errors.New("untrusted certificate and no valid join code"), errs.ErrAuthRejected)
+
+// authorizer checks clients after the TLS handshake when an allowlist is
+// configured, as the handshake then accepts any certificate.
+type authorizer struct {
+	pool      func() *x509.CertPool
+	allowlist *transport.Allowlist
+	code      *transport.JoinCode
+}
+
+// authorize returns nil if the client of conn is trusted, or joined the
+// allowlist with a valid join code sent as its first frame.
+func (a *authorizer) authorize(conn net.Conn) error {
+	if a.allowlist == nil {
+		return nil
+	}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("no client certificate")
+	}
+	if a.verified(certs) || a.allowlist.Contains(transport.Fingerprint(certs[0])) {
+		return nil
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(transport.ConnectTimeout)); err != nil {
+		return err
+	}
+	frm, err := transport.ReadFrame(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read join: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return err
+	}
+
+	var join transport.Join
+	if frm.Tag != transport.TagJoin || transport.DecodeMessage(frm, &join) != nil || a.code == nil || !a.code.Redeem(join.Code, time.Now()) {
+		refuse(conn, transport.CloseReasonUnauthorized)
+		return errUnauthorized
+	}
+
+	fingerprint, name := transport.Fingerprint(certs[0]), transport.PeerName(conn)
+	if err := a.allowlist.Add(fingerprint, name); err != nil {
+		return fmt.Errorf("failed to add client to allowlist: %v", err)
+	}
+	slog.Info("client joined", "peer", name, "fingerprint", fingerprint)
+	return nil
+}
+
+// verified reports whether certs are signed by the pool, as checked by the
+// handshake without an allowlist.
+func (a *authorizer) verified(certs []*x509.Certificate) bool {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         a.pool(),
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
@@ -8,31 +8,126 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"sync/atomic"
+	"time"
 
-	"github.com/fxamacker/cbor/v2"
+	"kafji.net/terong/crash"
+	"kafji.net/terong/errs"
 	"kafji.net/terong/inputevent"
 	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+	"kafji.net/terong/terong/events"
 	"kafji.net/terong/terong/transport"
+	"kafji.net/terong/tracing"
 )
 
 var slog = logging.NewLogger("terong/transport/server")
 
+// droppedInputs counts inputs dropped because the session they were held for
+// ended.
+var droppedInputs = metrics.NewCounterMap("transport_dropped_inputs")
+
+// sessions counts established sessions by peer name.
+var sessions = metrics.NewActivityCounterMap("transport_sessions")
+
+// activePeer is the name of the peer of the active session.
+var activePeer = metrics.NewLabel("transport_peer")
+
+// activeFingerprint is the certificate fingerprint of the peer of the active
+// session.
+var activeFingerprint = metrics.NewLabel("transport_client_fingerprint")
+
+// peerStatus is the latest status received from the client.
+var peerStatus atomic.Value
+
+// peerGeometry is the screen layout received from the client.
+var peerGeometry atomic.Pointer[transport.Geometry]
+
+func init() {
+	metrics.PublishFunc("transport_client_status", func() any {
+		return peerStatus.Load()
+	})
+	metrics.PublishFunc("transport_client_geometry", func() any {
+		return peerGeometry.Load()
+	})
+}
+
+// SessionPolicy decides what happens to a connection arriving while a session
+// is active.
+type SessionPolicy int
+
+const (
+	// PolicyReject keeps the active session and closes the new connection.
+	PolicyReject SessionPolicy = iota
+	// PolicyTakeover closes the active session and accepts the new
+	// connection.
+	PolicyTakeover
+)
+
 type Config struct {
 	Addr              string
 	TLSCertPath       string
 	TLSKeyPath        string
 	ClientTLSCertPath string
+	TCP               transport.TCPConfig
+	Session           transport.SessionConfig
+	// Admit, if set, is asked before a session is established. A non-nil
+	// error refuses the connection.
+	Admit func() error
+	// MaxSessionLifetime closes sessions older than it, making the client
+	// reconnect and authenticate again. TLS sessions aren't resumed when
+	// set. Zero means no limit.
+	MaxSessionLifetime time.Duration
+	SessionPolicy      SessionPolicy
+	// Name identifies this node in routes announced to clients.
+	Name string
+	// Route, if set, returns the route inputs took to reach this node when it
+	// relays inputs from an upstream server.
+	Route func() []string
+	// Settings, if set, are pushed to clients at session start.
+	Settings *transport.Settings
+	// Geometry, if set, is sent to clients at session start.
+	Geometry *transport.Geometry
+	// Dropped, if set, returns the number of inputs dropped before they were
+	// handed to the transport. It is added to the dropped inputs reported to
+	// clients.
+	Dropped func() int64
+	// Allowlist, if set, trusts the clients in it besides those whose
+	// certificate is signed by ClientTLSCertPath.
+	Allowlist *transport.Allowlist
+	// JoinCode, if set, lets a client that is trusted by neither join the
+	// Allowlist by presenting it, see [transport.Join].
+	JoinCode *transport.JoinCode
+}
+
+// route is the route announced to clients.
+func (c *Config) route() []string {
+	var route []string
+	if c.Route != nil {
+		route = slices.Clone(c.Route())
+	}
+	if c.Name != "" {
+		route = append(route, c.Name)
+	}
+	return route
+}
+
+// CheckTLS reads and parses the certificates and key of cfg.
+func CheckTLS(cfg *Config) error {
+	_, err := newTLSConfig(cfg)
+	return errs.Mark(err, errs.ErrConfigInvalid)
 }
 
 func newTLSConfig(cfg *Config) (*tls.Config, error) {
 	cert, err := os.ReadFile(cfg.TLSCertPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tls cert file: %v", err)
+		return nil, fmt.Errorf("failed to read tls cert file %s: %v", cfg.TLSCertPath, err)
 	}
 
 	key, err := os.ReadFile(cfg.TLSKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read tls key file: %v", err)
+		return nil, fmt.Errorf("failed to read tls key file %s: %v", cfg.TLSKeyPath, err)
 	}
 
 	keyPair, err := tls.X509KeyPair(cert, key)
@@ -42,168 +137,812 @@ func newTLSConfig(cfg *Config) (*tls.Config, error) {
 
 	clientCert, err := os.ReadFile(cfg.ClientTLSCertPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read client cert file: %v", err)
+		return nil, fmt.Errorf("failed to read client cert file %s: %v", cfg.ClientTLSCertPath, err)
 	}
 
 	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(clientCert)
+	if !pool.AppendCertsFromPEM(clientCert) {
+		return nil, fmt.Errorf("failed to parse client cert file %s: no PEM certificate", cfg.ClientTLSCertPath)
+	}
 
-	return &tls.Config{
+	tlsCfg := &tls.Config{
 		Certificates: []tls.Certificate{keyPair},
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 		ClientCAs:    pool,
-	}, nil
+		// a resumed session skips authenticating the client again, which
+		// the lifetime is meant to force
+		SessionTicketsDisabled: cfg.MaxSessionLifetime > 0,
+	}
+	if cfg.Allowlist != nil {
+		// clients not signed by the pool are checked after the handshake,
+		// see [authorizer]
+		tlsCfg.ClientAuth = tls.RequireAnyClientCert
+	}
+	return tlsCfg, nil
+}
+
+type Handle struct {
+	cfg       *Config
+	shutdowns chan context.Context
+	tlsCfg    atomic.Pointer[tls.Config]
+	tickets   ticketKeys
+	done      chan error
+	// stopped is closed when the server stopped
+	stopped     chan struct{}
+	relayStates chan bool
+	commands    chan transport.Command
+	peer        atomic.Value
+	sess        atomic.Pointer[session]
+}
+
+// Done receives the error that stopped the server.
+func (h *Handle) Done() <-chan error {
+	return h.done
+}
+
+// Peer returns the name of the connected client, or an empty string if none
+// is connected.
+func (h *Handle) Peer() string {
+	peer, _ := h.peer.Load().(string)
+	return peer
+}
+
+// RTT returns the latest round-trip time to the connected client, or zero if
+// none is connected or it wasn't measured yet.
+func (h *Handle) RTT() time.Duration {
+	if sess := h.sess.Load(); sess != nil && !sess.Closed() {
+		return sess.RTT()
+	}
+	return 0
+}
+
+// Dropped returns the number of inputs dropped because the session they were
+// held for ended.
+func (h *Handle) Dropped() int64 {
+	return droppedInputs.Total()
+}
+
+// ReloadTLS reads the certificates at the paths for connections accepted from
+// now on. The active session carries on with the ones it was established
+// with.
+func (h *Handle) ReloadTLS(certPath, keyPath, clientCertPath string) error {
+	cfg := *h.cfg
+	cfg.TLSCertPath, cfg.TLSKeyPath, cfg.ClientTLSCertPath = certPath, keyPath, clientCertPath
+	tlsCfg, err := newTLSConfig(&cfg)
+	if err != nil {
+		return errs.Mark(err, errs.ErrConfigInvalid)
+	}
+	h.tlsCfg.Store(tlsCfg)
+	if err := h.tickets.reset(); err != nil {
+		slog.Warn("failed to reset session ticket keys", "error", err)
+	}
+	return nil
+}
+
+// errShutdown is the error of a server stopped by [Handle.Shutdown].
+var errShutdown = errors.New("server shut down")
+
+// Shutdown stops the server in order: the input taken last is sent to the
+// connected client, the session is closed telling the client the server is
+// stopping, then the listener stops. It gives up waiting on the client when
+// ctx is done. Inputs must not be sent to the server after.
+func (h *Handle) Shutdown(ctx context.Context) error {
+	select {
+	case h.shutdowns <- ctx:
+	case err := <-h.done:
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+	select {
+	case err := <-h.done:
+		if err == errShutdown {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return context.Cause(ctx)
+	}
+}
+
+// PeerFingerprint returns the certificate fingerprint of the connected
+// client, or an empty string if none is connected.
+func (h *Handle) PeerFingerprint() string {
+	if sess := h.sess.Load(); sess != nil && !sess.Closed() {
+		return sess.Fingerprint()
+	}
+	return ""
+}
+
+// ClientGeometry returns the screen layout of the connected client, or nil if
+// none is connected or it didn't send one.
+func (h *Handle) ClientGeometry() *transport.Geometry {
+	return peerGeometry.Load()
+}
+
+func (h *Handle) setPeer(peer string) {
+	h.peer.Store(peer)
+	activePeer.Set(peer)
+	if peer == "" {
+		activeFingerprint.Set("")
+		peerStatus.Store((*transport.Status)(nil))
+		peerGeometry.Store(nil)
+	}
+}
+
+// SetRelayState notifies the connected client, and clients connecting later,
+// whether inputs are being relayed. It doesn't block once the server stopped.
+func (h *Handle) SetRelayState(enabled bool) {
+	// a state not taken yet is replaced, only the latest matters
+	select {
+	case <-h.relayStates:
+	default:
+	}
+	select {
+	case h.relayStates <- enabled:
+	case <-h.stopped:
+	}
 }
 
-func Start(ctx context.Context, cfg *Config, inputs <-chan inputevent.InputEvent) <-chan error {
-	done := make(chan error, 1)
+// SendCommand sends cmd to the connected client. It is dropped if no client
+// is connected or the server stopped.
+func (h *Handle) SendCommand(cmd transport.Command) {
+	select {
+	case h.commands <- cmd:
+	case <-h.stopped:
+		slog.Info("dropping command, server stopped", "action", cmd.Action)
+	}
+}
+
+func Start(ctx context.Context, cfg *Config, inputs <-chan inputevent.InputEvent) *Handle {
+	h := &Handle{cfg: cfg, shutdowns: make(chan context.Context), done: make(chan error, 1), stopped: make(chan struct{}), relayStates: make(chan bool, 1), commands: make(chan transport.Command, 1)}
 	go func() {
-		err := run(ctx, cfg, inputs)
-		done <- err
+		defer crash.Recover()
+		err := run(ctx, cfg, inputs, h)
+		close(h.stopped)
+		h.done <- err
 	}()
-	return done
+	return h
 }
 
-func run(ctx context.Context, cfg *Config, inputs <-chan inputevent.InputEvent) error {
+func run(ctx context.Context, cfg *Config, inputs <-chan inputevent.InputEvent, h *Handle) error {
 	tlsCfg, err := newTLSConfig(cfg)
 	if err != nil {
-		return err
+		return errs.Mark(err, errs.ErrConfigInvalid)
 	}
+	// certificates reloaded meanwhile are newer
+	h.tlsCfg.CompareAndSwap(nil, tlsCfg)
 
 	slog.Info("listening for connection", "address", cfg.Addr)
-	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp4", cfg.Addr)
+	listener, err := transport.ListenTCP(ctx, cfg.Addr, &cfg.TCP)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
-	listener = tls.NewListener(listener, tlsCfg)
+	listenerCfg := &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return h.tlsCfg.Load(), nil
+		},
+	}
+	// the returned configs have no keys of their own, so tickets are
+	// encrypted with the keys of the listener's
+	if err := h.tickets.use(listenerCfg); err != nil {
+		return err
+	}
+	listener = tls.NewListener(listener, listenerCfg)
 	defer listener.Close()
 
-	receptionist := newReceptionist(listener)
+	rotateTicker := time.NewTicker(ticketKeyRotation)
+	defer rotateTicker.Stop()
+
+	pool := func() *x509.CertPool {
+		return h.tlsCfg.Load().ClientCAs
+	}
+	auth := &authorizer{pool: pool, allowlist: cfg.Allowlist, code: cfg.JoinCode}
+	receptionist := newReceptionist(listener, auth.authorize)
+	defer receptionist.close()
 
 	sess := emptySession()
 	defer func() {
 		sess.Close()
 	}()
 
+	relay := false
+
+	// An input the session can't take yet is held, and no more inputs are
+	// taken until it is, so congestion reaches the sender, which decides what
+	// to drop.
+	var pending inputevent.InputEvent
+
 	for {
+		in, out := inputs, chan<- inputevent.InputEvent(nil)
+		if pending != nil {
+			in, out = nil, sess.inputs
+		}
+
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return context.Cause(ctx)
+
+		case <-receptionist.done:
+			return receptionist.err
 
-		case conn, ok := <-receptionist.conns:
-			if !ok {
-				return receptionist.err
+		case conn := <-receptionist.conns:
+			if cfg.Admit != nil {
+				if err := cfg.Admit(); err != nil {
+					slog.Info("refusing connection", "address", conn.RemoteAddr(), "reason", err)
+					if err := conn.Close(); err != nil {
+						slog.Warn("failed to close connection", "address", conn.RemoteAddr(), "error", err)
+					}
+					continue
+				}
 			}
 			if !sess.Closed() {
-				slog.Info("rejecting connection, active session exists", "address", conn.RemoteAddr())
-				err := conn.Close()
-				if err != nil {
-					slog.Warn("failed to close connection", "address", conn.RemoteAddr(), "error", err)
+				if cfg.SessionPolicy != PolicyTakeover {
+					slog.Info("rejecting connection, active session exists", "address", conn.RemoteAddr())
+					refuse(conn, transport.CloseReasonBusy)
+					err := conn.Close()
+					if err != nil {
+						slog.Warn("failed to close connection", "address", conn.RemoteAddr(), "error", err)
+					}
+					continue
 				}
+				sess.log.Info("session superseded", "address", conn.RemoteAddr())
+				sess.closeWith(transport.CloseReasonSuperseded)
+				err := <-sess.done
+				sess.log.Info("session terminated", "error", err)
+				sess.Close()
+				events.Publish(events.Event{Kind: events.Disconnected, Peer: sess.Peer(), Reason: transport.CloseReasonSuperseded})
+				h.setPeer("")
+				pending = discardPending(pending)
+			}
+			sess = newSession(ctx, conn, cfg.Session)
+			sess.route = cfg.route()
+			sess.settings = cfg.Settings
+			sess.geometry = cfg.Geometry
+			sess.dropped = cfg.Dropped
+			sess.log.Info("session established", "address", conn.RemoteAddr(), "peer_fingerprint", sess.Fingerprint())
+			sessions.Add(sess.Peer(), 1)
+			h.setPeer(sess.Peer())
+			activeFingerprint.Set(sess.Fingerprint())
+			h.sess.Store(sess)
+			events.Publish(events.Event{Kind: events.Connected, Peer: sess.Peer(), Fingerprint: sess.Fingerprint()})
+			sess.setRelayState(relay)
+			runSession(ctx, sess, cfg.MaxSessionLifetime)
+
+		case <-rotateTicker.C:
+			if err := h.tickets.rotate(); err != nil {
+				slog.Warn("failed to rotate session ticket keys", "error", err)
+			}
+
+		case shutdownCtx := <-h.shutdowns:
+			return shutdownSession(shutdownCtx, sess, pending, h)
+
+		case relay = <-h.relayStates:
+			if !sess.Closed() {
+				sess.setRelayState(relay)
+			}
+
+		case cmd := <-h.commands:
+			if sess.Closed() {
+				slog.Info("dropping command, no client connected", "action", cmd.Action)
 				continue
 			}
-			sess = newSession(ctx, conn)
-			slog.Info("session established", "address", conn.RemoteAddr())
-			runSession(ctx, sess)
+			sess.sendCommand(cmd)
 
-		case input := <-inputs:
+		case input := <-in:
+			if sess.Closed() {
+				continue
+			}
 			select {
 			case sess.inputs <- input:
 			default:
+				pending = input
 			}
 
+		case out <- pending:
+			pending = nil
+
 		case err := <-sess.done:
-			slog.Error("session terminated", "error", err)
+			sess.log.Error("session terminated", "error", err)
+			var closed *transport.ClosedError
+			if !errors.As(err, &closed) || closed.Reason != transport.CloseReasonExpired {
+				crash.DumpLogs(err)
+			}
 			sess.Close()
+			events.Publish(events.Disconnect(sess.Peer(), err))
+			h.setPeer("")
+			pending = discardPending(pending)
+		}
+	}
+}
+
+// shutdownSession closes sess for the server stopping, after handing it the
+// pending input. It gives up when ctx is done.
+func shutdownSession(ctx context.Context, sess *session, pending inputevent.InputEvent, h *Handle) error {
+	if sess.Closed() {
+		return errShutdown
+	}
+	var err error
+	ended := false
+	if pending != nil {
+		select {
+		case sess.inputs <- pending:
+		case err = <-sess.done:
+			ended = true
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	}
+	if !ended {
+		sess.closeWith(transport.CloseReasonShutdown)
+		select {
+		case err = <-sess.done:
+		case <-ctx.Done():
+			return context.Cause(ctx)
 		}
 	}
+	sess.log.Info("session terminated", "error", err)
+	events.Publish(events.Disconnect(sess.Peer(), err))
+	h.setPeer("")
+	return errShutdown
 }
 
-// receptionist handles incoming connections.
+// refuseTimeout bounds writing the reason a client is refused.
+const refuseTimeout = time.Second
+
+// refuse tells a client why it's being refused before its connection is
+// closed, best effort.
+func refuse(conn net.Conn, reason string) {
+	frm, err := transport.EncodeMessage(transport.TagClose, transport.Close{Reason: reason})
+	if err != nil {
+		return
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(refuseTimeout)); err != nil {
+		return
+	}
+	if err := transport.WriteFrame(conn, frm); err != nil {
+		slog.Debug("failed to write close", "address", conn.RemoteAddr(), "error", err)
+	}
+}
+
+// discardPending drops the input held for a session that is gone.
+func discardPending(pending inputevent.InputEvent) inputevent.InputEvent {
+	if pending != nil {
+		droppedInputs.Add(inputevent.TypeName(pending), 1)
+	}
+	return nil
+}
+
+// Accept is retried after temporary errors, e.g. running out of file
+// descriptors, with the delay doubled on every consecutive error.
+const (
+	acceptMinDelay = 5 * time.Millisecond
+	acceptMaxDelay = time.Second
+)
+
+// maxAdmitting bounds the connections in handshake or authorization at once,
+// more are closed right away.
+const maxAdmitting = 16
+
+// receptionist handles incoming connections. Each is handshaken and
+// authorized in its own goroutine, so a client that stalls doesn't hold up
+// the others.
 type receptionist struct {
 	listener net.Listener
-	conns    chan net.Conn
-	err      error
+	// conns are handshaken and authorized
+	conns chan net.Conn
+	// done is closed when accepting failed, with err set
+	done chan struct{}
+	err  error
+	// stop is closed when conns are no longer taken
+	stop      chan struct{}
+	admitting chan struct{}
 }
 
-func newReceptionist(listener net.Listener) *receptionist {
+func newReceptionist(listener net.Listener, authorize func(net.Conn) error) *receptionist {
 	r := &receptionist{
-		listener: listener,
-		conns:    make(chan net.Conn),
+		listener:  listener,
+		conns:     make(chan net.Conn),
+		done:      make(chan struct{}),
+		stop:      make(chan struct{}),
+		admitting: make(chan struct{}, maxAdmitting),
 	}
 
 	go func() {
-		defer close(r.conns)
+		defer crash.Recover()
+		defer close(r.done)
 
+		var delay time.Duration
 		for {
 			conn, err := r.listener.Accept()
 			if err != nil {
-				r.err = fmt.Errorf("failed to accept connection: %v", err)
-				return
+				if !temporary(err) {
+					r.err = fmt.Errorf("failed to accept connection: %v", err)
+					return
+				}
+				delay = min(max(2*delay, acceptMinDelay), acceptMaxDelay)
+				slog.Warn("failed to accept connection, retrying", "error", err, "delay", delay)
+				time.Sleep(delay)
+				continue
+			}
+			delay = 0
+			select {
+			case r.admitting <- struct{}{}:
+			default:
+				slog.Warn("refusing connection, too many connecting", "address", conn.RemoteAddr())
+				conn.Close()
+				continue
 			}
 			slog.Info("connected to client", "address", conn.RemoteAddr())
-			r.conns <- conn
+			go r.admit(conn, authorize)
 		}
 	}()
 
 	return r
 }
 
+// admit hands conn over once it's handshaken and authorized.
+func (r *receptionist) admit(conn net.Conn, authorize func(net.Conn) error) {
+	defer crash.Recover()
+	err := handshake(conn)
+	if err != nil {
+		slog.Warn("tls handshake failed", "address", conn.RemoteAddr(), "error", err)
+	} else if err = authorize(conn); err != nil {
+		slog.Warn("client not authorized", "address", conn.RemoteAddr(), "error", err)
+	}
+	<-r.admitting
+	if err != nil {
+		conn.Close()
+		return
+	}
+	select {
+	case r.conns <- conn:
+	case <-r.stop:
+		conn.Close()
+	}
+}
+
+// close closes the connections admitted from now on.
+func (r *receptionist) close() {
+	close(r.stop)
+}
+
+// temporary reports whether the listener may accept connections again after
+// err.
+func temporary(err error) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	var netErr net.Error
+	// Temporary is deprecated but still how accept reports EMFILE and the like.
+	return errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary())
+}
+
+// handshake completes the TLS handshake so the peer certificate is known
+// before the connection is handed over.
+func handshake(conn net.Conn) error {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), transport.ConnectTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return err
+	}
+	countHandshake(tlsConn.ConnectionState())
+	return nil
+}
+
 type session struct {
 	*transport.Session
-	inputs chan inputevent.InputEvent
-	done   chan error
+	log         logging.Logger
+	inputs      chan inputevent.InputEvent
+	relayStates chan bool
+	commands    chan transport.Command
+	closing     chan string
+	done        chan error
+	// codec of the negotiated protocol version
+	codec transport.Codec
+	// route announced in the hello
+	route []string
+	// settings pushed after the hello
+	settings *transport.Settings
+	// geometry sent after the hello
+	geometry *transport.Geometry
+	// negotiated protocol version
+	version uint16
+	// relay state last sent
+	relay bool
+	// the client carries out commands, see [transport.CapabilityCommands]
+	acceptsCommands bool
+	// dropped returns the inputs dropped upstream, see [Config.Dropped]
+	dropped func() int64
+	// keys the client reported it can't inject, logged once each
+	unmappedKeys map[inputevent.KeyCode]struct{}
 }
 
 func emptySession() *session {
-	return &session{Session: transport.EmptySession()}
+	return &session{Session: transport.EmptySession(), log: slog}
 }
 
-func newSession(ctx context.Context, conn net.Conn) *session {
+func newSession(ctx context.Context, conn net.Conn, cfg transport.SessionConfig) *session {
+	s := transport.NewSession(ctx, conn, cfg)
 	return &session{
-		Session: transport.NewSession(ctx, conn),
-		inputs:  make(chan inputevent.InputEvent, 1),
-		done:    make(chan error, 1),
+		Session:      s,
+		log:          slog.With("session", s.ID(), "peer", s.Peer()),
+		inputs:       make(chan inputevent.InputEvent, 1),
+		relayStates:  make(chan bool, 1),
+		commands:     make(chan transport.Command, 1),
+		closing:      make(chan string, 1),
+		unmappedKeys: make(map[inputevent.KeyCode]struct{}),
+		done:         make(chan error, 1),
+		codec:        transport.CBORCodec,
+	}
+}
+
+// setRelayState queues the relay state to be sent, replacing one that has not
+// been sent yet.
+func (s *session) setRelayState(enabled bool) {
+	select {
+	case <-s.relayStates:
+	default:
 	}
+	s.relayStates <- enabled
+}
+
+// sendCommand queues cmd to be sent, dropping it if another one has not been
+// sent yet.
+func (s *session) sendCommand(cmd transport.Command) {
+	select {
+	case s.commands <- cmd:
+	default:
+		s.log.Warn("dropping command, another one is pending", "action", cmd.Action)
+	}
+}
+
+// closeWith asks the session to close itself, telling the client the reason,
+// e.g. in favor of a new connection.
+func (s *session) closeWith(reason string) {
+	select {
+	case s.closing <- reason:
+	default:
+	}
+}
+
+// writeRelayState sends the relay state followed by the matching pause or
+// resume signal.
+func (s *session) writeRelayState(enabled bool) error {
+	frm, err := transport.EncodeMessage(transport.TagRelayState, transport.RelayState{Enabled: enabled})
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFrame(frm); err != nil {
+		return err
+	}
+	s.relay = enabled
+	if enabled {
+		return s.WriteSignal(transport.TagResume)
+	}
+	return s.WriteSignal(transport.TagPause)
+}
+
+// writeCommand sends cmd, if the client carries out commands.
+func (s *session) writeCommand(cmd transport.Command) error {
+	if !s.acceptsCommands {
+		s.log.Info("client doesn't accept commands", "action", cmd.Action)
+		return nil
+	}
+	frm, err := transport.EncodeMessage(transport.TagCommand, cmd)
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(frm)
 }
 
 func (s *session) writeInput(input inputevent.InputEvent) error {
-	value, err := cbor.Marshal(&input)
+	if !s.Accepts(input) {
+		// the client can't inject it
+		return nil
+	}
+	if s.Traces() && tracing.Sample() {
+		return s.writeTracedInput(input)
+	}
+	return s.WriteInput(s.codec, input)
+}
+
+// writeTracedInput sends input preceded by its trace context, and flushes it
+// right away so the send span covers the write to the connection.
+func (s *session) writeTracedInput(input inputevent.InputEvent) error {
+	root := tracing.StartSpan("relay_input", tracing.KindProducer)
+	root.SetAttr("input", inputevent.TypeName(input))
+	defer root.End()
+
+	trace, err := transport.EncodeMessage(transport.TagTrace, transport.Trace{TraceID: root.TraceID(), SpanID: root.ID()})
 	if err != nil {
-		return fmt.Errorf("failed to marshal value: %v", err)
+		return fmt.Errorf("failed to encode trace: %v", err)
 	}
 
-	lengthInt := len(value)
-	if lengthInt > transport.ValueMaxLength {
-		return errors.New("length is larger than maximum value length")
+	span := root.StartChild("serialize")
+	frm, err := transport.EncodeInput(s.codec, input)
+	span.End()
+	if err != nil {
+		return err
+	}
+
+	span = root.StartChild("send")
+	defer span.End()
+	if err := s.WriteFrame(trace); err != nil {
+		return err
+	}
+	if err := s.WriteFrame(frm); err != nil {
+		return err
 	}
-	length := uint16(lengthInt)
+	return s.Flush()
+}
 
-	tag, err := transport.TagFor(input)
+// negotiate replies to the client's hello and switches to the codec of the
+// agreed protocol version and the agreed capabilities.
+func (s *session) negotiate(hello transport.Hello) error {
+	version := min(hello.Version, transport.ProtocolVersion)
+	capabilities := transport.NegotiateCapabilities(hello.Capabilities, s.Capabilities())
+	reply := transport.Hello{Version: version, Route: s.route, Capabilities: capabilities}
+	frm, err := transport.EncodeMessage(transport.TagHello, reply)
 	if err != nil {
-		return fmt.Errorf("failed to get tag: %v", err)
+		return err
+	}
+	if err := s.WriteFrame(frm); err != nil {
+		return err
+	}
+	s.version = version
+	s.codec = transport.CodecFor(version)
+	s.EnableCapabilities(capabilities)
+	s.acceptsCommands = slices.Contains(capabilities, transport.CapabilityCommands)
+	s.log.Info("protocol negotiated", "version", version, "capabilities", capabilities)
+
+	if s.settings != nil && slices.Contains(capabilities, transport.CapabilitySettings) {
+		frm, err := transport.EncodeMessage(transport.TagSettings, s.settings)
+		if err != nil {
+			return fmt.Errorf("failed to encode settings: %v", err)
+		}
+		if err := s.WriteFrame(frm); err != nil {
+			return err
+		}
 	}
+	if s.geometry != nil && slices.Contains(capabilities, transport.CapabilityGeometry) {
+		frm, err := transport.EncodeMessage(transport.TagGeometry, s.geometry)
+		if err != nil {
+			return fmt.Errorf("failed to encode geometry: %v", err)
+		}
+		if err := s.WriteFrame(frm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	frm := transport.Frame{Tag: tag, Length: length, Value: value}
+// logUnmappedKeys warns about the keys the client can't inject the first time
+// it reports them, rather than on every press.
+func (s *session) logUnmappedKeys(keys []inputevent.KeyCode) {
+	for _, k := range keys {
+		if _, ok := s.unmappedKeys[k]; ok {
+			continue
+		}
+		s.unmappedKeys[k] = struct{}{}
+		s.log.Warn("client can't inject key, upgrade the client", "key", k)
+	}
+}
+
+// writeStatus sends the state of this end, if the client understands it.
+func (s *session) writeStatus() error {
+	if s.version < transport.ProtocolVersionStatus {
+		return nil
+	}
+	status := transport.Status{
+		Relay:      s.relay,
+		QueueDepth: len(s.inputs),
+		Dropped:    droppedInputs.Total(),
+	}
+	if s.dropped != nil {
+		status.Dropped += s.dropped()
+	}
+	frm, err := transport.EncodeMessage(transport.TagStatus, status)
+	if err != nil {
+		return err
+	}
 	return s.WriteFrame(frm)
 }
 
-func runSession(ctx context.Context, sess *session) {
+// writeClose sends the reason the session is being closed.
+func (s *session) writeClose(reason string) error {
+	return s.writeCloseMessage(transport.Close{Reason: reason})
+}
+
+func (s *session) writeCloseMessage(msg transport.Close) error {
+	frm, err := transport.EncodeMessage(transport.TagClose, msg)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteFrame(frm); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+func runSession(ctx context.Context, sess *session, maxLifetime time.Duration) {
 	go func() {
+		defer crash.Recover()
 		err := func() error {
+			var expired <-chan time.Time
+			if maxLifetime > 0 {
+				timer := time.NewTimer(maxLifetime)
+				defer timer.Stop()
+				expired = timer.C
+			}
+
 			for {
 				select {
 				case <-ctx.Done():
-					return ctx.Err()
+					// let the client release held keys before the connection
+					// goes away
+					if err := sess.WriteSignal(transport.TagPause); err != nil {
+						sess.log.Debug("failed to write pause", "error", err)
+					}
+					return context.Cause(ctx)
+
+				case <-expired:
+					sess.log.Info("session expired", "lifetime", maxLifetime)
+					if err := sess.writeClose(transport.CloseReasonExpired); err != nil {
+						return fmt.Errorf("failed to write close: %v", err)
+					}
+					return &transport.ClosedError{Reason: transport.CloseReasonExpired}
+
+				case reason := <-sess.closing:
+					if reason == transport.CloseReasonShutdown {
+						// the input handed over last is still sent
+						select {
+						case input := <-sess.inputs:
+							if err := sess.writeInput(input); err != nil {
+								return fmt.Errorf("failed to write input: %v", err)
+							}
+						default:
+						}
+					}
+					if err := sess.writeClose(reason); err != nil {
+						return fmt.Errorf("failed to write close: %v", err)
+					}
+					return &transport.ClosedError{Reason: reason}
 
 				case input := <-sess.inputs:
-					slog.Debug("sending input", "input", input)
+					if sess.log.DebugEnabled() {
+						sess.log.Debug("sending input", "input", input)
+					}
 					if err := sess.writeInput(input); err != nil {
 						return fmt.Errorf("failed to write input: %v", err)
 					}
 
+				case enabled := <-sess.relayStates:
+					sess.log.Debug("sending relay state", "enabled", enabled)
+					if err := sess.writeRelayState(enabled); err != nil {
+						return fmt.Errorf("failed to write relay state: %v", err)
+					}
+
+				case cmd := <-sess.commands:
+					sess.log.Info("sending command", "action", cmd.Action)
+					if err := sess.writeCommand(cmd); err != nil {
+						return fmt.Errorf("failed to write command: %v", err)
+					}
+
+				case <-sess.FlushDeadline():
+					if err := sess.Flush(); err != nil {
+						return fmt.Errorf("failed to flush inputs: %v", err)
+					}
+
 				case <-sess.SendPingDeadline():
-					slog.Debug("sending ping")
+					sess.log.Debug("sending ping")
+					if err := sess.writeStatus(); err != nil {
+						return fmt.Errorf("failed to write status: %v", err)
+					}
 					if err := sess.SendPing(); err != nil {
 						return fmt.Errorf("failed to write ping: %v", err)
 					}
@@ -215,12 +954,62 @@ func runSession(ctx context.Context, sess *session) {
 					if !ok {
 						return sess.InboxErr()
 					}
+					if frm.Channel != transport.ChannelDefault {
+						if err := sess.SkipChannel(frm); err != nil {
+							return fmt.Errorf("failed to write channel credit: %v", err)
+						}
+						break
+					}
 					switch frm.Tag {
+					case transport.TagChannelCredit:
+						if err := sess.HandleCredit(frm); err != nil {
+							return err
+						}
 					case transport.TagPing:
-						slog.Debug("ping received")
+						sess.log.Debug("ping received")
 						sess.SetRecvPingDeadline()
+						if err := sess.HandlePing(frm); err != nil {
+							return fmt.Errorf("failed to write pong: %v", err)
+						}
+					case transport.TagPong:
+						sess.HandlePong(frm)
+					case transport.TagStatus:
+						var status transport.Status
+						if err := transport.DecodeMessage(frm, &status); err != nil {
+							sess.log.Warn("failed to unmarshal status", "error", err)
+							break
+						}
+						peerStatus.Store(&status)
+						sess.logUnmappedKeys(status.UnmappedKeys)
+					case transport.TagGeometry:
+						var geometry transport.Geometry
+						if err := transport.DecodeMessage(frm, &geometry); err != nil {
+							sess.log.Warn("failed to unmarshal geometry", "error", err)
+							break
+						}
+						sess.log.Info("client screens received", "screens", geometry)
+						peerGeometry.Store(&geometry)
+					case transport.TagJoin:
+						sess.log.Debug("ignoring join, client is already trusted")
+					case transport.TagHello:
+						var hello transport.Hello
+						if err := transport.DecodeMessage(frm, &hello); err != nil {
+							return fmt.Errorf("failed to unmarshal hello: %v", err)
+						}
+						if err := transport.CheckVersion(hello.Version); err != nil {
+							msg := transport.Close{Reason: transport.CloseReasonIncompatible, Version: transport.ProtocolVersion}
+							if err := sess.writeCloseMessage(msg); err != nil {
+								sess.log.Debug("failed to write close", "error", err)
+							}
+							return err
+						}
+						if err := sess.negotiate(hello); err != nil {
+							return fmt.Errorf("failed to write hello: %v", err)
+						}
 					default:
-						slog.Warn("unexpected tag", "tag", frm.Tag)
+						if err := sess.SkipUnknown(frm); err != nil {
+							return err
+						}
 					}
 				}
 			}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
+	"kafji.net/terong/terong/transport"
+)
+
+func TestReceptionistStalledClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	stalled := make(chan struct{})
+	defer close(stalled)
+	var started atomic.Bool
+	r := newReceptionist(listener, func(net.Conn) error {
+		if started.CompareAndSwap(false, true) {
+			<-stalled
+		}
+		return nil
+	})
+	defer r.close()
+
+	c1, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer c1.Close()
+	// the first is taken into authorization before the second connects
+	time.Sleep(50 * time.Millisecond)
+	c2, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	defer c2.Close()
+
+	select {
+	case conn := <-r.conns:
+		assert.Equal(t, c2.LocalAddr().String(), conn.RemoteAddr().String())
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Fatal("connection held up by a stalled one")
+	}
+}
+
+func TestHandleStopped(t *testing.T) {
+	h := &Handle{stopped: make(chan struct{}), relayStates: make(chan bool, 1), commands: make(chan transport.Command, 1)}
+	close(h.stopped)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			h.SetRelayState(i%2 == 0)
+			h.SendCommand(transport.Command{})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked on a stopped server")
+	}
+}
+
+// startSession runs a session over a pipe, returning the client end.
+func startSession(t *testing.T, ctx context.Context) (*session, net.Conn) {
+	a, b := net.Pipe()
+	t.Cleanup(func() { b.Close() })
+	sess := newSession(ctx, a, transport.SessionConfig{})
+	t.Cleanup(sess.Close)
+	runSession(ctx, sess, 0)
+	return sess, b
+}
+
+// readFrames reads frames from conn until it's closed.
+func readFrames(conn net.Conn) <-chan transport.Frame {
+	frames := make(chan transport.Frame, 16)
+	go func() {
+		defer close(frames)
+		for {
+			frm, err := transport.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			frames <- frm
+		}
+	}()
+	return frames
+}
+
+func TestShutdownSession(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sess, client := startSession(t, ctx)
+	frames := readFrames(client)
+
+	pending := inputevent.MouseMove{DX: 1, DY: 2}
+	err := shutdownSession(ctx, sess, pending, &Handle{})
+	assert.Equal(t, errShutdown, err)
+
+	var inputs []inputevent.InputEvent
+	var closed transport.Close
+	for frm := range frames {
+		if frm.Tag == transport.TagClose {
+			require.NoError(t, transport.DecodeMessage(frm, &closed))
+			break
+		}
+		if input, err := transport.CBORCodec.Unmarshal(frm.Tag, frm.Value); err == nil {
+			inputs = append(inputs, input)
+		}
+	}
+	assert.Equal(t, []inputevent.InputEvent{pending}, inputs)
+	assert.Equal(t, transport.CloseReasonShutdown, closed.Reason)
+}
+
+func TestShutdownSessionTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the client never reads, so the close can't be written
+	sess, _ := startSession(t, ctx)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancelShutdown()
+	start := time.Now()
+	err := shutdownSession(shutdownCtx, sess, inputevent.MouseMove{DX: 1}, &Handle{})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestShutdownSessionNoSession(t *testing.T) {
+	assert.Equal(t, errShutdown, shutdownSession(context.Background(), emptySession(), nil, &Handle{}))
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"sync"
+	"time"
+
+	"kafji.net/terong/metrics"
+)
+
+const (
+	// ticketKeyRotation is how often a new session ticket key is made.
+	ticketKeyRotation = 12 * time.Hour
+	// ticketKeyCount is how many keys decrypt tickets, so a ticket can be
+	// resumed for up to ticketKeyCount*ticketKeyRotation.
+	ticketKeyCount = 3
+)
+
+// handshakes counts TLS handshakes of accepted connections, "full" or
+// "resumed".
+var handshakes = metrics.NewActivityCounterMap("transport_tls_handshakes")
+
+// ticketKeys encrypt the session tickets given to clients, so a reconnecting
+// client resumes its TLS session instead of doing a full handshake. New
+// tickets are encrypted with the newest key, older keys only decrypt.
+type ticketKeys struct {
+	mu   sync.Mutex
+	keys [][32]byte
+	// cfg the keys are set on, nil until listening
+	cfg *tls.Config
+}
+
+// use sets the keys on cfg from now on.
+func (t *ticketKeys) use(cfg *tls.Config) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cfg = cfg
+	return t.add(true)
+}
+
+// add makes a new key, dropping the oldest one, or all of them if keep is
+// false.
+func (t *ticketKeys) add(keep bool) error {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return fmt.Errorf("failed to generate session ticket key: %v", err)
+	}
+	if !keep {
+		t.keys = nil
+	}
+	t.keys = append([][32]byte{key}, t.keys...)
+	if len(t.keys) > ticketKeyCount {
+		t.keys = t.keys[:ticketKeyCount]
+	}
+	if t.cfg != nil {
+		t.cfg.SetSessionTicketKeys(t.keys)
+	}
+	return nil
+}
+
+// rotate makes a new key.
+func (t *ticketKeys) rotate() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.add(true)
+}
+
+// reset replaces every key, so sessions established with the certificates
+// before a reload can't be resumed.
+func (t *ticketKeys) reset() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.add(false)
+}
+
+// countHandshake counts the completed handshake of cs.
+func countHandshake(cs tls.ConnectionState) {
+	if cs.DidResume {
+		handshakes.Add("resumed", 1)
+	} else {
+		handshakes.Add("full", 1)
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert writes a self-signed certificate and its key to dir, named after
+// name.
+func writeCert(t *testing.T, dir, name string) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{name},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath, keyPath = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func TestSessionResumption(t *testing.T) {
+	dir := t.TempDir()
+	serverCert, serverKey := writeCert(t, dir, "server")
+	clientCert, clientKey := writeCert(t, dir, "client")
+	serverPEM, err := os.ReadFile(serverCert)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(serverPEM))
+	clientPair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		lifetime time.Duration
+		resumed  bool
+	}{
+		{0, true},
+		{time.Hour, false},
+	} {
+		tlsCfg, err := newTLSConfig(&Config{TLSCertPath: serverCert, TLSKeyPath: serverKey, ClientTLSCertPath: clientCert, MaxSessionLifetime: tc.lifetime})
+		require.NoError(t, err)
+		// as set up by run
+		listenerCfg := &tls.Config{
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) { return tlsCfg, nil },
+		}
+		var tickets ticketKeys
+		require.NoError(t, tickets.use(listenerCfg))
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", listenerCfg)
+		require.NoError(t, err)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				// tickets are sent after the handshake, with the first write
+				conn.Write([]byte{1})
+				conn.Close()
+			}
+		}()
+
+		clientCfg := &tls.Config{
+			ServerName:         "server",
+			RootCAs:            roots,
+			Certificates:       []tls.Certificate{clientPair},
+			ClientSessionCache: tls.NewLRUClientSessionCache(1),
+		}
+		var resumed []bool
+		for i := 0; i < 2; i++ {
+			conn, err := tls.Dial("tcp", listener.Addr().String(), clientCfg)
+			require.NoError(t, err)
+			_, err = conn.Read(make([]byte, 1))
+			require.NoError(t, err)
+			resumed = append(resumed, conn.ConnectionState().DidResume)
+			conn.Close()
+		}
+		listener.Close()
+		assert.Equal(t, []bool{false, tc.resumed}, resumed, "lifetime %v", tc.lifetime)
+	}
+}
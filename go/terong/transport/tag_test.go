@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisteredTagsAreInRange(t *testing.T) {
+	for tag := range tagNames {
+		assert.LessOrEqual(t, tag, TagRangeRegisteredEnd, tag)
+		assert.False(t, tag.Critical(), tag)
+		assert.Equal(t, tag, tag.Number(), tag)
+	}
+}
+
+func TestTagString(t *testing.T) {
+	assert.Equal(t, "key_press", TagKeyPress.String())
+	assert.Equal(t, "0x0100", Tag(0x0100).String())
+	assert.Equal(t, "0x4100 (critical)", (TagFlagCritical | 0x0100).String())
+}
+
+func TestSkipUnknownNonCriticalFrames(t *testing.T) {
+	unknown := Tag(0x0100)
+	require.False(t, unknown.Known())
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, Frame{Tag: unknown, Length: 2, Value: []byte{0xaa, 0xbb}}))
+	require.NoError(t, WriteFrame(&buf, Frame{Tag: TagPing}))
+
+	frm, err := ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, unknown, frm.Tag)
+	assert.NoError(t, CheckUnknownTag(frm.Tag))
+
+	frm, err = ReadFrame(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, TagPing, frm.Tag)
+}
+
+func TestRejectUnknownCriticalFrames(t *testing.T) {
+	assert.Equal(t, ErrUnknownCriticalTag, CheckUnknownTag(TagFlagCritical|0x0100))
+}
+
+func TestOversizedFrameClosesInbox(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	defer b.Close()
+	s := NewSession(ctx, a, SessionConfig{})
+	defer s.Close()
+
+	before := oversizedFrames.Total()
+	go b.Write([]byte{0x00, byte(TagKeyPress), 0xff, 0xff})
+
+	_, ok := <-s.Inbox()
+	assert.False(t, ok)
+	assert.Equal(t, ErrMaxLengthExceeded, s.InboxErr())
+	assert.Equal(t, before+1, oversizedFrames.Total())
+}
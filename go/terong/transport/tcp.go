@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TCPConfig tunes the TCP connection underneath the TLS session. Zero values
+// leave the Go or operating system defaults in place.
+type TCPConfig struct {
+	// NoDelay disables Nagle's algorithm when true. Go enables TCP_NODELAY by
+	// default.
+	NoDelay *bool
+	// KeepAlivePeriod is the interval between keep-alive probes. Negative
+	// value disables keep-alive.
+	KeepAlivePeriod time.Duration
+	ReadBufferSize  int
+	WriteBufferSize int
+}
+
+func (c *TCPConfig) apply(conn net.Conn) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if c.NoDelay != nil {
+		if err := tcpConn.SetNoDelay(*c.NoDelay); err != nil {
+			return fmt.Errorf("failed to set no delay: %v", err)
+		}
+	}
+
+	if c.ReadBufferSize > 0 {
+		if err := tcpConn.SetReadBuffer(c.ReadBufferSize); err != nil {
+			return fmt.Errorf("failed to set read buffer size: %v", err)
+		}
+	}
+
+	if c.WriteBufferSize > 0 {
+		if err := tcpConn.SetWriteBuffer(c.WriteBufferSize); err != nil {
+			return fmt.Errorf("failed to set write buffer size: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ListenTCP listens on addr and applies cfg to every accepted connection.
+func ListenTCP(ctx context.Context, addr string, cfg *TCPConfig) (net.Listener, error) {
+	listener, err := (&net.ListenConfig{KeepAlive: cfg.KeepAlivePeriod}).Listen(ctx, "tcp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpListener{Listener: listener, cfg: cfg}, nil
+}
+
+type tcpListener struct {
+	net.Listener
+	cfg *TCPConfig
+}
+
+func (l *tcpListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if err := l.cfg.apply(conn); err != nil {
+		slog.Warn("failed to tune connection", "error", err, "address", conn.RemoteAddr())
+	}
+	return conn, nil
+}
+
+// DialTCP connects to addr and applies cfg to the connection.
+func DialTCP(ctx context.Context, addr string, cfg *TCPConfig) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ConnectTimeout, KeepAlive: cfg.KeepAlivePeriod}
+	conn, err := dialer.DialContext(ctx, "tcp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.apply(conn); err != nil {
+		slog.Warn("failed to tune connection", "error", err, "address", conn.RemoteAddr())
+	}
+	return conn, nil
+}
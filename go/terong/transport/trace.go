@@ -0,0 +1,14 @@
+package transport
+
+import "kafji.net/terong/tracing"
+
+// CapabilityTrace is the [Hello] capability of tracing sampled inputs. The
+// server sends a [Trace] right before the input frame it traces.
+const CapabilityTrace = "trace"
+
+// Trace is the trace context of the input frame following it, so the
+// client's spans join the server's trace.
+type Trace struct {
+	TraceID tracing.TraceID `json:"trace_id"`
+	SpanID  tracing.SpanID  `json:"span_id"`
+}
@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"io"
+	stdslog "log/slog"
+	"sync"
+
+	"kafji.net/terong/metrics"
+)
+
+// sessions are the open sessions by ID, published with their traffic.
+var sessions sync.Map
+
+func init() {
+	metrics.PublishFunc("transport_session_traffic", func() any {
+		m := make(map[string]any)
+		sessions.Range(func(k, v any) bool {
+			s := v.(*Session)
+			m[k.(string)] = map[string]any{"peer": s.Peer(), "traffic": s.Traffic()}
+			return true
+		})
+		return m
+	})
+}
+
+// TagTraffic counts frames and their bytes on the wire.
+type TagTraffic struct {
+	Frames int64 `json:"frames"`
+	Bytes  int64 `json:"bytes"`
+}
+
+func (t TagTraffic) add(u TagTraffic) TagTraffic {
+	return TagTraffic{Frames: t.Frames + u.Frames, Bytes: t.Bytes + u.Bytes}
+}
+
+// Traffic is what a session sent and received, by tag.
+type Traffic struct {
+	Sent     map[string]TagTraffic `json:"sent"`
+	Received map[string]TagTraffic `json:"received"`
+}
+
+func total(m map[string]TagTraffic) TagTraffic {
+	var t TagTraffic
+	for _, v := range m {
+		t = t.add(v)
+	}
+	return t
+}
+
+func (t Traffic) LogValue() stdslog.Value {
+	sent, received := total(t.Sent), total(t.Received)
+	return stdslog.GroupValue(
+		stdslog.Int64("sent_frames", sent.Frames),
+		stdslog.Int64("sent_bytes", sent.Bytes),
+		stdslog.Int64("received_frames", received.Frames),
+		stdslog.Int64("received_bytes", received.Bytes),
+	)
+}
+
+// trafficCounter is written by the reading and the writing goroutine of a
+// session and read by the status endpoint.
+type trafficCounter struct {
+	mu       sync.Mutex
+	sent     map[Tag]TagTraffic
+	received map[Tag]TagTraffic
+}
+
+func (c *trafficCounter) addSent(tag Tag, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sent == nil {
+		c.sent = make(map[Tag]TagTraffic)
+	}
+	c.sent[tag.Number()] = c.sent[tag.Number()].add(TagTraffic{Frames: 1, Bytes: int64(bytes)})
+}
+
+func (c *trafficCounter) addReceived(tag Tag, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.received == nil {
+		c.received = make(map[Tag]TagTraffic)
+	}
+	c.received[tag.Number()] = c.received[tag.Number()].add(TagTraffic{Frames: 1, Bytes: int64(bytes)})
+}
+
+func (c *trafficCounter) snapshot() Traffic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := Traffic{Sent: make(map[string]TagTraffic), Received: make(map[string]TagTraffic)}
+	for k, v := range c.sent {
+		t.Sent[k.String()] = v
+	}
+	for k, v := range c.received {
+		t.Received[k.String()] = v
+	}
+	return t
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
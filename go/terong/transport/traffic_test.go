@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionTraffic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, b := net.Pipe()
+	sender := NewSession(ctx, a, SessionConfig{})
+	defer sender.Close()
+	receiver := NewSession(ctx, b, SessionConfig{})
+	defer receiver.Close()
+
+	status, err := EncodeMessage(TagStatus, Status{Relay: true})
+	require.NoError(t, err)
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		<-receiver.Inbox()
+		<-receiver.Inbox()
+		<-receiver.Inbox()
+	}()
+
+	require.NoError(t, sender.WritePing())
+	require.NoError(t, sender.WriteFrame(status))
+	require.NoError(t, sender.WritePing())
+	require.NoError(t, sender.Flush())
+	<-received
+
+	want := Traffic{
+		Sent: map[string]TagTraffic{
+			"ping":   {Frames: 2, Bytes: 8},
+			"status": {Frames: 1, Bytes: 4 + int64(status.Length)},
+		},
+		Received: map[string]TagTraffic{},
+	}
+	assert.Equal(t, want, sender.Traffic())
+
+	want.Sent, want.Received = want.Received, want.Sent
+	assert.Equal(t, want, receiver.Traffic())
+}
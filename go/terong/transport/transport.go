@@ -1,21 +1,44 @@
 package transport
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"math/rand"
 	"net"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"kafji.net/terong/crash"
 	"kafji.net/terong/inputevent"
 	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
 )
 
 var slog = logging.NewLogger("terong/transport")
 
+var (
+	// corruptedFrames counts dropped frames that failed their checksum, by
+	// tag.
+	corruptedFrames = metrics.NewCounterMap("transport_corrupted_frames")
+	// unknownFrames counts skipped frames of unknown tags, by tag.
+	unknownFrames = metrics.NewCounterMap("transport_unknown_frames")
+	// oversizedFrames counts frames longer than ValueMaxLength that closed
+	// their session, by tag.
+	oversizedFrames = metrics.NewCounterMap("transport_oversized_frames")
+)
+
 const (
 	ValueMaxLength = 1024 - 2 /* tag */ - 2 /* length */
 	// ValueMaxLength can fit in uint16.
@@ -27,6 +50,10 @@ const (
 	ConnectTimeout = 5 * time.Second
 	ReconnectDelay = 5 * time.Second
 	WriteTimeout   = 100 * time.Millisecond
+
+	// IncompatibleReconnectDelay replaces ReconnectDelay after the peers
+	// turned out to share no protocol version, which retrying won't fix.
+	IncompatibleReconnectDelay = 5 * time.Minute
 )
 
 var (
@@ -34,8 +61,26 @@ var (
 	ErrPingTimedOut      = errors.New("ping timed out")
 )
 
+// Tag identifies the kind of a frame.
+//
+// The five high bits are flags: [TagFlagChecksum], [TagFlagCritical],
+// [TagFlagFragment], [TagFlagFinal], and [TagFlagChannel]. The rest is the tag
+// number, 0x0001 to 0x00ff are registered in [tagNames], 0x0100 to 0x06ff are
+// reserved for future versions, and 0x0700 to 0x07ff are for private
+// experiments. New tags that an older peer may not understand
+// must be ignorable unless the session can't go on without them.
 type Tag uint16
 
+// TagFlagCritical marks a frame that must not be skipped. A peer that doesn't
+// know the tag closes the session instead, see [CheckUnknownTag].
+const TagFlagCritical Tag = 0x4000
+
+const (
+	TagRangeRegisteredEnd Tag = 0x00ff
+	TagRangeReservedEnd   Tag = 0x06ff
+	TagRangePrivateEnd    Tag = 0x07ff
+)
+
 const (
 	TagMouseMove Tag = iota + 1
 	TagMouseClick
@@ -43,8 +88,123 @@ const (
 	TagKeyPress
 
 	TagPing
+
+	// TagRelayState carries a [RelayState] from server to client.
+	TagRelayState
+
+	// TagPause instructs the client to release every held key and button and
+	// to discard inputs until TagResume.
+	TagPause
+	TagResume
+
+	// TagClose carries a [Close] sent right before the session is closed.
+	TagClose
+
+	// TagHello carries a [Hello].
+	TagHello
+
+	// TagStatus carries a [Status].
+	TagStatus
+
+	// TagChannelCredit carries a [ChannelCredit].
+	TagChannelCredit
+
+	// TagPong echoes the value of a TagPing, see [CapabilityRTT].
+	TagPong
+
+	// TagSettings carries [Settings] from server to client.
+	TagSettings
+
+	// TagGamepadButton and TagGamepadAxis carry gamepad inputs, see
+	// [CapabilityGamepad].
+	TagGamepadButton
+	TagGamepadAxis
+
+	// TagTouchFrame carries touchpad contacts, see [CapabilityTouch].
+	TagTouchFrame
+
+	// TagPenState carries tablet pen inputs, see [CapabilityPen].
+	TagPenState
+
+	// TagCommand carries a [Command] from server to client, see
+	// [CapabilityCommands].
+	TagCommand
+
+	// TagTrace carries a [Trace] from server to client, see
+	// [CapabilityTrace].
+	TagTrace
+
+	// TagJoin carries a [Join] from client to server.
+	TagJoin
+
+	// TagGeometry carries the [Geometry] of either end, see
+	// [CapabilityGeometry].
+	TagGeometry
 )
 
+var tagNames = map[Tag]string{
+	TagMouseMove:     "mouse_move",
+	TagMouseClick:    "mouse_click",
+	TagMouseScroll:   "mouse_scroll",
+	TagKeyPress:      "key_press",
+	TagPing:          "ping",
+	TagRelayState:    "relay_state",
+	TagPause:         "pause",
+	TagResume:        "resume",
+	TagClose:         "close",
+	TagHello:         "hello",
+	TagStatus:        "status",
+	TagChannelCredit: "channel_credit",
+	TagPong:          "pong",
+	TagSettings:      "settings",
+	TagGamepadButton: "gamepad_button",
+	TagGamepadAxis:   "gamepad_axis",
+	TagTouchFrame:    "touch_frame",
+	TagPenState:      "pen_state",
+	TagCommand:       "command",
+	TagTrace:         "trace",
+	TagJoin:          "join",
+	TagGeometry:      "geometry",
+}
+
+var ErrUnknownCriticalTag = errors.New("unknown critical tag")
+
+// Number returns the tag without flags.
+func (t Tag) Number() Tag {
+	return t &^ (TagFlagChecksum | TagFlagCritical | TagFlagFragment | TagFlagFinal | TagFlagChannel)
+}
+
+// Critical reports whether a frame with this tag must be understood.
+func (t Tag) Critical() bool {
+	return t&TagFlagCritical != 0
+}
+
+// Known reports whether the tag is registered.
+func (t Tag) Known() bool {
+	_, ok := tagNames[t]
+	return ok
+}
+
+func (t Tag) String() string {
+	if name, ok := tagNames[t]; ok {
+		return name
+	}
+	s := fmt.Sprintf("0x%04x", uint16(t))
+	if t.Critical() {
+		s += " (critical)"
+	}
+	return s
+}
+
+// CheckUnknownTag returns ErrUnknownCriticalTag if a frame with the unknown
+// tag must not be skipped.
+func CheckUnknownTag(tag Tag) error {
+	if tag.Critical() {
+		return ErrUnknownCriticalTag
+	}
+	return nil
+}
+
 func TagFor(v any) (Tag, error) {
 	switch v.(type) {
 	case inputevent.MouseMove:
@@ -55,6 +215,14 @@ func TagFor(v any) (Tag, error) {
 		return TagMouseScroll, nil
 	case inputevent.KeyPress:
 		return TagKeyPress, nil
+	case inputevent.GamepadButtonPress:
+		return TagGamepadButton, nil
+	case inputevent.GamepadAxisMove:
+		return TagGamepadAxis, nil
+	case inputevent.TouchFrame:
+		return TagTouchFrame, nil
+	case inputevent.PenState:
+		return TagPenState, nil
 	}
 	return 0, errors.New("unexpected type")
 }
@@ -91,20 +259,18 @@ func readUint16(r io.Reader) (uint16, error) {
 }
 
 type Frame struct {
-	Tag    Tag
+	Tag     Tag
+	Channel Channel
+	// Length is the length of Value on the wire. It is zero for frames
+	// reassembled from fragments, see [Reassembler].
 	Length uint16
 	Value  []byte
 }
 
 func WriteFrame(w io.Writer, frm Frame) error {
-	err := WriteTag(w, frm.Tag)
+	_, err := w.Write(appendHeader(nil, frm, 0))
 	if err != nil {
-		return fmt.Errorf("failed to write tag: %v", err)
-	}
-
-	err = WriteLength(w, frm.Length)
-	if err != nil {
-		return fmt.Errorf("failed to write length: %v", err)
+		return fmt.Errorf("failed to write header: %v", err)
 	}
 
 	_, err = w.Write(frm.Value[:frm.Length])
@@ -115,38 +281,155 @@ func WriteFrame(w io.Writer, frm Frame) error {
 	return nil
 }
 
+// appendFrame appends frm, with its checksum if checksum is set, to buf.
+func appendFrame(buf []byte, frm Frame, checksum bool) []byte {
+	if !checksum {
+		buf = appendHeader(buf, frm, 0)
+		return append(buf, frm.Value[:frm.Length]...)
+	}
+	start := len(buf)
+	buf = appendHeader(buf, frm, TagFlagChecksum)
+	buf = append(buf, frm.Value[:frm.Length]...)
+	return binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[start:]))
+}
+
+// appendHeader appends the tag, flagged with flags, the channel, and the
+// length of frm to buf.
+func appendHeader(buf []byte, frm Frame, flags Tag) []byte {
+	tag := frm.Tag | flags
+	if frm.Channel != ChannelDefault {
+		tag |= TagFlagChannel
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(tag))
+	if frm.Channel != ChannelDefault {
+		buf = append(buf, byte(frm.Channel))
+	}
+	return binary.BigEndian.AppendUint16(buf, frm.Length)
+}
+
 func ReadFrame(r io.Reader) (Frame, error) {
 	tag, err := ReadTag(r)
 	if err != nil {
 		return Frame{}, fmt.Errorf("failed to read tag: %v", err)
 	}
 
+	ch := ChannelDefault
+	if tag&TagFlagChannel != 0 {
+		var buf [1]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return Frame{}, fmt.Errorf("failed to read channel: %v", err)
+		}
+		ch = Channel(buf[0])
+	}
+
 	length, err := ReadLength(r)
 	if err != nil {
 		return Frame{}, fmt.Errorf("failed to read length: %v", err)
 	}
 
+	// a peer must not be able to make us allocate more than we'd ever accept
+	if length > ValueMaxLength {
+		return Frame{Tag: tag &^ (TagFlagChecksum | TagFlagChannel), Channel: ch, Length: length}, ErrMaxLengthExceeded
+	}
+
 	value := make([]byte, length)
 	_, err = io.ReadFull(r, value)
 	if err != nil {
 		return Frame{}, fmt.Errorf("failed to read value: %v", err)
 	}
 
-	if length > ValueMaxLength {
-		err = ErrMaxLengthExceeded
+	frm := Frame{Tag: tag &^ (TagFlagChecksum | TagFlagChannel), Channel: ch, Length: length, Value: value}
+
+	if tag&TagFlagChecksum != 0 {
+		err := verifyChecksum(r, frm)
+		if err == ErrChecksumMismatch {
+			return frm, err
+		}
+		if err != nil {
+			return Frame{}, fmt.Errorf("failed to read checksum: %v", err)
+		}
 	}
 
-	return Frame{Tag: tag, Length: length, Value: value}, err
+	return frm, nil
+}
+
+// SessionConfig configures write coalescing. Frames written within
+// CoalesceDelay of the first unflushed frame share a single flush, unless
+// CoalesceFrames frames are pending first. Zero CoalesceDelay flushes every
+// frame immediately.
+type SessionConfig struct {
+	CoalesceDelay  time.Duration
+	CoalesceFrames int
+	// PeerNames names peers by their certificate fingerprint, see
+	// [Fingerprint].
+	PeerNames map[string]string
+	// Checksum offers or accepts frame checksums, see [CapabilityChecksum].
+	Checksum bool
+	// MaxMessageLength is the largest value reassembled from fragments, see
+	// [Fragment]. Zero means DefaultMaxMessageLength.
+	MaxMessageLength int
+	// Clock drives ping deadlines. Nil means SystemClock.
+	Clock Clock
+	// Trace offers or accepts tracing sampled inputs, see [CapabilityTrace].
+	Trace bool
+	// LatencyAlert publishes an events.LatencyAlert when the round-trip time
+	// rises above it. Zero disables the alert.
+	LatencyAlert time.Duration
+}
+
+func (c *SessionConfig) clock() Clock {
+	if c.Clock == nil {
+		return SystemClock
+	}
+	return c.Clock
 }
 
 type Session struct {
 	conn net.Conn
+	cfg  SessionConfig
+	id   string
+	peer string
+	// fingerprint of the peer's certificate
+	fingerprint string
+	log         logging.Logger
+
+	w             *bufio.Writer
+	pending       int
+	flushDeadline <-chan time.Time
+	flushTimer    *time.Timer
+	// reused to encode inputs and frames, see [Session.WriteInput]
+	encodeBuf []byte
+	frameBuf  []byte
+	// frames are written with checksums
+	checksum bool
+	// channels other than the default can be written
+	channels bool
+	// bytes that can be written by channel, see [Session.WriteChannel]
+	credits map[Channel]int
+	// pings carry timestamps, see [CapabilityRTT]
+	rtt     bool
+	started time.Time
+	// gamepad inputs can be written, see [CapabilityGamepad]
+	gamepad bool
+	// touch frames can be written, see [CapabilityTouch]
+	touch bool
+	// pen states can be written, see [CapabilityPen]
+	pen bool
+	// sampled inputs are traced, see [CapabilityTrace]
+	trace   bool
+	lastRTT atomic.Int64
+	// the round-trip time is above SessionConfig.LatencyAlert
+	latencyAlerted bool
+
+	r       *countingReader
+	traffic trafficCounter
 
 	mu     sync.Mutex
 	closed bool
 
-	sendPingDeadline chan struct{}
-	recvPingDeadline chan struct{}
+	clock            Clock
+	sendPingDeadline Timer
+	recvPingDeadline Timer
 
 	inbox       chan Frame
 	inboxErr    error
@@ -157,24 +440,74 @@ func EmptySession() *Session {
 	return &Session{closed: true}
 }
 
-func NewSession(ctx context.Context, conn net.Conn) *Session {
+func NewSession(ctx context.Context, conn net.Conn, cfg SessionConfig) *Session {
 	inbox := make(chan Frame)
 	inboxCtx, cancelInbox := context.WithCancel(ctx)
-	s := &Session{conn: conn, inbox: inbox, cancelInbox: cancelInbox}
-	s.SetSendPingDeadline()
-	s.SetRecvPingDeadline()
+	id := newSessionID()
+	peer, fingerprint := PeerName(conn), PeerFingerprint(conn)
+	if name, ok := cfg.PeerNames[fingerprint]; ok {
+		peer = name
+	}
+	s := &Session{
+		conn:        conn,
+		cfg:         cfg,
+		id:          id,
+		peer:        peer,
+		fingerprint: fingerprint,
+		log:         slog.With("session", id, "peer", peer),
+		w:           bufio.NewWriter(conn),
+		r:           &countingReader{r: conn},
+		clock:       cfg.clock(),
+		inbox:       inbox,
+		cancelInbox: cancelInbox,
+	}
+	s.started = s.clock.Now()
+	s.sendPingDeadline = s.clock.NewTimer(PingTimeout / 2)
+	s.recvPingDeadline = s.clock.NewTimer(PingTimeout)
+	sessions.Store(id, s)
 
 	go func() {
+		defer crash.Recover()
 		defer close(s.inbox)
+		reassemblers := make(map[Channel]*Reassembler)
 		err := func() error {
 			for {
 				frm, err := s.ReadFrame()
+				if err == ErrChecksumMismatch {
+					s.log.Debug("dropping corrupted frame", "tag", frm.Tag)
+					corruptedFrames.Add(frm.Tag.String(), 1)
+					if r, ok := reassemblers[frm.Channel]; ok && frm.Tag&TagFlagFragment != 0 {
+						r.Discard()
+					}
+					continue
+				}
+				if err == ErrMaxLengthExceeded {
+					s.log.Warn("closing session on oversized frame", "tag", frm.Tag, "length", frm.Length)
+					oversizedFrames.Add(frm.Tag.String(), 1)
+					return err
+				}
 				if err != nil {
 					return err
 				}
+				r, ok := reassemblers[frm.Channel]
+				if !ok {
+					r = &Reassembler{MaxLength: cfg.MaxMessageLength}
+					reassemblers[frm.Channel] = r
+				}
+				frm, ok, err = r.Push(frm)
+				if err == ErrMessageTooLarge {
+					s.log.Warn("dropping message", "error", err)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if !ok {
+					continue
+				}
 				select {
 				case <-inboxCtx.Done():
-					return inboxCtx.Err()
+					return context.Cause(inboxCtx)
 				case s.inbox <- frm:
 				}
 			}
@@ -185,6 +518,62 @@ func NewSession(ctx context.Context, conn net.Conn) *Session {
 	return s
 }
 
+func newSessionID() string {
+	return fmt.Sprintf("%08x", rand.Uint32())
+}
+
+// PeerName identifies the peer of conn by its certificate common name, or by
+// its address if conn is not a TLS connection with a peer certificate.
+func PeerName(conn net.Conn) string {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		certs := tlsConn.ConnectionState().PeerCertificates
+		if len(certs) > 0 && certs[0].Subject.CommonName != "" {
+			return certs[0].Subject.CommonName
+		}
+	}
+	return conn.RemoteAddr().String()
+}
+
+// PeerFingerprint returns the fingerprint of the certificate of the peer of
+// conn, or an empty string if it has none.
+func PeerFingerprint(conn net.Conn) string {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		certs := tlsConn.ConnectionState().PeerCertificates
+		if len(certs) > 0 {
+			return Fingerprint(certs[0])
+		}
+	}
+	return ""
+}
+
+// Fingerprint is the lowercase hex SHA-256 of the DER encoding of cert, as
+// printed by `openssl x509 -fingerprint -sha256` without the colons.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeFingerprint converts a fingerprint with colons or in uppercase to
+// the form returned by [Fingerprint].
+func NormalizeFingerprint(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, ":", ""))
+}
+
+// ID is a short random identifier to correlate log records of this session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) Peer() string {
+	return s.peer
+}
+
+// Fingerprint returns the fingerprint of the peer's certificate, see
+// [Fingerprint], or an empty string if it has none.
+func (s *Session) Fingerprint() string {
+	return s.fingerprint
+}
+
 func (s *Session) Inbox() <-chan Frame {
 	return s.inbox
 }
@@ -193,55 +582,223 @@ func (s *Session) InboxErr() error {
 	return s.inboxErr
 }
 
+// SetSendPingDeadline schedules the next ping half a ping timeout from now,
+// replacing the previous deadline.
 func (s *Session) SetSendPingDeadline() {
-	ch := make(chan struct{}, 1)
-	go func() {
-		d := PingTimeout/2 + time.Duration(rand.Intn(int(PingTimeout/time.Second/2)))
-		time.Sleep(d)
-		ch <- struct{}{}
-	}()
-	s.sendPingDeadline = ch
+	s.sendPingDeadline.Reset(PingTimeout / 2)
 }
 
-func (s *Session) SendPingDeadline() <-chan struct{} {
-	return s.sendPingDeadline
+// SendPingDeadline receives when a ping is due.
+func (s *Session) SendPingDeadline() <-chan time.Time {
+	if s.sendPingDeadline == nil {
+		return nil
+	}
+	return s.sendPingDeadline.C()
 }
 
+// SetRecvPingDeadline expects the next ping within a ping timeout from now,
+// replacing the previous deadline.
 func (s *Session) SetRecvPingDeadline() {
-	ch := make(chan struct{}, 1)
-	go func() {
-		time.Sleep(PingTimeout)
-		ch <- struct{}{}
-	}()
-	s.recvPingDeadline = ch
+	s.recvPingDeadline.Reset(PingTimeout)
 }
 
-func (s *Session) RecvPingDeadline() <-chan struct{} {
-	return s.recvPingDeadline
+// RecvPingDeadline receives when the peer's ping is overdue.
+func (s *Session) RecvPingDeadline() <-chan time.Time {
+	if s.recvPingDeadline == nil {
+		return nil
+	}
+	return s.recvPingDeadline.C()
 }
 
 func (s *Session) WriteFrame(frm Frame) error {
+	if err := s.setWriteDeadline(); err != nil {
+		return err
+	}
+
+	s.frameBuf = appendFrame(s.frameBuf[:0], frm, s.checksum)
+	if _, err := s.w.Write(s.frameBuf); err != nil {
+		return fmt.Errorf("failed to write frame: %v", err)
+	}
+	s.pending++
+	s.traffic.addSent(frm.Tag, len(s.frameBuf))
+
+	if s.cfg.CoalesceDelay <= 0 || (s.cfg.CoalesceFrames > 0 && s.pending >= s.cfg.CoalesceFrames) {
+		return s.Flush()
+	}
+	if s.flushDeadline == nil {
+		if s.flushTimer == nil {
+			s.flushTimer = time.NewTimer(s.cfg.CoalesceDelay)
+		} else {
+			s.flushTimer.Reset(s.cfg.CoalesceDelay)
+		}
+		s.flushDeadline = s.flushTimer.C
+	}
+	return nil
+}
+
+// WriteInput encodes input with codec and writes it. Its buffers are reused,
+// so relaying inputs doesn't allocate once they have grown, see
+// [AppendInput].
+func (s *Session) WriteInput(codec Codec, input inputevent.InputEvent) error {
+	frm, buf, err := AppendInput(codec, s.encodeBuf[:0], input)
+	s.encodeBuf = buf
+	if err != nil {
+		return err
+	}
+	return s.WriteFrame(frm)
+}
+
+// WriteMessage writes tag and value, fragmented if value doesn't fit in a
+// frame.
+func (s *Session) WriteMessage(tag Tag, value []byte) error {
+	for _, frm := range Fragment(tag, value) {
+		if err := s.WriteFrame(frm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SkipUnknown handles a frame of an unknown tag received by the session. It
+// returns an error if the session has to be closed.
+func (s *Session) SkipUnknown(frm Frame) error {
+	if err := CheckUnknownTag(frm.Tag); err != nil {
+		s.log.Error("received unknown critical frame", "tag", frm.Tag)
+		return fmt.Errorf("%v: %v", err, frm.Tag)
+	}
+	s.log.Debug("skipping unknown frame", "tag", frm.Tag, "length", frm.Length)
+	unknownFrames.Add(frm.Tag.String(), 1)
+	return nil
+}
+
+// Capabilities returns the capabilities enabled by the session config.
+func (s *Session) Capabilities() []string {
+	var capabilities []string
+	if s.cfg.Checksum {
+		capabilities = append(capabilities, CapabilityChecksum)
+	}
+	capabilities = append(capabilities, CapabilityChannels, CapabilityRTT, CapabilitySettings, CapabilityGamepad, CapabilityTouch, CapabilityPen, CapabilityCommands, CapabilityGeometry)
+	if s.cfg.Trace {
+		capabilities = append(capabilities, CapabilityTrace)
+	}
+	return capabilities
+}
+
+// EnableCapabilities turns on the negotiated capabilities for frames written
+// from now on.
+func (s *Session) EnableCapabilities(capabilities []string) {
+	s.checksum = slices.Contains(capabilities, CapabilityChecksum)
+	s.channels = slices.Contains(capabilities, CapabilityChannels)
+	s.rtt = slices.Contains(capabilities, CapabilityRTT)
+	s.gamepad = slices.Contains(capabilities, CapabilityGamepad)
+	s.touch = slices.Contains(capabilities, CapabilityTouch)
+	s.pen = slices.Contains(capabilities, CapabilityPen)
+	s.trace = slices.Contains(capabilities, CapabilityTrace)
+}
+
+// Traces reports whether sampled inputs are traced.
+func (s *Session) Traces() bool {
+	return s.trace
+}
+
+const (
+	// CapabilityGamepad is the [Hello] capability of receiving gamepad
+	// inputs.
+	CapabilityGamepad = "gamepad"
+	// CapabilityTouch is the [Hello] capability of receiving touchpad
+	// contacts.
+	CapabilityTouch = "touch"
+	// CapabilityPen is the [Hello] capability of receiving tablet pen
+	// inputs.
+	CapabilityPen = "pen"
+)
+
+// Accepts reports whether the peer can receive input. Optional kinds of
+// inputs need their capability.
+func (s *Session) Accepts(input inputevent.InputEvent) bool {
+	switch input.(type) {
+	case inputevent.GamepadButtonPress, inputevent.GamepadAxisMove:
+		return s.gamepad
+	case inputevent.TouchFrame:
+		return s.touch
+	case inputevent.PenState:
+		return s.pen
+	}
+	return true
+}
+
+// FlushDeadline fires when pending frames must be flushed. It is nil when
+// nothing is pending.
+func (s *Session) FlushDeadline() <-chan time.Time {
+	return s.flushDeadline
+}
+
+func (s *Session) Flush() error {
+	s.pending = 0
+	if s.flushDeadline != nil && !s.flushTimer.Stop() {
+		// drain an expiry the caller didn't receive, so Reset starts afresh
+		select {
+		case <-s.flushTimer.C:
+		default:
+		}
+	}
+	s.flushDeadline = nil
+	if err := s.setWriteDeadline(); err != nil {
+		return err
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush: %v", err)
+	}
+	return nil
+}
+
+func (s *Session) setWriteDeadline() error {
 	t := time.Now().Add(WriteTimeout)
 	err := s.conn.SetWriteDeadline(t)
-	if err != err {
+	if err != nil {
 		return fmt.Errorf("failed to set write deadline: %v", err)
 	}
-	return WriteFrame(s.conn, frm)
+	return nil
 }
 
 func (s *Session) WritePing() error {
 	frm := Frame{Tag: TagPing, Length: 0}
+	if s.rtt {
+		frm.Value = s.pingValue()
+		frm.Length = uint16(len(frm.Value))
+	}
 	return s.WriteFrame(frm)
 }
 
+// WriteSignal writes and flushes a frame without value.
+func (s *Session) WriteSignal(tag Tag) error {
+	if err := s.WriteFrame(Frame{Tag: tag, Length: 0}); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
 func (s *Session) ReadFrame() (Frame, error) {
-	return ReadFrame(s.conn)
+	n := s.r.n
+	frm, err := ReadFrame(s.r)
+	if err == nil || err == ErrChecksumMismatch {
+		s.traffic.addReceived(frm.Tag, s.r.n-n)
+	}
+	return frm, err
+}
+
+// Traffic returns what the session sent and received so far.
+func (s *Session) Traffic() Traffic {
+	return s.traffic.snapshot()
 }
 
 func (s *Session) SendPing() error {
 	if err := s.WritePing(); err != nil {
 		return err
 	}
+	if err := s.Flush(); err != nil {
+		return err
+	}
 	s.SetSendPingDeadline()
 	return nil
 }
@@ -257,10 +814,14 @@ func (s *Session) Close() {
 		return
 	}
 	s.closed = true
+	sessions.Delete(s.id)
+	s.sendPingDeadline.Stop()
+	s.recvPingDeadline.Stop()
+	s.log.Info("session traffic", "traffic", s.Traffic())
 
 	err := s.conn.Close()
 	if err != nil {
-		slog.Warn(
+		s.log.Warn(
 			"failed to close connection",
 			"error", err,
 			"local_addr", s.conn.LocalAddr(),
@@ -0,0 +1,137 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"kafji.net/terong/inputevent"
+)
+
+var benchInputs = []struct {
+	name  string
+	input inputevent.InputEvent
+}{
+	{"MouseMove", inputevent.MouseMove{DX: -120, DY: 45}},
+	{"MouseClick", inputevent.MouseClick{Button: inputevent.MouseButtonLeft, Action: inputevent.MouseButtonActionDown}},
+	{"MouseScroll", inputevent.MouseScroll{Direction: inputevent.MouseScrollDown, Count: 3}},
+	{"KeyPress", inputevent.KeyPress{Key: inputevent.RightCtrl, Action: inputevent.KeyActionDown}},
+}
+
+func benchFrame(b *testing.B, input inputevent.InputEvent) Frame {
+	frm, err := EncodeInput(CBORCodec, input)
+	require.NoError(b, err)
+	return frm
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	for _, bi := range benchInputs {
+		b.Run(bi.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := CBORCodec.Marshal(bi.input); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteFrame(b *testing.B) {
+	frm := benchFrame(b, benchInputs[0].input)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		if err := WriteFrame(&buf, frm); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadFrame(b *testing.B) {
+	frm := benchFrame(b, benchInputs[0].input)
+	var buf bytes.Buffer
+	require.NoError(b, WriteFrame(&buf, frm))
+	data := buf.Bytes()
+	r := bytes.NewReader(data)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		if _, err := ReadFrame(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSessionThroughput(b *testing.B) {
+	for _, cfg := range []struct {
+		name string
+		cfg  SessionConfig
+	}{
+		{"NoCoalesce", SessionConfig{}},
+		{"Coalesce", SessionConfig{CoalesceDelay: time.Millisecond, CoalesceFrames: 16}},
+	} {
+		b.Run(cfg.name, func(b *testing.B) {
+			benchSessionThroughput(b, cfg.cfg)
+		})
+	}
+}
+
+func benchSessionThroughput(b *testing.B, cfg SessionConfig) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(b, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	conn, err := net.Dial("tcp4", listener.Addr().String())
+	require.NoError(b, err)
+	peer, ok := <-accepted
+	require.True(b, ok)
+
+	sender := NewSession(ctx, conn, cfg)
+	defer sender.Close()
+	receiver := NewSession(ctx, peer, SessionConfig{})
+	defer receiver.Close()
+
+	frm := benchFrame(b, benchInputs[0].input)
+
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for i := 0; i < b.N; i++ {
+			if _, ok := <-receiver.Inbox(); !ok {
+				return
+			}
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := sender.WriteFrame(frm); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := sender.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	<-received
+}
@@ -0,0 +1,92 @@
+// Package tui renders a status dashboard in the terminal in place of log
+// lines.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+)
+
+// RefreshInterval is how often the dashboard is redrawn.
+const RefreshInterval = 500 * time.Millisecond
+
+// clears the screen and moves the cursor to the top left
+const clearScreen = "\x1b[H\x1b[2J"
+
+// Field is a labelled value shown at the top of the dashboard.
+type Field struct {
+	Label string
+	Value string
+}
+
+type Dashboard struct {
+	Title string
+	// Fields returns the state to show. It's called from the drawing
+	// goroutine.
+	Fields func() []Field
+	// Hint is shown at the bottom, e.g. how to toggle relay.
+	Hint   string
+	Recent *logging.Recent
+}
+
+// Run redraws the dashboard on w every RefreshInterval until ctx is done.
+func (d *Dashboard) Run(ctx context.Context, w io.Writer) {
+	ticker := time.NewTicker(RefreshInterval)
+	defer ticker.Stop()
+	for {
+		io.WriteString(w, clearScreen+d.Render(time.Now()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Render returns the dashboard as of now.
+func (d *Dashboard) Render(now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n\n", d.Title, now.Format("15:04:05"))
+
+	if d.Fields != nil {
+		for _, f := range d.Fields() {
+			fmt.Fprintf(&b, "%-10s %s\n", f.Label, f.Value)
+		}
+	}
+
+	totals := metrics.Totals()
+	names := make([]string, 0, len(totals))
+	for name, total := range totals {
+		if total != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		b.WriteString("\ncounters\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "  %-32s %d\n", name, totals[name])
+		}
+	}
+
+	if d.Recent != nil {
+		if lines := d.Recent.Lines(); len(lines) > 0 {
+			b.WriteString("\nrecent warnings\n")
+			for _, line := range lines {
+				fmt.Fprintf(&b, "  %s\n", line)
+			}
+		}
+	}
+
+	if d.Hint != "" {
+		fmt.Fprintf(&b, "\n%s\n", d.Hint)
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kafji.net/terong/logging"
+)
+
+func TestRender(t *testing.T) {
+	recent := logging.Capture(2)
+	log := logging.NewLogger("test")
+	log.Info("not kept")
+	log.Warn("first")
+	log.Warn("second", "n", 2)
+	log.Error("third")
+
+	d := Dashboard{
+		Title: "terong test",
+		Fields: func() []Field {
+			return []Field{{"relay", "on"}, {"client", "laptop"}}
+		},
+		Hint:   "double tap Right Ctrl to toggle relay",
+		Recent: recent,
+	}
+	now := time.Date(2024, 6, 7, 15, 4, 5, 0, time.Local)
+	out := d.Render(now)
+
+	assert.Contains(t, out, "terong test  15:04:05\n\nrelay      on\nclient     laptop\n")
+	assert.NotContains(t, out, "not kept")
+	assert.NotContains(t, out, "first")
+	assert.Contains(t, out, "WARN test: second n=2\n")
+	assert.Contains(t, out, "ERROR test: third\n")
+	assert.Contains(t, out, "\ndouble tap Right Ctrl to toggle relay\n")
+}
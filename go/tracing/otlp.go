@@ -0,0 +1,157 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+type exporter struct {
+	cfg   Config
+	spans chan *Span
+	// inputs seen by Sample
+	sampled atomic.Uint64
+}
+
+func (e *exporter) run(ctx context.Context) {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	client := &http.Client{Timeout: exportTimeout}
+	batch := make([]*Span, 0, maxBatch)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(ctx, client, batch); err != nil {
+			slog.Warn("failed to export spans", "count", len(batch), "error", err)
+			spans.Add("failed", int64(len(batch)))
+		} else {
+			spans.Add("exported", int64(len(batch)))
+		}
+		clear(batch)
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// the run ctx is gone, give the last batch a moment of its own
+			for len(e.spans) > 0 && len(batch) < maxBatch {
+				batch = append(batch, <-e.spans)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			flush(ctx)
+			cancel()
+			return
+
+		case s := <-e.spans:
+			batch = append(batch, s)
+			if len(batch) >= maxBatch {
+				flush(ctx)
+			}
+
+		case <-ticker.C:
+			flush(ctx)
+		}
+	}
+}
+
+func (e *exporter) export(ctx context.Context, client *http.Client, batch []*Span) error {
+	body, err := encodeSpans(e.cfg.ServiceName, e.cfg.Node, batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// The OTLP/JSON encoding of an export request.
+//
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttr `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              Kind       `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []otlpAttr `json:"attributes,omitempty"`
+	}
+	otlpAttr struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue string `json:"stringValue"`
+	}
+)
+
+func encodeSpans(service string, node string, batch []*Span) ([]byte, error) {
+	out := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		v := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.id[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parent != (SpanID{}) {
+			v.ParentSpanID = hex.EncodeToString(s.parent[:])
+		}
+		for _, a := range s.attrs {
+			v.Attributes = append(v.Attributes, otlpAttr{Key: a[0], Value: otlpValue{StringValue: a[1]}})
+		}
+		out = append(out, v)
+	}
+
+	req := otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpAttr{
+			{Key: "service.name", Value: otlpValue{StringValue: service}},
+			{Key: "host.name", Value: otlpValue{StringValue: node}},
+		}},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "kafji.net/terong"}, Spans: out}},
+	}}}
+	return json.Marshal(req)
+}
@@ -0,0 +1,155 @@
+// Package tracing records spans of sampled inputs and exports them to an
+// OpenTelemetry collector over OTLP/HTTP, so the latency of the event path
+// can be seen across both machines.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync/atomic"
+	"time"
+
+	"kafji.net/terong/crash"
+	"kafji.net/terong/logging"
+	"kafji.net/terong/metrics"
+)
+
+var slog = logging.NewLogger("tracing")
+
+// spans counts spans by what happened to them: exported, dropped because the
+// queue was full, or failed to export.
+var spans = metrics.NewActivityCounterMap("tracing_spans")
+
+const (
+	// DefaultSampleEvery is how many inputs are relayed for every traced one
+	// by default.
+	DefaultSampleEvery = 100
+
+	// spans are exported in batches this often, or sooner when a batch fills
+	exportInterval = 5 * time.Second
+	maxBatch       = 512
+	// spans waiting to be exported, more are dropped
+	queueLength = 4096
+	// how long an export may take
+	exportTimeout = 10 * time.Second
+)
+
+type Config struct {
+	// Endpoint is the OTLP/HTTP traces endpoint of the collector, e.g.
+	// "http://localhost:4318/v1/traces".
+	Endpoint string
+	// ServiceName and Node identify this end in the collector.
+	ServiceName string
+	Node        string
+	// SampleEvery traces one of every this many inputs. Zero means
+	// DefaultSampleEvery.
+	SampleEvery int
+}
+
+// current is the exporter spans are sent to, nil when tracing is disabled.
+var current atomic.Pointer[exporter]
+
+// Start exports recorded spans until ctx is done.
+func Start(ctx context.Context, cfg Config) {
+	if cfg.SampleEvery <= 0 {
+		cfg.SampleEvery = DefaultSampleEvery
+	}
+	e := &exporter{cfg: cfg, spans: make(chan *Span, queueLength)}
+	current.Store(e)
+	slog.Info("exporting traces", "endpoint", cfg.Endpoint, "sample_every", cfg.SampleEvery)
+	go func() {
+		defer crash.Recover()
+		e.run(ctx)
+		current.CompareAndSwap(e, nil)
+	}()
+}
+
+// Enabled reports whether spans are exported.
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Sample reports whether the next input should be traced.
+func Sample() bool {
+	e := current.Load()
+	if e == nil {
+		return false
+	}
+	return e.sampled.Add(1)%uint64(e.cfg.SampleEvery) == 0
+}
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+// Kind is the role of a span in a trace, as numbered by OpenTelemetry.
+type Kind int
+
+const (
+	KindInternal Kind = 1
+	KindProducer Kind = 4
+	KindConsumer Kind = 5
+)
+
+// Span is a timed operation in a trace.
+type Span struct {
+	traceID TraceID
+	id      SpanID
+	parent  SpanID
+	name    string
+	kind    Kind
+	start   time.Time
+	end     time.Time
+	attrs   [][2]string
+}
+
+// StartSpan starts the root span of a new trace.
+func StartSpan(name string, kind Kind) *Span {
+	var traceID TraceID
+	rand.Read(traceID[:])
+	return startSpan(traceID, SpanID{}, name, kind)
+}
+
+// StartRemoteSpan starts a span whose parent is on the other machine.
+func StartRemoteSpan(traceID TraceID, parent SpanID, name string, kind Kind) *Span {
+	return startSpan(traceID, parent, name, kind)
+}
+
+func startSpan(traceID TraceID, parent SpanID, name string, kind Kind) *Span {
+	s := &Span{traceID: traceID, parent: parent, name: name, kind: kind, start: time.Now()}
+	rand.Read(s.id[:])
+	return s
+}
+
+// StartChild starts a span under s.
+func (s *Span) StartChild(name string) *Span {
+	return startSpan(s.traceID, s.id, name, KindInternal)
+}
+
+func (s *Span) TraceID() TraceID {
+	return s.traceID
+}
+
+func (s *Span) ID() SpanID {
+	return s.id
+}
+
+// SetAttr adds an attribute to the span.
+func (s *Span) SetAttr(key string, value string) {
+	s.attrs = append(s.attrs, [2]string{key, value})
+}
+
+// End ends the span and queues it for export. It's dropped if tracing is
+// disabled or the queue is full.
+func (s *Span) End() {
+	s.end = time.Now()
+	e := current.Load()
+	if e == nil {
+		return
+	}
+	select {
+	case e.spans <- s:
+	default:
+		spans.Add("dropped", 1)
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSample(t *testing.T) {
+	assert.False(t, Sample())
+
+	e := &exporter{cfg: Config{SampleEvery: 3}}
+	current.Store(e)
+	defer current.Store(nil)
+
+	var sampled []bool
+	for range 6 {
+		sampled = append(sampled, Sample())
+	}
+	assert.Equal(t, []bool{false, false, true, false, false, true}, sampled)
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		requests <- req
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	Start(ctx, Config{Endpoint: srv.URL, ServiceName: "terong-server", Node: "desktop"})
+
+	root := StartSpan("relay_input", KindProducer)
+	child := root.StartChild("serialize")
+	child.SetAttr("input", "key_press")
+	child.End()
+	root.End()
+	remote := StartRemoteSpan(root.TraceID(), root.ID(), "receive", KindConsumer)
+	remote.End()
+
+	// stopping exports what is left
+	cancel()
+
+	var req otlpRequest
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("spans not exported")
+	}
+	require.Len(t, req.ResourceSpans, 1)
+	rs := req.ResourceSpans[0]
+	assert.Contains(t, rs.Resource.Attributes, otlpAttr{Key: "service.name", Value: otlpValue{StringValue: "terong-server"}})
+	require.Len(t, rs.ScopeSpans, 1)
+	spans := rs.ScopeSpans[0].Spans
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "serialize", spans[0].Name)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, []otlpAttr{{Key: "input", Value: otlpValue{StringValue: "key_press"}}}, spans[0].Attributes)
+
+	assert.Equal(t, "relay_input", spans[1].Name)
+	assert.Empty(t, spans[1].ParentSpanID)
+	assert.Len(t, spans[1].TraceID, 32)
+
+	assert.Equal(t, spans[1].TraceID, spans[2].TraceID)
+	assert.Equal(t, spans[1].SpanID, spans[2].ParentSpanID)
+}
@@ -0,0 +1,48 @@
+// Package wol wakes sleeping machines with Wake-on-LAN magic packets.
+package wol
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// DefaultAddr is where packets are sent by default, the limited broadcast
+// address on the discard port.
+const DefaultAddr = "255.255.255.255:9"
+
+// MagicPacket returns the packet that wakes the machine with mac, six 0xff
+// bytes followed by mac sixteen times.
+func MagicPacket(mac net.HardwareAddr) ([]byte, error) {
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("invalid mac address %v", mac)
+	}
+	packet := bytes.Repeat([]byte{0xff}, 6)
+	for range 16 {
+		packet = append(packet, mac...)
+	}
+	return packet, nil
+}
+
+// Send sends the magic packet for mac to addr, [DefaultAddr] if empty. The
+// packet is fire and forget, there is no telling whether it woke anything.
+func Send(mac net.HardwareAddr, addr string) error {
+	packet, err := MagicPacket(mac)
+	if err != nil {
+		return err
+	}
+	if addr == "" {
+		addr = DefaultAddr
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(packet); err != nil {
+		return fmt.Errorf("failed to write packet: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package wol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMagicPacket(t *testing.T) {
+	mac, err := net.ParseMAC("01:23:45:67:89:ab")
+	require.NoError(t, err)
+
+	packet, err := MagicPacket(mac)
+	require.NoError(t, err)
+	assert.Len(t, packet, 102)
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, packet[:6])
+	for i := 6; i < len(packet); i += 6 {
+		assert.Equal(t, []byte(mac), packet[i:i+6])
+	}
+
+	_, err = MagicPacket(net.HardwareAddr{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestSend(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	mac, err := net.ParseMAC("01:23:45:67:89:ab")
+	require.NoError(t, err)
+	require.NoError(t, Send(mac, conn.LocalAddr().String()))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 256)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	want, err := MagicPacket(mac)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(want, buf[:n]))
+}